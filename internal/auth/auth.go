@@ -0,0 +1,194 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+	"unicode/utf8"
+
+	"github.com/firefart/go-webserver-template/internal/config"
+	"github.com/firefart/go-webserver-template/internal/database"
+)
+
+var (
+	// ErrInvalidCredentials is returned for unknown users, wrong passwords
+	// and locked accounts so the response does not reveal if a user exists
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	// ErrLocked is returned if the IP is blocked after too many failed logins
+	ErrLocked = errors.New("too many failed logins, try again later")
+	// ErrUserNotFound is returned when changing the password of an unknown user
+	ErrUserNotFound = errors.New("user not found")
+)
+
+// Authenticator checks the passwords of the users and locks accounts and IPs
+// after too many failed logins
+type Authenticator struct {
+	logger  *slog.Logger
+	db      database.Interface
+	config  config.Auth
+	limiter *ipLimiter
+	// dummyHash is checked for unknown and locked users so the response time
+	// does not reveal if a user exists
+	dummyHash     string
+	now           func() time.Time
+	checkPassword func(password, encoded string, params config.AuthPassword) (bool, bool, error)
+}
+
+// NewAuthenticator creates the authenticator. The context controls the
+// lifetime of the cleanup routine of the per IP lockout.
+func NewAuthenticator(ctx context.Context, logger *slog.Logger, db database.Interface, configuration config.Auth) (*Authenticator, error) {
+	dummyHash, err := HashPassword("dummy password", configuration.Password)
+	if err != nil {
+		return nil, fmt.Errorf("could not create dummy hash: %w", err)
+	}
+	a := Authenticator{
+		logger:        logger,
+		db:            db,
+		config:        configuration,
+		limiter:       newIPLimiter(configuration.Lockout.MaxIPAttempts, configuration.Lockout.IPWindow),
+		dummyHash:     dummyHash,
+		now:           time.Now,
+		checkPassword: CheckPassword,
+	}
+
+	// start invalidator go function
+	go a.limiter.invalidator(ctx)
+
+	return &a, nil
+}
+
+// Login checks the credentials and returns the principal of the user. Failed
+// logins are counted per account and per IP.
+func (a *Authenticator) Login(ctx context.Context, username, password, ip string) (Principal, error) {
+	now := a.now()
+	if a.limiter.blocked(ip, now) {
+		return Principal{}, ErrLocked
+	}
+
+	user, found, err := a.db.GetUserByUsername(ctx, username)
+	if err != nil {
+		return Principal{}, err
+	}
+	if !found {
+		_, _, _ = a.checkPassword(password, a.dummyHash, a.config.Password)
+		a.limiter.fail(ip, now)
+		return Principal{}, ErrInvalidCredentials
+	}
+
+	// the attempt is counted before the password is checked so parallel
+	// requests can't check more than MaxAttempts passwords
+	attempts, unlocked, err := a.db.RecordLoginAttempt(ctx, user.ID, now)
+	if err != nil {
+		return Principal{}, err
+	}
+	if !unlocked || attempts > a.config.Lockout.MaxAttempts {
+		_, _, _ = a.checkPassword(password, a.dummyHash, a.config.Password)
+		a.limiter.fail(ip, now)
+		a.logger.Warn("login attempt on locked user", slog.String("username", user.Username), slog.String("ip", ip))
+		if unlocked {
+			// the attempts ran out while the last allowed one is checked, lock
+			// the user in case that request fails before locking
+			if err := a.db.LockUser(ctx, user.ID, now.Add(a.config.Lockout.Duration)); err != nil {
+				return Principal{}, err
+			}
+		}
+		return Principal{}, ErrInvalidCredentials
+	}
+
+	match, rehash, err := a.checkPassword(password, user.PasswordHash, a.config.Password)
+	if err != nil {
+		return Principal{}, fmt.Errorf("could not check password of user %d: %w", user.ID, err)
+	}
+	if !match {
+		a.limiter.fail(ip, now)
+		if attempts == a.config.Lockout.MaxAttempts {
+			// the user gets the full number of attempts after the lockout
+			a.logger.Warn("locking user after too many failed logins", slog.String("username", user.Username), slog.String("ip", ip))
+			if err := a.db.LockUser(ctx, user.ID, now.Add(a.config.Lockout.Duration)); err != nil {
+				return Principal{}, err
+			}
+		}
+		return Principal{}, ErrInvalidCredentials
+	}
+
+	if rehash {
+		// the parameters were changed since the password was set
+		hash, err := HashPassword(password, a.config.Password)
+		if err != nil {
+			return Principal{}, err
+		}
+		if err := a.db.SetUserPassword(ctx, user.ID, hash); err != nil {
+			return Principal{}, err
+		}
+	}
+	if err := a.db.RecordLoginSuccess(ctx, user.ID, now); err != nil {
+		return Principal{}, err
+	}
+	return principal(user), nil
+}
+
+// Principal returns the principal of the user with the id
+func (a *Authenticator) Principal(ctx context.Context, id int64) (Principal, bool, error) {
+	user, found, err := a.db.GetUser(ctx, id)
+	if err != nil || !found {
+		return Principal{}, false, err
+	}
+	return principal(user), true, nil
+}
+
+// CreateUser creates a new user with the password and role
+func (a *Authenticator) CreateUser(ctx context.Context, username, password, role string) (int64, error) {
+	if username == "" {
+		return 0, errors.New("username must not be empty")
+	}
+	if err := validateRole(role); err != nil {
+		return 0, err
+	}
+	hash, err := a.hash(password)
+	if err != nil {
+		return 0, err
+	}
+	return a.db.CreateUser(ctx, username, hash, role)
+}
+
+// SetPassword replaces the password of the user and removes a lockout
+func (a *Authenticator) SetPassword(ctx context.Context, username, password string) error {
+	user, found, err := a.db.GetUserByUsername(ctx, username)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrUserNotFound
+	}
+	hash, err := a.hash(password)
+	if err != nil {
+		return err
+	}
+	return a.db.SetUserPassword(ctx, user.ID, hash)
+}
+
+func (a *Authenticator) hash(password string) (string, error) {
+	if utf8.RuneCountInString(password) < a.config.Password.MinLength {
+		return "", fmt.Errorf("password must be at least %d characters long", a.config.Password.MinLength)
+	}
+	return HashPassword(password, a.config.Password)
+}
+
+func validateRole(role string) error {
+	switch role {
+	case RoleAdmin, RoleUser:
+		return nil
+	default:
+		return fmt.Errorf("invalid role %q", role)
+	}
+}
+
+func principal(user database.User) Principal {
+	return Principal{
+		UserID:   user.ID,
+		Username: user.Username,
+		Role:     user.Role,
+	}
+}
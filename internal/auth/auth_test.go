@@ -0,0 +1,196 @@
+package auth
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/firefart/go-webserver-template/internal/config"
+	"github.com/firefart/go-webserver-template/internal/database"
+	"github.com/firefart/go-webserver-template/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestAuthenticator(t *testing.T) (*Authenticator, *database.Database) {
+	t.Helper()
+
+	db := testutil.NewDB(t)
+
+	a, err := NewAuthenticator(t.Context(), slog.New(slog.DiscardHandler), db, config.Auth{
+		Password: testParams,
+		Lockout: config.AuthLockout{
+			MaxAttempts:   3,
+			Duration:      15 * time.Minute,
+			MaxIPAttempts: 10,
+			IPWindow:      15 * time.Minute,
+		},
+	})
+	require.NoError(t, err)
+	return a, db
+}
+
+func TestLogin(t *testing.T) {
+	t.Parallel()
+
+	a, db := newTestAuthenticator(t)
+	id, err := a.CreateUser(t.Context(), "admin", "correct horse", RoleAdmin)
+	require.NoError(t, err)
+
+	p, err := a.Login(t.Context(), "Admin", "correct horse", "192.0.2.1")
+	require.NoError(t, err)
+	require.Equal(t, Principal{UserID: id, Username: "admin", Role: RoleAdmin}, p)
+	user, _, err := db.GetUser(t.Context(), id)
+	require.NoError(t, err)
+	require.False(t, user.LastLogin.IsZero())
+
+	_, err = a.Login(t.Context(), "admin", "wrong horse", "192.0.2.1")
+	require.ErrorIs(t, err, ErrInvalidCredentials)
+	_, err = a.Login(t.Context(), "unknown", "correct horse", "192.0.2.1")
+	require.ErrorIs(t, err, ErrInvalidCredentials)
+
+	p, found, err := a.Principal(t.Context(), id)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "admin", p.Username)
+	_, found, err = a.Principal(t.Context(), id+1)
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+func TestCreateUser(t *testing.T) {
+	t.Parallel()
+
+	a, _ := newTestAuthenticator(t)
+	_, err := a.CreateUser(t.Context(), "user", "short", RoleUser)
+	require.ErrorContains(t, err, "at least 8 characters")
+	_, err = a.CreateUser(t.Context(), "user", "correct horse", "root")
+	require.ErrorContains(t, err, "invalid role")
+	_, err = a.CreateUser(t.Context(), "", "correct horse", RoleUser)
+	require.Error(t, err)
+	_, err = a.CreateUser(t.Context(), "user", "correct horse", RoleUser)
+	require.NoError(t, err)
+	_, err = a.CreateUser(t.Context(), "user", "correct horse", RoleUser)
+	require.Error(t, err)
+
+	require.ErrorIs(t, a.SetPassword(t.Context(), "unknown", "correct horse"), ErrUserNotFound)
+	require.NoError(t, a.SetPassword(t.Context(), "user", "battery staple"))
+	_, err = a.Login(t.Context(), "user", "battery staple", "192.0.2.1")
+	require.NoError(t, err)
+}
+
+func TestAccountLockout(t *testing.T) {
+	t.Parallel()
+
+	a, _ := newTestAuthenticator(t)
+	now := time.Now()
+	a.now = func() time.Time { return now }
+	_, err := a.CreateUser(t.Context(), "user", "correct horse", RoleUser)
+	require.NoError(t, err)
+
+	// the attempts are counted per account, regardless of the IP
+	for i := range 3 {
+		_, err := a.Login(t.Context(), "user", "wrong horse", fmt.Sprintf("192.0.2.%d", i+1))
+		require.ErrorIs(t, err, ErrInvalidCredentials)
+	}
+	// locked users get the same error as unknown users
+	_, err = a.Login(t.Context(), "user", "correct horse", "192.0.2.10")
+	require.ErrorIs(t, err, ErrInvalidCredentials)
+
+	now = now.Add(16 * time.Minute)
+	_, err = a.Login(t.Context(), "user", "correct horse", "192.0.2.10")
+	require.NoError(t, err)
+
+	// a password reset unlocks the account
+	for range 3 {
+		_, err := a.Login(t.Context(), "user", "wrong horse", "192.0.2.20")
+		require.ErrorIs(t, err, ErrInvalidCredentials)
+	}
+	require.NoError(t, a.SetPassword(t.Context(), "user", "battery staple"))
+	_, err = a.Login(t.Context(), "user", "battery staple", "192.0.2.20")
+	require.NoError(t, err)
+}
+
+func TestAccountLockoutConcurrent(t *testing.T) {
+	t.Parallel()
+
+	a, db := newTestAuthenticator(t)
+	id, err := a.CreateUser(t.Context(), "user", "correct horse", RoleUser)
+	require.NoError(t, err)
+
+	// count the checks against the real password hash
+	var checks atomic.Int64
+	a.checkPassword = func(password, encoded string, params config.AuthPassword) (bool, bool, error) {
+		if encoded != a.dummyHash {
+			checks.Add(1)
+		}
+		return CheckPassword(password, encoded, params)
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 20)
+	for i := range errs {
+		wg.Go(func() {
+			_, errs[i] = a.Login(t.Context(), "user", "wrong horse", fmt.Sprintf("192.0.2.%d", i+1))
+		})
+	}
+	wg.Wait()
+	for _, err := range errs {
+		require.ErrorIs(t, err, ErrInvalidCredentials)
+	}
+
+	require.Equal(t, a.config.Lockout.MaxAttempts, checks.Load())
+	user, _, err := db.GetUser(t.Context(), id)
+	require.NoError(t, err)
+	require.True(t, user.LockedUntil.After(time.Now()))
+	_, err = a.Login(t.Context(), "user", "correct horse", "192.0.2.100")
+	require.ErrorIs(t, err, ErrInvalidCredentials)
+}
+
+func TestIPLockout(t *testing.T) {
+	t.Parallel()
+
+	a, _ := newTestAuthenticator(t)
+	now := time.Now()
+	a.now = func() time.Time { return now }
+	_, err := a.CreateUser(t.Context(), "user", "correct horse", RoleUser)
+	require.NoError(t, err)
+
+	// unknown users count for the IP
+	for range 10 {
+		_, err := a.Login(t.Context(), "unknown", "password", "192.0.2.1")
+		require.ErrorIs(t, err, ErrInvalidCredentials)
+	}
+	_, err = a.Login(t.Context(), "user", "correct horse", "192.0.2.1")
+	require.ErrorIs(t, err, ErrLocked)
+	_, err = a.Login(t.Context(), "user", "correct horse", "192.0.2.2")
+	require.NoError(t, err)
+
+	now = now.Add(15 * time.Minute)
+	_, err = a.Login(t.Context(), "user", "correct horse", "192.0.2.1")
+	require.NoError(t, err)
+
+	a.limiter.deleteExpired(now.Add(15 * time.Minute))
+	require.Empty(t, a.limiter.ips)
+}
+
+func TestRehash(t *testing.T) {
+	t.Parallel()
+
+	a, db := newTestAuthenticator(t)
+	id, err := a.CreateUser(t.Context(), "user", "correct horse", RoleUser)
+	require.NoError(t, err)
+	user, _, err := db.GetUser(t.Context(), id)
+	require.NoError(t, err)
+	oldHash := user.PasswordHash
+
+	a.config.Password.Iterations = 2
+	_, err = a.Login(t.Context(), "user", "correct horse", "192.0.2.1")
+	require.NoError(t, err)
+	user, _, err = db.GetUser(t.Context(), id)
+	require.NoError(t, err)
+	require.NotEqual(t, oldHash, user.PasswordHash)
+	require.Contains(t, user.PasswordHash, "t=2")
+}
@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type ipEntry struct {
+	failures int
+	start    time.Time
+}
+
+// ipLimiter counts the failed logins per IP within a fixed window
+type ipLimiter struct {
+	mu          sync.Mutex
+	maxAttempts int
+	window      time.Duration
+	ips         map[string]*ipEntry
+}
+
+func newIPLimiter(maxAttempts int, window time.Duration) *ipLimiter {
+	return &ipLimiter{
+		maxAttempts: maxAttempts,
+		window:      window,
+		ips:         make(map[string]*ipEntry),
+	}
+}
+
+func (l *ipLimiter) blocked(ip string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e, ok := l.ips[ip]
+	return ok && now.Sub(e.start) < l.window && e.failures >= l.maxAttempts
+}
+
+func (l *ipLimiter) fail(ip string, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e, ok := l.ips[ip]
+	if !ok || now.Sub(e.start) >= l.window {
+		e = &ipEntry{start: now}
+		l.ips[ip] = e
+	}
+	e.failures++
+}
+
+func (l *ipLimiter) invalidator(ctx context.Context) {
+	ticker := time.NewTicker(1 * time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			l.deleteExpired(time.Now())
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (l *ipLimiter) deleteExpired(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for ip, e := range l.ips {
+		if now.Sub(e.start) >= l.window {
+			delete(l.ips, ip)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/firefart/go-webserver-template/internal/config"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	saltLength = 16
+	keyLength  = 32
+)
+
+var errInvalidHash = errors.New("invalid password hash")
+
+// HashPassword hashes the password with argon2id and returns it in the PHC
+// string format so the parameters are stored next to the hash:
+// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>
+func HashPassword(password string, params config.AuthPassword) (string, error) {
+	salt := make([]byte, saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, keyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, params.Memory, params.Iterations, params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// CheckPassword compares the password to the encoded hash. rehash is true if
+// the hash was created with other parameters than the current ones and should
+// be replaced after a successful login.
+func CheckPassword(password, encoded string, params config.AuthPassword) (match bool, rehash bool, err error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != "argon2id" {
+		return false, false, errInvalidHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, false, errInvalidHash
+	}
+	var hashParams config.AuthPassword
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &hashParams.Memory, &hashParams.Iterations, &hashParams.Parallelism); err != nil {
+		return false, false, errInvalidHash
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, false, errInvalidHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return false, false, errInvalidHash
+	}
+
+	// nolint: gosec
+	computed := argon2.IDKey([]byte(password), salt, hashParams.Iterations, hashParams.Memory, hashParams.Parallelism, uint32(len(key)))
+	if subtle.ConstantTimeCompare(key, computed) != 1 {
+		return false, false, nil
+	}
+	rehash = hashParams.Memory != params.Memory ||
+		hashParams.Iterations != params.Iterations ||
+		hashParams.Parallelism != params.Parallelism ||
+		len(salt) != saltLength || len(key) != keyLength
+	return true, rehash, nil
+}
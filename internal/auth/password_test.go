@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/firefart/go-webserver-template/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+var testParams = config.AuthPassword{
+	Memory:      8 * 1024,
+	Iterations:  1,
+	Parallelism: 1,
+	MinLength:   8,
+}
+
+func TestPassword(t *testing.T) {
+	t.Parallel()
+
+	hash, err := HashPassword("correct horse", testParams)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=8192,t=1,p=1$"))
+
+	// the random salt makes every hash unique
+	other, err := HashPassword("correct horse", testParams)
+	require.NoError(t, err)
+	require.NotEqual(t, hash, other)
+
+	match, rehash, err := CheckPassword("correct horse", hash, testParams)
+	require.NoError(t, err)
+	require.True(t, match)
+	require.False(t, rehash)
+
+	match, _, err = CheckPassword("wrong horse", hash, testParams)
+	require.NoError(t, err)
+	require.False(t, match)
+
+	// raised parameters still accept the old hash but request a new one
+	upgraded := testParams
+	upgraded.Iterations = 2
+	match, rehash, err = CheckPassword("correct horse", hash, upgraded)
+	require.NoError(t, err)
+	require.True(t, match)
+	require.True(t, rehash)
+}
+
+func TestCheckPasswordInvalidHash(t *testing.T) {
+	t.Parallel()
+
+	for _, hash := range []string{
+		"",
+		"plain",
+		"$argon2i$v=19$m=8192,t=1,p=1$c2FsdA$aGFzaA",
+		"$argon2id$v=18$m=8192,t=1,p=1$c2FsdA$aGFzaA",
+		"$argon2id$v=19$m=x,t=1,p=1$c2FsdA$aGFzaA",
+		"$argon2id$v=19$m=8192,t=1,p=1$!!!$aGFzaA",
+		"$argon2id$v=19$m=8192,t=1,p=1$c2FsdA$",
+	} {
+		_, _, err := CheckPassword("password", hash, testParams)
+		require.ErrorIs(t, err, errInvalidHash, hash)
+	}
+}
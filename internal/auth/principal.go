@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"context"
+	"slices"
+)
+
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+)
+
+// SessionKeyUserID is the session key holding the id of the logged in user
+const SessionKeyUserID = "user_id"
+
+// SessionKeyPrincipal is the session key holding the principal of users
+// logged in by an external identity provider. They have no local account.
+const SessionKeyPrincipal = "principal"
+
+type contextKey struct{}
+
+// Principal is the authenticated user or API token of a request. Tokens have
+// no role and use the name of the token as username.
+type Principal struct {
+	UserID   int64
+	TokenID  int64
+	Username string
+	Role     string
+	Scopes   []string
+}
+
+// HasRole returns true if the principal has one of the roles
+func (p Principal) HasRole(roles ...string) bool {
+	return p.Role != "" && slices.Contains(roles, p.Role)
+}
+
+// HasScopes returns true if the principal has all the scopes
+func (p Principal) HasScopes(scopes ...string) bool {
+	for _, scope := range scopes {
+		if !slices.Contains(p.Scopes, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// WithPrincipal returns a copy of the context holding the principal
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, p)
+}
+
+// PrincipalFromContext returns the principal set by the authentication
+// middleware. It returns false for anonymous requests.
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(contextKey{}).(Principal)
+	return p, ok
+}
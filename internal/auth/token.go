@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/firefart/go-webserver-template/internal/database"
+)
+
+const (
+	// TokenPrefix is prepended to all API tokens so leaked tokens are easy to
+	// find by secret scanners
+	TokenPrefix = "gwt_"
+	// tokenPrefixLength is the number of characters of the token stored in
+	// clear text to identify it in listings
+	tokenPrefixLength = len(TokenPrefix) + 8
+	// tokenTouchInterval limits the writes of the last used time
+	tokenTouchInterval = 1 * time.Minute
+)
+
+const (
+	ScopeDummyRead  = "dummy:read"
+	ScopeDummyWrite = "dummy:write"
+)
+
+// Scopes lists all scopes an API token can be created with
+var Scopes = []string{ScopeDummyRead, ScopeDummyWrite}
+
+var (
+	// ErrInvalidToken is returned for unknown and expired tokens
+	ErrInvalidToken = errors.New("invalid api token")
+	// ErrTokenNotFound is returned when revoking an unknown token
+	ErrTokenNotFound = errors.New("api token not found")
+	// ErrInvalidScope is returned when creating a token with an unknown scope
+	ErrInvalidScope = errors.New("invalid scope")
+)
+
+// Tokens manages the API tokens of machine clients. Only a hash of the
+// tokens is stored, the token itself is returned once on creation.
+type Tokens struct {
+	logger *slog.Logger
+	db     database.Interface
+	now    func() time.Time
+}
+
+func NewTokens(logger *slog.Logger, db database.Interface) *Tokens {
+	return &Tokens{
+		logger: logger,
+		db:     db,
+		now:    time.Now,
+	}
+}
+
+// Create creates a new token with the scopes. A ttl of 0 creates a token that
+// never expires. The returned token can't be retrieved again.
+func (t *Tokens) Create(ctx context.Context, name string, scopes []string, ttl time.Duration) (string, database.APIToken, error) {
+	if name == "" {
+		return "", database.APIToken{}, errors.New("token name must not be empty")
+	}
+	if ttl < 0 {
+		return "", database.APIToken{}, errors.New("token ttl must not be negative")
+	}
+	scopes = slices.Compact(slices.Sorted(slices.Values(scopes)))
+	for _, scope := range scopes {
+		if !slices.Contains(Scopes, scope) {
+			return "", database.APIToken{}, fmt.Errorf("%w %q, valid scopes are %s", ErrInvalidScope, scope, strings.Join(Scopes, ", "))
+		}
+	}
+
+	token := TokenPrefix + rand.Text()
+	info := database.APIToken{
+		Name:    name,
+		Prefix:  token[:tokenPrefixLength],
+		Hash:    hashToken(token),
+		Scopes:  scopes,
+		Created: t.now(),
+	}
+	if ttl > 0 {
+		info.Expires = info.Created.Add(ttl)
+	}
+	id, err := t.db.CreateAPIToken(ctx, info.Name, info.Prefix, info.Hash, info.Scopes, info.Created, info.Expires)
+	if err != nil {
+		return "", database.APIToken{}, err
+	}
+	info.ID = id
+	return token, info, nil
+}
+
+func (t *Tokens) List(ctx context.Context) ([]database.APIToken, error) {
+	return t.db.ListAPITokens(ctx)
+}
+
+func (t *Tokens) Revoke(ctx context.Context, id int64) error {
+	deleted, err := t.db.DeleteAPIToken(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrTokenNotFound
+	}
+	return nil
+}
+
+// Authenticate returns the principal of the token
+func (t *Tokens) Authenticate(ctx context.Context, token string) (Principal, error) {
+	if !strings.HasPrefix(token, TokenPrefix) {
+		return Principal{}, ErrInvalidToken
+	}
+	info, found, err := t.db.GetAPITokenByHash(ctx, hashToken(token))
+	if err != nil {
+		return Principal{}, err
+	}
+	now := t.now()
+	if !found || (!info.Expires.IsZero() && !info.Expires.After(now)) {
+		return Principal{}, ErrInvalidToken
+	}
+	if now.Sub(info.LastUsed) >= tokenTouchInterval {
+		if err := t.db.TouchAPIToken(ctx, info.ID, now); err != nil {
+			t.logger.Error("could not update last use of api token", slog.Int64("id", info.ID), slog.String("err", err.Error()))
+		}
+	}
+	return Principal{
+		TokenID:  info.ID,
+		Username: info.Name,
+		Scopes:   info.Scopes,
+	}, nil
+}
+
+// hashToken hashes the token for the lookup. The tokens are random so a fast
+// hash is enough, unlike for passwords.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
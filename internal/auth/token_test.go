@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokens(t *testing.T) {
+	t.Parallel()
+
+	_, db := newTestAuthenticator(t)
+	tokens := NewTokens(slog.New(slog.DiscardHandler), db)
+	now := time.Now()
+	tokens.now = func() time.Time { return now }
+
+	token, info, err := tokens.Create(t.Context(), "ci", []string{ScopeDummyWrite, ScopeDummyRead, ScopeDummyRead}, 1*time.Hour)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(token, TokenPrefix))
+	require.True(t, strings.HasPrefix(token, info.Prefix))
+	require.Equal(t, []string{ScopeDummyRead, ScopeDummyWrite}, info.Scopes)
+
+	// only the hash is stored
+	list, err := tokens.List(t.Context())
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	require.NotContains(t, list[0].Hash, token[len(TokenPrefix):])
+	require.True(t, list[0].LastUsed.IsZero())
+
+	p, err := tokens.Authenticate(t.Context(), token)
+	require.NoError(t, err)
+	require.Equal(t, Principal{TokenID: info.ID, Username: "ci", Scopes: []string{ScopeDummyRead, ScopeDummyWrite}}, p)
+	require.True(t, p.HasScopes(ScopeDummyRead, ScopeDummyWrite))
+	require.False(t, p.HasRole(RoleAdmin))
+	list, err = tokens.List(t.Context())
+	require.NoError(t, err)
+	require.False(t, list[0].LastUsed.IsZero())
+
+	_, err = tokens.Authenticate(t.Context(), token+"x")
+	require.ErrorIs(t, err, ErrInvalidToken)
+	_, err = tokens.Authenticate(t.Context(), token[len(TokenPrefix):])
+	require.ErrorIs(t, err, ErrInvalidToken)
+
+	now = now.Add(1 * time.Hour)
+	_, err = tokens.Authenticate(t.Context(), token)
+	require.ErrorIs(t, err, ErrInvalidToken)
+
+	require.NoError(t, tokens.Revoke(t.Context(), info.ID))
+	require.ErrorIs(t, tokens.Revoke(t.Context(), info.ID), ErrTokenNotFound)
+}
+
+func TestTokensCreate(t *testing.T) {
+	t.Parallel()
+
+	_, db := newTestAuthenticator(t)
+	tokens := NewTokens(slog.New(slog.DiscardHandler), db)
+
+	_, _, err := tokens.Create(t.Context(), "ci", []string{"root"}, 0)
+	require.ErrorIs(t, err, ErrInvalidScope)
+	_, _, err = tokens.Create(t.Context(), "", nil, 0)
+	require.Error(t, err)
+	_, _, err = tokens.Create(t.Context(), "ci", nil, -1*time.Hour)
+	require.Error(t, err)
+
+	// without ttl the token never expires
+	token, info, err := tokens.Create(t.Context(), "ci", nil, 0)
+	require.NoError(t, err)
+	require.True(t, info.Expires.IsZero())
+	tokens.now = func() time.Time { return time.Now().Add(100 * 365 * 24 * time.Hour) }
+	p, err := tokens.Authenticate(t.Context(), token)
+	require.NoError(t, err)
+	require.Empty(t, p.Scopes)
+}
@@ -2,83 +2,99 @@
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
-	"sync"
 	"time"
 
 	"github.com/firefart/go-webserver-template/internal/metrics"
 )
 
-type cacheEntry[T any] struct {
-	value     T
-	timestamp time.Time
-}
-
 type Cache[T any] struct {
 	logger     *slog.Logger
 	metrics    *metrics.Metrics
-	mu         sync.RWMutex
-	cache      map[string]cacheEntry[T]
+	store      Store
+	codec      Codec[T]
 	name       string
 	expiration time.Duration
 }
 
-func New[T any](ctx context.Context, logger *slog.Logger, metrics *metrics.Metrics, name string, expiration time.Duration) *Cache[T] {
+type OptionsCacheFunc[T any] func(c *Cache[T]) error
+
+// WithCodec sets the codec used to (de)serialize the values. Defaults to JSON.
+func WithCodec[T any](codec Codec[T]) OptionsCacheFunc[T] {
+	return func(c *Cache[T]) error { c.codec = codec; return nil }
+}
+
+// New creates a new named cache on top of the given store. The name is used
+// as a key prefix so multiple caches can share the same store.
+func New[T any](logger *slog.Logger, metrics *metrics.Metrics, store Store, name string, expiration time.Duration, opts ...OptionsCacheFunc[T]) (*Cache[T], error) {
 	c := Cache[T]{
-		cache:      make(map[string]cacheEntry[T]),
 		logger:     logger,
+		metrics:    metrics,
+		store:      store,
+		codec:      JSONCodec[T]{},
 		name:       name,
 		expiration: expiration,
-		metrics:    metrics,
 	}
 
-	// start invalidator go function
-	go c.invalidator(ctx)
+	for _, o := range opts {
+		if err := o(&c); err != nil {
+			return nil, err
+		}
+	}
 
-	return &c
+	return &c, nil
 }
 
-func (c *Cache[T]) invalidator(ctx context.Context) {
-	ticker := time.NewTicker(1 * time.Minute)
-	defer ticker.Stop()
-	for {
-		select {
-		case <-ticker.C:
-			c.mu.Lock()
-			expiration := time.Now().Add(c.expiration * -1)
-			for k, v := range c.cache {
-				if v.timestamp.Before(expiration) {
-					c.logger.Debug("deleting expired cache entry", slog.String("name", c.name), slog.String("key", k), slog.Time("timestamp", v.timestamp), slog.Time("expiration", expiration))
-					delete(c.cache, k)
-				}
-			}
-			c.mu.Unlock()
-		case <-ctx.Done():
-			return
-		}
+func (c *Cache[T]) storeKey(key string) string {
+	return fmt.Sprintf("%s:%s", c.name, key)
+}
+
+// Get returns the cached value. Store and decoding errors are returned to the
+// caller who can decide to treat them as a cache miss.
+func (c *Cache[T]) Get(ctx context.Context, key string) (T, bool, error) {
+	var result T
+	data, ok, err := c.store.Get(ctx, c.storeKey(key))
+	if err != nil {
+		return result, false, fmt.Errorf("could not get cache entry %s from cache %s: %w", key, c.name, err)
+	}
+	if !ok {
+		c.metrics.CacheMisses.WithLabelValues(c.name).Inc()
+		return result, false, nil
+	}
+
+	result, err = c.codec.Unmarshal(data)
+	if err != nil {
+		return result, false, fmt.Errorf("could not decode cache entry %s from cache %s: %w", key, c.name, err)
 	}
+	c.logger.Debug("returning cached entry", slog.String("name", c.name), slog.String("key", key))
+	c.metrics.CacheHits.WithLabelValues(c.name).Inc()
+	return result, true, nil
+}
+
+// Set stores the value with the default expiration of the cache
+func (c *Cache[T]) Set(ctx context.Context, key string, value T) error {
+	return c.SetWithTTL(ctx, key, value, c.expiration)
 }
 
-func (c *Cache[T]) Get(key string) (T, bool) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	if x, ok := c.cache[key]; ok {
-		c.logger.Debug("returning cached entry", slog.String("name", c.name), slog.String("key", key), slog.Time("timestamp", x.timestamp))
-		c.metrics.CacheHits.WithLabelValues(c.name).Inc()
-		return x.value, true
+// SetWithTTL stores the value with a custom expiration
+func (c *Cache[T]) SetWithTTL(ctx context.Context, key string, value T, ttl time.Duration) error {
+	data, err := c.codec.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("could not encode cache entry %s for cache %s: %w", key, c.name, err)
 	}
-	var result T
-	c.metrics.CacheMisses.WithLabelValues(c.name).Inc()
-	return result, false
+	c.logger.Debug("setting cache entry", slog.String("name", c.name), slog.String("key", key), slog.Duration("ttl", ttl))
+	if err := c.store.Set(ctx, c.storeKey(key), data, ttl); err != nil {
+		return fmt.Errorf("could not set cache entry %s in cache %s: %w", key, c.name, err)
+	}
+	return nil
 }
 
-func (c *Cache[T]) Set(key string, value T) {
-	c.mu.Lock()
-	timestamp := time.Now()
-	c.logger.Debug("setting cache entry", slog.String("name", c.name), slog.String("key", key), slog.Time("timestamp", timestamp))
-	c.cache[key] = cacheEntry[T]{
-		value:     value,
-		timestamp: timestamp,
+// Delete removes the value from the cache
+func (c *Cache[T]) Delete(ctx context.Context, key string) error {
+	c.logger.Debug("deleting cache entry", slog.String("name", c.name), slog.String("key", key))
+	if err := c.store.Delete(ctx, c.storeKey(key)); err != nil {
+		return fmt.Errorf("could not delete cache entry %s from cache %s: %w", key, c.name, err)
 	}
-	c.mu.Unlock()
+	return nil
 }
@@ -0,0 +1,118 @@
+package cacher
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/firefart/go-webserver-template/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+type testStruct struct {
+	Name  string
+	Count int
+	Tags  []string
+}
+
+func newTestMetrics(t *testing.T) *metrics.Metrics {
+	t.Helper()
+	m, err := metrics.NewMetrics(prometheus.NewRegistry())
+	require.NoError(t, err)
+	return m
+}
+
+func TestCache(t *testing.T) {
+	t.Parallel()
+
+	store := NewMemoryStore(t.Context(), slog.New(slog.DiscardHandler))
+	c, err := New[string](slog.New(slog.DiscardHandler), newTestMetrics(t), store, "test", 1*time.Hour)
+	require.NoError(t, err)
+
+	_, found, err := c.Get(t.Context(), "key")
+	require.NoError(t, err)
+	require.False(t, found)
+
+	require.NoError(t, c.Set(t.Context(), "key", "value"))
+	value, found, err := c.Get(t.Context(), "key")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "value", value)
+
+	// keys are namespaced by the cache name
+	_, found, err = store.Get(t.Context(), "key")
+	require.NoError(t, err)
+	require.False(t, found)
+	_, found, err = store.Get(t.Context(), "test:key")
+	require.NoError(t, err)
+	require.True(t, found)
+
+	require.NoError(t, c.Delete(t.Context(), "key"))
+	_, found, err = c.Get(t.Context(), "key")
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+func TestCacheExpiration(t *testing.T) {
+	t.Parallel()
+
+	store := NewMemoryStore(t.Context(), slog.New(slog.DiscardHandler))
+	c, err := New[string](slog.New(slog.DiscardHandler), newTestMetrics(t), store, "test", 1*time.Hour)
+	require.NoError(t, err)
+
+	require.NoError(t, c.SetWithTTL(t.Context(), "key", "value", 10*time.Millisecond))
+	time.Sleep(20 * time.Millisecond)
+	_, found, err := c.Get(t.Context(), "key")
+	require.NoError(t, err)
+	require.False(t, found)
+
+	store.deleteExpired()
+	require.Empty(t, store.cache)
+}
+
+func TestCacheCodecs(t *testing.T) {
+	t.Parallel()
+
+	expected := testStruct{
+		Name:  "name",
+		Count: 3,
+		Tags:  []string{"a", "b"},
+	}
+
+	tests := []struct {
+		name  string
+		codec Codec[testStruct]
+	}{
+		{name: "json", codec: JSONCodec[testStruct]{}},
+		{name: "gob", codec: GobCodec[testStruct]{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			store := NewMemoryStore(t.Context(), slog.New(slog.DiscardHandler))
+			c, err := New(slog.New(slog.DiscardHandler), newTestMetrics(t), store, "test", 1*time.Hour, WithCodec(tt.codec))
+			require.NoError(t, err)
+
+			require.NoError(t, c.Set(t.Context(), "key", expected))
+			value, found, err := c.Get(t.Context(), "key")
+			require.NoError(t, err)
+			require.True(t, found)
+			require.Equal(t, expected, value)
+		})
+	}
+}
+
+func TestCacheDecodeError(t *testing.T) {
+	t.Parallel()
+
+	store := NewMemoryStore(t.Context(), slog.New(slog.DiscardHandler))
+	require.NoError(t, store.Set(t.Context(), "test:key", []byte("invalid json"), 1*time.Hour))
+
+	c, err := New[testStruct](slog.New(slog.DiscardHandler), newTestMetrics(t), store, "test", 1*time.Hour)
+	require.NoError(t, err)
+	_, found, err := c.Get(t.Context(), "key")
+	require.Error(t, err)
+	require.False(t, found)
+}
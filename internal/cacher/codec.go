@@ -0,0 +1,64 @@
+package cacher
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+)
+
+// Codec converts cache values to and from their stored representation
+type Codec[T any] interface {
+	Marshal(value T) ([]byte, error)
+	Unmarshal(data []byte) (T, error)
+}
+
+// JSONCodec encodes values as JSON. This is the default codec.
+type JSONCodec[T any] struct{}
+
+// compile time check that struct implements the interface
+var _ Codec[any] = JSONCodec[any]{}
+
+func (JSONCodec[T]) Marshal(value T) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+func (JSONCodec[T]) Unmarshal(data []byte) (T, error) {
+	var value T
+	err := json.Unmarshal(data, &value)
+	return value, err
+}
+
+// GobCodec encodes values using encoding/gob which also supports
+// unexported types registered via gob.Register
+type GobCodec[T any] struct{}
+
+// compile time check that struct implements the interface
+var _ Codec[any] = GobCodec[any]{}
+
+func (GobCodec[T]) Marshal(value T) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (GobCodec[T]) Unmarshal(data []byte) (T, error) {
+	var value T
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&value)
+	return value, err
+}
+
+// BytesCodec stores raw byte slices without any encoding overhead
+type BytesCodec struct{}
+
+// compile time check that struct implements the interface
+var _ Codec[[]byte] = BytesCodec{}
+
+func (BytesCodec) Marshal(value []byte) ([]byte, error) {
+	return value, nil
+}
+
+func (BytesCodec) Unmarshal(data []byte) ([]byte, error) {
+	return data, nil
+}
@@ -0,0 +1,87 @@
+package cacher
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+type memoryEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+// MemoryStore keeps all entries in process memory. Entries are lost on restart.
+type MemoryStore struct {
+	logger *slog.Logger
+	mu     sync.RWMutex
+	cache  map[string]memoryEntry
+}
+
+// compile time check that struct implements the interface
+var _ Store = (*MemoryStore)(nil)
+
+func NewMemoryStore(ctx context.Context, logger *slog.Logger) *MemoryStore {
+	s := MemoryStore{
+		logger: logger,
+		cache:  make(map[string]memoryEntry),
+	}
+
+	// start invalidator go function
+	go s.invalidator(ctx)
+
+	return &s
+}
+
+func (s *MemoryStore) invalidator(ctx context.Context) {
+	ticker := time.NewTicker(1 * time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.deleteExpired()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *MemoryStore) deleteExpired() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for k, v := range s.cache {
+		if v.expires.Before(now) {
+			s.logger.Debug("deleting expired cache entry", slog.String("key", k), slog.Time("expires", v.expires))
+			delete(s.cache, k)
+		}
+	}
+}
+
+func (s *MemoryStore) Get(_ context.Context, key string) ([]byte, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	x, ok := s.cache[key]
+	if !ok || x.expires.Before(time.Now()) {
+		return nil, false, nil
+	}
+	return x.value, true, nil
+}
+
+func (s *MemoryStore) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cache[key] = memoryEntry{
+		value:   value,
+		expires: time.Now().Add(ttl),
+	}
+	return nil
+}
+
+func (s *MemoryStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.cache, key)
+	return nil
+}
@@ -0,0 +1,283 @@
+package cacher
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/firefart/go-webserver-template/internal/config"
+)
+
+// RedisStore talks the Redis serialization protocol (RESP) and works with
+// Redis and compatible servers like Valkey, KeyDB or Dragonfly. Connections
+// are pooled and reused.
+type RedisStore struct {
+	address  string
+	username string
+	password string
+	db       int
+	prefix   string
+	timeout  time.Duration
+
+	mu     sync.Mutex
+	pool   chan *redisConn
+	closed bool
+}
+
+// compile time check that struct implements the interface
+var _ Store = (*RedisStore)(nil)
+
+// redisError is an error reply sent by the server
+type redisError string
+
+func (e redisError) Error() string {
+	return string(e)
+}
+
+type redisConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+}
+
+func NewRedisStore(c config.CacheRedis) (*RedisStore, error) {
+	if c.Address == "" {
+		return nil, errors.New("redis store requires an address")
+	}
+	poolSize := c.PoolSize
+	if poolSize <= 0 {
+		poolSize = 10
+	}
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	return &RedisStore{
+		address:  c.Address,
+		username: c.Username,
+		password: c.Password,
+		db:       c.DB,
+		prefix:   c.Prefix,
+		timeout:  timeout,
+		pool:     make(chan *redisConn, poolSize),
+	}, nil
+}
+
+func (s *RedisStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	reply, err := s.do(ctx, "GET", []byte(s.prefix+key))
+	if err != nil {
+		return nil, false, err
+	}
+	if reply == nil {
+		return nil, false, nil
+	}
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, false, fmt.Errorf("unexpected reply type %T for GET", reply)
+	}
+	return value, true, nil
+}
+
+func (s *RedisStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	ms := max(ttl.Milliseconds(), 1)
+	_, err := s.do(ctx, "SET", []byte(s.prefix+key), value, []byte("PX"), []byte(strconv.FormatInt(ms, 10)))
+	return err
+}
+
+func (s *RedisStore) Delete(ctx context.Context, key string) error {
+	_, err := s.do(ctx, "DEL", []byte(s.prefix+key))
+	return err
+}
+
+// Ping checks the connection to the server
+func (s *RedisStore) Ping(ctx context.Context) error {
+	_, err := s.do(ctx, "PING")
+	return err
+}
+
+// Close closes all idle connections. Connections in use are closed when they
+// are returned.
+func (s *RedisStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	close(s.pool)
+	var errs error
+	for c := range s.pool {
+		errs = errors.Join(errs, c.conn.Close())
+	}
+	return errs
+}
+
+func (s *RedisStore) do(ctx context.Context, cmd string, args ...[]byte) (any, error) {
+	c, err := s.getConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := c.do(ctx, s.timeout, cmd, args...)
+	if err != nil {
+		var rErr redisError
+		if errors.As(err, &rErr) {
+			// error replies leave the connection in a usable state
+			s.putConn(c)
+		} else {
+			_ = c.conn.Close()
+		}
+		return nil, err
+	}
+	s.putConn(c)
+	return reply, nil
+}
+
+func (s *RedisStore) getConn(ctx context.Context) (*redisConn, error) {
+	select {
+	case c, ok := <-s.pool:
+		if ok {
+			return c, nil
+		}
+		return nil, errors.New("redis store is closed")
+	default:
+	}
+
+	d := net.Dialer{Timeout: s.timeout}
+	conn, err := d.DialContext(ctx, "tcp", s.address)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to redis at %s: %w", s.address, err)
+	}
+	c := &redisConn{
+		conn: conn,
+		r:    bufio.NewReader(conn),
+		w:    bufio.NewWriter(conn),
+	}
+
+	if s.password != "" {
+		args := [][]byte{[]byte(s.password)}
+		if s.username != "" {
+			args = [][]byte{[]byte(s.username), []byte(s.password)}
+		}
+		if _, err := c.do(ctx, s.timeout, "AUTH", args...); err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("could not authenticate to redis: %w", err)
+		}
+	}
+
+	if s.db > 0 {
+		if _, err := c.do(ctx, s.timeout, "SELECT", []byte(strconv.Itoa(s.db))); err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("could not select redis db %d: %w", s.db, err)
+		}
+	}
+
+	return c, nil
+}
+
+func (s *RedisStore) putConn(c *redisConn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		_ = c.conn.Close()
+		return
+	}
+	select {
+	case s.pool <- c:
+	default:
+		// pool is full
+		_ = c.conn.Close()
+	}
+}
+
+func (c *redisConn) do(ctx context.Context, timeout time.Duration, cmd string, args ...[]byte) (any, error) {
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	// commands are sent as an array of bulk strings
+	fmt.Fprintf(c.w, "*%d\r\n$%d\r\n%s\r\n", len(args)+1, len(cmd), cmd)
+	for _, a := range args {
+		fmt.Fprintf(c.w, "$%d\r\n", len(a))
+		_, _ = c.w.Write(a)
+		_, _ = c.w.WriteString("\r\n")
+	}
+	if err := c.w.Flush(); err != nil {
+		return nil, err
+	}
+
+	return readRESP(c.r)
+}
+
+// readRESP reads a single reply. Nil replies are returned as nil, bulk strings
+// as []byte, simple strings as string, integers as int64 and arrays as []any.
+func readRESP(r *bufio.Reader) (any, error) {
+	line, err := readRESPLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if line == "" {
+		return nil, errors.New("empty redis reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid bulk string length %q: %w", line[1:], err)
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return buf[:size], nil
+	case '*':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid array length %q: %w", line[1:], err)
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		arr := make([]any, size)
+		for i := range arr {
+			arr[i], err = readRESP(r)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return arr, nil
+	default:
+		return nil, fmt.Errorf("invalid redis reply %q", line)
+	}
+}
+
+func readRESPLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return "", fmt.Errorf("invalid redis line %q", line)
+	}
+	return line[:len(line)-2], nil
+}
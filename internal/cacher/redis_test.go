@@ -0,0 +1,227 @@
+package cacher
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/firefart/go-webserver-template/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeRedisEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+// fakeRedis is a minimal in-process server speaking the subset of RESP used
+// by the RedisStore
+type fakeRedis struct {
+	listener net.Listener
+	password string
+	mu       sync.Mutex
+	data     map[int]map[string]fakeRedisEntry
+	commands []string
+}
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	f := &fakeRedis{
+		listener: l,
+		password: password,
+		data:     make(map[int]map[string]fakeRedisEntry),
+	}
+	go f.serve()
+	t.Cleanup(func() { _ = l.Close() })
+	return f
+}
+
+func (f *fakeRedis) addr() string {
+	return f.listener.Addr().String()
+}
+
+func (f *fakeRedis) serve() {
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		go f.handle(conn)
+	}
+}
+
+func (f *fakeRedis) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	authenticated := f.password == ""
+	db := 0
+	for {
+		req, err := readRESP(r)
+		if err != nil {
+			return
+		}
+		arr, ok := req.([]any)
+		if !ok || len(arr) == 0 {
+			return
+		}
+		args := make([]string, len(arr))
+		for i, a := range arr {
+			b, _ := a.([]byte)
+			args[i] = string(b)
+		}
+		cmd := strings.ToUpper(args[0])
+
+		f.mu.Lock()
+		f.commands = append(f.commands, cmd)
+		if f.data[db] == nil {
+			f.data[db] = make(map[string]fakeRedisEntry)
+		}
+		store := f.data[db]
+		switch {
+		case cmd == "AUTH":
+			if args[len(args)-1] == f.password {
+				authenticated = true
+				fmt.Fprint(w, "+OK\r\n")
+			} else {
+				fmt.Fprint(w, "-WRONGPASS invalid password\r\n")
+			}
+		case !authenticated:
+			fmt.Fprint(w, "-NOAUTH Authentication required.\r\n")
+		case cmd == "PING":
+			fmt.Fprint(w, "+PONG\r\n")
+		case cmd == "SELECT":
+			db, _ = strconv.Atoi(args[1])
+			fmt.Fprint(w, "+OK\r\n")
+		case cmd == "GET":
+			e, ok := store[args[1]]
+			if !ok || (!e.expires.IsZero() && e.expires.Before(time.Now())) {
+				fmt.Fprint(w, "$-1\r\n")
+			} else {
+				fmt.Fprintf(w, "$%d\r\n%s\r\n", len(e.value), e.value)
+			}
+		case cmd == "SET":
+			e := fakeRedisEntry{value: []byte(args[2])}
+			if len(args) == 5 && strings.ToUpper(args[3]) == "PX" {
+				ms, _ := strconv.Atoi(args[4])
+				e.expires = time.Now().Add(time.Duration(ms) * time.Millisecond)
+			}
+			store[args[1]] = e
+			fmt.Fprint(w, "+OK\r\n")
+		case cmd == "DEL":
+			_, ok := store[args[1]]
+			delete(store, args[1])
+			if ok {
+				fmt.Fprint(w, ":1\r\n")
+			} else {
+				fmt.Fprint(w, ":0\r\n")
+			}
+		default:
+			fmt.Fprintf(w, "-ERR unknown command '%s'\r\n", args[0])
+		}
+		f.mu.Unlock()
+		if err := w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func TestRedisStore(t *testing.T) {
+	t.Parallel()
+
+	f := newFakeRedis(t, "secret")
+	store, err := NewRedisStore(config.CacheRedis{
+		Address:  f.addr(),
+		Password: "secret",
+		DB:       2,
+		Prefix:   "prefix:",
+	})
+	require.NoError(t, err)
+	defer store.Close()
+
+	require.NoError(t, store.Ping(t.Context()))
+
+	c, err := New[testStruct](slog.New(slog.DiscardHandler), newTestMetrics(t), store, "test", 1*time.Hour)
+	require.NoError(t, err)
+
+	_, found, err := c.Get(t.Context(), "key")
+	require.NoError(t, err)
+	require.False(t, found)
+
+	expected := testStruct{Name: "name", Count: 1, Tags: []string{"x"}}
+	require.NoError(t, c.Set(t.Context(), "key", expected))
+	value, found, err := c.Get(t.Context(), "key")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, expected, value)
+
+	f.mu.Lock()
+	_, ok := f.data[2]["prefix:test:key"]
+	f.mu.Unlock()
+	require.True(t, ok, "entry not stored with prefix in selected db")
+
+	require.NoError(t, c.Delete(t.Context(), "key"))
+	_, found, err = c.Get(t.Context(), "key")
+	require.NoError(t, err)
+	require.False(t, found)
+
+	// connections are reused
+	f.mu.Lock()
+	authCount := 0
+	for _, cmd := range f.commands {
+		if cmd == "AUTH" {
+			authCount++
+		}
+	}
+	f.mu.Unlock()
+	require.Equal(t, 1, authCount)
+}
+
+func TestRedisStoreExpiration(t *testing.T) {
+	t.Parallel()
+
+	f := newFakeRedis(t, "")
+	store, err := NewRedisStore(config.CacheRedis{Address: f.addr()})
+	require.NoError(t, err)
+	defer store.Close()
+
+	require.NoError(t, store.Set(t.Context(), "key", []byte("value"), 10*time.Millisecond))
+	time.Sleep(20 * time.Millisecond)
+	_, found, err := store.Get(t.Context(), "key")
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+func TestRedisStoreErrors(t *testing.T) {
+	t.Parallel()
+
+	f := newFakeRedis(t, "secret")
+	store, err := NewRedisStore(config.CacheRedis{Address: f.addr(), Password: "wrong"})
+	require.NoError(t, err)
+	defer store.Close()
+	err = store.Ping(t.Context())
+	var rErr redisError
+	require.ErrorAs(t, err, &rErr)
+
+	_, err = NewRedisStore(config.CacheRedis{})
+	require.Error(t, err)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	require.NoError(t, l.Close())
+	store, err = NewRedisStore(config.CacheRedis{Address: addr, Timeout: 100 * time.Millisecond})
+	require.NoError(t, err)
+	_, _, err = store.Get(t.Context(), "key")
+	require.Error(t, err)
+	require.False(t, errors.As(err, &rErr))
+}
@@ -0,0 +1,86 @@
+package cacher
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/firefart/go-webserver-template/internal/database"
+	"github.com/firefart/go-webserver-template/internal/notification"
+)
+
+// SQLiteStore persists the entries in the application database so they
+// survive restarts
+type SQLiteStore struct {
+	logger *slog.Logger
+	db     database.Interface
+	// notifier is told about failing cleanups, it may be nil
+	notifier notification.Notifier
+}
+
+// compile time check that struct implements the interface
+var _ Store = (*SQLiteStore)(nil)
+
+func NewSQLiteStore(ctx context.Context, logger *slog.Logger, db database.Interface, notifier notification.Notifier) *SQLiteStore {
+	s := SQLiteStore{
+		logger:   logger,
+		db:       db,
+		notifier: notifier,
+	}
+
+	// start invalidator go function
+	go s.invalidator(ctx)
+
+	return &s
+}
+
+func (s *SQLiteStore) invalidator(ctx context.Context) {
+	ticker := time.NewTicker(1 * time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			deleted, err := s.db.DeleteExpiredCacheEntries(ctx)
+			if err != nil {
+				s.cleanupFailed(ctx, err)
+				continue
+			}
+			if deleted > 0 {
+				s.logger.Debug("deleted expired cache entries", slog.Int64("count", deleted))
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *SQLiteStore) cleanupFailed(ctx context.Context, err error) {
+	if s.notifier == nil {
+		s.logger.Error("could not delete expired cache entries", slog.String("err", err.Error()))
+		return
+	}
+	// the error gets its own notification instead of the log forwarding,
+	// repeated failures are throttled by the notifier
+	s.logger.With(notification.SkipNotify).Error("could not delete expired cache entries", slog.String("err", err.Error()))
+	if err := s.notifier.Notify(ctx, notification.Notification{
+		Severity: notification.SeverityError,
+		Subject:  "cache cleanup failed",
+		Message:  err.Error(),
+		Source:   "cacher",
+		Tags:     []string{"cache:cleanup"},
+	}); err != nil {
+		s.logger.Error("error on notification send", slog.String("err", err.Error()))
+	}
+}
+
+func (s *SQLiteStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	return s.db.GetCacheEntry(ctx, key)
+}
+
+func (s *SQLiteStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return s.db.SetCacheEntry(ctx, key, value, time.Now().Add(ttl))
+}
+
+func (s *SQLiteStore) Delete(ctx context.Context, key string) error {
+	return s.db.DeleteCacheEntry(ctx, key)
+}
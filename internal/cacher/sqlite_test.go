@@ -0,0 +1,36 @@
+package cacher
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/firefart/go-webserver-template/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteStore(t *testing.T) {
+	t.Parallel()
+
+	db := testutil.NewDB(t)
+
+	store := NewSQLiteStore(t.Context(), slog.New(slog.DiscardHandler), db, nil)
+	c, err := New[testStruct](slog.New(slog.DiscardHandler), newTestMetrics(t), store, "test", 1*time.Hour)
+	require.NoError(t, err)
+
+	expected := testStruct{Name: "name", Count: 1}
+	require.NoError(t, c.Set(t.Context(), "key", expected))
+
+	// a second cache on the same database sees the persisted entry
+	c2, err := New[testStruct](slog.New(slog.DiscardHandler), newTestMetrics(t), NewSQLiteStore(t.Context(), slog.New(slog.DiscardHandler), db, nil), "test", 1*time.Hour)
+	require.NoError(t, err)
+	value, found, err := c2.Get(t.Context(), "key")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, expected, value)
+
+	require.NoError(t, c.Delete(t.Context(), "key"))
+	_, found, err = c2.Get(t.Context(), "key")
+	require.NoError(t, err)
+	require.False(t, found)
+}
@@ -0,0 +1,40 @@
+package cacher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/firefart/go-webserver-template/internal/config"
+	"github.com/firefart/go-webserver-template/internal/database"
+	"github.com/firefart/go-webserver-template/internal/notification"
+)
+
+// Store is the storage backend of a cache. Implementations must be safe for
+// concurrent use and handle the expiration of entries themselves.
+type Store interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+}
+
+// NewStore creates the store configured in the cache section of the config.
+// The context controls the lifetime of background cleanup routines, their
+// errors are sent to the notifier if it is not nil.
+func NewStore(ctx context.Context, configuration config.Configuration, logger *slog.Logger, db database.Interface, notifier notification.Notifier) (Store, error) {
+	switch configuration.Cache.Backend {
+	case "", "memory":
+		return NewMemoryStore(ctx, logger), nil
+	case "sqlite":
+		return NewSQLiteStore(ctx, logger, db, notifier), nil
+	case "redis":
+		if configuration.Cache.Redis == nil {
+			return nil, errors.New("redis cache backend requires a redis configuration")
+		}
+		return NewRedisStore(*configuration.Cache.Redis)
+	default:
+		return nil, fmt.Errorf("invalid cache backend %q", configuration.Cache.Backend)
+	}
+}
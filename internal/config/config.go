@@ -20,9 +20,12 @@ type Configuration struct {
 	Server        Server        `koanf:"server"`
 	Logging       Logging       `koanf:"logging"`
 	Proxy         *Proxy        `koanf:"proxy"`
+	HTTPClient    HTTPClient    `koanf:"http_client"`
 	Cache         Cache         `koanf:"cache"`
 	Mail          Mail          `koanf:"mail"`
 	Database      Database      `koanf:"database"`
+	Session       Session       `koanf:"session"`
+	Auth          Auth          `koanf:"auth"`
 	Notifications Notification  `koanf:"notifications"`
 	Timeout       time.Duration `koanf:"timeout" validate:"required"`
 	UserAgent     string        `koanf:"user_agent"`
@@ -51,18 +54,130 @@ type Logging struct {
 		MaxAge     int  `koanf:"max_age" validate:"omitempty,gte=1"`
 		Compress   bool `koanf:"compress"`
 	} `koanf:"rotate"`
+	Notify LoggingNotify `koanf:"notify"`
 }
 
+// LoggingNotify forwards log records at or above Level to the notifications
+type LoggingNotify struct {
+	Enabled bool   `koanf:"enabled"`
+	Level   string `koanf:"level" validate:"required,oneof=debug info warn error"`
+}
+
+// Proxy configures the proxy of the http client. URLs may use the http, https,
+// socks5 and socks5h schemes. HTTPURL and HTTPSURL override URL for requests
+// with the matching scheme. If PACFile is set it is evaluated instead and
+// NoProxy must be handled by the PAC file.
 type Proxy struct {
 	URL      string `koanf:"url" json:"url" validate:"omitempty,url"`
+	HTTPURL  string `koanf:"http_url" json:"http_url" validate:"omitempty,url"`
+	HTTPSURL string `koanf:"https_url" json:"https_url" validate:"omitempty,url"`
 	Username string `koanf:"username" json:"username" validate:"required_with=Password"`
 	Password string `koanf:"password" json:"password" validate:"required_with=Username"`
-	NoProxy  string `koanf:"no_proxy" json:"no_proxy"`
+	NoProxy  string `koanf:"no_proxy" json:"no_proxy" validate:"excluded_with=PACFile"`
+	PACFile  string `koanf:"pac_file" json:"pac_file" validate:"omitempty,file"`
+	// PACCacheTTL is the time a PAC result is cached per scheme and host
+	PACCacheTTL time.Duration `koanf:"pac_cache_ttl" json:"pac_cache_ttl"`
+}
+
+type HTTPClient struct {
+	Retry          HTTPClientRetry          `koanf:"retry"`
+	CircuitBreaker HTTPClientCircuitBreaker `koanf:"circuit_breaker"`
+	Dump           HTTPClientDump           `koanf:"dump"`
+	// MaxResponseSize limits the body size read by the JSON helpers
+	MaxResponseSize int64 `koanf:"max_response_size" validate:"required,gte=1"`
+	// TLSProfiles are selected by the host of a request, the first match wins
+	TLSProfiles []HTTPClientTLSProfile `koanf:"tls_profiles" validate:"dive"`
+	// RateLimits are selected by the host of a request, the first match wins.
+	// Every host gets its own limiter.
+	RateLimits []HTTPClientRateLimit `koanf:"rate_limits" validate:"dive"`
+}
+
+type HTTPClientRateLimit struct {
+	// Hosts are matched against the request host, *.example.com matches all
+	// subdomains and * matches every host
+	Hosts []string `koanf:"hosts" validate:"required,min=1,dive,required"`
+	// RequestsPerSecond of 0 disables the rate limit
+	RequestsPerSecond float64 `koanf:"requests_per_second" validate:"gte=0"`
+	Burst             int     `koanf:"burst" validate:"gte=0"`
+	// MaxInFlight of 0 disables the concurrency limit
+	MaxInFlight int `koanf:"max_in_flight" validate:"gte=0"`
+	// Policy is either wait to queue requests until the context is done or
+	// fail to return an error immediately
+	Policy string `koanf:"policy" validate:"required,oneof=wait fail"`
+}
+
+// HTTPClientTLSProfile holds the TLS settings for outbound requests to a set
+// of hosts
+type HTTPClientTLSProfile struct {
+	// Hosts are matched against the request host, *.example.com matches all subdomains
+	Hosts      []string `koanf:"hosts" validate:"required,min=1,dive,required"`
+	ClientCert string   `koanf:"client_cert" validate:"required_with=ClientKey,omitempty,file"`
+	ClientKey  string   `koanf:"client_key" validate:"required_with=ClientCert,omitempty,file"`
+	// RootCAs are added to the system roots and the certificates in the cert dir
+	RootCAs    []string `koanf:"root_cas" validate:"dive,file"`
+	ServerName string   `koanf:"server_name"`
+	MinVersion string   `koanf:"min_version" validate:"omitempty,oneof=1.0 1.1 1.2 1.3"`
+	MaxVersion string   `koanf:"max_version" validate:"omitempty,oneof=1.0 1.1 1.2 1.3"`
+	// PinnedSPKI are base64 encoded sha256 hashes of the subject public key
+	// info. If set one certificate of the verified chain must match.
+	PinnedSPKI []string `koanf:"pinned_spki"`
+}
+
+// HTTPClientDump controls the request and response dumps logged in debug mode
+type HTTPClientDump struct {
+	// RedactHeaders are replaced with a placeholder, matched case insensitive
+	RedactHeaders []string `koanf:"redact_headers"`
+	// RedactBodyPatterns are regular expressions, the first capture group is
+	// replaced with a placeholder. Without a group the whole match is replaced.
+	RedactBodyPatterns []string `koanf:"redact_body_patterns"`
+	// MaxBodySize is the maximum number of body bytes included in a dump
+	MaxBodySize int `koanf:"max_body_size" validate:"gte=0"`
+}
+
+type HTTPClientRetry struct {
+	// MaxAttempts includes the initial request, 1 disables retries
+	MaxAttempts    int           `koanf:"max_attempts" validate:"gte=1"`
+	InitialBackoff time.Duration `koanf:"initial_backoff" validate:"required"`
+	MaxBackoff     time.Duration `koanf:"max_backoff" validate:"required,gtefield=InitialBackoff"`
+	Multiplier     float64       `koanf:"multiplier" validate:"gte=1"`
+	// Jitter randomizes the backoff by +/- the given fraction
+	Jitter float64 `koanf:"jitter" validate:"gte=0,lte=1"`
+	// MaxRetryAfter caps the wait time requested by a Retry-After header
+	MaxRetryAfter time.Duration `koanf:"max_retry_after"`
+}
+
+type HTTPClientCircuitBreaker struct {
+	Enabled bool `koanf:"enabled"`
+	// FailureThreshold is the number of consecutive failures that open the circuit
+	FailureThreshold int `koanf:"failure_threshold" validate:"required_if=Enabled true,omitempty,gte=1"`
+	// OpenTimeout is the time the circuit stays open before a probe request is allowed
+	OpenTimeout time.Duration `koanf:"open_timeout" validate:"required_if=Enabled true"`
 }
 
 type Cache struct {
-	Enabled bool          `koanf:"enabled"`
-	Timeout time.Duration `koanf:"timeout" validate:"required"`
+	Enabled   bool           `koanf:"enabled"`
+	Timeout   time.Duration  `koanf:"timeout" validate:"required"`
+	Backend   string         `koanf:"backend" validate:"required,oneof=memory sqlite redis"`
+	Redis     *CacheRedis    `koanf:"redis" validate:"required_if=Backend redis"`
+	Responses CacheResponses `koanf:"responses"`
+}
+
+type CacheResponses struct {
+	Enabled     bool          `koanf:"enabled"`
+	TTL         time.Duration `koanf:"ttl" validate:"required_if=Enabled true"`
+	MaxTTL      time.Duration `koanf:"max_ttl"`
+	VaryHeaders []string      `koanf:"vary_headers"`
+	MaxBodySize int64         `koanf:"max_body_size" validate:"omitempty,gte=1"`
+}
+
+type CacheRedis struct {
+	Address  string        `koanf:"address" validate:"required,hostname_port"`
+	Username string        `koanf:"username"`
+	Password string        `koanf:"password"`
+	DB       int           `koanf:"db" validate:"gte=0"`
+	Prefix   string        `koanf:"prefix"`
+	Timeout  time.Duration `koanf:"timeout"`
+	PoolSize int           `koanf:"pool_size" validate:"omitempty,gte=1"`
 }
 
 type Mail struct {
@@ -81,18 +196,200 @@ type Mail struct {
 	SkipTLS  bool          `koanf:"skiptls"`
 	Retries  int           `koanf:"retries" validate:"required_if=Enabled true"`
 	Timeout  time.Duration `koanf:"timeout" validate:"required_if=Enabled true"`
+	// RetryBackoff is the wait before the first retry of a synchronous send,
+	// it doubles with every retry
+	RetryBackoff time.Duration `koanf:"retry_backoff" validate:"required"`
+	Queue        MailQueue     `koanf:"queue"`
+	DKIM         MailDKIM      `koanf:"dkim"`
+}
+
+// MailDKIM configures the DKIM signature of outgoing mails. The private key
+// is a PEM encoded RSA or Ed25519 key. Headers defaults to From, To, Subject,
+// Date, Message-ID, Content-Type and MIME-Version and must contain From.
+type MailDKIM struct {
+	Enabled        bool     `koanf:"enabled"`
+	Selector       string   `koanf:"selector" validate:"required_if=Enabled true"`
+	Domain         string   `koanf:"domain" validate:"required_if=Enabled true,omitempty,fqdn"`
+	PrivateKeyFile string   `koanf:"private_key_file" validate:"required_if=Enabled true,omitempty,file"`
+	Headers        []string `koanf:"headers" validate:"dive,required"`
+}
+
+// MailQueue controls the delivery of mails persisted in the database. Mails
+// failing MaxAttempts times are kept in the failed state.
+type MailQueue struct {
+	// Workers is the number of concurrent smtp sessions
+	Workers        int           `koanf:"workers" validate:"required,gte=1"`
+	MaxAttempts    int           `koanf:"max_attempts" validate:"required,gte=1"`
+	InitialBackoff time.Duration `koanf:"initial_backoff" validate:"required"`
+	MaxBackoff     time.Duration `koanf:"max_backoff" validate:"required,gtefield=InitialBackoff"`
+	// PollInterval is the interval the queue is checked for due retries
+	PollInterval time.Duration `koanf:"poll_interval" validate:"required"`
+	BatchSize    int           `koanf:"batch_size" validate:"required,gte=1"`
 }
 
 type Database struct {
 	Filename string `koanf:"filename" validate:"required"`
 }
 
+// Session configures the cookie based sessions. New cookies are signed and
+// encrypted with the first key, the other keys are still accepted so keys
+// can be rotated without ending the existing sessions. The cookies are only
+// encrypted if encryption keys are set.
+type Session struct {
+	Enabled         bool          `koanf:"enabled"`
+	Backend         string        `koanf:"backend" validate:"required,oneof=memory sqlite"`
+	CookieName      string        `koanf:"cookie_name" validate:"required"`
+	SigningKeys     []string      `koanf:"signing_keys" validate:"required_if=Enabled true,dive,min=32"`
+	EncryptionKeys  []string      `koanf:"encryption_keys" validate:"dive,min=32"`
+	IdleTimeout     time.Duration `koanf:"idle_timeout" validate:"required"`
+	AbsoluteTimeout time.Duration `koanf:"absolute_timeout" validate:"required,gtefield=IdleTimeout"`
+	Secure          bool          `koanf:"secure"`
+	SameSite        string        `koanf:"same_site" validate:"required,oneof=lax strict none"`
+}
+
+// Auth configures the user accounts with password login, the single sign-on
+// and the API tokens. Enabled switches on the password login, both logins
+// need the sessions to be enabled. The password hash parameters can be raised
+// at any time, existing hashes are upgraded on the next successful login.
+type Auth struct {
+	Enabled  bool         `koanf:"enabled"`
+	Password AuthPassword `koanf:"password"`
+	Lockout  AuthLockout  `koanf:"lockout"`
+	Tokens   AuthTokens   `koanf:"tokens"`
+	OIDC     OIDC         `koanf:"oidc"`
+}
+
+// AuthPassword holds the argon2id parameters, the memory is set in KiB
+type AuthPassword struct {
+	Memory      uint32 `koanf:"memory" validate:"required,gte=8192"`
+	Iterations  uint32 `koanf:"iterations" validate:"required,gte=1"`
+	Parallelism uint8  `koanf:"parallelism" validate:"required,gte=1"`
+	MinLength   int    `koanf:"min_length" validate:"required,gte=8"`
+}
+
+// AuthLockout locks an account after MaxAttempts failed logins in a row and
+// blocks an IP after MaxIPAttempts failed logins within IPWindow
+type AuthLockout struct {
+	MaxAttempts   int64         `koanf:"max_attempts" validate:"required,gte=1"`
+	Duration      time.Duration `koanf:"duration" validate:"required"`
+	MaxIPAttempts int           `koanf:"max_ip_attempts" validate:"required,gte=1"`
+	IPWindow      time.Duration `koanf:"ip_window" validate:"required"`
+}
+
+// AuthTokens enables the API tokens for machine clients on /api. Tokens
+// expire after DefaultTTL unless another ttl is set on creation, 0 creates
+// tokens that never expire.
+type AuthTokens struct {
+	Enabled    bool          `koanf:"enabled"`
+	DefaultTTL time.Duration `koanf:"default_ttl" validate:"gte=0"`
+}
+
+// OIDC configures the single sign on with an OpenID Connect provider. The
+// provider must redirect to RedirectURL, which is the /auth/oidc/callback
+// route of this server. The session cookies must not use the same_site mode
+// strict as they are not sent on the redirect back from the provider.
+type OIDC struct {
+	Enabled       bool          `koanf:"enabled"`
+	Issuer        string        `koanf:"issuer" validate:"required_if=Enabled true,omitempty,url"`
+	ClientID      string        `koanf:"client_id" validate:"required_if=Enabled true"`
+	ClientSecret  string        `koanf:"client_secret"`
+	RedirectURL   string        `koanf:"redirect_url" validate:"required_if=Enabled true,omitempty,url"`
+	Scopes        []string      `koanf:"scopes" validate:"required"`
+	UsernameClaim string        `koanf:"username_claim" validate:"required"`
+	GroupsClaim   string        `koanf:"groups_claim" validate:"required"`
+	JWKSCacheTTL  time.Duration `koanf:"jwks_cache_ttl" validate:"required"`
+	Rules         []OIDCRule    `koanf:"rules" validate:"required_if=Enabled true,dive"`
+}
+
+// OIDCRule grants the role to users in one of the groups having all the
+// claims. Empty groups match all users. The first matching rule wins, users
+// matching no rule can't log in.
+type OIDCRule struct {
+	Role   string            `koanf:"role" validate:"required,oneof=admin user"`
+	Groups []string          `koanf:"groups"`
+	Claims map[string]string `koanf:"claims"`
+}
+
 type Notification struct {
-	Telegram NotificationTelegram `koanf:"telegram"`
-	Discord  NotificationDiscord  `koanf:"discord"`
-	Email    NotificationEmail    `koanf:"email"`
-	Mailgun  NotificationMailgun  `koanf:"mailgun"`
-	MSTeams  NotificationMSTeams  `koanf:"msteams"`
+	Telegram  NotificationTelegram  `koanf:"telegram"`
+	Discord   NotificationDiscord   `koanf:"discord"`
+	Email     NotificationEmail     `koanf:"email"`
+	Mailgun   NotificationMailgun   `koanf:"mailgun"`
+	MSTeams   NotificationMSTeams   `koanf:"msteams"`
+	Webhook   NotificationWebhook   `koanf:"webhook"`
+	Slack     NotificationSlack     `koanf:"slack"`
+	Ntfy      NotificationNtfy      `koanf:"ntfy"`
+	Gotify    NotificationGotify    `koanf:"gotify"`
+	Matrix    NotificationMatrix    `koanf:"matrix"`
+	Pushover  NotificationPushover  `koanf:"pushover"`
+	Outbox    NotificationOutbox    `koanf:"outbox"`
+	Throttle  NotificationThrottle  `koanf:"throttle"`
+	Lifecycle NotificationLifecycle `koanf:"lifecycle"`
+	// Routes select the services of a notification, the first match wins.
+	// Notifications without a matching route are sent to all services.
+	Routes []NotificationRoute `koanf:"routes" validate:"dive"`
+	// Templates override the default subject and message per service and
+	// severity, the first match wins
+	Templates []NotificationTemplate `koanf:"templates" validate:"dive"`
+}
+
+// NotificationLifecycle enables the notifications about the state of the
+// instance. Crash only covers errors after the notifications are set up.
+type NotificationLifecycle struct {
+	Startup  bool `koanf:"startup"`
+	Shutdown bool `koanf:"shutdown"`
+	Crash    bool `koanf:"crash"`
+	Panic    bool `koanf:"panic"`
+}
+
+type NotificationTemplate struct {
+	// Services and Severities restrict the template, empty matches all
+	Services   []string `koanf:"services" validate:"dive,oneof=telegram discord email mailgun msteams webhook slack ntfy gotify matrix pushover"`
+	Severities []string `koanf:"severities" validate:"dive,oneof=info warning error critical"`
+	// Subject and Message are go text/templates, empty keeps the default.
+	// The escape function escapes a value for the format of the service.
+	Subject string `koanf:"subject"`
+	Message string `koanf:"message"`
+	// MaxLength overrides the message length limit of the service
+	MaxLength int `koanf:"max_length" validate:"gte=0"`
+}
+
+type NotificationRoute struct {
+	// Severities, Sources and Tags restrict the route, empty matches all.
+	// A notification matches Tags if it has at least one of them.
+	Severities []string `koanf:"severities" validate:"dive,oneof=info warning error critical"`
+	Sources    []string `koanf:"sources"`
+	Tags       []string `koanf:"tags"`
+	// Services receive the matching notifications, an empty list drops them
+	Services []string `koanf:"services" validate:"dive,oneof=telegram discord email mailgun msteams webhook slack ntfy gotify matrix pushover"`
+}
+
+// NotificationThrottle suppresses duplicate notifications. Rules are keyed by
+// severity, severities without a rule are never throttled.
+type NotificationThrottle struct {
+	Enabled bool                                `koanf:"enabled"`
+	Rules   map[string]NotificationThrottleRule `koanf:"rules" validate:"dive,keys,oneof=info warning error critical,endkeys"`
+}
+
+type NotificationThrottleRule struct {
+	// Window is the time identical notifications are grouped. A summary of
+	// the suppressed notifications is sent when it ends.
+	Window time.Duration `koanf:"window" validate:"required"`
+	// Burst is the number of identical notifications sent per window
+	Burst int `koanf:"burst" validate:"gte=1"`
+}
+
+// NotificationOutbox controls the delivery of notifications persisted in the
+// database. Notifications failing MaxAttempts times are kept as dead letters.
+type NotificationOutbox struct {
+	Workers        int           `koanf:"workers" validate:"required,gte=1"`
+	MaxAttempts    int           `koanf:"max_attempts" validate:"required,gte=1"`
+	InitialBackoff time.Duration `koanf:"initial_backoff" validate:"required"`
+	MaxBackoff     time.Duration `koanf:"max_backoff" validate:"required,gtefield=InitialBackoff"`
+	// PollInterval is the interval the outbox is checked for due retries
+	PollInterval time.Duration `koanf:"poll_interval" validate:"required"`
+	SendTimeout  time.Duration `koanf:"send_timeout" validate:"required"`
+	BatchSize    int           `koanf:"batch_size" validate:"required,gte=1"`
 }
 
 type NotificationTelegram struct {
@@ -130,6 +427,57 @@ type NotificationMSTeams struct {
 	Webhooks []string `koanf:"webhooks" validate:"required_if=Enabled true,dive,http_url"`
 }
 
+// NotificationWebhook sends notifications to a generic HTTP endpoint
+type NotificationWebhook struct {
+	Enabled     bool              `koanf:"enabled"`
+	URL         string            `koanf:"url" validate:"required_if=Enabled true,omitempty,http_url"`
+	Method      string            `koanf:"method" validate:"oneof=POST PUT"`
+	ContentType string            `koanf:"content_type" validate:"required"`
+	Headers     map[string]string `koanf:"headers"`
+	// BodyTemplate is a go text/template with the fields .Subject, .Message
+	// and .Timestamp. The json function encodes a value as JSON.
+	BodyTemplate string `koanf:"body_template"`
+	// Secret enables a HMAC-SHA256 signature of the body in SignatureHeader
+	Secret          string `koanf:"secret"`
+	SignatureHeader string `koanf:"signature_header" validate:"required_with=Secret"`
+}
+
+type NotificationSlack struct {
+	Enabled     bool     `koanf:"enabled"`
+	WebhookURLs []string `koanf:"webhook_urls" validate:"required_if=Enabled true,dive,http_url"`
+}
+
+type NotificationNtfy struct {
+	Enabled   bool   `koanf:"enabled"`
+	ServerURL string `koanf:"server_url" validate:"required_if=Enabled true,omitempty,http_url"`
+	Topic     string `koanf:"topic" validate:"required_if=Enabled true"`
+	Token     string `koanf:"token"`
+	// Priority of 0 uses the server default
+	Priority int `koanf:"priority" validate:"gte=0,lte=5"`
+}
+
+type NotificationGotify struct {
+	Enabled   bool   `koanf:"enabled"`
+	ServerURL string `koanf:"server_url" validate:"required_if=Enabled true,omitempty,http_url"`
+	AppToken  string `koanf:"app_token" validate:"required_if=Enabled true"`
+	Priority  int    `koanf:"priority" validate:"gte=0"`
+}
+
+type NotificationMatrix struct {
+	Enabled       bool     `koanf:"enabled"`
+	HomeserverURL string   `koanf:"homeserver_url" validate:"required_if=Enabled true,omitempty,http_url"`
+	AccessToken   string   `koanf:"access_token" validate:"required_if=Enabled true"`
+	RoomIDs       []string `koanf:"room_ids" validate:"required_if=Enabled true,dive,startswith=!"`
+}
+
+type NotificationPushover struct {
+	Enabled  bool     `koanf:"enabled"`
+	APIURL   string   `koanf:"api_url" validate:"required_if=Enabled true,omitempty,http_url"`
+	APIToken string   `koanf:"api_token" validate:"required_if=Enabled true"`
+	UserKeys []string `koanf:"user_keys" validate:"required_if=Enabled true,dive,required"`
+	Priority int      `koanf:"priority" validate:"gte=-2,lte=2"`
+}
+
 // nolint: gosec
 var defaultConfig = Configuration{
 	Server: Server{
@@ -140,15 +488,148 @@ type NotificationMSTeams struct {
 	Cache: Cache{
 		Enabled: true,
 		Timeout: 1 * time.Hour,
+		Backend: "memory",
+		Responses: CacheResponses{
+			TTL:         5 * time.Minute,
+			MaxTTL:      24 * time.Hour,
+			VaryHeaders: []string{"Hx-Request"},
+			MaxBodySize: 1 << 20,
+		},
 	},
 	Database: Database{
 		Filename: "db.sqlite3",
 	},
+	Session: Session{
+		Backend:         "sqlite",
+		CookieName:      "session",
+		IdleTimeout:     30 * time.Minute,
+		AbsoluteTimeout: 24 * time.Hour,
+		Secure:          true,
+		SameSite:        "lax",
+	},
+	Auth: Auth{
+		Password: AuthPassword{
+			Memory:      64 * 1024,
+			Iterations:  3,
+			Parallelism: 2,
+			MinLength:   12,
+		},
+		Lockout: AuthLockout{
+			MaxAttempts:   5,
+			Duration:      15 * time.Minute,
+			MaxIPAttempts: 20,
+			IPWindow:      15 * time.Minute,
+		},
+		Tokens: AuthTokens{
+			DefaultTTL: 90 * 24 * time.Hour,
+		},
+		OIDC: OIDC{
+			Scopes:        []string{"openid", "profile", "email"},
+			UsernameClaim: "preferred_username",
+			GroupsClaim:   "groups",
+			JWKSCacheTTL:  1 * time.Hour,
+		},
+	},
+	Mail: Mail{
+		RetryBackoff: 1 * time.Second,
+		Queue: MailQueue{
+			Workers:        2,
+			MaxAttempts:    10,
+			InitialBackoff: 30 * time.Second,
+			MaxBackoff:     1 * time.Hour,
+			PollInterval:   5 * time.Second,
+			BatchSize:      50,
+		},
+	},
+	Logging: Logging{
+		Notify: LoggingNotify{
+			Level: "error",
+		},
+	},
+	Notifications: Notification{
+		Webhook: NotificationWebhook{
+			Method:          "POST",
+			ContentType:     "application/json",
+			SignatureHeader: "X-Signature-256",
+		},
+		Ntfy: NotificationNtfy{
+			ServerURL: "https://ntfy.sh",
+		},
+		Pushover: NotificationPushover{
+			APIURL: "https://api.pushover.net/1/messages.json",
+		},
+		Outbox: NotificationOutbox{
+			Workers:        2,
+			MaxAttempts:    10,
+			InitialBackoff: 10 * time.Second,
+			MaxBackoff:     1 * time.Hour,
+			PollInterval:   5 * time.Second,
+			SendTimeout:    30 * time.Second,
+			BatchSize:      50,
+		},
+		Throttle: NotificationThrottle{
+			Enabled: true,
+			Rules: map[string]NotificationThrottleRule{
+				"error": {
+					Window: 10 * time.Minute,
+					Burst:  1,
+				},
+				"critical": {
+					Window: 1 * time.Minute,
+					Burst:  3,
+				},
+			},
+		},
+	},
+	HTTPClient: HTTPClient{
+		MaxResponseSize: 10 << 20, // 10MB
+		Retry: HTTPClientRetry{
+			MaxAttempts:    3,
+			InitialBackoff: 200 * time.Millisecond,
+			MaxBackoff:     5 * time.Second,
+			Multiplier:     2,
+			Jitter:         0.2,
+			MaxRetryAfter:  30 * time.Second,
+		},
+		CircuitBreaker: HTTPClientCircuitBreaker{
+			Enabled:          true,
+			FailureThreshold: 5,
+			OpenTimeout:      30 * time.Second,
+		},
+		Dump: HTTPClientDump{
+			RedactHeaders: []string{
+				"Authorization",
+				"Proxy-Authorization",
+				"Cookie",
+				"Set-Cookie",
+				"X-Api-Key",
+				"X-Auth-Token",
+			},
+			RedactBodyPatterns: []string{
+				`(?i)"(?:password|passwd|secret|token|access_token|refresh_token|id_token|api_key|apikey|client_secret)"\s*:\s*"([^"]*)"`,
+				`(?i)(?:^|[&?])(?:password|passwd|secret|token|access_token|refresh_token|api_key|apikey|client_secret)=([^&\s]*)`,
+			},
+			MaxBodySize: 4096,
+		},
+	},
 	Timeout: 5 * time.Second,
 }
 
+// validateConfiguration checks the rules spanning several sections
+func validateConfiguration(sl validator.StructLevel) {
+	c, ok := sl.Current().Interface().(Configuration)
+	if !ok {
+		return
+	}
+	// the session cookie is not sent on the redirect back from the provider
+	if c.Auth.OIDC.Enabled && c.Session.SameSite == "strict" {
+		sl.ReportError(c.Session.SameSite, "Session.SameSite", "SameSite", "oidc", "")
+	}
+}
+
 func GetConfig(f string) (Configuration, error) {
 	validate := validator.New(validator.WithRequiredStructEnabled())
+	validate.RegisterStructValidation(validateConfiguration, Configuration{})
 
 	k := koanf.NewWithConf(koanf.Conf{
 		Delim: ".",
@@ -88,6 +88,219 @@ func TestGetConfigValidationErrors(t *testing.T) {
 			}`,
 			err: "'SecretKeyHeaderName' failed on the 'required' tag",
 		},
+		{
+			name: "redis cache backend without redis config",
+			config: `{
+				"server": {
+					"secret_key_header_name": "X-Secret-Key",
+					"secret_key_header_value": "SECRET"
+				},
+				"cache": {
+					"backend": "redis"
+				}
+			}`,
+			err: "'Redis' failed on the 'required_if' tag",
+		},
+		{
+			name: "invalid cache backend",
+			config: `{
+				"server": {
+					"secret_key_header_name": "X-Secret-Key",
+					"secret_key_header_value": "SECRET"
+				},
+				"cache": {
+					"backend": "memcached"
+				}
+			}`,
+			err: "'Backend' failed on the 'oneof' tag",
+		},
+		{
+			name: "unknown notification throttle severity",
+			config: `{
+				"server": {
+					"secret_key_header_name": "X-Secret-Key",
+					"secret_key_header_value": "SECRET"
+				},
+				"notifications": {
+					"throttle": {
+						"rules": {
+							"fatal": {
+								"window": "1m",
+								"burst": 1
+							}
+						}
+					}
+				}
+			}`,
+			err: "failed on the 'oneof' tag",
+		},
+		{
+			name: "webhook notification without url",
+			config: `{
+				"server": {
+					"secret_key_header_name": "X-Secret-Key",
+					"secret_key_header_value": "SECRET"
+				},
+				"notifications": {
+					"webhook": {
+						"enabled": true
+					}
+				}
+			}`,
+			err: "'URL' failed on the 'required_if' tag",
+		},
+		{
+			name: "invalid matrix room id",
+			config: `{
+				"server": {
+					"secret_key_header_name": "X-Secret-Key",
+					"secret_key_header_value": "SECRET"
+				},
+				"notifications": {
+					"matrix": {
+						"enabled": true,
+						"homeserver_url": "https://matrix.example.com",
+						"access_token": "token",
+						"room_ids": ["#alias:example.com"]
+					}
+				}
+			}`,
+			err: "'RoomIDs[0]' failed on the 'startswith' tag",
+		},
+		{
+			name: "unknown notification route service",
+			config: `{
+				"server": {
+					"secret_key_header_name": "X-Secret-Key",
+					"secret_key_header_value": "SECRET"
+				},
+				"notifications": {
+					"routes": [
+						{
+							"severities": ["critical"],
+							"services": ["pager"]
+						}
+					]
+				}
+			}`,
+			err: "'Services[0]' failed on the 'oneof' tag",
+		},
+		{
+			name: "dkim without private key file",
+			config: `{
+				"server": {
+					"secret_key_header_name": "X-Secret-Key",
+					"secret_key_header_value": "SECRET"
+				},
+				"mail": {
+					"dkim": {
+						"enabled": true,
+						"selector": "mail",
+						"domain": "example.com"
+					}
+				}
+			}`,
+			err: "'PrivateKeyFile' failed on the 'required_if' tag",
+		},
+		{
+			name: "short session signing key",
+			config: `{
+				"server": {
+					"secret_key_header_name": "X-Secret-Key",
+					"secret_key_header_value": "SECRET"
+				},
+				"session": {
+					"enabled": true,
+					"signing_keys": ["short"]
+				}
+			}`,
+			err: "'SigningKeys[0]' failed on the 'min' tag",
+		},
+		{
+			name: "weak password hash parameters",
+			config: `{
+				"server": {
+					"secret_key_header_name": "X-Secret-Key",
+					"secret_key_header_value": "SECRET"
+				},
+				"auth": {
+					"password": {
+						"memory": 1024
+					}
+				}
+			}`,
+			err: "'Memory' failed on the 'gte' tag",
+		},
+		{
+			name: "oidc without client id",
+			config: `{
+				"server": {
+					"secret_key_header_name": "X-Secret-Key",
+					"secret_key_header_value": "SECRET"
+				},
+				"auth": {
+					"oidc": {
+						"enabled": true,
+						"issuer": "https://sso.example.com",
+						"redirect_url": "https://example.com/auth/oidc/callback",
+						"rules": [{"role": "admin"}]
+					}
+				}
+			}`,
+			err: "'ClientID' failed on the 'required_if' tag",
+		},
+		{
+			name: "oidc rule with invalid role",
+			config: `{
+				"server": {
+					"secret_key_header_name": "X-Secret-Key",
+					"secret_key_header_value": "SECRET"
+				},
+				"auth": {
+					"oidc": {
+						"rules": [{"role": "root"}]
+					}
+				}
+			}`,
+			err: "'Role' failed on the 'oneof' tag",
+		},
+		{
+			name: "oidc with strict session cookies",
+			config: `{
+				"server": {
+					"secret_key_header_name": "X-Secret-Key",
+					"secret_key_header_value": "SECRET"
+				},
+				"session": {
+					"enabled": true,
+					"signing_keys": ["0123456789abcdef0123456789abcdef"],
+					"same_site": "strict"
+				},
+				"auth": {
+					"oidc": {
+						"enabled": true,
+						"issuer": "https://sso.example.com",
+						"client_id": "webserver",
+						"redirect_url": "https://example.com/auth/oidc/callback"
+					}
+				}
+			}`,
+			err: "'Session.SameSite' failed on the 'oidc' tag",
+		},
+		{
+			name: "no proxy with pac file",
+			config: `{
+				"server": {
+					"secret_key_header_name": "X-Secret-Key",
+					"secret_key_header_value": "SECRET"
+				},
+				"proxy": {
+					"pac_file": "config_test.go",
+					"no_proxy": "localhost"
+				}
+			}`,
+			err: "'NoProxy' failed on the 'excluded_with' tag",
+		},
 	}
 
 	for _, tt := range tests {
@@ -279,6 +492,22 @@ func TestGetConfigLoggingValidation(t *testing.T) {
 			}`,
 			expectedErr: "'MaxAge' failed on the 'gte' tag",
 		},
+		{
+			name: "invalid notify level should fail validation",
+			config: `{
+				"server": {
+					"secret_key_header_name": "X-Secret-Key",
+					"secret_key_header_value": "SECRET"
+				},
+				"logging": {
+					"notify": {
+						"enabled": true,
+						"level": "fatal"
+					}
+				}
+			}`,
+			expectedErr: "'Level' failed on the 'oneof' tag",
+		},
 		{
 			name: "valid logging config should pass",
 			config: `{
@@ -296,6 +525,10 @@ func TestGetConfigLoggingValidation(t *testing.T) {
 						"max_backups": 5,
 						"max_age": 30,
 						"compress": true
+					},
+					"notify": {
+						"enabled": true,
+						"level": "warn"
 					}
 				}
 			}`,
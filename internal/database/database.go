@@ -27,6 +27,8 @@ type Database struct {
 	writer    *sqlc.Queries
 	readerRAW *sql.DB
 	writerRAW *sql.DB
+	// migrations is the number of migrations applied on startup
+	migrations int
 }
 
 func New(ctx context.Context, configuration config.Configuration, logger *slog.Logger, debug bool) (*Database, error) {
@@ -36,13 +38,13 @@ func New(ctx context.Context, configuration config.Configuration, logger *slog.L
 		return nil, errors.New("in memory databases are not supported")
 	}
 
-	reader, err := newDatabase(ctx, configuration, logger, debug, true)
+	reader, _, err := newDatabase(ctx, configuration, logger, debug, true)
 	if err != nil {
 		return nil, fmt.Errorf("could not create reader: %w", err)
 	}
 	reader.SetMaxOpenConns(100)
 	// no migrations on the second connection
-	writer, err := newDatabase(ctx, configuration, logger, debug, false)
+	writer, migrations, err := newDatabase(ctx, configuration, logger, debug, false)
 	if err != nil {
 		return nil, fmt.Errorf("could not create writer: %w", err)
 	}
@@ -51,24 +53,31 @@ func New(ctx context.Context, configuration config.Configuration, logger *slog.L
 	writer.SetMaxIdleConns(1)
 
 	return &Database{
-		reader:    sqlc.New(reader),
-		writer:    sqlc.New(writer),
-		readerRAW: reader,
-		writerRAW: writer,
+		reader:     sqlc.New(reader),
+		writer:     sqlc.New(writer),
+		readerRAW:  reader,
+		writerRAW:  writer,
+		migrations: migrations,
 	}, nil
 }
 
-func newDatabase(ctx context.Context, configuration config.Configuration, logger *slog.Logger, debug bool, skipMigrations bool) (*sql.DB, error) {
+// AppliedMigrations returns the number of migrations applied on startup
+func (db *Database) AppliedMigrations() int {
+	return db.migrations
+}
+
+func newDatabase(ctx context.Context, configuration config.Configuration, logger *slog.Logger, debug bool, skipMigrations bool) (*sql.DB, int, error) {
 	db, err := sql.Open("sqlite", fmt.Sprintf("%s?_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)", configuration.Database.Filename))
 	if err != nil {
-		return nil, fmt.Errorf("could not open database %s: %w", configuration.Database.Filename, err)
+		return nil, 0, fmt.Errorf("could not open database %s: %w", configuration.Database.Filename, err)
 	}
 
+	var migrations int
 	// we have a reader and a writer so no need to apply all migrations twice
 	if !skipMigrations {
 		migrationFS, err := fs.Sub(embedMigrations, "migrations")
 		if err != nil {
-			return nil, fmt.Errorf("could not sub migration fs: %w", err)
+			return nil, 0, fmt.Errorf("could not sub migration fs: %w", err)
 		}
 
 		var options []goose.ProviderOption
@@ -78,52 +87,53 @@ func newDatabase(ctx context.Context, configuration config.Configuration, logger
 
 		prov, err := goose.NewProvider("sqlite3", db, migrationFS, options...)
 		if err != nil {
-			return nil, fmt.Errorf("could not create goose provider: %w", err)
+			return nil, 0, fmt.Errorf("could not create goose provider: %w", err)
 		}
 
 		result, err := prov.Up(ctx)
 		if err != nil {
-			return nil, fmt.Errorf("could not apply migrations: %w", err)
+			return nil, 0, fmt.Errorf("could not apply migrations: %w", err)
 		}
 
 		for _, r := range result {
 			if r.Error != nil {
-				return nil, fmt.Errorf("could not apply migration %s: %w", r.Source.Path, r.Error)
+				return nil, 0, fmt.Errorf("could not apply migration %s: %w", r.Source.Path, r.Error)
 			}
 		}
 
+		migrations = len(result)
 		if len(result) > 0 {
 			logger.Info(fmt.Sprintf("applied %d database migrations", len(result)))
 		}
 
 		version, err := prov.GetDBVersion(ctx)
 		if err != nil {
-			return nil, fmt.Errorf("could not get current database version: %w", err)
+			return nil, 0, fmt.Errorf("could not get current database version: %w", err)
 		}
 		logger.Info("database setup completed", slog.Int64("version", version))
 	}
 
 	// shrink and defrag the database (must be run before the checkpoint)
 	if _, err := db.ExecContext(ctx, "VACUUM;"); err != nil {
-		return nil, fmt.Errorf("could not vacuum: %w", err)
+		return nil, 0, fmt.Errorf("could not vacuum: %w", err)
 	}
 
 	// truncate the wal file
 	if _, err := db.ExecContext(ctx, "PRAGMA wal_checkpoint(TRUNCATE);"); err != nil {
-		return nil, fmt.Errorf("could not truncate wal: %w", err)
+		return nil, 0, fmt.Errorf("could not truncate wal: %w", err)
 	}
 
 	// set synchronous mode to normal as it's recommended for WAL
 	if _, err := db.ExecContext(ctx, "PRAGMA synchronous=NORMAL;"); err != nil {
-		return nil, fmt.Errorf("could not set synchronous: %w", err)
+		return nil, 0, fmt.Errorf("could not set synchronous: %w", err)
 	}
 
 	// set the busy timeout (ms) - how long a command waits to be executed when the db is locked / busy
 	if _, err := db.ExecContext(ctx, "PRAGMA busy_timeout=5000;"); err != nil {
-		return nil, fmt.Errorf("could not set synchronous: %w", err)
+		return nil, 0, fmt.Errorf("could not set synchronous: %w", err)
 	}
 
-	return db, nil
+	return db, migrations, nil
 }
 
 func (db *Database) Close(timeout time.Duration) error {
@@ -2,13 +2,99 @@
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"strings"
 	"time"
+
+	"github.com/firefart/go-webserver-template/internal/database/sqlc"
 )
 
 type Interface interface {
 	Close(timeout time.Duration) error
 	InsertDummy(ctx context.Context, name string) (int64, error)
 	GetAllDummy(ctx context.Context) ([]int64, error)
+	GetCacheEntry(ctx context.Context, key string) ([]byte, bool, error)
+	SetCacheEntry(ctx context.Context, key string, value []byte, expires time.Time) error
+	DeleteCacheEntry(ctx context.Context, key string) error
+	DeleteExpiredCacheEntries(ctx context.Context) (int64, error)
+	InsertNotification(ctx context.Context, service, subject, message string, nextAttempt time.Time) (int64, error)
+	GetDueNotifications(ctx context.Context, now time.Time, limit int64) ([]OutboxNotification, error)
+	DeleteNotification(ctx context.Context, id int64) error
+	RetryNotification(ctx context.Context, id int64, attempts int64, nextAttempt time.Time, lastError string) error
+	DeadLetterNotification(ctx context.Context, id int64, attempts int64, lastError string) error
+	InsertMail(ctx context.Context, subject, message string, nextAttempt time.Time) (int64, error)
+	GetDueMails(ctx context.Context, now time.Time, limit int64) ([]OutboxMail, error)
+	DeleteMail(ctx context.Context, id int64) error
+	RetryMail(ctx context.Context, id int64, attempts int64, nextAttempt time.Time, lastError string) error
+	FailMail(ctx context.Context, id int64, attempts int64, lastError string) error
+	CountMailsByStatus(ctx context.Context) (map[string]int64, error)
+	GetSession(ctx context.Context, id string) ([]byte, bool, error)
+	SetSession(ctx context.Context, id string, data []byte, expires time.Time) error
+	DeleteSession(ctx context.Context, id string) error
+	DeleteExpiredSessions(ctx context.Context) (int64, error)
+	CreateUser(ctx context.Context, username, passwordHash, role string) (int64, error)
+	GetUser(ctx context.Context, id int64) (User, bool, error)
+	GetUserByUsername(ctx context.Context, username string) (User, bool, error)
+	SetUserPassword(ctx context.Context, id int64, passwordHash string) error
+	RecordLoginAttempt(ctx context.Context, id int64, now time.Time) (int64, bool, error)
+	LockUser(ctx context.Context, id int64, until time.Time) error
+	RecordLoginSuccess(ctx context.Context, id int64, when time.Time) error
+	CreateAPIToken(ctx context.Context, name, prefix, hash string, scopes []string, created, expires time.Time) (int64, error)
+	GetAPITokenByHash(ctx context.Context, hash string) (APIToken, bool, error)
+	ListAPITokens(ctx context.Context) ([]APIToken, error)
+	DeleteAPIToken(ctx context.Context, id int64) (bool, error)
+	TouchAPIToken(ctx context.Context, id int64, when time.Time) error
+}
+
+// OutboxNotification is a notification waiting for delivery to a single service
+type OutboxNotification struct {
+	ID          int64
+	Service     string
+	Subject     string
+	Message     string
+	Attempts    int64
+	NextAttempt time.Time
+	LastError   string
+	Created     time.Time
+}
+
+// OutboxMail is a mail waiting for delivery, the message is stored encoded
+type OutboxMail struct {
+	ID          int64
+	Subject     string
+	Message     string
+	Attempts    int64
+	NextAttempt time.Time
+	LastError   string
+	Created     time.Time
+}
+
+// User is an account for the password login. LockedUntil and LastLogin are
+// zero if the user was never locked or logged in.
+type User struct {
+	ID             int64
+	Username       string
+	PasswordHash   string
+	Role           string
+	FailedAttempts int64
+	LockedUntil    time.Time
+	LastLogin      time.Time
+	Created        time.Time
+}
+
+// APIToken is a token of a machine client. Only the hash of the token is
+// stored, the prefix identifies the token in listings. Expires and LastUsed
+// are zero if the token never expires or was never used.
+type APIToken struct {
+	ID       int64
+	Name     string
+	Prefix   string
+	Hash     string
+	Scopes   []string
+	Created  time.Time
+	Expires  time.Time
+	LastUsed time.Time
 }
 
 // compile time check that struct implements the interface
@@ -33,3 +119,333 @@ func (db *Database) InsertDummy(ctx context.Context, name string) (int64, error)
 	}
 	return dummy.ID, nil
 }
+
+func (db *Database) GetCacheEntry(ctx context.Context, key string) ([]byte, bool, error) {
+	entry, err := db.reader.GetCacheEntry(ctx, sqlc.GetCacheEntryParams{
+		Key:     key,
+		Expires: time.Now().UnixMilli(),
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return entry.Value, true, nil
+}
+
+func (db *Database) SetCacheEntry(ctx context.Context, key string, value []byte, expires time.Time) error {
+	return db.writer.SetCacheEntry(ctx, sqlc.SetCacheEntryParams{
+		Key:     key,
+		Value:   value,
+		Expires: expires.UnixMilli(),
+	})
+}
+
+func (db *Database) DeleteCacheEntry(ctx context.Context, key string) error {
+	return db.writer.DeleteCacheEntry(ctx, key)
+}
+
+func (db *Database) DeleteExpiredCacheEntries(ctx context.Context) (int64, error) {
+	return db.writer.DeleteExpiredCacheEntries(ctx, time.Now().UnixMilli())
+}
+
+func (db *Database) InsertNotification(ctx context.Context, service, subject, message string, nextAttempt time.Time) (int64, error) {
+	return db.writer.InsertNotification(ctx, sqlc.InsertNotificationParams{
+		Service:     service,
+		Subject:     subject,
+		Message:     message,
+		NextAttempt: nextAttempt.UnixMilli(),
+	})
+}
+
+func (db *Database) GetDueNotifications(ctx context.Context, now time.Time, limit int64) ([]OutboxNotification, error) {
+	rows, err := db.reader.GetDueNotifications(ctx, sqlc.GetDueNotificationsParams{
+		NextAttempt: now.UnixMilli(),
+		Limit:       limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	notifications := make([]OutboxNotification, len(rows))
+	for i, row := range rows {
+		notifications[i] = OutboxNotification{
+			ID:          row.ID,
+			Service:     row.Service,
+			Subject:     row.Subject,
+			Message:     row.Message,
+			Attempts:    row.Attempts,
+			NextAttempt: time.UnixMilli(row.NextAttempt),
+			LastError:   row.LastError,
+			Created:     row.Created,
+		}
+	}
+	return notifications, nil
+}
+
+func (db *Database) DeleteNotification(ctx context.Context, id int64) error {
+	return db.writer.DeleteNotification(ctx, id)
+}
+
+func (db *Database) RetryNotification(ctx context.Context, id int64, attempts int64, nextAttempt time.Time, lastError string) error {
+	return db.writer.RetryNotification(ctx, sqlc.RetryNotificationParams{
+		Attempts:    attempts,
+		NextAttempt: nextAttempt.UnixMilli(),
+		LastError:   lastError,
+		ID:          id,
+	})
+}
+
+func (db *Database) DeadLetterNotification(ctx context.Context, id int64, attempts int64, lastError string) error {
+	return db.writer.DeadLetterNotification(ctx, sqlc.DeadLetterNotificationParams{
+		Attempts:  attempts,
+		LastError: lastError,
+		ID:        id,
+	})
+}
+
+func (db *Database) InsertMail(ctx context.Context, subject, message string, nextAttempt time.Time) (int64, error) {
+	return db.writer.InsertMail(ctx, sqlc.InsertMailParams{
+		Subject:     subject,
+		Message:     message,
+		NextAttempt: nextAttempt.UnixMilli(),
+	})
+}
+
+func (db *Database) GetDueMails(ctx context.Context, now time.Time, limit int64) ([]OutboxMail, error) {
+	rows, err := db.reader.GetDueMails(ctx, sqlc.GetDueMailsParams{
+		NextAttempt: now.UnixMilli(),
+		Limit:       limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	mails := make([]OutboxMail, len(rows))
+	for i, row := range rows {
+		mails[i] = OutboxMail{
+			ID:          row.ID,
+			Subject:     row.Subject,
+			Message:     row.Message,
+			Attempts:    row.Attempts,
+			NextAttempt: time.UnixMilli(row.NextAttempt),
+			LastError:   row.LastError,
+			Created:     row.Created,
+		}
+	}
+	return mails, nil
+}
+
+func (db *Database) DeleteMail(ctx context.Context, id int64) error {
+	return db.writer.DeleteMail(ctx, id)
+}
+
+func (db *Database) RetryMail(ctx context.Context, id int64, attempts int64, nextAttempt time.Time, lastError string) error {
+	return db.writer.RetryMail(ctx, sqlc.RetryMailParams{
+		Attempts:    attempts,
+		NextAttempt: nextAttempt.UnixMilli(),
+		LastError:   lastError,
+		ID:          id,
+	})
+}
+
+func (db *Database) FailMail(ctx context.Context, id int64, attempts int64, lastError string) error {
+	return db.writer.FailMail(ctx, sqlc.FailMailParams{
+		Attempts:  attempts,
+		LastError: lastError,
+		ID:        id,
+	})
+}
+
+// CountMailsByStatus returns the number of mails per status
+func (db *Database) CountMailsByStatus(ctx context.Context) (map[string]int64, error) {
+	rows, err := db.reader.CountMailsByStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	return counts, nil
+}
+
+func (db *Database) GetSession(ctx context.Context, id string) ([]byte, bool, error) {
+	session, err := db.reader.GetSession(ctx, sqlc.GetSessionParams{
+		ID:      id,
+		Expires: time.Now().UnixMilli(),
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return session.Data, true, nil
+}
+
+func (db *Database) SetSession(ctx context.Context, id string, data []byte, expires time.Time) error {
+	return db.writer.SetSession(ctx, sqlc.SetSessionParams{
+		ID:      id,
+		Data:    data,
+		Expires: expires.UnixMilli(),
+	})
+}
+
+func (db *Database) DeleteSession(ctx context.Context, id string) error {
+	return db.writer.DeleteSession(ctx, id)
+}
+
+func (db *Database) DeleteExpiredSessions(ctx context.Context) (int64, error) {
+	return db.writer.DeleteExpiredSessions(ctx, time.Now().UnixMilli())
+}
+
+func (db *Database) CreateUser(ctx context.Context, username, passwordHash, role string) (int64, error) {
+	return db.writer.CreateUser(ctx, sqlc.CreateUserParams{
+		Username:     username,
+		PasswordHash: passwordHash,
+		Role:         role,
+	})
+}
+
+func (db *Database) GetUser(ctx context.Context, id int64) (User, bool, error) {
+	user, err := db.reader.GetUser(ctx, id)
+	return userFromRow(user, err)
+}
+
+func (db *Database) GetUserByUsername(ctx context.Context, username string) (User, bool, error) {
+	user, err := db.reader.GetUserByUsername(ctx, username)
+	return userFromRow(user, err)
+}
+
+func userFromRow(row sqlc.User, err error) (User, bool, error) {
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return User{}, false, nil
+		}
+		return User{}, false, err
+	}
+	return User{
+		ID:             row.ID,
+		Username:       row.Username,
+		PasswordHash:   row.PasswordHash,
+		Role:           row.Role,
+		FailedAttempts: row.FailedAttempts,
+		LockedUntil:    timeFromMilli(row.LockedUntil),
+		LastLogin:      timeFromMilli(row.LastLogin),
+		Created:        row.Created,
+	}, true, nil
+}
+
+// timeFromMilli returns the zero time for 0 instead of the unix epoch
+func timeFromMilli(ms int64) time.Time {
+	if ms == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms)
+}
+
+func (db *Database) SetUserPassword(ctx context.Context, id int64, passwordHash string) error {
+	return db.writer.SetUserPassword(ctx, sqlc.SetUserPasswordParams{
+		PasswordHash: passwordHash,
+		ID:           id,
+	})
+}
+
+// RecordLoginAttempt atomically counts a login attempt of the user and
+// returns the number of attempts since the last successful login or lock. It
+// returns false if the user is locked at now.
+func (db *Database) RecordLoginAttempt(ctx context.Context, id int64, now time.Time) (int64, bool, error) {
+	attempts, err := db.writer.RecordLoginAttempt(ctx, sqlc.RecordLoginAttemptParams{
+		ID:          id,
+		LockedUntil: now.UnixMilli(),
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+	return attempts, true, nil
+}
+
+// LockUser locks the user until the time and resets the attempts
+func (db *Database) LockUser(ctx context.Context, id int64, until time.Time) error {
+	return db.writer.LockUser(ctx, sqlc.LockUserParams{
+		LockedUntil: until.UnixMilli(),
+		ID:          id,
+	})
+}
+
+func (db *Database) RecordLoginSuccess(ctx context.Context, id int64, when time.Time) error {
+	return db.writer.RecordLoginSuccess(ctx, sqlc.RecordLoginSuccessParams{
+		LastLogin: when.UnixMilli(),
+		ID:        id,
+	})
+}
+
+func (db *Database) CreateAPIToken(ctx context.Context, name, prefix, hash string, scopes []string, created, expires time.Time) (int64, error) {
+	var expiresMilli int64
+	if !expires.IsZero() {
+		expiresMilli = expires.UnixMilli()
+	}
+	return db.writer.CreateAPIToken(ctx, sqlc.CreateAPITokenParams{
+		Name:    name,
+		Prefix:  prefix,
+		Hash:    hash,
+		Scopes:  strings.Join(scopes, " "),
+		Created: created.UnixMilli(),
+		Expires: expiresMilli,
+	})
+}
+
+func (db *Database) GetAPITokenByHash(ctx context.Context, hash string) (APIToken, bool, error) {
+	token, err := db.reader.GetAPITokenByHash(ctx, hash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return APIToken{}, false, nil
+		}
+		return APIToken{}, false, err
+	}
+	return apiTokenFromRow(token), true, nil
+}
+
+func (db *Database) ListAPITokens(ctx context.Context) ([]APIToken, error) {
+	rows, err := db.reader.ListAPITokens(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tokens := make([]APIToken, 0, len(rows))
+	for _, row := range rows {
+		tokens = append(tokens, apiTokenFromRow(row))
+	}
+	return tokens, nil
+}
+
+func apiTokenFromRow(row sqlc.ApiToken) APIToken {
+	return APIToken{
+		ID:       row.ID,
+		Name:     row.Name,
+		Prefix:   row.Prefix,
+		Hash:     row.Hash,
+		Scopes:   strings.Fields(row.Scopes),
+		Created:  time.UnixMilli(row.Created),
+		Expires:  timeFromMilli(row.Expires),
+		LastUsed: timeFromMilli(row.LastUsed),
+	}
+}
+
+// DeleteAPIToken returns false if the token does not exist
+func (db *Database) DeleteAPIToken(ctx context.Context, id int64) (bool, error) {
+	deleted, err := db.writer.DeleteAPIToken(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	return deleted > 0, nil
+}
+
+func (db *Database) TouchAPIToken(ctx context.Context, id int64, when time.Time) error {
+	return db.writer.TouchAPIToken(ctx, sqlc.TouchAPITokenParams{
+		LastUsed: when.UnixMilli(),
+		ID:       id,
+	})
+}
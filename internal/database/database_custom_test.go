@@ -42,3 +42,353 @@ func TestGetAllDummy(t *testing.T) {
 	require.Len(t, ids, 1)
 	require.Equal(t, id, ids[0])
 }
+
+func TestCacheEntries(t *testing.T) {
+	t.Parallel()
+
+	file, err := os.CreateTemp(t.TempDir(), "*.sqlite")
+	require.NoError(t, err)
+	defer func(name string) {
+		err := os.Remove(name)
+		require.NoError(t, err)
+	}(file.Name())
+
+	configuration := config.Configuration{
+		Database: config.Database{
+			Filename: file.Name(),
+		},
+	}
+	db, err := database.New(t.Context(), configuration, slog.New(slog.DiscardHandler), false)
+	require.NoError(t, err)
+	defer func(db *database.Database, timeout time.Duration) {
+		err := db.Close(timeout)
+		require.NoError(t, err)
+	}(db, 1*time.Second)
+
+	_, found, err := db.GetCacheEntry(t.Context(), "key")
+	require.NoError(t, err)
+	require.False(t, found)
+
+	err = db.SetCacheEntry(t.Context(), "key", []byte("value"), time.Now().Add(1*time.Hour))
+	require.NoError(t, err)
+	value, found, err := db.GetCacheEntry(t.Context(), "key")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, []byte("value"), value)
+
+	// overwrite with an already expired entry
+	err = db.SetCacheEntry(t.Context(), "key", []byte("value2"), time.Now().Add(-1*time.Second))
+	require.NoError(t, err)
+	_, found, err = db.GetCacheEntry(t.Context(), "key")
+	require.NoError(t, err)
+	require.False(t, found)
+
+	deleted, err := db.DeleteExpiredCacheEntries(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, int64(1), deleted)
+
+	err = db.SetCacheEntry(t.Context(), "key", []byte("value"), time.Now().Add(1*time.Hour))
+	require.NoError(t, err)
+	err = db.DeleteCacheEntry(t.Context(), "key")
+	require.NoError(t, err)
+	_, found, err = db.GetCacheEntry(t.Context(), "key")
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+func TestNotificationOutbox(t *testing.T) {
+	t.Parallel()
+
+	file, err := os.CreateTemp(t.TempDir(), "*.sqlite")
+	require.NoError(t, err)
+
+	configuration := config.Configuration{
+		Database: config.Database{
+			Filename: file.Name(),
+		},
+	}
+	db, err := database.New(t.Context(), configuration, slog.New(slog.DiscardHandler), false)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, db.Close(1*time.Second))
+	}()
+
+	now := time.Now()
+	first, err := db.InsertNotification(t.Context(), "telegram", "subject", "first", now.Add(-1*time.Second))
+	require.NoError(t, err)
+	second, err := db.InsertNotification(t.Context(), "discord", "subject", "second", now)
+	require.NoError(t, err)
+	_, err = db.InsertNotification(t.Context(), "discord", "subject", "later", now.Add(1*time.Hour))
+	require.NoError(t, err)
+
+	due, err := db.GetDueNotifications(t.Context(), now, 10)
+	require.NoError(t, err)
+	require.Len(t, due, 2)
+	require.Equal(t, first, due[0].ID)
+	require.Equal(t, "telegram", due[0].Service)
+	require.Equal(t, "first", due[0].Message)
+	require.Equal(t, second, due[1].ID)
+
+	due, err = db.GetDueNotifications(t.Context(), now, 1)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+
+	require.NoError(t, db.RetryNotification(t.Context(), first, 1, now.Add(1*time.Minute), "failed"))
+	require.NoError(t, db.DeadLetterNotification(t.Context(), second, 5, "failed"))
+	due, err = db.GetDueNotifications(t.Context(), now, 10)
+	require.NoError(t, err)
+	require.Empty(t, due)
+
+	due, err = db.GetDueNotifications(t.Context(), now.Add(2*time.Minute), 10)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	require.Equal(t, int64(1), due[0].Attempts)
+	require.Equal(t, "failed", due[0].LastError)
+
+	require.NoError(t, db.DeleteNotification(t.Context(), first))
+	due, err = db.GetDueNotifications(t.Context(), now.Add(2*time.Minute), 10)
+	require.NoError(t, err)
+	require.Empty(t, due)
+}
+
+func TestMailOutbox(t *testing.T) {
+	t.Parallel()
+
+	file, err := os.CreateTemp(t.TempDir(), "*.sqlite")
+	require.NoError(t, err)
+
+	configuration := config.Configuration{
+		Database: config.Database{
+			Filename: file.Name(),
+		},
+	}
+	db, err := database.New(t.Context(), configuration, slog.New(slog.DiscardHandler), false)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, db.Close(1*time.Second))
+	}()
+
+	now := time.Now()
+	first, err := db.InsertMail(t.Context(), "first", `{"subject":"first"}`, now.Add(-1*time.Second))
+	require.NoError(t, err)
+	second, err := db.InsertMail(t.Context(), "second", `{"subject":"second"}`, now)
+	require.NoError(t, err)
+	_, err = db.InsertMail(t.Context(), "later", `{"subject":"later"}`, now.Add(1*time.Hour))
+	require.NoError(t, err)
+
+	due, err := db.GetDueMails(t.Context(), now, 10)
+	require.NoError(t, err)
+	require.Len(t, due, 2)
+	require.Equal(t, first, due[0].ID)
+	require.Equal(t, "first", due[0].Subject)
+	require.JSONEq(t, `{"subject":"first"}`, due[0].Message)
+	require.Equal(t, second, due[1].ID)
+
+	require.NoError(t, db.RetryMail(t.Context(), first, 1, now.Add(1*time.Minute), "failed"))
+	require.NoError(t, db.FailMail(t.Context(), second, 5, "failed"))
+	due, err = db.GetDueMails(t.Context(), now, 10)
+	require.NoError(t, err)
+	require.Empty(t, due)
+
+	counts, err := db.CountMailsByStatus(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, map[string]int64{"pending": 2, "failed": 1}, counts)
+
+	due, err = db.GetDueMails(t.Context(), now.Add(2*time.Minute), 10)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	require.Equal(t, int64(1), due[0].Attempts)
+	require.Equal(t, "failed", due[0].LastError)
+
+	require.NoError(t, db.DeleteMail(t.Context(), first))
+	due, err = db.GetDueMails(t.Context(), now.Add(2*time.Minute), 10)
+	require.NoError(t, err)
+	require.Empty(t, due)
+}
+
+func TestSessions(t *testing.T) {
+	t.Parallel()
+
+	file, err := os.CreateTemp(t.TempDir(), "*.sqlite")
+	require.NoError(t, err)
+
+	configuration := config.Configuration{
+		Database: config.Database{
+			Filename: file.Name(),
+		},
+	}
+	db, err := database.New(t.Context(), configuration, slog.New(slog.DiscardHandler), false)
+	require.NoError(t, err)
+	defer func(db *database.Database, timeout time.Duration) {
+		err := db.Close(timeout)
+		require.NoError(t, err)
+	}(db, 1*time.Second)
+
+	_, found, err := db.GetSession(t.Context(), "id")
+	require.NoError(t, err)
+	require.False(t, found)
+
+	require.NoError(t, db.SetSession(t.Context(), "id", []byte("data"), time.Now().Add(1*time.Hour)))
+	data, found, err := db.GetSession(t.Context(), "id")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, []byte("data"), data)
+
+	// expired sessions are not returned and removed by the cleanup
+	require.NoError(t, db.SetSession(t.Context(), "expired", []byte("data"), time.Now().Add(-1*time.Second)))
+	_, found, err = db.GetSession(t.Context(), "expired")
+	require.NoError(t, err)
+	require.False(t, found)
+	deleted, err := db.DeleteExpiredSessions(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, int64(1), deleted)
+
+	require.NoError(t, db.DeleteSession(t.Context(), "id"))
+	_, found, err = db.GetSession(t.Context(), "id")
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+func TestUsers(t *testing.T) {
+	t.Parallel()
+
+	file, err := os.CreateTemp(t.TempDir(), "*.sqlite")
+	require.NoError(t, err)
+
+	configuration := config.Configuration{
+		Database: config.Database{
+			Filename: file.Name(),
+		},
+	}
+	db, err := database.New(t.Context(), configuration, slog.New(slog.DiscardHandler), false)
+	require.NoError(t, err)
+	defer func(db *database.Database, timeout time.Duration) {
+		err := db.Close(timeout)
+		require.NoError(t, err)
+	}(db, 1*time.Second)
+
+	_, found, err := db.GetUserByUsername(t.Context(), "admin")
+	require.NoError(t, err)
+	require.False(t, found)
+
+	id, err := db.CreateUser(t.Context(), "admin", "hash", "admin")
+	require.NoError(t, err)
+	// usernames are unique regardless of the case
+	_, err = db.CreateUser(t.Context(), "Admin", "hash", "user")
+	require.Error(t, err)
+
+	user, found, err := db.GetUserByUsername(t.Context(), "ADMIN")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, id, user.ID)
+	require.Equal(t, "admin", user.Role)
+	require.True(t, user.LockedUntil.IsZero())
+	require.True(t, user.LastLogin.IsZero())
+
+	now := time.Now().Truncate(time.Millisecond)
+	for i := range 3 {
+		attempts, unlocked, err := db.RecordLoginAttempt(t.Context(), id, now)
+		require.NoError(t, err)
+		require.True(t, unlocked)
+		require.Equal(t, int64(i+1), attempts)
+	}
+
+	lockedUntil := now.Add(1 * time.Hour)
+	require.NoError(t, db.LockUser(t.Context(), id, lockedUntil))
+	user, _, err = db.GetUser(t.Context(), id)
+	require.NoError(t, err)
+	require.Zero(t, user.FailedAttempts)
+	require.True(t, lockedUntil.Equal(user.LockedUntil))
+	// attempts on locked users are not counted
+	_, unlocked, err := db.RecordLoginAttempt(t.Context(), id, now)
+	require.NoError(t, err)
+	require.False(t, unlocked)
+	attempts, unlocked, err := db.RecordLoginAttempt(t.Context(), id, lockedUntil)
+	require.NoError(t, err)
+	require.True(t, unlocked)
+	require.Equal(t, int64(1), attempts)
+
+	require.NoError(t, db.RecordLoginSuccess(t.Context(), id, now))
+	user, _, err = db.GetUser(t.Context(), id)
+	require.NoError(t, err)
+	require.Zero(t, user.FailedAttempts)
+	require.True(t, user.LockedUntil.IsZero())
+	require.True(t, now.Equal(user.LastLogin))
+
+	// a new password also unlocks the user
+	require.NoError(t, db.LockUser(t.Context(), id, lockedUntil))
+	require.NoError(t, db.SetUserPassword(t.Context(), id, "new hash"))
+	user, _, err = db.GetUser(t.Context(), id)
+	require.NoError(t, err)
+	require.Equal(t, "new hash", user.PasswordHash)
+	require.Zero(t, user.FailedAttempts)
+	require.True(t, user.LockedUntil.IsZero())
+
+	_, found, err = db.GetUser(t.Context(), id+1)
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+func TestAPITokens(t *testing.T) {
+	t.Parallel()
+
+	file, err := os.CreateTemp(t.TempDir(), "*.sqlite")
+	require.NoError(t, err)
+
+	configuration := config.Configuration{
+		Database: config.Database{
+			Filename: file.Name(),
+		},
+	}
+	db, err := database.New(t.Context(), configuration, slog.New(slog.DiscardHandler), false)
+	require.NoError(t, err)
+	defer func(db *database.Database, timeout time.Duration) {
+		err := db.Close(timeout)
+		require.NoError(t, err)
+	}(db, 1*time.Second)
+
+	tokens, err := db.ListAPITokens(t.Context())
+	require.NoError(t, err)
+	require.Empty(t, tokens)
+
+	created := time.Now().Truncate(time.Millisecond)
+	expires := created.Add(24 * time.Hour)
+	id, err := db.CreateAPIToken(t.Context(), "ci", "gwt_ABCDEFGH", "hash1", []string{"dummy:read", "dummy:write"}, created, expires)
+	require.NoError(t, err)
+	// the hash must be unique
+	_, err = db.CreateAPIToken(t.Context(), "other", "gwt_ABCDEFGH", "hash1", nil, created, time.Time{})
+	require.Error(t, err)
+	_, err = db.CreateAPIToken(t.Context(), "other", "gwt_IJKLMNOP", "hash2", nil, created, time.Time{})
+	require.NoError(t, err)
+
+	token, found, err := db.GetAPITokenByHash(t.Context(), "hash1")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, id, token.ID)
+	require.Equal(t, "ci", token.Name)
+	require.Equal(t, []string{"dummy:read", "dummy:write"}, token.Scopes)
+	require.True(t, created.Equal(token.Created))
+	require.True(t, expires.Equal(token.Expires))
+	require.True(t, token.LastUsed.IsZero())
+
+	require.NoError(t, db.TouchAPIToken(t.Context(), id, expires))
+	token, _, err = db.GetAPITokenByHash(t.Context(), "hash1")
+	require.NoError(t, err)
+	require.True(t, expires.Equal(token.LastUsed))
+
+	tokens, err = db.ListAPITokens(t.Context())
+	require.NoError(t, err)
+	require.Len(t, tokens, 2)
+	require.Empty(t, tokens[1].Scopes)
+	require.True(t, tokens[1].Expires.IsZero())
+
+	deleted, err := db.DeleteAPIToken(t.Context(), id)
+	require.NoError(t, err)
+	require.True(t, deleted)
+	deleted, err = db.DeleteAPIToken(t.Context(), id)
+	require.NoError(t, err)
+	require.False(t, deleted)
+	_, found, err = db.GetAPITokenByHash(t.Context(), "hash1")
+	require.NoError(t, err)
+	require.False(t, found)
+}
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE cache
+(
+    key     TEXT    NOT NULL PRIMARY KEY,
+    value   BLOB    NOT NULL,
+    expires INTEGER NOT NULL
+);
+CREATE INDEX idx_cache_expires ON cache (expires);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX idx_cache_expires;
+DROP TABLE cache;
+-- +goose StatementEnd
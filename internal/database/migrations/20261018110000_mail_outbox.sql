@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE mail_outbox
+(
+    id           INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+    subject      TEXT     NOT NULL,
+    message      TEXT     NOT NULL,
+    status       TEXT     NOT NULL DEFAULT 'pending',
+    attempts     INTEGER  NOT NULL DEFAULT 0,
+    next_attempt INTEGER  NOT NULL,
+    last_error   TEXT     NOT NULL DEFAULT '',
+    created      DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX idx_mail_outbox_status_next_attempt ON mail_outbox (status, next_attempt);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX idx_mail_outbox_status_next_attempt;
+DROP TABLE mail_outbox;
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE sessions
+(
+    id      TEXT    NOT NULL PRIMARY KEY,
+    data    BLOB    NOT NULL,
+    expires INTEGER NOT NULL
+);
+CREATE INDEX idx_sessions_expires ON sessions (expires);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX idx_sessions_expires;
+DROP TABLE sessions;
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE users
+(
+    id              INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+    username        TEXT     NOT NULL UNIQUE COLLATE NOCASE,
+    password_hash   TEXT     NOT NULL,
+    role            TEXT     NOT NULL DEFAULT 'user',
+    failed_attempts INTEGER  NOT NULL DEFAULT 0,
+    locked_until    INTEGER  NOT NULL DEFAULT 0,
+    last_login      INTEGER  NOT NULL DEFAULT 0,
+    created         DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE users;
+-- +goose StatementEnd
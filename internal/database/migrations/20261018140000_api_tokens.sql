@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE api_tokens
+(
+    id        INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    name      TEXT    NOT NULL,
+    prefix    TEXT    NOT NULL,
+    hash      TEXT    NOT NULL UNIQUE,
+    scopes    TEXT    NOT NULL DEFAULT '',
+    created   INTEGER NOT NULL,
+    expires   INTEGER NOT NULL DEFAULT 0,
+    last_used INTEGER NOT NULL DEFAULT 0
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE api_tokens;
+-- +goose StatementEnd
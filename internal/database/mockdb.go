@@ -24,3 +24,127 @@ func (*MockDB) GetAllDummy(_ context.Context) ([]int64, error) {
 func (*MockDB) InsertDummy(_ context.Context, _ string) (int64, error) {
 	return -1, nil
 }
+
+func (*MockDB) GetCacheEntry(_ context.Context, _ string) ([]byte, bool, error) {
+	return nil, false, nil
+}
+
+func (*MockDB) SetCacheEntry(_ context.Context, _ string, _ []byte, _ time.Time) error {
+	return nil
+}
+
+func (*MockDB) DeleteCacheEntry(_ context.Context, _ string) error {
+	return nil
+}
+
+func (*MockDB) DeleteExpiredCacheEntries(_ context.Context) (int64, error) {
+	return 0, nil
+}
+
+func (*MockDB) InsertNotification(_ context.Context, _, _, _ string, _ time.Time) (int64, error) {
+	return -1, nil
+}
+
+func (*MockDB) GetDueNotifications(_ context.Context, _ time.Time, _ int64) ([]OutboxNotification, error) {
+	return nil, nil
+}
+
+func (*MockDB) DeleteNotification(_ context.Context, _ int64) error {
+	return nil
+}
+
+func (*MockDB) RetryNotification(_ context.Context, _ int64, _ int64, _ time.Time, _ string) error {
+	return nil
+}
+
+func (*MockDB) DeadLetterNotification(_ context.Context, _ int64, _ int64, _ string) error {
+	return nil
+}
+
+func (*MockDB) InsertMail(_ context.Context, _, _ string, _ time.Time) (int64, error) {
+	return -1, nil
+}
+
+func (*MockDB) GetDueMails(_ context.Context, _ time.Time, _ int64) ([]OutboxMail, error) {
+	return nil, nil
+}
+
+func (*MockDB) DeleteMail(_ context.Context, _ int64) error {
+	return nil
+}
+
+func (*MockDB) RetryMail(_ context.Context, _ int64, _ int64, _ time.Time, _ string) error {
+	return nil
+}
+
+func (*MockDB) FailMail(_ context.Context, _ int64, _ int64, _ string) error {
+	return nil
+}
+
+func (*MockDB) CountMailsByStatus(_ context.Context) (map[string]int64, error) {
+	return nil, nil
+}
+
+func (*MockDB) GetSession(_ context.Context, _ string) ([]byte, bool, error) {
+	return nil, false, nil
+}
+
+func (*MockDB) SetSession(_ context.Context, _ string, _ []byte, _ time.Time) error {
+	return nil
+}
+
+func (*MockDB) DeleteSession(_ context.Context, _ string) error {
+	return nil
+}
+
+func (*MockDB) DeleteExpiredSessions(_ context.Context) (int64, error) {
+	return 0, nil
+}
+
+func (*MockDB) CreateUser(_ context.Context, _, _, _ string) (int64, error) {
+	return -1, nil
+}
+
+func (*MockDB) GetUser(_ context.Context, _ int64) (User, bool, error) {
+	return User{}, false, nil
+}
+
+func (*MockDB) GetUserByUsername(_ context.Context, _ string) (User, bool, error) {
+	return User{}, false, nil
+}
+
+func (*MockDB) SetUserPassword(_ context.Context, _ int64, _ string) error {
+	return nil
+}
+
+func (*MockDB) RecordLoginAttempt(_ context.Context, _ int64, _ time.Time) (int64, bool, error) {
+	return 0, true, nil
+}
+
+func (*MockDB) LockUser(_ context.Context, _ int64, _ time.Time) error {
+	return nil
+}
+
+func (*MockDB) RecordLoginSuccess(_ context.Context, _ int64, _ time.Time) error {
+	return nil
+}
+
+func (*MockDB) CreateAPIToken(_ context.Context, _, _, _ string, _ []string, _, _ time.Time) (int64, error) {
+	return -1, nil
+}
+
+func (*MockDB) GetAPITokenByHash(_ context.Context, _ string) (APIToken, bool, error) {
+	return APIToken{}, false, nil
+}
+
+func (*MockDB) ListAPITokens(_ context.Context) ([]APIToken, error) {
+	return nil, nil
+}
+
+func (*MockDB) DeleteAPIToken(_ context.Context, _ int64) (bool, error) {
+	return false, nil
+}
+
+func (*MockDB) TouchAPIToken(_ context.Context, _ int64, _ time.Time) error {
+	return nil
+}
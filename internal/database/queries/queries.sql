@@ -7,3 +7,179 @@ order by id;
 INSERT INTO dummy(name)
 VALUES (?)
 RETURNING *;
+
+-- name: GetCacheEntry :one
+SELECT *
+FROM cache
+WHERE key = ?
+  AND expires > ?;
+
+-- name: SetCacheEntry :exec
+INSERT INTO cache(key, value, expires)
+VALUES (?, ?, ?)
+ON CONFLICT(key) DO UPDATE SET value   = excluded.value,
+                               expires = excluded.expires;
+
+-- name: DeleteCacheEntry :exec
+DELETE
+FROM cache
+WHERE key = ?;
+
+-- name: DeleteExpiredCacheEntries :execrows
+DELETE
+FROM cache
+WHERE expires <= ?;
+
+-- name: InsertNotification :execlastid
+INSERT INTO notification_outbox(service, subject, message, next_attempt)
+VALUES (?, ?, ?, ?);
+
+-- name: GetDueNotifications :many
+SELECT *
+FROM notification_outbox
+WHERE status = 'pending'
+  AND next_attempt <= ?
+ORDER BY next_attempt, id
+LIMIT ?;
+
+-- name: DeleteNotification :exec
+DELETE
+FROM notification_outbox
+WHERE id = ?;
+
+-- name: RetryNotification :exec
+UPDATE notification_outbox
+SET attempts     = ?,
+    next_attempt = ?,
+    last_error   = ?
+WHERE id = ?;
+
+-- name: DeadLetterNotification :exec
+UPDATE notification_outbox
+SET status     = 'dead',
+    attempts   = ?,
+    last_error = ?
+WHERE id = ?;
+
+-- name: InsertMail :execlastid
+INSERT INTO mail_outbox(subject, message, next_attempt)
+VALUES (?, ?, ?);
+
+-- name: GetDueMails :many
+SELECT *
+FROM mail_outbox
+WHERE status = 'pending'
+  AND next_attempt <= ?
+ORDER BY next_attempt, id
+LIMIT ?;
+
+-- name: DeleteMail :exec
+DELETE
+FROM mail_outbox
+WHERE id = ?;
+
+-- name: RetryMail :exec
+UPDATE mail_outbox
+SET attempts     = ?,
+    next_attempt = ?,
+    last_error   = ?
+WHERE id = ?;
+
+-- name: FailMail :exec
+UPDATE mail_outbox
+SET status     = 'failed',
+    attempts   = ?,
+    last_error = ?
+WHERE id = ?;
+
+-- name: CountMailsByStatus :many
+SELECT status, COUNT(*) AS count
+FROM mail_outbox
+GROUP BY status;
+
+-- name: GetSession :one
+SELECT *
+FROM sessions
+WHERE id = ?
+  AND expires > ?;
+
+-- name: SetSession :exec
+INSERT INTO sessions(id, data, expires)
+VALUES (?, ?, ?)
+ON CONFLICT(id) DO UPDATE SET data    = excluded.data,
+                              expires = excluded.expires;
+
+-- name: DeleteSession :exec
+DELETE
+FROM sessions
+WHERE id = ?;
+
+-- name: DeleteExpiredSessions :execrows
+DELETE
+FROM sessions
+WHERE expires <= ?;
+
+-- name: CreateUser :execlastid
+INSERT INTO users(username, password_hash, role)
+VALUES (?, ?, ?);
+
+-- name: GetUser :one
+SELECT *
+FROM users
+WHERE id = ?;
+
+-- name: GetUserByUsername :one
+SELECT *
+FROM users
+WHERE username = ?;
+
+-- name: SetUserPassword :exec
+UPDATE users
+SET password_hash   = ?,
+    failed_attempts = 0,
+    locked_until    = 0
+WHERE id = ?;
+
+-- name: RecordLoginAttempt :one
+UPDATE users
+SET failed_attempts = failed_attempts + 1
+WHERE id = ?
+  AND locked_until <= ?
+RETURNING failed_attempts;
+
+-- name: LockUser :exec
+UPDATE users
+SET failed_attempts = 0,
+    locked_until    = ?
+WHERE id = ?;
+
+-- name: RecordLoginSuccess :exec
+UPDATE users
+SET failed_attempts = 0,
+    locked_until    = 0,
+    last_login      = ?
+WHERE id = ?;
+
+-- name: CreateAPIToken :execlastid
+INSERT INTO api_tokens(name, prefix, hash, scopes, created, expires)
+VALUES (?, ?, ?, ?, ?, ?);
+
+-- name: GetAPITokenByHash :one
+SELECT *
+FROM api_tokens
+WHERE hash = ?;
+
+-- name: ListAPITokens :many
+SELECT *
+FROM api_tokens
+ORDER BY id;
+
+-- name: DeleteAPIToken :execrows
+DELETE
+FROM api_tokens
+WHERE id = ?;
+
+-- name: TouchAPIToken :exec
+UPDATE api_tokens
+SET last_used = ?
+WHERE id = ?;
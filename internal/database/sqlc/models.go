@@ -6,10 +6,68 @@
 
 import (
 	"database/sql"
+	"time"
 )
 
+type ApiToken struct {
+	ID       int64
+	Name     string
+	Prefix   string
+	Hash     string
+	Scopes   string
+	Created  int64
+	Expires  int64
+	LastUsed int64
+}
+
+type Cache struct {
+	Key     string
+	Value   []byte
+	Expires int64
+}
+
 type Dummy struct {
 	ID      int64
 	Name    string
 	Updated sql.NullTime
 }
+
+type MailOutbox struct {
+	ID          int64
+	Subject     string
+	Message     string
+	Status      string
+	Attempts    int64
+	NextAttempt int64
+	LastError   string
+	Created     time.Time
+}
+
+type NotificationOutbox struct {
+	ID          int64
+	Service     string
+	Subject     string
+	Message     string
+	Status      string
+	Attempts    int64
+	NextAttempt int64
+	LastError   string
+	Created     time.Time
+}
+
+type Session struct {
+	ID      string
+	Data    []byte
+	Expires int64
+}
+
+type User struct {
+	ID             int64
+	Username       string
+	PasswordHash   string
+	Role           string
+	FailedAttempts int64
+	LockedUntil    int64
+	LastLogin      int64
+	Created        time.Time
+}
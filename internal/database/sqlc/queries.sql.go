@@ -50,3 +50,663 @@ func (q *Queries) InsertDummy(ctx context.Context, name string) (Dummy, error) {
 	err := row.Scan(&i.ID, &i.Name, &i.Updated)
 	return i, err
 }
+
+const getCacheEntry = `-- name: GetCacheEntry :one
+SELECT key, value, expires
+FROM cache
+WHERE key = ?
+  AND expires > ?
+`
+
+type GetCacheEntryParams struct {
+	Key     string
+	Expires int64
+}
+
+func (q *Queries) GetCacheEntry(ctx context.Context, arg GetCacheEntryParams) (Cache, error) {
+	row := q.db.QueryRowContext(ctx, getCacheEntry, arg.Key, arg.Expires)
+	var i Cache
+	err := row.Scan(&i.Key, &i.Value, &i.Expires)
+	return i, err
+}
+
+const setCacheEntry = `-- name: SetCacheEntry :exec
+INSERT INTO cache(key, value, expires)
+VALUES (?, ?, ?)
+ON CONFLICT(key) DO UPDATE SET value   = excluded.value,
+                               expires = excluded.expires
+`
+
+type SetCacheEntryParams struct {
+	Key     string
+	Value   []byte
+	Expires int64
+}
+
+func (q *Queries) SetCacheEntry(ctx context.Context, arg SetCacheEntryParams) error {
+	_, err := q.db.ExecContext(ctx, setCacheEntry, arg.Key, arg.Value, arg.Expires)
+	return err
+}
+
+const deleteCacheEntry = `-- name: DeleteCacheEntry :exec
+DELETE
+FROM cache
+WHERE key = ?
+`
+
+func (q *Queries) DeleteCacheEntry(ctx context.Context, key string) error {
+	_, err := q.db.ExecContext(ctx, deleteCacheEntry, key)
+	return err
+}
+
+const deleteExpiredCacheEntries = `-- name: DeleteExpiredCacheEntries :execrows
+DELETE
+FROM cache
+WHERE expires <= ?
+`
+
+func (q *Queries) DeleteExpiredCacheEntries(ctx context.Context, expires int64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteExpiredCacheEntries, expires)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const insertNotification = `-- name: InsertNotification :execlastid
+INSERT INTO notification_outbox(service, subject, message, next_attempt)
+VALUES (?, ?, ?, ?)
+`
+
+type InsertNotificationParams struct {
+	Service     string
+	Subject     string
+	Message     string
+	NextAttempt int64
+}
+
+func (q *Queries) InsertNotification(ctx context.Context, arg InsertNotificationParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, insertNotification,
+		arg.Service,
+		arg.Subject,
+		arg.Message,
+		arg.NextAttempt,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+const getDueNotifications = `-- name: GetDueNotifications :many
+SELECT id, service, subject, message, status, attempts, next_attempt, last_error, created
+FROM notification_outbox
+WHERE status = 'pending'
+  AND next_attempt <= ?
+ORDER BY next_attempt, id
+LIMIT ?
+`
+
+type GetDueNotificationsParams struct {
+	NextAttempt int64
+	Limit       int64
+}
+
+func (q *Queries) GetDueNotifications(ctx context.Context, arg GetDueNotificationsParams) ([]NotificationOutbox, error) {
+	rows, err := q.db.QueryContext(ctx, getDueNotifications, arg.NextAttempt, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []NotificationOutbox
+	for rows.Next() {
+		var i NotificationOutbox
+		if err := rows.Scan(
+			&i.ID,
+			&i.Service,
+			&i.Subject,
+			&i.Message,
+			&i.Status,
+			&i.Attempts,
+			&i.NextAttempt,
+			&i.LastError,
+			&i.Created,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const deleteNotification = `-- name: DeleteNotification :exec
+DELETE
+FROM notification_outbox
+WHERE id = ?
+`
+
+func (q *Queries) DeleteNotification(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, deleteNotification, id)
+	return err
+}
+
+const retryNotification = `-- name: RetryNotification :exec
+UPDATE notification_outbox
+SET attempts     = ?,
+    next_attempt = ?,
+    last_error   = ?
+WHERE id = ?
+`
+
+type RetryNotificationParams struct {
+	Attempts    int64
+	NextAttempt int64
+	LastError   string
+	ID          int64
+}
+
+func (q *Queries) RetryNotification(ctx context.Context, arg RetryNotificationParams) error {
+	_, err := q.db.ExecContext(ctx, retryNotification,
+		arg.Attempts,
+		arg.NextAttempt,
+		arg.LastError,
+		arg.ID,
+	)
+	return err
+}
+
+const deadLetterNotification = `-- name: DeadLetterNotification :exec
+UPDATE notification_outbox
+SET status     = 'dead',
+    attempts   = ?,
+    last_error = ?
+WHERE id = ?
+`
+
+type DeadLetterNotificationParams struct {
+	Attempts  int64
+	LastError string
+	ID        int64
+}
+
+func (q *Queries) DeadLetterNotification(ctx context.Context, arg DeadLetterNotificationParams) error {
+	_, err := q.db.ExecContext(ctx, deadLetterNotification, arg.Attempts, arg.LastError, arg.ID)
+	return err
+}
+
+const insertMail = `-- name: InsertMail :execlastid
+INSERT INTO mail_outbox(subject, message, next_attempt)
+VALUES (?, ?, ?)
+`
+
+type InsertMailParams struct {
+	Subject     string
+	Message     string
+	NextAttempt int64
+}
+
+func (q *Queries) InsertMail(ctx context.Context, arg InsertMailParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, insertMail, arg.Subject, arg.Message, arg.NextAttempt)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+const getDueMails = `-- name: GetDueMails :many
+SELECT id, subject, message, status, attempts, next_attempt, last_error, created
+FROM mail_outbox
+WHERE status = 'pending'
+  AND next_attempt <= ?
+ORDER BY next_attempt, id
+LIMIT ?
+`
+
+type GetDueMailsParams struct {
+	NextAttempt int64
+	Limit       int64
+}
+
+func (q *Queries) GetDueMails(ctx context.Context, arg GetDueMailsParams) ([]MailOutbox, error) {
+	rows, err := q.db.QueryContext(ctx, getDueMails, arg.NextAttempt, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []MailOutbox
+	for rows.Next() {
+		var i MailOutbox
+		if err := rows.Scan(
+			&i.ID,
+			&i.Subject,
+			&i.Message,
+			&i.Status,
+			&i.Attempts,
+			&i.NextAttempt,
+			&i.LastError,
+			&i.Created,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const deleteMail = `-- name: DeleteMail :exec
+DELETE
+FROM mail_outbox
+WHERE id = ?
+`
+
+func (q *Queries) DeleteMail(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, deleteMail, id)
+	return err
+}
+
+const retryMail = `-- name: RetryMail :exec
+UPDATE mail_outbox
+SET attempts     = ?,
+    next_attempt = ?,
+    last_error   = ?
+WHERE id = ?
+`
+
+type RetryMailParams struct {
+	Attempts    int64
+	NextAttempt int64
+	LastError   string
+	ID          int64
+}
+
+func (q *Queries) RetryMail(ctx context.Context, arg RetryMailParams) error {
+	_, err := q.db.ExecContext(ctx, retryMail,
+		arg.Attempts,
+		arg.NextAttempt,
+		arg.LastError,
+		arg.ID,
+	)
+	return err
+}
+
+const failMail = `-- name: FailMail :exec
+UPDATE mail_outbox
+SET status     = 'failed',
+    attempts   = ?,
+    last_error = ?
+WHERE id = ?
+`
+
+type FailMailParams struct {
+	Attempts  int64
+	LastError string
+	ID        int64
+}
+
+func (q *Queries) FailMail(ctx context.Context, arg FailMailParams) error {
+	_, err := q.db.ExecContext(ctx, failMail, arg.Attempts, arg.LastError, arg.ID)
+	return err
+}
+
+const countMailsByStatus = `-- name: CountMailsByStatus :many
+SELECT status, COUNT(*) AS count
+FROM mail_outbox
+GROUP BY status
+`
+
+type CountMailsByStatusRow struct {
+	Status string
+	Count  int64
+}
+
+func (q *Queries) CountMailsByStatus(ctx context.Context) ([]CountMailsByStatusRow, error) {
+	rows, err := q.db.QueryContext(ctx, countMailsByStatus)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []CountMailsByStatusRow
+	for rows.Next() {
+		var i CountMailsByStatusRow
+		if err := rows.Scan(&i.Status, &i.Count); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getSession = `-- name: GetSession :one
+SELECT id, data, expires
+FROM sessions
+WHERE id = ?
+  AND expires > ?
+`
+
+type GetSessionParams struct {
+	ID      string
+	Expires int64
+}
+
+func (q *Queries) GetSession(ctx context.Context, arg GetSessionParams) (Session, error) {
+	row := q.db.QueryRowContext(ctx, getSession, arg.ID, arg.Expires)
+	var i Session
+	err := row.Scan(&i.ID, &i.Data, &i.Expires)
+	return i, err
+}
+
+const setSession = `-- name: SetSession :exec
+INSERT INTO sessions(id, data, expires)
+VALUES (?, ?, ?)
+ON CONFLICT(id) DO UPDATE SET data    = excluded.data,
+                              expires = excluded.expires
+`
+
+type SetSessionParams struct {
+	ID      string
+	Data    []byte
+	Expires int64
+}
+
+func (q *Queries) SetSession(ctx context.Context, arg SetSessionParams) error {
+	_, err := q.db.ExecContext(ctx, setSession, arg.ID, arg.Data, arg.Expires)
+	return err
+}
+
+const deleteSession = `-- name: DeleteSession :exec
+DELETE
+FROM sessions
+WHERE id = ?
+`
+
+func (q *Queries) DeleteSession(ctx context.Context, id string) error {
+	_, err := q.db.ExecContext(ctx, deleteSession, id)
+	return err
+}
+
+const deleteExpiredSessions = `-- name: DeleteExpiredSessions :execrows
+DELETE
+FROM sessions
+WHERE expires <= ?
+`
+
+func (q *Queries) DeleteExpiredSessions(ctx context.Context, expires int64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteExpiredSessions, expires)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const createUser = `-- name: CreateUser :execlastid
+INSERT INTO users(username, password_hash, role)
+VALUES (?, ?, ?)
+`
+
+type CreateUserParams struct {
+	Username     string
+	PasswordHash string
+	Role         string
+}
+
+func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, createUser, arg.Username, arg.PasswordHash, arg.Role)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+const getUser = `-- name: GetUser :one
+SELECT id, username, password_hash, role, failed_attempts, locked_until, last_login, created
+FROM users
+WHERE id = ?
+`
+
+func (q *Queries) GetUser(ctx context.Context, id int64) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUser, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.PasswordHash,
+		&i.Role,
+		&i.FailedAttempts,
+		&i.LockedUntil,
+		&i.LastLogin,
+		&i.Created,
+	)
+	return i, err
+}
+
+const getUserByUsername = `-- name: GetUserByUsername :one
+SELECT id, username, password_hash, role, failed_attempts, locked_until, last_login, created
+FROM users
+WHERE username = ?
+`
+
+func (q *Queries) GetUserByUsername(ctx context.Context, username string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByUsername, username)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.PasswordHash,
+		&i.Role,
+		&i.FailedAttempts,
+		&i.LockedUntil,
+		&i.LastLogin,
+		&i.Created,
+	)
+	return i, err
+}
+
+const setUserPassword = `-- name: SetUserPassword :exec
+UPDATE users
+SET password_hash   = ?,
+    failed_attempts = 0,
+    locked_until    = 0
+WHERE id = ?
+`
+
+type SetUserPasswordParams struct {
+	PasswordHash string
+	ID           int64
+}
+
+func (q *Queries) SetUserPassword(ctx context.Context, arg SetUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, setUserPassword, arg.PasswordHash, arg.ID)
+	return err
+}
+
+const recordLoginAttempt = `-- name: RecordLoginAttempt :one
+UPDATE users
+SET failed_attempts = failed_attempts + 1
+WHERE id = ?
+  AND locked_until <= ?
+RETURNING failed_attempts
+`
+
+type RecordLoginAttemptParams struct {
+	ID          int64
+	LockedUntil int64
+}
+
+func (q *Queries) RecordLoginAttempt(ctx context.Context, arg RecordLoginAttemptParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, recordLoginAttempt, arg.ID, arg.LockedUntil)
+	var failed_attempts int64
+	err := row.Scan(&failed_attempts)
+	return failed_attempts, err
+}
+
+const lockUser = `-- name: LockUser :exec
+UPDATE users
+SET failed_attempts = 0,
+    locked_until    = ?
+WHERE id = ?
+`
+
+type LockUserParams struct {
+	LockedUntil int64
+	ID          int64
+}
+
+func (q *Queries) LockUser(ctx context.Context, arg LockUserParams) error {
+	_, err := q.db.ExecContext(ctx, lockUser, arg.LockedUntil, arg.ID)
+	return err
+}
+
+const recordLoginSuccess = `-- name: RecordLoginSuccess :exec
+UPDATE users
+SET failed_attempts = 0,
+    locked_until    = 0,
+    last_login      = ?
+WHERE id = ?
+`
+
+type RecordLoginSuccessParams struct {
+	LastLogin int64
+	ID        int64
+}
+
+func (q *Queries) RecordLoginSuccess(ctx context.Context, arg RecordLoginSuccessParams) error {
+	_, err := q.db.ExecContext(ctx, recordLoginSuccess, arg.LastLogin, arg.ID)
+	return err
+}
+
+const createAPIToken = `-- name: CreateAPIToken :execlastid
+INSERT INTO api_tokens(name, prefix, hash, scopes, created, expires)
+VALUES (?, ?, ?, ?, ?, ?)
+`
+
+type CreateAPITokenParams struct {
+	Name    string
+	Prefix  string
+	Hash    string
+	Scopes  string
+	Created int64
+	Expires int64
+}
+
+func (q *Queries) CreateAPIToken(ctx context.Context, arg CreateAPITokenParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, createAPIToken,
+		arg.Name,
+		arg.Prefix,
+		arg.Hash,
+		arg.Scopes,
+		arg.Created,
+		arg.Expires,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+const getAPITokenByHash = `-- name: GetAPITokenByHash :one
+SELECT id, name, prefix, hash, scopes, created, expires, last_used
+FROM api_tokens
+WHERE hash = ?
+`
+
+func (q *Queries) GetAPITokenByHash(ctx context.Context, hash string) (ApiToken, error) {
+	row := q.db.QueryRowContext(ctx, getAPITokenByHash, hash)
+	var i ApiToken
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Prefix,
+		&i.Hash,
+		&i.Scopes,
+		&i.Created,
+		&i.Expires,
+		&i.LastUsed,
+	)
+	return i, err
+}
+
+const listAPITokens = `-- name: ListAPITokens :many
+SELECT id, name, prefix, hash, scopes, created, expires, last_used
+FROM api_tokens
+ORDER BY id
+`
+
+func (q *Queries) ListAPITokens(ctx context.Context) ([]ApiToken, error) {
+	rows, err := q.db.QueryContext(ctx, listAPITokens)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ApiToken
+	for rows.Next() {
+		var i ApiToken
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Prefix,
+			&i.Hash,
+			&i.Scopes,
+			&i.Created,
+			&i.Expires,
+			&i.LastUsed,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const deleteAPIToken = `-- name: DeleteAPIToken :execrows
+DELETE
+FROM api_tokens
+WHERE id = ?
+`
+
+func (q *Queries) DeleteAPIToken(ctx context.Context, id int64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteAPIToken, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const touchAPIToken = `-- name: TouchAPIToken :exec
+UPDATE api_tokens
+SET last_used = ?
+WHERE id = ?
+`
+
+type TouchAPITokenParams struct {
+	LastUsed int64
+	ID       int64
+}
+
+func (q *Queries) TouchAPIToken(ctx context.Context, arg TouchAPITokenParams) error {
+	_, err := q.db.ExecContext(ctx, touchAPIToken, arg.LastUsed, arg.ID)
+	return err
+}
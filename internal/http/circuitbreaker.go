@@ -0,0 +1,123 @@
+package http
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/firefart/go-webserver-template/internal/metrics"
+)
+
+// ErrCircuitOpen is returned if requests to a host are rejected because the
+// host failed too often
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitHalfOpen
+	circuitOpen
+)
+
+type circuit struct {
+	state    circuitState
+	failures int
+	openedAt time.Time
+	// probing is set while a request in the half-open state is in flight
+	probing bool
+}
+
+// circuitBreaker tracks the health of every target host. After
+// failureThreshold consecutive failures the circuit opens and requests fail
+// fast. After openTimeout a single probe request is let through, if it
+// succeeds the circuit is closed again.
+type circuitBreaker struct {
+	mu               sync.Mutex
+	circuits         map[string]*circuit
+	failureThreshold int
+	openTimeout      time.Duration
+	metrics          *metrics.Metrics
+	now              func() time.Time
+}
+
+func newCircuitBreaker(failureThreshold int, openTimeout time.Duration, m *metrics.Metrics) *circuitBreaker {
+	return &circuitBreaker{
+		circuits:         make(map[string]*circuit),
+		failureThreshold: failureThreshold,
+		openTimeout:      openTimeout,
+		metrics:          m,
+		now:              time.Now,
+	}
+}
+
+func (cb *circuitBreaker) get(host string) *circuit {
+	c, ok := cb.circuits[host]
+	if !ok {
+		c = &circuit{}
+		cb.circuits[host] = c
+	}
+	return c
+}
+
+func (cb *circuitBreaker) setState(host string, c *circuit, state circuitState) {
+	c.state = state
+	if cb.metrics != nil {
+		cb.metrics.HTTPClientCircuitBreaker.WithLabelValues(host).Set(float64(state))
+	}
+}
+
+// allow returns an error if the request to the host must not be sent
+func (cb *circuitBreaker) allow(host string) error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	c := cb.get(host)
+	switch c.state {
+	case circuitClosed:
+		return nil
+	case circuitOpen:
+		if cb.now().Sub(c.openedAt) < cb.openTimeout {
+			break
+		}
+		cb.setState(host, c, circuitHalfOpen)
+		c.probing = true
+		return nil
+	case circuitHalfOpen:
+		if !c.probing {
+			c.probing = true
+			return nil
+		}
+	}
+	if cb.metrics != nil {
+		cb.metrics.HTTPClientCircuitRejected.WithLabelValues(host).Inc()
+	}
+	return ErrCircuitOpen
+}
+
+// record updates the circuit with the result of a request
+func (cb *circuitBreaker) record(host string, failed bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	c := cb.get(host)
+	c.probing = false
+	if !failed {
+		c.failures = 0
+		if c.state != circuitClosed {
+			cb.setState(host, c, circuitClosed)
+		}
+		return
+	}
+
+	c.failures++
+	if c.state == circuitHalfOpen || c.failures >= cb.failureThreshold {
+		c.openedAt = cb.now()
+		cb.setState(host, c, circuitOpen)
+	}
+}
+
+// release frees the probe slot without changing the state of the circuit
+func (cb *circuitBreaker) release(host string) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.get(host).probing = false
+}
@@ -0,0 +1,56 @@
+package http
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Now()
+	cb := newCircuitBreaker(3, 10*time.Second, nil)
+	cb.now = func() time.Time { return now }
+
+	// failures on other hosts do not matter
+	cb.record("other", true)
+	cb.record("other", true)
+	cb.record("other", true)
+
+	for range 2 {
+		require.NoError(t, cb.allow("host"))
+		cb.record("host", true)
+	}
+	// a success resets the counter
+	require.NoError(t, cb.allow("host"))
+	cb.record("host", false)
+
+	for range 3 {
+		require.NoError(t, cb.allow("host"))
+		cb.record("host", true)
+	}
+	require.ErrorIs(t, cb.allow("host"), ErrCircuitOpen)
+	require.ErrorIs(t, cb.allow("other"), ErrCircuitOpen)
+
+	// after the timeout only a single probe is allowed
+	now = now.Add(11 * time.Second)
+	require.NoError(t, cb.allow("host"))
+	require.ErrorIs(t, cb.allow("host"), ErrCircuitOpen)
+
+	// failed probe opens the circuit again
+	cb.record("host", true)
+	require.ErrorIs(t, cb.allow("host"), ErrCircuitOpen)
+
+	now = now.Add(11 * time.Second)
+	require.NoError(t, cb.allow("host"))
+	cb.record("host", false)
+	require.NoError(t, cb.allow("host"))
+	require.NoError(t, cb.allow("host"))
+
+	// released probes do not change the state
+	now = now.Add(11 * time.Second)
+	require.NoError(t, cb.allow("other"))
+	cb.release("other")
+	require.NoError(t, cb.allow("other"))
+	require.ErrorIs(t, cb.allow("other"), ErrCircuitOpen)
+}
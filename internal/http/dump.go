@@ -0,0 +1,173 @@
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"regexp"
+	"strings"
+
+	"github.com/firefart/go-webserver-template/internal/config"
+)
+
+const (
+	redactedPlaceholder = "[REDACTED]"
+	// dumpLookahead is read in addition to the max body size so redaction
+	// patterns can match values crossing the limit
+	dumpLookahead = 1024
+)
+
+// redactor removes secrets from the request and response dumps written in
+// debug mode
+type redactor struct {
+	headers     map[string]struct{}
+	patterns    []*regexp.Regexp
+	maxBodySize int
+}
+
+func newRedactor(c config.HTTPClientDump) (*redactor, error) {
+	r := redactor{
+		headers:     make(map[string]struct{}, len(c.RedactHeaders)),
+		maxBodySize: c.MaxBodySize,
+	}
+	for _, h := range c.RedactHeaders {
+		r.headers[http.CanonicalHeaderKey(h)] = struct{}{}
+	}
+	for _, p := range c.RedactBodyPatterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid body redaction pattern %q: %w", p, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return &r, nil
+}
+
+func (r *redactor) redactHeader(h http.Header) http.Header {
+	clone := h.Clone()
+	for k, v := range clone {
+		if _, ok := r.headers[http.CanonicalHeaderKey(k)]; ok {
+			for i := range v {
+				v[i] = redactedPlaceholder
+			}
+		}
+	}
+	return clone
+}
+
+func (r *redactor) redactBody(body []byte) []byte {
+	for _, re := range r.patterns {
+		body = re.ReplaceAllFunc(body, func(match []byte) []byte {
+			sub := re.FindSubmatchIndex(match)
+			// no capture group, replace everything
+			if len(sub) < 4 || sub[2] < 0 {
+				return []byte(redactedPlaceholder)
+			}
+			var b bytes.Buffer
+			b.Write(match[:sub[2]])
+			b.WriteString(redactedPlaceholder)
+			b.Write(match[sub[3]:])
+			return b.Bytes()
+		})
+	}
+	return body
+}
+
+// formatBody redacts and truncates the body preview. Redaction happens before
+// truncating so secrets crossing the size limit are still caught as long as
+// they end within the lookahead.
+func (r *redactor) formatBody(body []byte) string {
+	truncated := len(body) > r.maxBodySize
+	body = r.redactBody(body)
+	if len(body) > r.maxBodySize {
+		body = body[:r.maxBodySize]
+	}
+	var b strings.Builder
+	b.Write(body)
+	if truncated {
+		fmt.Fprintf(&b, "\n[truncated to %d bytes]", r.maxBodySize)
+	}
+	return b.String()
+}
+
+// previewSize is the number of bytes read for a dump
+func (r *redactor) previewSize() int64 {
+	return int64(r.maxBodySize) + dumpLookahead
+}
+
+// dumpRequest dumps the request with redacted headers. The body is only
+// included if it can be read without consuming the original body.
+func (r *redactor) dumpRequest(req *http.Request) (string, error) {
+	clone := req.Clone(req.Context())
+	clone.Header = r.redactHeader(req.Header)
+	// credentials in the url are sent as basic auth header
+	if clone.URL.User != nil {
+		clone.URL.User = nil
+		clone.Header.Set("Authorization", redactedPlaceholder)
+	}
+	// DumpRequestOut replaces non empty bodies with a placeholder of the same
+	// length, so the original body is never consumed
+	if clone.Body != nil && clone.Body != http.NoBody {
+		clone.Body = io.NopCloser(strings.NewReader(""))
+	}
+	clone.GetBody = nil
+	dump, err := httputil.DumpRequestOut(clone, false)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.Write(dump)
+	switch {
+	case req.Body == nil || req.Body == http.NoBody || r.maxBodySize == 0:
+	case req.GetBody == nil:
+		b.WriteString("[body not rewindable, omitted]")
+	default:
+		body, err := req.GetBody()
+		if err != nil {
+			return "", err
+		}
+		defer body.Close()
+		preview, err := io.ReadAll(io.LimitReader(body, r.previewSize()))
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(r.formatBody(preview))
+	}
+	return b.String(), nil
+}
+
+// dumpResponse dumps the response with redacted headers. Only the first bytes
+// of the body are read, the body stays readable for the caller.
+func (r *redactor) dumpResponse(resp *http.Response) (string, error) {
+	clone := *resp
+	clone.Header = r.redactHeader(resp.Header)
+	clone.Body = http.NoBody
+	dump, err := httputil.DumpResponse(&clone, false)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.Write(dump)
+	if resp.Body != nil && resp.Body != http.NoBody && r.maxBodySize > 0 {
+		preview, err := io.ReadAll(io.LimitReader(resp.Body, r.previewSize()))
+		// put the read bytes back in front of the body
+		resp.Body = &multiReadCloser{
+			Reader: io.MultiReader(bytes.NewReader(preview), resp.Body),
+			Closer: resp.Body,
+		}
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(r.formatBody(preview))
+	}
+	return b.String(), nil
+}
+
+type multiReadCloser struct {
+	io.Reader
+	io.Closer
+}
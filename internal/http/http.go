@@ -1,31 +1,42 @@
 package http
 
 import (
-	"crypto/tls"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
-	"net/http/httputil"
+	"strconv"
+	"time"
 
 	"github.com/firefart/go-webserver-template/internal/config"
+	"github.com/firefart/go-webserver-template/internal/metrics"
 )
 
 type Client struct {
-	userAgent string
-	client    *http.Client
-	debug     bool
-	logger    *slog.Logger
+	userAgent      string
+	client         *http.Client
+	debug          bool
+	logger         *slog.Logger
+	metrics        *metrics.Metrics
+	retryPolicy    RetryPolicy
+	circuitBreaker *circuitBreaker
+	redactor       *redactor
+	tlsTransport   *tlsTransport
+	limiters       *hostLimiters
+	// maxResponseSize limits the body size read by the JSON helpers
+	maxResponseSize int64
 }
 
-func NewHTTPClient(config config.Configuration, logger *slog.Logger, debugMode bool) (*Client, error) {
+func NewHTTPClient(config config.Configuration, logger *slog.Logger, m *metrics.Metrics, debugMode bool) (*Client, error) {
 	// clone default transport to avoid mutating global state
 	defaultTransport, ok := http.DefaultTransport.(*http.Transport)
 	if !ok {
 		return nil, errors.New("failed to cast default transport to http.Transport")
 	}
 	tr := defaultTransport.Clone()
-	if config.Proxy != nil && config.Proxy.URL != "" {
+	if config.Proxy != nil && (config.Proxy.URL != "" || config.Proxy.HTTPURL != "" || config.Proxy.HTTPSURL != "" || config.Proxy.PACFile != "") {
 		proxy, err := newProxy(*config.Proxy)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create proxy: %w", err)
@@ -33,57 +44,208 @@ func NewHTTPClient(config config.Configuration, logger *slog.Logger, debugMode b
 		tr.Proxy = proxy.ProxyFromConfig
 	}
 
-	// add additional certs
-	if config.CertDir != "" {
-		rootCAs, err := getCertificateChain(config.CertDir)
-		if err != nil {
-			return nil, fmt.Errorf("could not get root cas: %w", err)
-		}
-		if tr.TLSClientConfig == nil {
-			tr.TLSClientConfig = &tls.Config{} //nolint:gosec
-		}
-		tr.TLSClientConfig.RootCAs = rootCAs
+	// add additional certs and the per host tls profiles
+	tlsTr, err := newTLSTransport(tr, config.CertDir, config.HTTPClient.TLSProfiles)
+	if err != nil {
+		return nil, err
 	}
 
 	httpClient := http.Client{
 		Timeout:   config.Timeout,
-		Transport: tr,
+		Transport: tlsTr,
 	}
+
+	redactor, err := newRedactor(config.HTTPClient.Dump)
+	if err != nil {
+		return nil, err
+	}
+
+	var cb *circuitBreaker
+	if config.HTTPClient.CircuitBreaker.Enabled {
+		cb = newCircuitBreaker(config.HTTPClient.CircuitBreaker.FailureThreshold, config.HTTPClient.CircuitBreaker.OpenTimeout, m)
+	}
+
 	return &Client{
-		userAgent: config.UserAgent,
-		client:    &httpClient,
-		debug:     debugMode,
-		logger:    logger,
+		userAgent:       config.UserAgent,
+		client:          &httpClient,
+		debug:           debugMode,
+		logger:          logger,
+		metrics:         m,
+		retryPolicy:     retryPolicyFromConfig(config.HTTPClient.Retry),
+		circuitBreaker:  cb,
+		redactor:        redactor,
+		tlsTransport:    tlsTr,
+		limiters:        newHostLimiters(config.HTTPClient.RateLimits, m),
+		maxResponseSize: config.HTTPClient.MaxResponseSize,
 	}, nil
 }
 
+// WithLogger returns a copy of the client logging to logger. The copy shares
+// the transport, circuit breaker and rate limits with the original client.
+func (c *Client) WithLogger(logger *slog.Logger) *Client {
+	clone := *c
+	clone.logger = logger
+	return &clone
+}
+
+// WatchCertDir reloads the certificates when the content of the cert dir
+// changes. It blocks until the context is done and returns immediately if no
+// cert dir is configured.
+func (c *Client) WatchCertDir(ctx context.Context) error {
+	if c.tlsTransport.certDir == "" {
+		return nil
+	}
+	return c.tlsTransport.watchCertDir(ctx, c.logger)
+}
+
+// Do sends the request and retries it according to the retry policy. The
+// policy of the client can be overridden per request with WithRetryPolicy.
+// Requests with a body are only retried if GetBody is set, which is the case
+// for requests created by http.NewRequest with a bytes or strings reader.
 func (c *Client) Do(req *http.Request) (*http.Response, error) {
 	if c.userAgent != "" {
 		req.Header.Set("User-Agent", c.userAgent)
 	}
 
+	policy := c.retryPolicy
+	if p, ok := retryPolicyFromContext(req.Context()); ok {
+		policy = p
+	}
+	maxAttempts := 1
+	if policy.canRetry(req) {
+		maxAttempts = policy.MaxAttempts
+	}
+
+	host := req.URL.Host
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("could not rewind request body: %w", err)
+			}
+			req.Body = body
+		}
+
+		resp, err := c.attempt(req)
+		if attempt >= maxAttempts || !policy.shouldRetry(req.Context(), resp, err) {
+			return resp, err
+		}
+
+		wait := policy.backoff(attempt, resp)
+		if resp != nil {
+			// drain the body so the connection can be reused
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			_ = resp.Body.Close()
+		}
+		attrs := []any{
+			slog.String("method", req.Method),
+			slog.String("host", host),
+			slog.Int("attempt", attempt),
+			slog.Duration("wait", wait),
+		}
+		if err != nil {
+			attrs = append(attrs, slog.String("err", err.Error()))
+		} else {
+			attrs = append(attrs, slog.Int("status", resp.StatusCode))
+		}
+		c.logger.Warn("retrying http request", attrs...)
+		if c.metrics != nil {
+			c.metrics.HTTPClientRetries.WithLabelValues(host, req.Method).Inc()
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// attempt sends the request exactly once and updates the circuit breaker.
+// Depending on the policy it waits for the rate and concurrency limits of the
+// host or fails immediately.
+func (c *Client) attempt(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	release, err := c.limiters.acquire(req.Context(), host, req.URL.Hostname())
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.send(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	releaseOnClose(resp, release)
+	return resp, nil
+}
+
+func (c *Client) send(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	if c.circuitBreaker != nil {
+		if err := c.circuitBreaker.allow(host); err != nil {
+			return nil, fmt.Errorf("%s: %w", host, err)
+		}
+	}
+
 	if c.debug {
-		reqDump, err := httputil.DumpRequestOut(req, true)
+		reqDump, err := c.redactor.dumpRequest(req)
 		if err != nil {
-			c.logger.Error("error on DumpRequestOut", slog.String("err", err.Error()))
+			c.logger.Error("error on dumping request", slog.String("err", err.Error()))
 		} else {
-			c.logger.Debug("sending http request", slog.String("req", string(reqDump)))
+			c.logger.Debug("sending http request", slog.String("req", reqDump))
 		}
 	}
 
+	if c.metrics != nil {
+		req = withPhaseTrace(req, c.metrics)
+	}
+	start := time.Now()
 	resp, err := c.client.Do(req) // nolint: gosec
+	duration := time.Since(start)
+	if c.circuitBreaker != nil {
+		if err != nil && req.Context().Err() != nil {
+			// canceled requests say nothing about the health of the host
+			c.circuitBreaker.release(host)
+		} else {
+			c.circuitBreaker.record(host, isHostFailure(resp, err))
+		}
+	}
+	if c.metrics != nil {
+		code := "error"
+		if err == nil {
+			code = strconv.Itoa(resp.StatusCode)
+		}
+		c.metrics.HTTPClientRequests.WithLabelValues(host, req.Method, code).Inc()
+		c.metrics.HTTPClientDuration.WithLabelValues(host, req.Method, code).Observe(duration.Seconds())
+	}
 	if err != nil {
 		return nil, err
 	}
 
 	if c.debug {
-		respDump, err := httputil.DumpResponse(resp, true)
+		respDump, err := c.redactor.dumpResponse(resp)
 		if err != nil {
-			c.logger.Error("error on DumpResponse", slog.String("err", err.Error()))
+			c.logger.Error("error on dumping response", slog.String("err", err.Error()))
 		} else {
-			c.logger.Debug("got http response", slog.String("resp", string(respDump)))
+			c.logger.Debug("got http response", slog.String("resp", respDump))
 		}
 	}
 
 	return resp, nil
 }
+
+// isHostFailure reports if the result indicates that the host is down. Other
+// server errors are application errors and do not trip the circuit breaker.
+func isHostFailure(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,281 @@
+package http
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/firefart/go-webserver-template/internal/config"
+	"github.com/firefart/go-webserver-template/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestConfig() config.Configuration {
+	return config.Configuration{
+		Timeout:   5 * time.Second,
+		UserAgent: "test-agent",
+		HTTPClient: config.HTTPClient{
+			MaxResponseSize: 1 << 20,
+			Retry: config.HTTPClientRetry{
+				MaxAttempts:    3,
+				InitialBackoff: 1 * time.Millisecond,
+				MaxBackoff:     5 * time.Millisecond,
+				Multiplier:     2,
+				MaxRetryAfter:  10 * time.Millisecond,
+			},
+			CircuitBreaker: config.HTTPClientCircuitBreaker{
+				Enabled:          true,
+				FailureThreshold: 2,
+				OpenTimeout:      1 * time.Hour,
+			},
+		},
+	}
+}
+
+func newTestClient(t *testing.T, c config.Configuration) (*Client, *metrics.Metrics) {
+	t.Helper()
+	m, err := metrics.NewMetrics(prometheus.NewRegistry())
+	require.NoError(t, err)
+	client, err := NewHTTPClient(c, slog.New(slog.DiscardHandler), m, false)
+	require.NoError(t, err)
+	return client, m
+}
+
+func metricValue(t *testing.T, c prometheus.Metric) float64 {
+	t.Helper()
+	var m dto.Metric
+	require.NoError(t, c.Write(&m))
+	switch {
+	case m.GetCounter() != nil:
+		return m.GetCounter().GetValue()
+	case m.GetGauge() != nil:
+		return m.GetGauge().GetValue()
+	default:
+		t.Fatal("unsupported metric type")
+		return 0
+	}
+}
+
+func TestClientDo(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "test-agent", r.UserAgent())
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	client, _ := newTestClient(t, newTestConfig())
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, ts.URL, nil)
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestClientRetries(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name             string
+		method           string
+		statusCodes      []int
+		body             string
+		expectedAttempts int32
+		expectedStatus   int
+	}{
+		{
+			name:             "retries get on 503",
+			method:           http.MethodGet,
+			statusCodes:      []int{http.StatusServiceUnavailable, http.StatusOK},
+			expectedAttempts: 2,
+			expectedStatus:   http.StatusOK,
+		},
+		{
+			name:             "gives up after max attempts",
+			method:           http.MethodGet,
+			statusCodes:      []int{http.StatusTooManyRequests, http.StatusTooManyRequests, http.StatusTooManyRequests, http.StatusOK},
+			expectedAttempts: 3,
+			expectedStatus:   http.StatusTooManyRequests,
+		},
+		{
+			name:             "does not retry post",
+			method:           http.MethodPost,
+			statusCodes:      []int{http.StatusServiceUnavailable, http.StatusOK},
+			body:             "body",
+			expectedAttempts: 1,
+			expectedStatus:   http.StatusServiceUnavailable,
+		},
+		{
+			name:             "rewinds put body",
+			method:           http.MethodPut,
+			statusCodes:      []int{http.StatusBadGateway, http.StatusGatewayTimeout, http.StatusOK},
+			body:             "body",
+			expectedAttempts: 3,
+			expectedStatus:   http.StatusOK,
+		},
+		{
+			name:             "does not retry other errors",
+			method:           http.MethodGet,
+			statusCodes:      []int{http.StatusInternalServerError, http.StatusOK},
+			expectedAttempts: 1,
+			expectedStatus:   http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var attempts atomic.Int32
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				i := attempts.Add(1)
+				body, err := io.ReadAll(r.Body)
+				require.NoError(t, err)
+				require.Equal(t, tt.body, string(body))
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(tt.statusCodes[i-1])
+			}))
+			defer ts.Close()
+
+			c := newTestConfig()
+			c.HTTPClient.CircuitBreaker.Enabled = false
+			client, m := newTestClient(t, c)
+			var body io.Reader
+			if tt.body != "" {
+				body = strings.NewReader(tt.body)
+			}
+			req, err := http.NewRequestWithContext(t.Context(), tt.method, ts.URL, body)
+			require.NoError(t, err)
+			resp, err := client.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, tt.expectedStatus, resp.StatusCode)
+			require.Equal(t, tt.expectedAttempts, attempts.Load())
+			require.InDelta(t, float64(tt.expectedAttempts-1), metricValue(t, m.HTTPClientRetries.WithLabelValues(req.URL.Host, tt.method)), 0)
+		})
+	}
+}
+
+func TestClientRetryPolicyOverride(t *testing.T) {
+	t.Parallel()
+
+	var attempts atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	c := newTestConfig()
+	c.HTTPClient.CircuitBreaker.Enabled = false
+	client, _ := newTestClient(t, c)
+	req, err := http.NewRequestWithContext(WithRetryPolicy(t.Context(), NoRetry), http.MethodGet, ts.URL, nil)
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, int32(1), attempts.Load())
+}
+
+func TestClientRetryCanceled(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Retry-After", "10")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	c := newTestConfig()
+	c.HTTPClient.Retry.MaxRetryAfter = 1 * time.Hour
+	client, _ := newTestClient(t, c)
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL, nil)
+	require.NoError(t, err)
+	_, err = client.Do(req) // nolint: bodyclose
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestClientCircuitBreaker(t *testing.T) {
+	t.Parallel()
+
+	var attempts atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	c := newTestConfig()
+	c.HTTPClient.Retry.MaxAttempts = 1
+	client, m := newTestClient(t, c)
+
+	for range 2 {
+		req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, ts.URL, nil)
+		require.NoError(t, err)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, ts.URL, nil)
+	require.NoError(t, err)
+	_, err = client.Do(req) // nolint: bodyclose
+	require.ErrorIs(t, err, ErrCircuitOpen)
+	require.Equal(t, int32(2), attempts.Load())
+	require.InDelta(t, float64(circuitOpen), metricValue(t, m.HTTPClientCircuitBreaker.WithLabelValues(req.URL.Host)), 0)
+	require.InDelta(t, 1, metricValue(t, m.HTTPClientCircuitRejected.WithLabelValues(req.URL.Host)), 0)
+}
+
+func TestClientMetrics(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer ts.Close()
+
+	client, m := newTestClient(t, newTestConfig())
+	// trust the test server certificate
+	client.client.Transport = ts.Client().Transport
+
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, ts.URL, nil)
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	host := req.URL.Host
+	require.InDelta(t, 1, metricValue(t, m.HTTPClientRequests.WithLabelValues(host, http.MethodGet, "202")), 0)
+
+	for _, phase := range []string{"connect", "tls", "ttfb"} {
+		observer, err := m.HTTPClientPhaseDuration.GetMetricWithLabelValues(host, phase)
+		require.NoError(t, err)
+		histogram, ok := observer.(prometheus.Histogram)
+		require.True(t, ok)
+		var metric dto.Metric
+		require.NoError(t, histogram.Write(&metric))
+		require.Equal(t, uint64(1), metric.GetHistogram().GetSampleCount(), phase)
+	}
+
+	// network errors are recorded with the error code
+	ts.Close()
+	c := newTestConfig()
+	c.HTTPClient.Retry.MaxAttempts = 1
+	client, m = newTestClient(t, c)
+	req, err = http.NewRequestWithContext(t.Context(), http.MethodGet, ts.URL, nil)
+	require.NoError(t, err)
+	_, err = client.Do(req) // nolint: bodyclose
+	require.Error(t, err)
+	require.InDelta(t, 1, metricValue(t, m.HTTPClientRequests.WithLabelValues(host, http.MethodGet, "error")), 0)
+}
@@ -0,0 +1,113 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"unicode/utf8"
+)
+
+// statusErrorBodySize is the maximum number of body bytes kept in a StatusError
+const statusErrorBodySize = 1024
+
+// ErrResponseTooLarge is returned if a response body exceeds the configured
+// max response size
+var ErrResponseTooLarge = errors.New("response body too large")
+
+// StatusError is returned by the JSON helpers if the server responds with a
+// non 2xx status code. Body holds the start of the response body.
+type StatusError struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	Truncated  bool
+}
+
+func (e *StatusError) Error() string {
+	body := string(bytes.ToValidUTF8(e.Body, []byte(string(utf8.RuneError))))
+	if e.Truncated {
+		body += "..."
+	}
+	if body == "" {
+		return fmt.Sprintf("unexpected status code %d", e.StatusCode)
+	}
+	return fmt.Sprintf("unexpected status code %d: %s", e.StatusCode, body)
+}
+
+// GetJSON sends a GET request to the url and decodes the JSON response into T
+func GetJSON[T any](ctx context.Context, c *Client, url string) (T, error) {
+	var resp T
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return resp, fmt.Errorf("could not create request: %w", err)
+	}
+	return DoJSON[T](c, req)
+}
+
+// PostJSON sends body encoded as JSON to the url and decodes the JSON
+// response into Resp. POST requests are only retried if an Idempotency-Key
+// header is set, use DoJSON for full control over the request.
+func PostJSON[Req, Resp any](ctx context.Context, c *Client, url string, body Req) (Resp, error) {
+	var resp Resp
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return resp, fmt.Errorf("could not encode request body: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return resp, fmt.Errorf("could not create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return DoJSON[Resp](c, req)
+}
+
+// DoJSON sends the request and decodes the JSON response into T. An empty
+// response body results in the zero value of T.
+func DoJSON[T any](c *Client, req *http.Request) (T, error) {
+	var result T
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", "application/json")
+	}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, err := io.ReadAll(io.LimitReader(resp.Body, statusErrorBodySize+1))
+		if err != nil {
+			return result, fmt.Errorf("could not read response body: %w", err)
+		}
+		statusErr := &StatusError{
+			StatusCode: resp.StatusCode,
+			Header:     resp.Header,
+			Body:       body,
+		}
+		if len(body) > statusErrorBodySize {
+			statusErr.Body = body[:statusErrorBodySize]
+			statusErr.Truncated = true
+		}
+		return result, statusErr
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, c.maxResponseSize+1))
+	if err != nil {
+		return result, fmt.Errorf("could not read response body: %w", err)
+	}
+	if int64(len(body)) > c.maxResponseSize {
+		return result, fmt.Errorf("%w: exceeds %d bytes", ErrResponseTooLarge, c.maxResponseSize)
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return result, nil
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return result, fmt.Errorf("could not decode response body: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,107 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testPayload struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func TestGetJSON(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodGet, r.Method)
+		require.Equal(t, "test-agent", r.UserAgent())
+		require.Equal(t, "application/json", r.Header.Get("Accept"))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"name":"test","count":3}`))
+	}))
+	defer ts.Close()
+
+	client, _ := newTestClient(t, newTestConfig())
+	resp, err := GetJSON[testPayload](t.Context(), client, ts.URL)
+	require.NoError(t, err)
+	require.Equal(t, testPayload{Name: "test", Count: 3}, resp)
+}
+
+func TestPostJSON(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		var req testPayload
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		req.Count++
+		w.Header().Set("Content-Type", "application/json")
+		require.NoError(t, json.NewEncoder(w).Encode(req))
+	}))
+	defer ts.Close()
+
+	client, _ := newTestClient(t, newTestConfig())
+	resp, err := PostJSON[testPayload, testPayload](t.Context(), client, ts.URL, testPayload{Name: "test", Count: 1})
+	require.NoError(t, err)
+	require.Equal(t, testPayload{Name: "test", Count: 2}, resp)
+}
+
+func TestJSONErrors(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/notfound":
+			w.Header().Set("X-Request-Id", "123")
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":"not found"}`))
+		case "/large-error":
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(strings.Repeat("a", 2*statusErrorBodySize)))
+		case "/large":
+			_, _ = w.Write([]byte(`"` + strings.Repeat("a", 100) + `"`))
+		case "/invalid":
+			_, _ = w.Write([]byte(`{`))
+		case "/empty":
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer ts.Close()
+
+	c := newTestConfig()
+	c.HTTPClient.MaxResponseSize = 50
+	client, _ := newTestClient(t, c)
+
+	_, err := GetJSON[testPayload](t.Context(), client, ts.URL+"/notfound")
+	var statusErr *StatusError
+	require.ErrorAs(t, err, &statusErr)
+	require.Equal(t, http.StatusNotFound, statusErr.StatusCode)
+	require.Equal(t, "123", statusErr.Header.Get("X-Request-Id"))
+	require.JSONEq(t, `{"error":"not found"}`, string(statusErr.Body))
+	require.False(t, statusErr.Truncated)
+	require.Equal(t, `unexpected status code 404: {"error":"not found"}`, err.Error())
+
+	_, err = GetJSON[testPayload](t.Context(), client, ts.URL+"/large-error")
+	require.ErrorAs(t, err, &statusErr)
+	require.Len(t, statusErr.Body, statusErrorBodySize)
+	require.True(t, statusErr.Truncated)
+
+	_, err = GetJSON[string](t.Context(), client, ts.URL+"/large")
+	require.ErrorIs(t, err, ErrResponseTooLarge)
+
+	_, err = GetJSON[testPayload](t.Context(), client, ts.URL+"/invalid")
+	require.Error(t, err)
+	require.False(t, errors.As(err, &statusErr))
+
+	resp, err := GetJSON[testPayload](t.Context(), client, ts.URL+"/empty")
+	require.NoError(t, err)
+	require.Equal(t, testPayload{}, resp)
+}
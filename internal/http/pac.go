@@ -0,0 +1,315 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/firefart/go-webserver-template/internal/config"
+)
+
+const (
+	defaultPACCacheTTL = 5 * time.Minute
+	// pacTimeout limits the runtime of a single FindProxyForURL call
+	pacTimeout = 5 * time.Second
+	// pacCacheSize triggers a cleanup of expired cache entries
+	pacCacheSize = 1000
+	// pacDNSTimeout limits lookups of other hosts than the request host which
+	// are done while the javascript runtime is locked
+	pacDNSTimeout = time.Second
+)
+
+// pacProxy evaluates a proxy auto-config file. FindProxyForURL is called with
+// the scheme and host of the request only, so results can be cached per
+// scheme and host. The date and time based helpers are not supported.
+type pacProxy struct {
+	// mu serializes access to the javascript runtime which is not thread safe
+	mu        sync.Mutex
+	vm        *goja.Runtime
+	findProxy goja.Callable
+	// host is the request host of the running evaluation which is resolved
+	// before the runtime is locked
+	host   string
+	hostIP net.IP
+
+	username string
+	password string
+	cacheTTL time.Duration
+	cacheMu  sync.Mutex
+	cache    map[string]pacCacheEntry
+
+	now      func() time.Time
+	lookupIP func(ctx context.Context, host string) ([]net.IP, error)
+}
+
+type pacCacheEntry struct {
+	proxy   *url.URL
+	expires time.Time
+}
+
+func newPACProxy(c config.Proxy) (*pacProxy, error) {
+	script, err := os.ReadFile(c.PACFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not read pac file: %w", err)
+	}
+	return newPACProxyFromScript(string(script), c)
+}
+
+func newPACProxyFromScript(script string, c config.Proxy) (*pacProxy, error) {
+	p := &pacProxy{
+		vm:       goja.New(),
+		username: c.Username,
+		password: c.Password,
+		cacheTTL: c.PACCacheTTL,
+		cache:    make(map[string]pacCacheEntry),
+		now:      time.Now,
+		lookupIP: func(ctx context.Context, host string) ([]net.IP, error) {
+			return net.DefaultResolver.LookupIP(ctx, "ip", host)
+		},
+	}
+	if p.cacheTTL <= 0 {
+		p.cacheTTL = defaultPACCacheTTL
+	}
+
+	if err := p.registerHelpers(); err != nil {
+		return nil, err
+	}
+	if _, err := p.vm.RunString(script); err != nil {
+		return nil, fmt.Errorf("could not evaluate pac file: %w", err)
+	}
+	findProxy, ok := goja.AssertFunction(p.vm.Get("FindProxyForURL"))
+	if !ok {
+		return nil, errors.New("pac file does not define FindProxyForURL")
+	}
+	p.findProxy = findProxy
+	return p, nil
+}
+
+func (p *pacProxy) proxyForURL(u *url.URL) (*url.URL, error) {
+	key := fmt.Sprintf("%s://%s", u.Scheme, u.Host)
+	now := p.now()
+
+	p.cacheMu.Lock()
+	entry, ok := p.cache[key]
+	p.cacheMu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.proxy, nil
+	}
+
+	// the request host is resolved outside of the runtime lock so a slow
+	// lookup does not block the evaluation for other hosts
+	hostIP := p.lookup(u.Hostname(), pacTimeout)
+	result, err := p.evaluate(fmt.Sprintf("%s://%s/", u.Scheme, u.Host), u.Hostname(), hostIP)
+	if err != nil {
+		return nil, err
+	}
+	proxyURL, err := p.parseResult(result)
+	if err != nil {
+		return nil, err
+	}
+
+	p.cacheMu.Lock()
+	defer p.cacheMu.Unlock()
+	if len(p.cache) >= pacCacheSize {
+		for k, v := range p.cache {
+			if !now.Before(v.expires) {
+				delete(p.cache, k)
+			}
+		}
+	}
+	p.cache[key] = pacCacheEntry{
+		proxy:   proxyURL,
+		expires: now.Add(p.cacheTTL),
+	}
+	return proxyURL, nil
+}
+
+func (p *pacProxy) evaluate(rawURL, host string, hostIP net.IP) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.host = host
+	p.hostIP = hostIP
+	defer func() {
+		p.host = ""
+		p.hostIP = nil
+	}()
+
+	timer := time.AfterFunc(pacTimeout, func() {
+		p.vm.Interrupt("pac evaluation timed out")
+	})
+	defer func() {
+		timer.Stop()
+		p.vm.ClearInterrupt()
+	}()
+
+	v, err := p.findProxy(goja.Undefined(), p.vm.ToValue(rawURL), p.vm.ToValue(host))
+	if err != nil {
+		return "", fmt.Errorf("could not evaluate FindProxyForURL: %w", err)
+	}
+	if goja.IsUndefined(v) || goja.IsNull(v) {
+		return "", nil
+	}
+	return v.String(), nil
+}
+
+// parseResult converts a PAC result like "PROXY a:8080; SOCKS5 b:1080; DIRECT"
+// into a proxy url. The first supported entry is used, nil means a direct
+// connection.
+func (p *pacProxy) parseResult(result string) (*url.URL, error) {
+	for entry := range strings.SplitSeq(result, ";") {
+		fields := strings.Fields(entry)
+		if len(fields) == 0 {
+			continue
+		}
+		var scheme string
+		switch strings.ToUpper(fields[0]) {
+		case "DIRECT":
+			return nil, nil // nolint: nilnil
+		case "PROXY", "HTTP":
+			scheme = "http"
+		case "HTTPS":
+			scheme = "https"
+		case "SOCKS", "SOCKS5":
+			scheme = "socks5"
+		default:
+			// SOCKS4 and unknown types are not supported by the transport
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid pac result %q", entry)
+		}
+		proxyURL := &url.URL{
+			Scheme: scheme,
+			Host:   fields[1],
+		}
+		if p.username != "" && p.password != "" {
+			proxyURL.User = url.UserPassword(p.username, p.password)
+		}
+		return proxyURL, nil
+	}
+	return nil, nil // nolint: nilnil
+}
+
+// resolve is called by the helpers while the runtime is locked. The request
+// host was already resolved, other hosts are looked up with a short timeout.
+func (p *pacProxy) resolve(host string) net.IP {
+	if p.host != "" && strings.EqualFold(host, p.host) {
+		return p.hostIP
+	}
+	return p.lookup(host, pacDNSTimeout)
+}
+
+func (p *pacProxy) lookup(host string, timeout time.Duration) net.IP {
+	if ip := net.ParseIP(host); ip != nil {
+		return ip
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	ips, err := p.lookupIP(ctx, host)
+	if err != nil || len(ips) == 0 {
+		return nil
+	}
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			return ip
+		}
+	}
+	return ips[0]
+}
+
+// registerHelpers defines the predefined functions available to PAC files
+func (p *pacProxy) registerHelpers() error {
+	helpers := map[string]any{
+		"isPlainHostName": func(host string) bool {
+			return !strings.Contains(host, ".")
+		},
+		"dnsDomainIs": func(host, domain string) bool {
+			return strings.HasSuffix(strings.ToLower(host), strings.ToLower(domain))
+		},
+		"localHostOrDomainIs": func(host, hostdom string) bool {
+			host = strings.ToLower(host)
+			hostdom = strings.ToLower(hostdom)
+			if host == hostdom {
+				return true
+			}
+			return !strings.Contains(host, ".") && strings.HasPrefix(hostdom, host+".")
+		},
+		"isResolvable": func(host string) bool {
+			return p.resolve(host) != nil
+		},
+		"dnsResolve": func(host string) any {
+			ip := p.resolve(host)
+			if ip == nil {
+				return nil
+			}
+			return ip.String()
+		},
+		"isInNet": func(host, pattern, mask string) bool {
+			ip := p.resolve(host)
+			patternIP := net.ParseIP(pattern)
+			maskIP := net.ParseIP(mask)
+			if ip == nil || patternIP == nil || maskIP == nil || ip.To4() == nil || patternIP.To4() == nil || maskIP.To4() == nil {
+				return false
+			}
+			m := net.IPMask(maskIP.To4())
+			return ip.To4().Mask(m).Equal(patternIP.To4().Mask(m))
+		},
+		"myIpAddress":     myIPAddress,
+		"dnsDomainLevels": func(host string) int { return strings.Count(host, ".") },
+		"shExpMatch":      shExpMatch,
+	}
+	for name, fn := range helpers {
+		if err := p.vm.Set(name, fn); err != nil {
+			return fmt.Errorf("could not register pac function %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// myIPAddress returns the first non loopback IPv4 address of the host
+func myIPAddress() string {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return "127.0.0.1"
+	}
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.IsLoopback() || ipNet.IP.To4() == nil {
+			continue
+		}
+		return ipNet.IP.String()
+	}
+	return "127.0.0.1"
+}
+
+// shExpMatch matches a shell expression where * matches any number of
+// characters and ? matches exactly one character
+func shExpMatch(str, pattern string) bool {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return false
+	}
+	return re.MatchString(str)
+}
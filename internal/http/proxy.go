@@ -1,6 +1,7 @@
 package http
 
 import (
+	"fmt"
 	"net/http"
 	"net/url"
 
@@ -13,16 +14,38 @@ type proxy struct {
 }
 
 func newProxy(c config.Proxy) (*proxy, error) {
-	proxyURL, err := url.Parse(c.URL)
+	if c.PACFile != "" {
+		pac, err := newPACProxy(c)
+		if err != nil {
+			return nil, err
+		}
+		return &proxy{
+			proxyFunc: pac.proxyForURL,
+		}, nil
+	}
+
+	proxyURL, err := parseProxyURL(c.URL, c)
 	if err != nil {
 		return nil, err
 	}
-	if c.Username != "" && c.Password != "" {
-		proxyURL.User = url.UserPassword(c.Username, c.Password)
+	httpProxy := proxyURL
+	if c.HTTPURL != "" {
+		httpProxy, err = parseProxyURL(c.HTTPURL, c)
+		if err != nil {
+			return nil, err
+		}
 	}
+	httpsProxy := proxyURL
+	if c.HTTPSURL != "" {
+		httpsProxy, err = parseProxyURL(c.HTTPSURL, c)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	config := &httpproxy.Config{
-		HTTPProxy:  proxyURL.String(),
-		HTTPSProxy: proxyURL.String(),
+		HTTPProxy:  httpProxy,
+		HTTPSProxy: httpsProxy,
 		NoProxy:    c.NoProxy,
 	}
 	return &proxy{
@@ -30,6 +53,28 @@ func newProxy(c config.Proxy) (*proxy, error) {
 	}, nil
 }
 
+// parseProxyURL validates the proxy url and adds the configured credentials.
+// SOCKS5 proxies are handled by the transport itself including the
+// username and password authentication.
+func parseProxyURL(raw string, c config.Proxy) (string, error) {
+	if raw == "" {
+		return "", nil
+	}
+	proxyURL, err := url.Parse(raw)
+	if err != nil {
+		return "", err
+	}
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return "", fmt.Errorf("unsupported proxy scheme %q", proxyURL.Scheme)
+	}
+	if c.Username != "" && c.Password != "" {
+		proxyURL.User = url.UserPassword(c.Username, c.Password)
+	}
+	return proxyURL.String(), nil
+}
+
 func (p *proxy) ProxyFromConfig(req *http.Request) (*url.URL, error) {
 	return p.proxyFunc(req.URL)
 }
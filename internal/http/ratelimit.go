@@ -0,0 +1,246 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/firefart/go-webserver-template/internal/config"
+	"github.com/firefart/go-webserver-template/internal/metrics"
+)
+
+// ErrRateLimited is returned if a request is rejected by a rate limit with
+// the fail policy
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+// hostLimiters holds a rate and concurrency limiter per target host. The
+// limits are taken from the first matching rule.
+type hostLimiters struct {
+	rules    []config.HTTPClientRateLimit
+	metrics  *metrics.Metrics
+	mu       sync.Mutex
+	limiters map[string]*hostLimiter
+	now      func() time.Time
+}
+
+type hostLimiter struct {
+	bucket *tokenBucket
+	// inFlight is nil if the number of concurrent requests is not limited
+	inFlight chan struct{}
+	wait     bool
+}
+
+func newHostLimiters(rules []config.HTTPClientRateLimit, m *metrics.Metrics) *hostLimiters {
+	return &hostLimiters{
+		rules:    rules,
+		metrics:  m,
+		limiters: make(map[string]*hostLimiter),
+		now:      time.Now,
+	}
+}
+
+// get returns the limiter for the host or nil if no rule matches
+func (l *hostLimiters) get(host, hostname string) *hostLimiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if limiter, ok := l.limiters[host]; ok {
+		return limiter
+	}
+	for _, rule := range l.rules {
+		if !matchesAny(hostname, rule.Hosts) {
+			continue
+		}
+		limiter := &hostLimiter{
+			wait: rule.Policy != "fail",
+		}
+		if rule.RequestsPerSecond > 0 {
+			limiter.bucket = newTokenBucket(rule.RequestsPerSecond, rule.Burst, l.now)
+		}
+		if rule.MaxInFlight > 0 {
+			limiter.inFlight = make(chan struct{}, rule.MaxInFlight)
+		}
+		l.limiters[host] = limiter
+		return limiter
+	}
+	// hosts without a limit are not cached so arbitrary hosts like webhook
+	// urls don't grow the map
+	return nil
+}
+
+// acquire blocks until the request may be sent according to the limits of
+// the host. The returned function must be called once the request is done.
+func (l *hostLimiters) acquire(ctx context.Context, host, hostname string) (func(), error) {
+	limiter := l.get(host, hostname)
+	if limiter == nil {
+		return func() {}, nil
+	}
+
+	start := time.Now()
+	release, err := limiter.acquire(ctx)
+	if l.metrics != nil {
+		l.metrics.HTTPClientQueueWait.WithLabelValues(host).Observe(time.Since(start).Seconds())
+		if errors.Is(err, ErrRateLimited) {
+			l.metrics.HTTPClientRateLimited.WithLabelValues(host).Inc()
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", host, err)
+	}
+	return release, nil
+}
+
+func (h *hostLimiter) acquire(ctx context.Context) (func(), error) {
+	if h.bucket != nil {
+		if h.wait {
+			if err := h.bucket.wait(ctx); err != nil {
+				return nil, err
+			}
+		} else if !h.bucket.take() {
+			return nil, ErrRateLimited
+		}
+	}
+
+	if h.inFlight == nil {
+		return func() {}, nil
+	}
+	if h.wait {
+		select {
+		case h.inFlight <- struct{}{}:
+		case <-ctx.Done():
+			h.cancelToken()
+			return nil, ctx.Err()
+		}
+	} else {
+		select {
+		case h.inFlight <- struct{}{}:
+		default:
+			h.cancelToken()
+			return nil, ErrRateLimited
+		}
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() { <-h.inFlight })
+	}, nil
+}
+
+// cancelToken returns the token of a request that was not sent
+func (h *hostLimiter) cancelToken() {
+	if h.bucket != nil {
+		h.bucket.cancel()
+	}
+}
+
+// tokenBucket allows rate requests per second with bursts of up to burst
+// requests
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+func newTokenBucket(rate float64, burst int, now func() time.Time) *tokenBucket {
+	b := float64(max(burst, 1))
+	return &tokenBucket{
+		rate:   rate,
+		burst:  b,
+		tokens: b,
+		last:   now(),
+		now:    now,
+	}
+}
+
+func (b *tokenBucket) refill() {
+	now := b.now()
+	elapsed := now.Sub(b.last).Seconds()
+	b.last = now
+	if elapsed > 0 {
+		b.tokens = min(b.burst, b.tokens+elapsed*b.rate)
+	}
+}
+
+// take takes a token if one is available
+func (b *tokenBucket) take() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// reserve takes a token and returns the time until it is available. The
+// token count may become negative so waiting requests are served in order.
+func (b *tokenBucket) reserve() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill()
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// cancel returns a reserved token that was not used
+func (b *tokenBucket) cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill()
+	b.tokens = min(b.burst, b.tokens+1)
+}
+
+func (b *tokenBucket) wait(ctx context.Context) error {
+	delay := b.reserve()
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		b.cancel()
+		return ctx.Err()
+	}
+}
+
+func matchesAny(host string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if hostMatches(host, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// releaseBody calls release once the response body is closed so the
+// concurrency limit covers reading the body
+type releaseBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
+
+func releaseOnClose(resp *http.Response, release func()) {
+	resp.Body = &releaseBody{
+		ReadCloser: resp.Body,
+		release:    release,
+	}
+}
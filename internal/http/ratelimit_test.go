@@ -0,0 +1,221 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/firefart/go-webserver-template/internal/config"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenBucket(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	b := newTokenBucket(2, 3, func() time.Time { return now })
+
+	for range 3 {
+		require.True(t, b.take())
+	}
+	require.False(t, b.take())
+
+	now = now.Add(500 * time.Millisecond)
+	require.True(t, b.take())
+	require.False(t, b.take())
+
+	// tokens never exceed the burst
+	now = now.Add(1 * time.Hour)
+	for range 3 {
+		require.Equal(t, time.Duration(0), b.reserve())
+	}
+	require.Equal(t, 500*time.Millisecond, b.reserve())
+	require.Equal(t, 1*time.Second, b.reserve())
+	b.cancel()
+	require.Equal(t, 1*time.Second, b.reserve())
+}
+
+func newRateLimitedClient(t *testing.T, limit config.HTTPClientRateLimit) (*Client, *httptest.Server, func() int64) {
+	t.Helper()
+	var inFlight, maxInFlight atomic.Int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		current := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			old := maxInFlight.Load()
+			if current <= old || maxInFlight.CompareAndSwap(old, current) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(ts.Close)
+
+	c := newTestConfig()
+	c.HTTPClient.RateLimits = []config.HTTPClientRateLimit{
+		{Hosts: []string{"other.test"}, MaxInFlight: 100, Policy: "wait"},
+		limit,
+	}
+	client, _ := newTestClient(t, c)
+	return client, ts, maxInFlight.Load
+}
+
+func TestRateLimitMaxInFlight(t *testing.T) {
+	t.Parallel()
+
+	client, ts, maxInFlight := newRateLimitedClient(t, config.HTTPClientRateLimit{
+		Hosts:       []string{"127.0.0.1"},
+		MaxInFlight: 2,
+		Policy:      "wait",
+	})
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Go(func() {
+			req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, ts.URL, nil)
+			require.NoError(t, err)
+			resp, err := client.Do(req)
+			require.NoError(t, err)
+			require.NoError(t, resp.Body.Close())
+		})
+	}
+	wg.Wait()
+	require.Equal(t, int64(2), maxInFlight())
+
+	observer, err := client.metrics.HTTPClientQueueWait.GetMetricWithLabelValues(req(t, ts.URL).URL.Host)
+	require.NoError(t, err)
+	histogram, ok := observer.(prometheus.Histogram)
+	require.True(t, ok)
+	var metric dto.Metric
+	require.NoError(t, histogram.Write(&metric))
+	require.Equal(t, uint64(10), metric.GetHistogram().GetSampleCount())
+	require.Positive(t, metric.GetHistogram().GetSampleSum())
+}
+
+func TestRateLimitFailFast(t *testing.T) {
+	t.Parallel()
+
+	client, ts, _ := newRateLimitedClient(t, config.HTTPClientRateLimit{
+		Hosts:       []string{"*"},
+		MaxInFlight: 1,
+		Policy:      "fail",
+	})
+
+	// the first response holds the slot until the body is closed
+	resp, err := client.Do(req(t, ts.URL))
+	require.NoError(t, err)
+
+	_, err = client.Do(req(t, ts.URL)) // nolint: bodyclose
+	require.ErrorIs(t, err, ErrRateLimited)
+	require.InDelta(t, 1, metricValue(t, client.metrics.HTTPClientRateLimited.WithLabelValues(resp.Request.URL.Host)), 0)
+
+	require.NoError(t, resp.Body.Close())
+	resp, err = client.Do(req(t, ts.URL))
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+}
+
+func TestRateLimitRequestsPerSecond(t *testing.T) {
+	t.Parallel()
+
+	t.Run("fail", func(t *testing.T) {
+		t.Parallel()
+		client, ts, _ := newRateLimitedClient(t, config.HTTPClientRateLimit{
+			Hosts:             []string{"127.0.0.1"},
+			RequestsPerSecond: 0.001,
+			Burst:             2,
+			Policy:            "fail",
+		})
+		for range 2 {
+			resp, err := client.Do(req(t, ts.URL))
+			require.NoError(t, err)
+			require.NoError(t, resp.Body.Close())
+		}
+		_, err := client.Do(req(t, ts.URL)) // nolint: bodyclose
+		require.ErrorIs(t, err, ErrRateLimited)
+	})
+
+	t.Run("wait with cancellation", func(t *testing.T) {
+		t.Parallel()
+		client, ts, _ := newRateLimitedClient(t, config.HTTPClientRateLimit{
+			Hosts:             []string{"127.0.0.1"},
+			RequestsPerSecond: 0.001,
+			Burst:             1,
+			Policy:            "wait",
+		})
+		resp, err := client.Do(req(t, ts.URL))
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+
+		ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+		defer cancel()
+		r, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL, nil)
+		require.NoError(t, err)
+		_, err = client.Do(r) // nolint: bodyclose
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("wait", func(t *testing.T) {
+		t.Parallel()
+		client, ts, _ := newRateLimitedClient(t, config.HTTPClientRateLimit{
+			Hosts:             []string{"127.0.0.1"},
+			RequestsPerSecond: 20,
+			Burst:             1,
+			Policy:            "wait",
+		})
+		start := time.Now()
+		for range 3 {
+			resp, err := client.Do(req(t, ts.URL))
+			require.NoError(t, err)
+			require.NoError(t, resp.Body.Close())
+		}
+		require.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+	})
+}
+
+func TestRateLimitNoMatchingRule(t *testing.T) {
+	t.Parallel()
+
+	limiters := newHostLimiters([]config.HTTPClientRateLimit{
+		{Hosts: []string{"*.example.com"}, MaxInFlight: 1, Policy: "fail"},
+	}, nil)
+	require.Nil(t, limiters.get("example.com:443", "example.com"))
+	// hosts without a limit are not cached
+	require.Empty(t, limiters.limiters)
+	require.NotNil(t, limiters.get("api.example.com:443", "api.example.com"))
+	// every host has its own limiter
+	require.NotSame(t, limiters.get("api.example.com:443", "api.example.com"), limiters.get("www.example.com:443", "www.example.com"))
+}
+
+func TestRateLimitReturnsTokenOfRejectedRequest(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	limiters := newHostLimiters([]config.HTTPClientRateLimit{
+		{Hosts: []string{"example.com"}, RequestsPerSecond: 0.001, Burst: 2, MaxInFlight: 1, Policy: "fail"},
+	}, nil)
+	limiters.now = func() time.Time { return now }
+
+	release, err := limiters.acquire(t.Context(), "example.com:443", "example.com")
+	require.NoError(t, err)
+	// rejected by the concurrency limit, the token is not used up
+	_, err = limiters.acquire(t.Context(), "example.com:443", "example.com")
+	require.ErrorIs(t, err, ErrRateLimited)
+	release()
+	_, err = limiters.acquire(t.Context(), "example.com:443", "example.com")
+	require.NoError(t, err)
+}
+
+func req(t *testing.T, url string) *http.Request {
+	t.Helper()
+	r, err := http.NewRequestWithContext(t.Context(), http.MethodGet, url, nil)
+	require.NoError(t, err)
+	return r
+}
@@ -0,0 +1,146 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/firefart/go-webserver-template/internal/config"
+)
+
+type contextKey string
+
+const contextKeyRetryPolicy contextKey = "retry_policy"
+
+// RetryPolicy controls if and how often a request is retried
+type RetryPolicy struct {
+	// MaxAttempts includes the initial request, 1 disables retries
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+	// Jitter randomizes the backoff by +/- the given fraction
+	Jitter float64
+	// MaxRetryAfter caps the wait time requested by a Retry-After header
+	MaxRetryAfter time.Duration
+	// RetryStatusCodes are the status codes that trigger a retry
+	RetryStatusCodes []int
+}
+
+// NoRetry disables retries for a request
+var NoRetry = RetryPolicy{MaxAttempts: 1}
+
+var defaultRetryStatusCodes = []int{
+	http.StatusTooManyRequests,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+func retryPolicyFromConfig(c config.HTTPClientRetry) RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:      c.MaxAttempts,
+		InitialBackoff:   c.InitialBackoff,
+		MaxBackoff:       c.MaxBackoff,
+		Multiplier:       c.Multiplier,
+		Jitter:           c.Jitter,
+		MaxRetryAfter:    c.MaxRetryAfter,
+		RetryStatusCodes: defaultRetryStatusCodes,
+	}
+}
+
+// WithRetryPolicy overrides the retry policy of the client for all requests
+// using the returned context
+func WithRetryPolicy(ctx context.Context, policy RetryPolicy) context.Context {
+	return context.WithValue(ctx, contextKeyRetryPolicy, policy)
+}
+
+func retryPolicyFromContext(ctx context.Context) (RetryPolicy, bool) {
+	p, ok := ctx.Value(contextKeyRetryPolicy).(RetryPolicy)
+	return p, ok
+}
+
+// isIdempotent reports if a request can safely be sent multiple times
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	// see https://datatracker.ietf.org/doc/draft-ietf-httpapi-idempotency-key-header/
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+// canRetry reports if the request can be retried at all. Requests with a body
+// need GetBody so the body can be rewound.
+func (p RetryPolicy) canRetry(req *http.Request) bool {
+	if p.MaxAttempts <= 1 || !isIdempotent(req) {
+		return false
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	return true
+}
+
+// shouldRetry reports if the result of an attempt is worth retrying
+func (p RetryPolicy) shouldRetry(ctx context.Context, resp *http.Response, err error) bool {
+	if err != nil {
+		// the caller gave up, no need to try again
+		if ctx.Err() != nil || errors.Is(err, context.Canceled) || errors.Is(err, ErrCircuitOpen) || errors.Is(err, ErrRateLimited) {
+			return false
+		}
+		return true
+	}
+	for _, code := range p.RetryStatusCodes {
+		if resp.StatusCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns the time to wait before the next attempt. A Retry-After
+// header takes precedence over the exponential backoff.
+func (p RetryPolicy) backoff(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if wait, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			if p.MaxRetryAfter > 0 {
+				wait = min(wait, p.MaxRetryAfter)
+			}
+			return wait
+		}
+	}
+
+	multiplier := max(p.Multiplier, 1)
+	wait := float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxBackoff > 0 {
+		wait = min(wait, float64(p.MaxBackoff))
+	}
+	if p.Jitter > 0 {
+		// random value in the range [1-jitter, 1+jitter)
+		wait *= 1 - p.Jitter + rand.Float64()*2*p.Jitter // nolint: gosec
+	}
+	return time.Duration(wait)
+}
+
+// parseRetryAfter parses both forms of the header, delay seconds or a HTTP date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	t, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	return max(t.Sub(now), 0), true
+}
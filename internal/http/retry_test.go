@@ -0,0 +1,79 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		value    string
+		expected time.Duration
+		ok       bool
+	}{
+		{name: "empty", value: "", ok: false},
+		{name: "seconds", value: "120", expected: 2 * time.Minute, ok: true},
+		{name: "negative", value: "-1", ok: false},
+		{name: "http date", value: now.Add(30 * time.Second).Format(http.TimeFormat), expected: 30 * time.Second, ok: true},
+		{name: "date in the past", value: now.Add(-30 * time.Second).Format(http.TimeFormat), expected: 0, ok: true},
+		{name: "invalid", value: "invalid", ok: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, ok := parseRetryAfter(tt.value, now)
+			require.Equal(t, tt.ok, ok)
+			require.Equal(t, tt.expected, d)
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	p := RetryPolicy{
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     1 * time.Second,
+		Multiplier:     2,
+		MaxRetryAfter:  5 * time.Second,
+	}
+	require.Equal(t, 100*time.Millisecond, p.backoff(1, nil))
+	require.Equal(t, 200*time.Millisecond, p.backoff(2, nil))
+	require.Equal(t, 400*time.Millisecond, p.backoff(3, nil))
+	require.Equal(t, 1*time.Second, p.backoff(10, nil))
+
+	resp := &http.Response{Header: http.Header{}}
+	resp.Header.Set("Retry-After", "3")
+	require.Equal(t, 3*time.Second, p.backoff(1, resp))
+	resp.Header.Set("Retry-After", "60")
+	require.Equal(t, 5*time.Second, p.backoff(1, resp))
+
+	p.Jitter = 0.5
+	for range 100 {
+		d := p.backoff(1, nil)
+		require.GreaterOrEqual(t, d, 50*time.Millisecond)
+		require.Less(t, d, 150*time.Millisecond)
+	}
+}
+
+func TestCanRetry(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 3}
+
+	req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	require.NoError(t, err)
+	require.True(t, p.canRetry(req))
+	require.False(t, NoRetry.canRetry(req))
+
+	req, err = http.NewRequest(http.MethodPost, "http://example.com", strings.NewReader("body"))
+	require.NoError(t, err)
+	require.False(t, p.canRetry(req))
+	req.Header.Set("Idempotency-Key", "key")
+	require.True(t, p.canRetry(req))
+
+	// body can not be rewound
+	req.GetBody = nil
+	require.False(t, p.canRetry(req))
+}
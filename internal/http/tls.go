@@ -0,0 +1,273 @@
+package http
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/firefart/go-webserver-template/internal/config"
+	"github.com/fsnotify/fsnotify"
+)
+
+// certDirDebounce delays the reload of the cert dir so a burst of file
+// events only results in a single reload
+const certDirDebounce = 500 * time.Millisecond
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// tlsTransport selects the transport by the host of the request so every
+// TLS profile gets its own connection pool. The transports are rebuilt when
+// the certificates in the cert dir change.
+type tlsTransport struct {
+	base     *http.Transport
+	certDir  string
+	profiles []config.HTTPClientTLSProfile
+	current  atomic.Pointer[transportSet]
+}
+
+type transportSet struct {
+	fallback *http.Transport
+	profiles []profileTransport
+}
+
+type profileTransport struct {
+	hosts     []string
+	transport *http.Transport
+}
+
+func newTLSTransport(base *http.Transport, certDir string, profiles []config.HTTPClientTLSProfile) (*tlsTransport, error) {
+	t := &tlsTransport{
+		base:     base,
+		certDir:  certDir,
+		profiles: profiles,
+	}
+	if err := t.reload(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *tlsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.current.Load().transportFor(req.URL.Hostname()).RoundTrip(req)
+}
+
+func (s *transportSet) transportFor(host string) *http.Transport {
+	for _, p := range s.profiles {
+		if matchesAny(host, p.hosts) {
+			return p.transport
+		}
+	}
+	return s.fallback
+}
+
+// hostMatches compares the host with the pattern, a leading *. matches
+// all subdomains but not the domain itself and * matches every host
+func hostMatches(host, pattern string) bool {
+	host = strings.ToLower(host)
+	pattern = strings.ToLower(pattern)
+	if pattern == "*" {
+		return true
+	}
+	if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+		return strings.HasSuffix(host, "."+suffix)
+	}
+	return host == pattern
+}
+
+// reload builds new transports and closes the idle connections of the old
+// ones. Requests in flight finish on the old transports.
+func (t *tlsTransport) reload() error {
+	var rootCAs *x509.CertPool
+	if t.certDir != "" {
+		var err error
+		rootCAs, err = getCertificateChain(t.certDir)
+		if err != nil {
+			return fmt.Errorf("could not get root cas: %w", err)
+		}
+	}
+
+	fallback := t.base.Clone()
+	if rootCAs != nil {
+		if fallback.TLSClientConfig == nil {
+			fallback.TLSClientConfig = &tls.Config{} //nolint:gosec
+		}
+		fallback.TLSClientConfig.RootCAs = rootCAs
+	}
+
+	set := &transportSet{
+		fallback: fallback,
+	}
+	for i, p := range t.profiles {
+		tlsConfig, err := newProfileTLSConfig(p, rootCAs)
+		if err != nil {
+			return fmt.Errorf("invalid tls profile %d: %w", i, err)
+		}
+		tr := t.base.Clone()
+		tr.TLSClientConfig = tlsConfig
+		set.profiles = append(set.profiles, profileTransport{
+			hosts:     p.Hosts,
+			transport: tr,
+		})
+	}
+
+	if old := t.current.Swap(set); old != nil {
+		old.fallback.CloseIdleConnections()
+		for _, p := range old.profiles {
+			p.transport.CloseIdleConnections()
+		}
+	}
+	return nil
+}
+
+func newProfileTLSConfig(p config.HTTPClientTLSProfile, rootCAs *x509.CertPool) (*tls.Config, error) {
+	tlsConfig := &tls.Config{ //nolint:gosec // the min version is configurable
+		ServerName: p.ServerName,
+		MinVersion: tls.VersionTLS12,
+	}
+	if p.MinVersion != "" {
+		tlsConfig.MinVersion = tlsVersions[p.MinVersion]
+	}
+	if p.MaxVersion != "" {
+		tlsConfig.MaxVersion = tlsVersions[p.MaxVersion]
+	}
+
+	if p.ClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(p.ClientCert, p.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("could not load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if len(p.RootCAs) > 0 {
+		if rootCAs != nil {
+			rootCAs = rootCAs.Clone()
+		} else {
+			var err error
+			rootCAs, err = x509.SystemCertPool()
+			if rootCAs == nil || err != nil {
+				rootCAs = x509.NewCertPool()
+			}
+		}
+		for _, file := range p.RootCAs {
+			content, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("could not read root ca %s: %w", file, err)
+			}
+			if ok := rootCAs.AppendCertsFromPEM(content); !ok {
+				return nil, fmt.Errorf("failed to append cert from %s", file)
+			}
+		}
+	}
+	tlsConfig.RootCAs = rootCAs
+
+	if len(p.PinnedSPKI) > 0 {
+		pins := make(map[string]struct{}, len(p.PinnedSPKI))
+		for _, pin := range p.PinnedSPKI {
+			pin = strings.TrimPrefix(pin, "sha256/")
+			raw, err := base64.StdEncoding.DecodeString(pin)
+			if err != nil || len(raw) != sha256.Size {
+				return nil, fmt.Errorf("invalid pinned spki %q", pin)
+			}
+			pins[pin] = struct{}{}
+		}
+		tlsConfig.VerifyConnection = func(cs tls.ConnectionState) error {
+			return verifyPins(cs, pins)
+		}
+	}
+	return tlsConfig, nil
+}
+
+// verifyPins checks that one certificate of the verified chains matches one
+// of the pins. It runs after the normal certificate verification.
+func verifyPins(cs tls.ConnectionState, pins map[string]struct{}) error {
+	for _, chain := range cs.VerifiedChains {
+		for _, cert := range chain {
+			if _, ok := pins[spkiHash(cert)]; ok {
+				return nil
+			}
+		}
+	}
+	return errors.New("no certificate matches the pinned public keys")
+}
+
+// spkiHash returns the base64 encoded sha256 hash of the subject public key
+// info of the certificate
+func spkiHash(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// watchCertDir reloads the transports on changes in the cert dir until the
+// context is done. Failed reloads keep the previous certificates.
+func (t *tlsTransport) watchCertDir(ctx context.Context, logger *slog.Logger) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("could not create watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	// fsnotify is not recursive so add all sub directories
+	if err := filepath.WalkDir(t.certDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return watcher.Add(path)
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("could not watch cert dir %s: %w", t.certDir, err)
+	}
+
+	debounce := time.NewTimer(certDirDebounce)
+	debounce.Stop()
+	defer debounce.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err := watcher.Add(event.Name); err != nil {
+						logger.Error("could not watch directory", slog.String("path", event.Name), slog.String("err", err.Error()))
+					}
+				}
+			}
+			debounce.Reset(certDirDebounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			logger.Error("error watching cert dir", slog.String("err", err.Error()))
+		case <-debounce.C:
+			if err := t.reload(); err != nil {
+				logger.Error("could not reload certificates", slog.String("err", err.Error()))
+				continue
+			}
+			logger.Info("reloaded certificates", slog.String("cert_dir", t.certDir))
+		}
+	}
+}
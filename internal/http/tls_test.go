@@ -0,0 +1,307 @@
+package http
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/firefart/go-webserver-template/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+func (c testCert) tlsCertificate(t *testing.T) tls.Certificate {
+	t.Helper()
+	cert, err := tls.X509KeyPair(c.certPEM, c.keyPEM)
+	require.NoError(t, err)
+	return cert
+}
+
+func (c testCert) write(t *testing.T, dir, name string) (string, string) {
+	t.Helper()
+	certFile := filepath.Join(dir, name+".pem")
+	keyFile := filepath.Join(dir, name+".key")
+	require.NoError(t, os.WriteFile(certFile, c.certPEM, 0o600))
+	require.NoError(t, os.WriteFile(keyFile, c.keyPEM, 0o600))
+	return certFile, keyFile
+}
+
+// newTestCert creates a certificate signed by parent, or a self signed CA if
+// parent is nil
+func newTestCert(t *testing.T, parent *testCert, template *x509.Certificate) testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+	template.SerialNumber = serial
+	template.NotBefore = time.Now().Add(-1 * time.Hour)
+	template.NotAfter = time.Now().Add(1 * time.Hour)
+
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+type testPKI struct {
+	ca     testCert
+	server testCert
+	client testCert
+}
+
+func newTestPKI(t *testing.T) testPKI {
+	t.Helper()
+	ca := newTestCert(t, nil, &x509.Certificate{Subject: pkix.Name{CommonName: "Test CA"}})
+	server := newTestCert(t, &ca, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "upstream.test"},
+		DNSNames:    []string{"upstream.test"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	client := newTestCert(t, &ca, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "client"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	return testPKI{ca: ca, server: server, client: client}
+}
+
+func newTestTLSServer(t *testing.T, pki testPKI, configure func(*tls.Config)) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) > 0 {
+			w.Header().Set("X-Client", r.TLS.PeerCertificates[0].Subject.CommonName)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	// handshake errors are expected in the tests
+	ts.Config.ErrorLog = log.New(io.Discard, "", 0)
+	ts.TLS = &tls.Config{
+		Certificates: []tls.Certificate{pki.server.tlsCertificate(t)},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if configure != nil {
+		configure(ts.TLS)
+	}
+	ts.StartTLS()
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func doTLSRequest(t *testing.T, c *Client, url string) (*http.Response, error) {
+	t.Helper()
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, url, nil)
+	require.NoError(t, err)
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	require.NoError(t, resp.Body.Close())
+	return resp, nil
+}
+
+func newTLSTestConfig() config.Configuration {
+	c := newTestConfig()
+	c.HTTPClient.Retry.MaxAttempts = 1
+	c.HTTPClient.CircuitBreaker.Enabled = false
+	return c
+}
+
+func TestTLSProfiles(t *testing.T) {
+	t.Parallel()
+
+	pki := newTestPKI(t)
+	dir := t.TempDir()
+	caFile, _ := pki.ca.write(t, dir, "ca")
+	clientCert, clientKey := pki.client.write(t, dir, "client")
+	clientPool := x509.NewCertPool()
+	clientPool.AddCert(pki.ca.cert)
+
+	ts := newTestTLSServer(t, pki, func(c *tls.Config) {
+		c.ClientAuth = tls.RequireAndVerifyClientCert
+		c.ClientCAs = clientPool
+	})
+
+	t.Run("mutual tls", func(t *testing.T) {
+		t.Parallel()
+		c := newTLSTestConfig()
+		c.HTTPClient.TLSProfiles = []config.HTTPClientTLSProfile{
+			{Hosts: []string{"other.test"}},
+			{
+				Hosts:      []string{"127.0.0.1"},
+				ClientCert: clientCert,
+				ClientKey:  clientKey,
+				RootCAs:    []string{caFile},
+				ServerName: "upstream.test",
+			},
+		}
+		client, _ := newTestClient(t, c)
+		resp, err := doTLSRequest(t, client, ts.URL)
+		require.NoError(t, err)
+		require.Equal(t, "client", resp.Header.Get("X-Client"))
+	})
+
+	t.Run("no matching profile", func(t *testing.T) {
+		t.Parallel()
+		c := newTLSTestConfig()
+		c.HTTPClient.TLSProfiles = []config.HTTPClientTLSProfile{
+			{Hosts: []string{"*.example.com"}, RootCAs: []string{caFile}},
+		}
+		client, _ := newTestClient(t, c)
+		_, err := doTLSRequest(t, client, ts.URL)
+		require.Error(t, err)
+	})
+
+	t.Run("pinning", func(t *testing.T) {
+		t.Parallel()
+		for name, tc := range map[string]struct {
+			pin   string
+			valid bool
+		}{
+			"ca":     {pin: spkiHash(pki.ca.cert), valid: true},
+			"leaf":   {pin: "sha256/" + spkiHash(pki.server.cert), valid: true},
+			"client": {pin: spkiHash(pki.client.cert), valid: false},
+		} {
+			t.Run(name, func(t *testing.T) {
+				t.Parallel()
+				c := newTLSTestConfig()
+				c.HTTPClient.TLSProfiles = []config.HTTPClientTLSProfile{
+					{
+						Hosts:      []string{"127.0.0.1"},
+						ClientCert: clientCert,
+						ClientKey:  clientKey,
+						RootCAs:    []string{caFile},
+						PinnedSPKI: []string{tc.pin},
+					},
+				}
+				client, _ := newTestClient(t, c)
+				_, err := doTLSRequest(t, client, ts.URL)
+				if tc.valid {
+					require.NoError(t, err)
+				} else {
+					require.ErrorContains(t, err, "pinned")
+				}
+			})
+		}
+	})
+
+	t.Run("invalid pin", func(t *testing.T) {
+		t.Parallel()
+		c := newTLSTestConfig()
+		c.HTTPClient.TLSProfiles = []config.HTTPClientTLSProfile{
+			{Hosts: []string{"127.0.0.1"}, PinnedSPKI: []string{"invalid"}},
+		}
+		_, err := NewHTTPClient(c, slog.New(slog.DiscardHandler), nil, false)
+		require.Error(t, err)
+	})
+}
+
+func TestTLSProfileVersion(t *testing.T) {
+	t.Parallel()
+
+	pki := newTestPKI(t)
+	caFile, _ := pki.ca.write(t, t.TempDir(), "ca")
+	ts := newTestTLSServer(t, pki, func(c *tls.Config) {
+		c.MaxVersion = tls.VersionTLS12
+	})
+
+	for version, valid := range map[string]bool{"1.2": true, "1.3": false} {
+		c := newTLSTestConfig()
+		c.HTTPClient.TLSProfiles = []config.HTTPClientTLSProfile{
+			{Hosts: []string{"127.0.0.1"}, RootCAs: []string{caFile}, MinVersion: version},
+		}
+		client, _ := newTestClient(t, c)
+		_, err := doTLSRequest(t, client, ts.URL)
+		if valid {
+			require.NoError(t, err, version)
+		} else {
+			require.Error(t, err, version)
+		}
+	}
+}
+
+func TestHostMatches(t *testing.T) {
+	t.Parallel()
+
+	require.True(t, hostMatches("example.com", "example.com"))
+	require.True(t, hostMatches("example.com", "Example.COM"))
+	require.True(t, hostMatches("Example.COM", "example.com"))
+	require.True(t, hostMatches("API.Example.com", "*.example.com"))
+	require.True(t, hostMatches("api.example.com", "*.example.com"))
+	require.True(t, hostMatches("a.b.example.com", "*.example.com"))
+	require.False(t, hostMatches("example.com", "*.example.com"))
+	require.False(t, hostMatches("badexample.com", "*.example.com"))
+	require.False(t, hostMatches("api.example.com", "example.com"))
+}
+
+func TestWatchCertDir(t *testing.T) {
+	t.Parallel()
+
+	pki := newTestPKI(t)
+	ts := newTestTLSServer(t, pki, nil)
+	certDir := t.TempDir()
+
+	c := newTLSTestConfig()
+	c.CertDir = certDir
+	client, _ := newTestClient(t, c)
+
+	_, err := doTLSRequest(t, client, ts.URL)
+	require.Error(t, err)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- client.WatchCertDir(t.Context())
+	}()
+	// give the watcher time to start
+	time.Sleep(100 * time.Millisecond)
+
+	require.NoError(t, os.WriteFile(filepath.Join(certDir, "ca.pem"), pki.ca.certPEM, 0o600))
+	require.Eventually(t, func() bool {
+		_, err := doTLSRequest(t, client, ts.URL)
+		return err == nil
+	}, 5*time.Second, 50*time.Millisecond)
+}
+
+func TestWatchCertDirDisabled(t *testing.T) {
+	t.Parallel()
+
+	client, _ := newTestClient(t, newTLSTestConfig())
+	require.NoError(t, client.WatchCertDir(t.Context()))
+}
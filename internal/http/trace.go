@@ -0,0 +1,84 @@
+package http
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/firefart/go-webserver-template/internal/metrics"
+)
+
+// phaseTracer records the duration of the connection phases of a request.
+// The callbacks can be called concurrently, for example when dialing
+// multiple addresses of a host.
+type phaseTracer struct {
+	mu           sync.Mutex
+	host         string
+	metrics      *metrics.Metrics
+	start        time.Time
+	dnsStart     time.Time
+	connectStart map[string]time.Time
+	tlsStart     time.Time
+}
+
+func (p *phaseTracer) observe(phase string, start time.Time) {
+	if start.IsZero() {
+		return
+	}
+	p.metrics.HTTPClientPhaseDuration.WithLabelValues(p.host, phase).Observe(time.Since(start).Seconds())
+}
+
+// withPhaseTrace returns a shallow copy of the request that reports the
+// phase timings to the metrics
+func withPhaseTrace(req *http.Request, m *metrics.Metrics) *http.Request {
+	p := &phaseTracer{
+		host:         req.URL.Host,
+		metrics:      m,
+		start:        time.Now(),
+		connectStart: make(map[string]time.Time),
+	}
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(_ httptrace.DNSStartInfo) {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			p.dnsStart = time.Now()
+		},
+		DNSDone: func(_ httptrace.DNSDoneInfo) {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			p.observe("dns", p.dnsStart)
+		},
+		ConnectStart: func(network, addr string) {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			p.connectStart[network+addr] = time.Now()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			if err == nil {
+				p.observe("connect", p.connectStart[network+addr])
+			}
+		},
+		TLSHandshakeStart: func() {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			p.tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			if err == nil {
+				p.observe("tls", p.tlsStart)
+			}
+		},
+		GotFirstResponseByte: func() {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			p.observe("ttfb", p.start)
+		},
+	}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+}
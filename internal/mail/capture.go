@@ -0,0 +1,141 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/firefart/go-webserver-template/internal/config"
+)
+
+// captureLimit is the number of messages kept by Capture, older messages are
+// dropped
+const captureLimit = 100
+
+// CapturedMail is a message recorded by Capture
+type CapturedMail struct {
+	ID      int
+	Time    time.Time
+	Message Message
+	// Raw is the MIME encoded message as it would be sent to the server
+	Raw []byte
+}
+
+// Capture records the messages in memory instead of sending them. It is used
+// in debug mode to preview mails without a mail server and in tests to assert
+// on the sent mails.
+type Capture struct {
+	mu     sync.Mutex
+	mailer *Mail
+	nextID int
+	mails  []CapturedMail
+}
+
+// compile time check that struct implements the interface
+var _ Interface = (*Capture)(nil)
+
+// NewCapture returns a capture using the sender, recipients and DKIM config
+// of the configuration. The sender defaults to capture@localhost so the
+// capture also works without a mail config.
+func NewCapture(configuration config.Configuration) (*Capture, error) {
+	if configuration.Mail.From.Mail == "" {
+		configuration.Mail.From.Name = "Capture"
+		configuration.Mail.From.Mail = "capture@localhost"
+	}
+	m := &Mail{
+		config: configuration,
+	}
+	if configuration.Mail.DKIM.Enabled {
+		var err error
+		m.dkim, err = NewDKIMSigner(configuration.Mail.DKIM)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &Capture{mailer: m, nextID: 1}, nil
+}
+
+func (c *Capture) Send(_ context.Context, msg Message) error {
+	if err := msg.Validate(); err != nil {
+		return err
+	}
+	gomsg, err := c.mailer.newMsg(msg)
+	if err != nil {
+		return err
+	}
+	var raw bytes.Buffer
+	if _, err := gomsg.WriteTo(&raw); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.mails = append(c.mails, CapturedMail{
+		ID:      c.nextID,
+		Time:    time.Now(),
+		Message: msg,
+		Raw:     raw.Bytes(),
+	})
+	c.nextID++
+	if len(c.mails) > captureLimit {
+		c.mails = c.mails[len(c.mails)-captureLimit:]
+	}
+	return nil
+}
+
+// Enqueue records the message like Send
+func (c *Capture) Enqueue(ctx context.Context, msg Message) error {
+	return c.Send(ctx, msg)
+}
+
+func (c *Capture) SendHTMLEmail(ctx context.Context, subject, body string) error {
+	return c.SendMultipartEmail(ctx, subject, "", body)
+}
+
+func (c *Capture) SendTXTEmail(ctx context.Context, subject, body string) error {
+	return c.SendMultipartEmail(ctx, subject, body, "")
+}
+
+func (c *Capture) SendMultipartEmail(ctx context.Context, subject, textBody, htmlBody string) error {
+	msgs, err := configuredMessages(c.mailer.config.Mail.To, subject, textBody, htmlBody)
+	if err != nil {
+		return err
+	}
+	for _, msg := range msgs {
+		if err := c.Send(ctx, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Mails returns the recorded mails, oldest first
+func (c *Capture) Mails() []CapturedMail {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]CapturedMail(nil), c.mails...)
+}
+
+// Mail returns the recorded mail with the given id
+func (c *Capture) Mail(id int) (CapturedMail, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, m := range c.mails {
+		if m.ID == id {
+			return m, true
+		}
+	}
+	return CapturedMail{}, false
+}
+
+// Messages returns the recorded messages, oldest first
+func (c *Capture) Messages() []Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	messages := make([]Message, len(c.mails))
+	for i, m := range c.mails {
+		messages[i] = m.Message
+	}
+	return messages
+}
@@ -0,0 +1,30 @@
+package mail
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/firefart/go-webserver-template/internal/config"
+
+	gomail "github.com/wneessen/go-mail"
+)
+
+// NewDKIMSigner loads the private key and returns the signer for the
+// configuration. It fails if the key can't be used for signing.
+func NewDKIMSigner(configuration config.MailDKIM) (*gomail.DKIMSigner, error) {
+	content, err := os.ReadFile(configuration.PrivateKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not read dkim private key: %w", err)
+	}
+	key, err := gomail.PrivKeyFromPEM(content)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse dkim private key %s: %w", configuration.PrivateKeyFile, err)
+	}
+
+	signer := gomail.NewDKIMSigner(configuration.Domain, configuration.Selector, key)
+	signer.SignHeaders(configuration.Headers...)
+	if err := signer.ValidateConfig(); err != nil {
+		return nil, fmt.Errorf("invalid dkim config: %w", err)
+	}
+	return signer, nil
+}
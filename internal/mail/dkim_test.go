@@ -0,0 +1,98 @@
+package mail
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/firefart/go-webserver-template/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func writeDKIMKey(t *testing.T) string {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	filename := filepath.Join(t.TempDir(), "dkim.pem")
+	require.NoError(t, os.WriteFile(filename, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600))
+	return filename
+}
+
+func TestNewDKIMSigner(t *testing.T) {
+	t.Parallel()
+
+	keyFile := writeDKIMKey(t)
+	invalidFile := filepath.Join(t.TempDir(), "invalid.pem")
+	require.NoError(t, os.WriteFile(invalidFile, []byte("not a key"), 0o600))
+
+	tests := []struct {
+		name   string
+		config config.MailDKIM
+		err    string
+	}{
+		{
+			name:   "valid",
+			config: config.MailDKIM{Selector: "mail", Domain: "example.com", PrivateKeyFile: keyFile},
+		},
+		{
+			name:   "custom headers",
+			config: config.MailDKIM{Selector: "mail", Domain: "example.com", PrivateKeyFile: keyFile, Headers: []string{"From", "Subject"}},
+		},
+		{
+			name:   "missing file",
+			config: config.MailDKIM{Selector: "mail", Domain: "example.com", PrivateKeyFile: filepath.Join(t.TempDir(), "missing.pem")},
+			err:    "could not read dkim private key",
+		},
+		{
+			name:   "invalid key",
+			config: config.MailDKIM{Selector: "mail", Domain: "example.com", PrivateKeyFile: invalidFile},
+			err:    "could not parse dkim private key",
+		},
+		{
+			name:   "headers without from",
+			config: config.MailDKIM{Selector: "mail", Domain: "example.com", PrivateKeyFile: keyFile, Headers: []string{"Subject"}},
+			err:    "invalid dkim config",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			signer, err := NewDKIMSigner(tt.config)
+			if tt.err != "" {
+				require.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.NotNil(t, signer)
+		})
+	}
+}
+
+func TestNewMsgDKIM(t *testing.T) {
+	t.Parallel()
+
+	m := newTestMail(t)
+	var err error
+	m.dkim, err = NewDKIMSigner(config.MailDKIM{Selector: "mail", Domain: "example.com", PrivateKeyFile: writeDKIMKey(t)})
+	require.NoError(t, err)
+
+	msg, err := NewMessage().To("user@example.com").Subject("subject").TextBody("body").Build()
+	require.NoError(t, err)
+	gomsg, err := m.newMsg(msg)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	_, err = gomsg.WriteTo(&buf)
+	require.NoError(t, err)
+	require.Contains(t, buf.String(), "DKIM-Signature:")
+	require.Contains(t, buf.String(), "d=example.com")
+	require.Contains(t, buf.String(), "s=mail")
+}
@@ -0,0 +1,292 @@
+package mail
+
+import (
+	"bytes"
+	"cmp"
+	"regexp"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var (
+	cssComment = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	// simpleSelector matches the selectors that can be inlined: an optional
+	// element name followed by ids and classes
+	simpleSelector = regexp.MustCompile(`^(\*|[a-zA-Z][a-zA-Z0-9]*)?((?:[.#][a-zA-Z0-9_-]+)*)$`)
+	selectorPart   = regexp.MustCompile(`[.#][a-zA-Z0-9_-]+`)
+)
+
+type cssDeclaration struct {
+	property string
+	value    string
+}
+
+type cssRule struct {
+	selector     string
+	declarations []cssDeclaration
+	// specificity and order decide which declaration wins
+	specificity int
+	order       int
+}
+
+// inlineCSS moves the rules of the style elements into the style attributes
+// of the matching elements as many mail clients ignore style elements. Rules
+// that can't be inlined like media queries, pseudo classes and combinators
+// are kept in a style element in the head.
+func inlineCSS(document string) (string, error) {
+	doc, err := html.Parse(strings.NewReader(document))
+	if err != nil {
+		return "", err
+	}
+
+	var styles []*html.Node
+	for n := range doc.Descendants() {
+		if n.Type == html.ElementNode && n.DataAtom == atom.Style {
+			styles = append(styles, n)
+		}
+	}
+	if len(styles) == 0 {
+		return document, nil
+	}
+
+	var css strings.Builder
+	for _, s := range styles {
+		for c := s.FirstChild; c != nil; c = c.NextSibling {
+			css.WriteString(c.Data)
+		}
+		s.Parent.RemoveChild(s)
+	}
+	rules, kept := parseCSS(css.String())
+
+	for n := range doc.Descendants() {
+		if n.Type != html.ElementNode {
+			continue
+		}
+		var matching []cssRule
+		for _, r := range rules {
+			if selectorMatches(r.selector, n) {
+				matching = append(matching, r)
+			}
+		}
+		if len(matching) == 0 {
+			continue
+		}
+		slices.SortStableFunc(matching, func(a, b cssRule) int {
+			return cmp.Or(cmp.Compare(a.specificity, b.specificity), cmp.Compare(a.order, b.order))
+		})
+		var declarations []cssDeclaration
+		for _, r := range matching {
+			declarations = append(declarations, r.declarations...)
+		}
+		// the existing inline style always wins
+		for i, a := range n.Attr {
+			if a.Key == "style" {
+				declarations = append(declarations, parseDeclarations(a.Val)...)
+				n.Attr = slices.Delete(n.Attr, i, i+1)
+				break
+			}
+		}
+		n.Attr = append(n.Attr, html.Attribute{Key: "style", Val: formatDeclarations(declarations)})
+	}
+
+	if kept != "" {
+		head := findElement(doc, atom.Head)
+		if head != nil {
+			head.AppendChild(&html.Node{
+				Type:     html.ElementNode,
+				Data:     "style",
+				DataAtom: atom.Style,
+				FirstChild: &html.Node{
+					Type: html.TextNode,
+					Data: kept,
+				},
+			})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := html.Render(&buf, doc); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func findElement(n *html.Node, a atom.Atom) *html.Node {
+	for d := range n.Descendants() {
+		if d.Type == html.ElementNode && d.DataAtom == a {
+			return d
+		}
+	}
+	return nil
+}
+
+// parseCSS returns the rules with simple selectors and the css that can't be
+// inlined
+func parseCSS(css string) ([]cssRule, string) {
+	css = cssComment.ReplaceAllString(css, "")
+
+	var rules []cssRule
+	var kept strings.Builder
+	order := 0
+	for {
+		css = strings.TrimSpace(css)
+		if css == "" {
+			break
+		}
+		open := strings.IndexByte(css, '{')
+		if open < 0 {
+			break
+		}
+		// at-rules like media queries contain nested blocks
+		if strings.HasPrefix(css, "@") {
+			end := blockEnd(css, open)
+			kept.WriteString(strings.TrimSpace(css[:end]))
+			kept.WriteString("\n")
+			css = css[end:]
+			continue
+		}
+		end := strings.IndexByte(css[open:], '}')
+		if end < 0 {
+			break
+		}
+		end += open
+		selectors := css[:open]
+		body := css[open+1 : end]
+		css = css[end+1:]
+
+		declarations := parseDeclarations(body)
+		var keptSelectors []string
+		for _, selector := range strings.Split(selectors, ",") {
+			selector = strings.TrimSpace(selector)
+			if selector == "" {
+				continue
+			}
+			specificity, ok := selectorSpecificity(selector)
+			if !ok {
+				keptSelectors = append(keptSelectors, selector)
+				continue
+			}
+			rules = append(rules, cssRule{
+				selector:     selector,
+				declarations: declarations,
+				specificity:  specificity,
+				order:        order,
+			})
+			order++
+		}
+		if len(keptSelectors) > 0 {
+			kept.WriteString(strings.Join(keptSelectors, ", "))
+			kept.WriteString(" { ")
+			kept.WriteString(formatDeclarations(declarations))
+			kept.WriteString(" }\n")
+		}
+	}
+	return rules, kept.String()
+}
+
+// blockEnd returns the index after the block starting at open
+func blockEnd(css string, open int) int {
+	depth := 0
+	for i := open; i < len(css); i++ {
+		switch css[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return len(css)
+}
+
+func parseDeclarations(s string) []cssDeclaration {
+	var declarations []cssDeclaration
+	for _, d := range strings.Split(s, ";") {
+		property, value, ok := strings.Cut(d, ":")
+		if !ok {
+			continue
+		}
+		property = strings.ToLower(strings.TrimSpace(property))
+		value = strings.TrimSpace(value)
+		if property == "" || value == "" {
+			continue
+		}
+		declarations = append(declarations, cssDeclaration{property: property, value: value})
+	}
+	return declarations
+}
+
+// formatDeclarations formats the declarations, later declarations of the
+// same property replace the earlier ones
+func formatDeclarations(declarations []cssDeclaration) string {
+	var result []cssDeclaration
+	for _, d := range declarations {
+		idx := slices.IndexFunc(result, func(r cssDeclaration) bool { return r.property == d.property })
+		if idx >= 0 {
+			result = slices.Delete(result, idx, idx+1)
+		}
+		result = append(result, d)
+	}
+	parts := make([]string, 0, len(result))
+	for _, d := range result {
+		parts = append(parts, d.property+": "+d.value)
+	}
+	return strings.Join(parts, "; ")
+}
+
+func selectorSpecificity(selector string) (int, bool) {
+	m := simpleSelector.FindStringSubmatch(selector)
+	if m == nil {
+		return 0, false
+	}
+	specificity := 0
+	if m[1] != "" && m[1] != "*" {
+		specificity++
+	}
+	for _, part := range selectorPart.FindAllString(m[2], -1) {
+		if part[0] == '#' {
+			specificity += 10000
+		} else {
+			specificity += 100
+		}
+	}
+	return specificity, true
+}
+
+func selectorMatches(selector string, n *html.Node) bool {
+	m := simpleSelector.FindStringSubmatch(selector)
+	if m == nil {
+		return false
+	}
+	if m[1] != "" && m[1] != "*" && !strings.EqualFold(m[1], n.Data) {
+		return false
+	}
+	var id string
+	var classes []string
+	for _, a := range n.Attr {
+		switch a.Key {
+		case "id":
+			id = a.Val
+		case "class":
+			classes = strings.Fields(a.Val)
+		}
+	}
+	for _, part := range selectorPart.FindAllString(m[2], -1) {
+		switch part[0] {
+		case '#':
+			if part[1:] != id {
+				return false
+			}
+		case '.':
+			if !slices.Contains(classes, part[1:]) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,53 @@
+package mail
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInlineCSS(t *testing.T) {
+	t.Parallel()
+
+	out, err := inlineCSS(`<html><head><style>
+/* comment */
+p { color: red; margin: 0 }
+.note, #intro { color: blue }
+p.note { font-weight: bold }
+a:hover { color: green }
+div p { padding: 1px }
+@media (max-width: 600px) { p { margin: 4px } }
+</style></head><body>
+<p id="intro">intro</p>
+<p class="note other" style="color: black">note</p>
+<p>plain</p>
+<a href="#">link</a>
+</body></html>`)
+	require.NoError(t, err)
+
+	require.Contains(t, out, `<p id="intro" style="margin: 0; color: blue">intro</p>`)
+	// the existing inline style wins over all rules
+	require.Contains(t, out, `<p class="note other" style="margin: 0; font-weight: bold; color: black">note</p>`)
+	require.Contains(t, out, `<p style="color: red; margin: 0">plain</p>`)
+	require.Contains(t, out, `<a href="#">link</a>`)
+	// rules that can't be inlined are kept
+	require.Contains(t, out, "a:hover { color: green }")
+	require.Contains(t, out, "div p { padding: 1px }")
+	require.Contains(t, out, "@media (max-width: 600px) { p { margin: 4px } }")
+	require.NotContains(t, out, "comment")
+}
+
+func TestInlineCSSWithoutStyle(t *testing.T) {
+	t.Parallel()
+
+	in := `<p>no styles</p>`
+	out, err := inlineCSS(in)
+	require.NoError(t, err)
+	require.Equal(t, in, out)
+
+	// the style element is removed if everything is inlined
+	out, err = inlineCSS(`<html><head><style>p { color: red }</style></head><body><p>x</p></body></html>`)
+	require.NoError(t, err)
+	require.NotContains(t, out, "<style>")
+	require.Contains(t, out, `<p style="color: red">x</p>`)
+}
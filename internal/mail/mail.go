@@ -1,18 +1,27 @@
 package mail
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
 	"errors"
 	"fmt"
 	"log/slog"
+	"time"
 
 	"github.com/firefart/go-webserver-template/internal/config"
 
 	gomail "github.com/wneessen/go-mail"
+	"github.com/wneessen/go-mail/smtp"
 )
 
 type Interface interface {
+	// Send sends the message to its recipients and returns after delivery
+	Send(ctx context.Context, msg Message) error
+	// Enqueue queues the message for an asynchronous delivery
+	Enqueue(ctx context.Context, msg Message) error
+	// SendHTMLEmail, SendTXTEmail and SendMultipartEmail send a message to
+	// each of the configured recipients
 	SendHTMLEmail(ctx context.Context, subject, body string) error
 	SendTXTEmail(ctx context.Context, subject, body string) error
 	SendMultipartEmail(ctx context.Context, subject, textBody, htmlBody string) error
@@ -22,6 +31,16 @@ type Mail struct {
 	client *gomail.Client
 	config config.Configuration
 	logger *slog.Logger
+	// dkim signs every message if configured
+	dkim *gomail.DKIMSigner
+	// dial opens a new smtp session, replaced in tests
+	dial func(ctx context.Context) (session, error)
+}
+
+// session sends messages over a single smtp connection
+type session interface {
+	send(msg Message) error
+	close() error
 }
 
 // compile time check that struct implements the interface
@@ -29,6 +48,14 @@ type Mail struct {
 
 type NullMailer struct{}
 
+func (NullMailer) Send(_ context.Context, _ Message) error {
+	return nil
+}
+
+func (NullMailer) Enqueue(_ context.Context, _ Message) error {
+	return nil
+}
+
 func (NullMailer) SendHTMLEmail(_ context.Context, _, _ string) error {
 	return nil
 }
@@ -75,68 +102,102 @@ func New(config config.Configuration, logger *slog.Logger) (*Mail, error) {
 		return nil, fmt.Errorf("could not create mail client: %w", err)
 	}
 
-	return &Mail{
+	m := &Mail{
 		client: mailer,
 		config: config,
 		logger: logger,
-	}, nil
-}
-
-func (m *Mail) SendHTMLEmail(ctx context.Context, subject, body string) error {
-	for _, to := range m.config.Mail.To {
-		if err := m.send(ctx, to, subject, "", body); err != nil {
-			return err
+	}
+	if config.Mail.DKIM.Enabled {
+		m.dkim, err = NewDKIMSigner(config.Mail.DKIM)
+		if err != nil {
+			return nil, err
 		}
 	}
+	m.dial = m.dialSMTP
+	return m, nil
+}
 
-	return nil
+type smtpSession struct {
+	m      *Mail
+	client *smtp.Client
 }
 
-func (m *Mail) SendTXTEmail(ctx context.Context, subject, body string) error {
-	for _, to := range m.config.Mail.To {
-		if err := m.send(ctx, to, subject, body, ""); err != nil {
-			return err
-		}
+func (m *Mail) dialSMTP(ctx context.Context) (session, error) {
+	client, err := m.client.DialToSMTPClientWithContext(ctx)
+	if err != nil {
+		return nil, err
 	}
-
-	return nil
+	return &smtpSession{m: m, client: client}, nil
 }
 
-func (m *Mail) SendMultipartEmail(ctx context.Context, subject, textBody, htmlBody string) error {
-	for _, to := range m.config.Mail.To {
-		if err := m.send(ctx, to, subject, textBody, htmlBody); err != nil {
-			return err
-		}
+func (s *smtpSession) send(msg Message) error {
+	gomsg, err := s.m.newMsg(msg)
+	if err != nil {
+		return err
 	}
+	return s.m.client.SendWithSMTPClient(s.client, gomsg)
+}
 
-	return nil
+func (s *smtpSession) close() error {
+	return s.m.client.CloseWithSMTPClient(s.client)
 }
 
-func (m *Mail) send(ctx context.Context, to string, subject, textContent, htmlContent string) error {
-	if textContent == "" && htmlContent == "" {
-		return errors.New("need a content to send email")
-	}
+func (m *Mail) SendHTMLEmail(ctx context.Context, subject, body string) error {
+	return m.sendToConfigured(ctx, subject, "", body)
+}
 
-	m.logger.Debug("sending email", slog.String("subject", subject), slog.String("to", to), slog.String("content-text", textContent), slog.String("html-content", htmlContent))
+func (m *Mail) SendTXTEmail(ctx context.Context, subject, body string) error {
+	return m.sendToConfigured(ctx, subject, body, "")
+}
 
-	msg := gomail.NewMsg(gomail.WithNoDefaultUserAgent())
-	if err := msg.FromFormat(m.config.Mail.From.Name, m.config.Mail.From.Mail); err != nil {
-		return err
-	}
-	if err := msg.To(to); err != nil {
+func (m *Mail) SendMultipartEmail(ctx context.Context, subject, textBody, htmlBody string) error {
+	return m.sendToConfigured(ctx, subject, textBody, htmlBody)
+}
+
+// sendToConfigured sends a separate message to each configured recipient
+func (m *Mail) sendToConfigured(ctx context.Context, subject, textContent, htmlContent string) error {
+	msgs, err := configuredMessages(m.config.Mail.To, subject, textContent, htmlContent)
+	if err != nil {
 		return err
 	}
-	msg.Subject(subject)
-	if textContent != "" {
-		msg.SetBodyString(gomail.TypeTextPlain, textContent)
+	return m.send(ctx, msgs...)
+}
+
+// configuredMessages returns one message per recipient so the recipients
+// don't see each other
+func configuredMessages(to []string, subject, textContent, htmlContent string) ([]Message, error) {
+	msgs := make([]Message, 0, len(to))
+	for _, rcpt := range to {
+		msg, err := NewMessage().To(rcpt).Subject(subject).TextBody(textContent).HTMLBody(htmlContent).Build()
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, msg)
 	}
-	if htmlContent != "" {
-		msg.SetBodyString(gomail.TypeTextHTML, htmlContent)
+	return msgs, nil
+}
+
+func (m *Mail) Send(ctx context.Context, msg Message) error {
+	if err := msg.Validate(); err != nil {
+		return err
 	}
+	return m.send(ctx, msg)
+}
 
+// Enqueue sends the message immediately as Mail has no queue, use Queue for
+// an asynchronous delivery
+func (m *Mail) Enqueue(ctx context.Context, msg Message) error {
+	return m.Send(ctx, msg)
+}
+
+// send delivers the messages over one smtp session. Failed messages are
+// retried with an exponential backoff on a new session.
+func (m *Mail) send(ctx context.Context, msgs ...Message) error {
+	pending := msgs
+	wait := m.config.Mail.RetryBackoff
 	var err error
 	for i := 1; i <= m.config.Mail.Retries; i++ {
-		err = m.client.DialAndSendWithContext(ctx, msg)
+		pending, err = m.sendSession(ctx, pending)
 		if err == nil {
 			return nil
 		}
@@ -144,7 +205,95 @@ func (m *Mail) send(ctx context.Context, to string, subject, textContent, htmlCo
 		if errors.Is(err, context.Canceled) {
 			return err
 		}
-		m.logger.Error("error on sending email", slog.String("subject", subject), slog.Int("try", i), slog.String("err", err.Error()))
+		m.logger.Error("error on sending email", slog.Int("try", i), slog.Int("pending", len(pending)), slog.String("err", err.Error()))
+		if i == m.config.Mail.Retries {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+	return fmt.Errorf("could not send %d mails after %d retries. Last error: %w", len(pending), m.config.Mail.Retries, err)
+}
+
+// sendSession sends the messages over a new session and returns the messages
+// not sent because of an error
+func (m *Mail) sendSession(ctx context.Context, msgs []Message) ([]Message, error) {
+	sess, err := m.dial(ctx)
+	if err != nil {
+		return msgs, fmt.Errorf("could not connect to mail server: %w", err)
+	}
+	defer func() {
+		if err := sess.close(); err != nil {
+			m.logger.Debug("error on closing smtp session", slog.String("err", err.Error()))
+		}
+	}()
+
+	for i, msg := range msgs {
+		m.logger.Debug("sending email", slog.String("subject", msg.Subject), slog.Any("to", msg.To), slog.Any("cc", msg.CC), slog.Any("bcc", msg.BCC), slog.String("content-text", msg.TextBody), slog.String("html-content", msg.HTMLBody), slog.Int("attachments", len(msg.Attachments)))
+		if err := sess.send(msg); err != nil {
+			return msgs[i:], err
+		}
+	}
+	return nil, nil
+}
+
+// newMsg converts the message into a go-mail message with the configured sender
+func (m *Mail) newMsg(msg Message) (*gomail.Msg, error) {
+	gomsg := gomail.NewMsg(gomail.WithNoDefaultUserAgent())
+	if m.dkim != nil {
+		gomsg.SetDKIM(m.dkim)
+	}
+	if err := gomsg.FromFormat(m.config.Mail.From.Name, m.config.Mail.From.Mail); err != nil {
+		return nil, err
+	}
+	if len(msg.To) > 0 {
+		if err := gomsg.To(msg.To...); err != nil {
+			return nil, err
+		}
+	}
+	if len(msg.CC) > 0 {
+		if err := gomsg.Cc(msg.CC...); err != nil {
+			return nil, err
+		}
+	}
+	if len(msg.BCC) > 0 {
+		if err := gomsg.Bcc(msg.BCC...); err != nil {
+			return nil, err
+		}
+	}
+	if msg.ReplyTo != "" {
+		if err := gomsg.ReplyTo(msg.ReplyTo); err != nil {
+			return nil, err
+		}
+	}
+	for name, value := range msg.Headers {
+		gomsg.SetGenHeader(gomail.Header(name), value)
+	}
+	gomsg.Subject(msg.Subject)
+	if msg.TextBody != "" {
+		gomsg.SetBodyString(gomail.TypeTextPlain, msg.TextBody)
+	}
+	if msg.HTMLBody != "" {
+		if msg.TextBody != "" {
+			gomsg.AddAlternativeString(gomail.TypeTextHTML, msg.HTMLBody)
+		} else {
+			gomsg.SetBodyString(gomail.TypeTextHTML, msg.HTMLBody)
+		}
+	}
+	for _, a := range msg.Attachments {
+		var opts []gomail.FileOption
+		if a.ContentType != "" {
+			opts = append(opts, gomail.WithFileContentType(gomail.ContentType(a.ContentType)))
+		}
+		if a.Inline {
+			gomsg.EmbedReadSeeker(a.Filename, bytes.NewReader(a.Content), opts...)
+		} else {
+			gomsg.AttachReadSeeker(a.Filename, bytes.NewReader(a.Content), opts...)
+		}
 	}
-	return fmt.Errorf("could not send mail %q after %d retries. Last error: %w", subject, m.config.Mail.Retries, err)
+	return gomsg, nil
 }
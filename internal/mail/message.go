@@ -0,0 +1,175 @@
+package mail
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/mail"
+	"path"
+)
+
+// Message is a mail with its own recipients, use NewMessage to build it
+type Message struct {
+	To       []string
+	CC       []string
+	BCC      []string
+	ReplyTo  string
+	Subject  string
+	TextBody string
+	HTMLBody string
+	// Headers are additional headers, the address and content headers are
+	// set from the other fields
+	Headers     map[string]string
+	Attachments []Attachment
+}
+
+// Attachment is a file attached to a message. Inline attachments are
+// referenced from the html body with cid:<Filename>.
+type Attachment struct {
+	Filename string
+	// ContentType is detected from the content if empty
+	ContentType string
+	Content     []byte
+	Inline      bool
+}
+
+// Validate checks that the message can be sent
+func (m Message) Validate() error {
+	var errs []error
+	if len(m.To)+len(m.CC)+len(m.BCC) == 0 {
+		errs = append(errs, errors.New("need a recipient to send email"))
+	}
+	for _, addrs := range [][]string{m.To, m.CC, m.BCC} {
+		for _, addr := range addrs {
+			if _, err := mail.ParseAddress(addr); err != nil {
+				errs = append(errs, fmt.Errorf("invalid address %q: %w", addr, err))
+			}
+		}
+	}
+	if m.ReplyTo != "" {
+		if _, err := mail.ParseAddress(m.ReplyTo); err != nil {
+			errs = append(errs, fmt.Errorf("invalid reply-to address %q: %w", m.ReplyTo, err))
+		}
+	}
+	if m.TextBody == "" && m.HTMLBody == "" {
+		errs = append(errs, errors.New("need a content to send email"))
+	}
+	for _, a := range m.Attachments {
+		if a.Filename == "" {
+			errs = append(errs, errors.New("attachment without a filename"))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// MessageBuilder builds a message. Errors are collected and returned by Build
+// so the setters can be chained.
+type MessageBuilder struct {
+	msg Message
+	err error
+}
+
+func NewMessage() *MessageBuilder {
+	return &MessageBuilder{
+		msg: Message{
+			Headers: make(map[string]string),
+		},
+	}
+}
+
+func (b *MessageBuilder) To(addrs ...string) *MessageBuilder {
+	b.msg.To = append(b.msg.To, addrs...)
+	return b
+}
+
+func (b *MessageBuilder) CC(addrs ...string) *MessageBuilder {
+	b.msg.CC = append(b.msg.CC, addrs...)
+	return b
+}
+
+func (b *MessageBuilder) BCC(addrs ...string) *MessageBuilder {
+	b.msg.BCC = append(b.msg.BCC, addrs...)
+	return b
+}
+
+func (b *MessageBuilder) ReplyTo(addr string) *MessageBuilder {
+	b.msg.ReplyTo = addr
+	return b
+}
+
+func (b *MessageBuilder) Subject(subject string) *MessageBuilder {
+	b.msg.Subject = subject
+	return b
+}
+
+func (b *MessageBuilder) TextBody(body string) *MessageBuilder {
+	b.msg.TextBody = body
+	return b
+}
+
+func (b *MessageBuilder) HTMLBody(body string) *MessageBuilder {
+	b.msg.HTMLBody = body
+	return b
+}
+
+func (b *MessageBuilder) Header(name, value string) *MessageBuilder {
+	b.msg.Headers[name] = value
+	return b
+}
+
+// Attach adds an attachment that is already in memory
+func (b *MessageBuilder) Attach(attachment Attachment) *MessageBuilder {
+	b.msg.Attachments = append(b.msg.Attachments, attachment)
+	return b
+}
+
+// AttachReader reads r and attaches the content as filename
+func (b *MessageBuilder) AttachReader(filename string, r io.Reader) *MessageBuilder {
+	return b.attachReader(filename, r, false)
+}
+
+// AttachFS attaches the file name of fsys, the attachment is named after the
+// base name of the file
+func (b *MessageBuilder) AttachFS(fsys fs.FS, name string) *MessageBuilder {
+	return b.attachFS(fsys, name, false)
+}
+
+// InlineReader reads r and embeds the content as filename, the html body
+// references it with cid:<filename>
+func (b *MessageBuilder) InlineReader(filename string, r io.Reader) *MessageBuilder {
+	return b.attachReader(filename, r, true)
+}
+
+// InlineFS embeds the file name of fsys, the html body references it with
+// cid:<base name of the file>
+func (b *MessageBuilder) InlineFS(fsys fs.FS, name string) *MessageBuilder {
+	return b.attachFS(fsys, name, true)
+}
+
+func (b *MessageBuilder) attachReader(filename string, r io.Reader, inline bool) *MessageBuilder {
+	// read the content now so the message can be retried
+	content, err := io.ReadAll(r)
+	if err != nil {
+		b.err = errors.Join(b.err, fmt.Errorf("could not read attachment %s: %w", filename, err))
+		return b
+	}
+	return b.Attach(Attachment{Filename: filename, Content: content, Inline: inline})
+}
+
+func (b *MessageBuilder) attachFS(fsys fs.FS, name string, inline bool) *MessageBuilder {
+	content, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		b.err = errors.Join(b.err, fmt.Errorf("could not read attachment %s: %w", name, err))
+		return b
+	}
+	return b.Attach(Attachment{Filename: path.Base(name), Content: content, Inline: inline})
+}
+
+// Build returns the message or the errors of the builder and the validation
+func (b *MessageBuilder) Build() (Message, error) {
+	if err := errors.Join(b.err, b.msg.Validate()); err != nil {
+		return Message{}, err
+	}
+	return b.msg, nil
+}
@@ -0,0 +1,176 @@
+package mail
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/firefart/go-webserver-template/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+type errReader struct{}
+
+func (errReader) Read(_ []byte) (int, error) {
+	return 0, errors.New("read failed")
+}
+
+func newTestMail(t *testing.T) *Mail {
+	t.Helper()
+	var c config.Configuration
+	c.Mail.Server = "localhost"
+	c.Mail.Port = 25
+	c.Mail.From.Name = "Sender"
+	c.Mail.From.Mail = "sender@example.com"
+	c.Mail.Retries = 1
+	c.Mail.Timeout = time.Second
+	m, err := New(c, slog.New(slog.DiscardHandler))
+	require.NoError(t, err)
+	return m
+}
+
+func TestMessageBuilder(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		"assets/logo.png": &fstest.MapFile{Data: []byte("png")},
+	}
+	msg, err := NewMessage().
+		To("user@example.com").
+		CC("cc@example.com").
+		BCC("bcc@example.com").
+		ReplyTo("support@example.com").
+		Header("X-Request-ID", "1").
+		Subject("Password reset").
+		TextBody("text").
+		HTMLBody(`<img src="cid:logo.png">`).
+		AttachReader("report.csv", strings.NewReader("a,b")).
+		InlineFS(fsys, "assets/logo.png").
+		Build()
+	require.NoError(t, err)
+	require.Equal(t, []string{"user@example.com"}, msg.To)
+	require.Equal(t, []string{"cc@example.com"}, msg.CC)
+	require.Equal(t, []string{"bcc@example.com"}, msg.BCC)
+	require.Equal(t, "support@example.com", msg.ReplyTo)
+	require.Equal(t, map[string]string{"X-Request-ID": "1"}, msg.Headers)
+	require.Equal(t, []Attachment{
+		{Filename: "report.csv", Content: []byte("a,b")},
+		{Filename: "logo.png", Content: []byte("png"), Inline: true},
+	}, msg.Attachments)
+}
+
+func TestMessageBuilderErrors(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewMessage().Subject("subject").TextBody("text").Build()
+	require.ErrorContains(t, err, "need a recipient")
+
+	_, err = NewMessage().To("user@example.com").Build()
+	require.ErrorContains(t, err, "need a content")
+
+	_, err = NewMessage().To("not an address").ReplyTo("invalid").TextBody("text").Build()
+	require.ErrorContains(t, err, `invalid address "not an address"`)
+	require.ErrorContains(t, err, `invalid reply-to address "invalid"`)
+
+	// attachment errors are reported on build
+	_, err = NewMessage().To("user@example.com").TextBody("text").
+		AttachReader("file.txt", errReader{}).
+		AttachFS(fstest.MapFS{}, "missing.txt").
+		Build()
+	require.ErrorContains(t, err, "could not read attachment file.txt: read failed")
+	require.ErrorContains(t, err, "could not read attachment missing.txt")
+}
+
+func TestNewMsg(t *testing.T) {
+	t.Parallel()
+
+	msg, err := NewMessage().
+		To("user@example.com").
+		CC("cc@example.com").
+		BCC("bcc@example.com").
+		ReplyTo("support@example.com").
+		Header("X-Request-ID", "42").
+		Subject("Password reset").
+		TextBody("plain text").
+		HTMLBody(`<img src="cid:logo.png">`).
+		Attach(Attachment{Filename: "report.csv", ContentType: "text/csv", Content: []byte("a,b")}).
+		InlineReader("logo.png", strings.NewReader("png")).
+		Build()
+	require.NoError(t, err)
+
+	gomsg, err := newTestMail(t).newMsg(msg)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	_, err = gomsg.WriteTo(&buf)
+	require.NoError(t, err)
+	raw := buf.String()
+
+	require.Contains(t, raw, `From: "Sender" <sender@example.com>`)
+	require.Contains(t, raw, "To: <user@example.com>")
+	require.Contains(t, raw, "Cc: <cc@example.com>")
+	// bcc recipients are not part of the headers
+	require.NotContains(t, raw, "bcc@example.com")
+	require.Contains(t, raw, "Reply-To: <support@example.com>")
+	require.Contains(t, raw, "X-Request-ID: 42")
+	require.Contains(t, raw, "Subject: Password reset")
+	require.Contains(t, raw, "multipart/alternative")
+	require.Contains(t, raw, "plain text")
+	require.Contains(t, raw, `filename="report.csv"`)
+	require.Contains(t, raw, "Content-Type: text/csv; name=\"report.csv\"")
+	require.Contains(t, raw, "Content-Id: <logo.png>")
+	// but they receive the message
+	recipients, err := gomsg.GetRecipients()
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"<user@example.com>", "<cc@example.com>", "<bcc@example.com>"}, recipients)
+}
+
+func newTestCapture(t *testing.T, to ...string) *Capture {
+	t.Helper()
+	var c config.Configuration
+	c.Mail.To = to
+	capture, err := NewCapture(c)
+	require.NoError(t, err)
+	return capture
+}
+
+func TestCapture(t *testing.T) {
+	t.Parallel()
+
+	m := newTestCapture(t, "one@example.com", "two@example.com")
+	require.NoError(t, m.SendTXTEmail(t.Context(), "subject", "body"))
+	msg, err := NewMessage().To("three@example.com").HTMLBody("<b>body</b>").Build()
+	require.NoError(t, err)
+	require.NoError(t, m.Enqueue(t.Context(), msg))
+	require.Error(t, m.Send(t.Context(), Message{}))
+
+	messages := m.Messages()
+	require.Len(t, messages, 3)
+	require.Equal(t, []string{"one@example.com"}, messages[0].To)
+	require.Equal(t, "body", messages[1].TextBody)
+	require.Equal(t, []string{"three@example.com"}, messages[2].To)
+
+	mails := m.Mails()
+	require.Len(t, mails, 3)
+	captured, ok := m.Mail(mails[2].ID)
+	require.True(t, ok)
+	require.Contains(t, string(captured.Raw), "To: <three@example.com>")
+	require.Contains(t, string(captured.Raw), "From: \"Capture\" <capture@localhost>")
+	_, ok = m.Mail(100)
+	require.False(t, ok)
+}
+
+func TestCaptureLimit(t *testing.T) {
+	t.Parallel()
+
+	m := newTestCapture(t, "user@example.com")
+	for range captureLimit + 5 {
+		require.NoError(t, m.SendTXTEmail(t.Context(), "subject", "body"))
+	}
+	mails := m.Mails()
+	require.Len(t, mails, captureLimit)
+	require.Equal(t, 6, mails[0].ID)
+}
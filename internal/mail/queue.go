@@ -0,0 +1,284 @@
+package mail
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/firefart/go-webserver-template/internal/config"
+	"github.com/firefart/go-webserver-template/internal/database"
+	"github.com/firefart/go-webserver-template/internal/metrics"
+	"github.com/firefart/go-webserver-template/internal/notification"
+)
+
+// the states of a mail in the queue
+const (
+	statusPending = "pending"
+	statusFailed  = "failed"
+)
+
+// Queue persists enqueued mails in the database and delivers them in the
+// background. Each worker reuses one smtp session for all mails of a batch.
+// The synchronous methods are passed through to the mailer.
+type Queue struct {
+	logger  *slog.Logger
+	db      database.Interface
+	metrics *metrics.Metrics
+	mailer  *Mail
+	config  config.MailQueue
+	// notifier is told about mails that failed permanently, it may be nil
+	notifier notification.Notifier
+	now      func() time.Time
+
+	wake chan struct{}
+	// closed when Run returns so Drain never delivers concurrently to it
+	done chan struct{}
+}
+
+// compile time check that struct implements the interface
+var _ Interface = (*Queue)(nil)
+
+// NewQueue creates a new queue delivering over the given mailer
+func NewQueue(logger *slog.Logger, db database.Interface, m *metrics.Metrics, mailer *Mail, configuration config.MailQueue, notifier notification.Notifier) *Queue {
+	return &Queue{
+		logger:   logger,
+		db:       db,
+		metrics:  m,
+		mailer:   mailer,
+		config:   configuration,
+		notifier: notifier,
+		now:      time.Now,
+		wake:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+}
+
+func (q *Queue) Send(ctx context.Context, msg Message) error {
+	return q.mailer.Send(ctx, msg)
+}
+
+func (q *Queue) SendHTMLEmail(ctx context.Context, subject, body string) error {
+	return q.mailer.SendHTMLEmail(ctx, subject, body)
+}
+
+func (q *Queue) SendTXTEmail(ctx context.Context, subject, body string) error {
+	return q.mailer.SendTXTEmail(ctx, subject, body)
+}
+
+func (q *Queue) SendMultipartEmail(ctx context.Context, subject, textBody, htmlBody string) error {
+	return q.mailer.SendMultipartEmail(ctx, subject, textBody, htmlBody)
+}
+
+// Enqueue persists the message for the delivery by Run. It only returns an
+// error if the message is invalid or could not be persisted.
+func (q *Queue) Enqueue(ctx context.Context, msg Message) error {
+	if err := msg.Validate(); err != nil {
+		return err
+	}
+	encoded, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("could not encode mail: %w", err)
+	}
+	if _, err := q.db.InsertMail(ctx, msg.Subject, string(encoded), q.now()); err != nil {
+		return fmt.Errorf("could not queue mail: %w", err)
+	}
+	q.metrics.MailsQueued.Inc()
+
+	// trigger the delivery without waiting for the next poll
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Run delivers queued mails until the context is cancelled. Call Drain
+// afterwards to deliver the mails still due.
+func (q *Queue) Run(ctx context.Context) {
+	defer close(q.done)
+
+	ticker := time.NewTicker(q.config.PollInterval)
+	defer ticker.Stop()
+	for {
+		// in-flight deliveries should not be cut off by the shutdown
+		q.process(context.WithoutCancel(ctx), ctx.Done())
+
+		select {
+		case <-ticker.C:
+		case <-q.wake:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Drain waits for Run to return and delivers all mails that are due until the
+// context is done. Mails that still fail stay in the database and are retried
+// on the next start. Run must have been started, it finishes the mails in
+// flight so the database can be closed once Drain returned.
+func (q *Queue) Drain(ctx context.Context) error {
+	<-q.done
+
+	q.process(ctx, ctx.Done())
+	return ctx.Err()
+}
+
+// process delivers batches of due mails until there are no more or stop is
+// closed
+func (q *Queue) process(ctx context.Context, stop <-chan struct{}) {
+	defer q.updateQueueSize(ctx)
+
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		due, err := q.db.GetDueMails(ctx, q.now(), int64(q.config.BatchSize))
+		if err != nil {
+			q.logger.Error("could not get due mails", slog.String("err", err.Error()))
+			return
+		}
+		if len(due) == 0 {
+			return
+		}
+		q.deliverBatch(ctx, due, stop)
+	}
+}
+
+func (q *Queue) deliverBatch(ctx context.Context, batch []database.OutboxMail, stop <-chan struct{}) {
+	jobs := make(chan database.OutboxMail)
+	var wg sync.WaitGroup
+	for range min(q.config.Workers, len(batch)) {
+		wg.Go(func() {
+			// the session is opened on the first mail and reused for the
+			// following ones until an error occurs
+			var sess session
+			defer func() {
+				if sess != nil {
+					q.closeSession(sess)
+				}
+			}()
+			for m := range jobs {
+				var err error
+				sess, err = q.deliver(ctx, sess, m)
+				if err != nil {
+					q.failed(ctx, m, err)
+				}
+			}
+		})
+	}
+	for _, m := range batch {
+		select {
+		case <-stop:
+		case jobs <- m:
+			continue
+		}
+		// the rest of the batch stays due
+		break
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// deliver sends the mail over the session, opening a new one if needed. The
+// returned session is nil if it can't be reused.
+func (q *Queue) deliver(ctx context.Context, sess session, m database.OutboxMail) (session, error) {
+	var msg Message
+	if err := json.Unmarshal([]byte(m.Message), &msg); err != nil {
+		return sess, fmt.Errorf("could not decode mail: %w", err)
+	}
+
+	if sess == nil {
+		var err error
+		sess, err = q.mailer.dial(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("could not connect to mail server: %w", err)
+		}
+	}
+
+	if err := sess.send(msg); err != nil {
+		q.closeSession(sess)
+		return nil, err
+	}
+
+	q.logger.Debug("mail sent", slog.Int64("id", m.ID), slog.String("subject", m.Subject))
+	q.metrics.MailsSent.Inc()
+	if err := q.db.DeleteMail(ctx, m.ID); err != nil {
+		q.logger.Error("could not delete sent mail", slog.Int64("id", m.ID), slog.String("err", err.Error()))
+	}
+	return sess, nil
+}
+
+func (q *Queue) closeSession(sess session) {
+	if err := sess.close(); err != nil {
+		q.logger.Debug("error on closing smtp session", slog.String("err", err.Error()))
+	}
+}
+
+// failed reschedules the mail or moves it to the failed state once it
+// exhausted its attempts
+func (q *Queue) failed(ctx context.Context, m database.OutboxMail, sendErr error) {
+	logger := q.logger.With(slog.Int64("id", m.ID), slog.String("subject", m.Subject))
+
+	q.metrics.MailSendErrors.Inc()
+	attempts := m.Attempts + 1
+	if attempts >= int64(q.config.MaxAttempts) {
+		failLogger := logger
+		if q.notifier != nil {
+			// the error gets its own notification instead of the log forwarding
+			failLogger = logger.With(notification.SkipNotify)
+		}
+		failLogger.Error("mail failed permanently", slog.Int64("attempts", attempts), slog.String("err", sendErr.Error()))
+		q.metrics.MailsFailed.Inc()
+		if err := q.db.FailMail(ctx, m.ID, attempts, sendErr.Error()); err != nil {
+			logger.Error("could not mark mail as failed", slog.String("err", err.Error()))
+		}
+		if q.notifier != nil {
+			if err := q.notifier.Notify(ctx, notification.Notification{
+				Severity: notification.SeverityError,
+				Subject:  "mail failed permanently",
+				Message:  fmt.Sprintf("Subject: %s\nAttempts: %d\nError: %s", m.Subject, attempts, sendErr),
+				Source:   "mail",
+				Tags:     []string{"mail:failed"},
+			}); err != nil {
+				logger.Error("error on notification send", slog.String("err", err.Error()))
+			}
+		}
+		return
+	}
+
+	next := q.now().Add(q.backoff(attempts))
+	logger.Warn("mail failed, retrying later", slog.Int64("attempts", attempts), slog.Time("next_attempt", next), slog.String("err", sendErr.Error()))
+	if err := q.db.RetryMail(ctx, m.ID, attempts, next, sendErr.Error()); err != nil {
+		logger.Error("could not reschedule mail", slog.String("err", err.Error()))
+	}
+}
+
+// backoff returns the exponential wait time after the given number of failed
+// attempts
+func (q *Queue) backoff(attempts int64) time.Duration {
+	wait := q.config.InitialBackoff
+	for i := int64(1); i < attempts; i++ {
+		wait *= 2
+		if wait >= q.config.MaxBackoff {
+			return q.config.MaxBackoff
+		}
+	}
+	return min(wait, q.config.MaxBackoff)
+}
+
+func (q *Queue) updateQueueSize(ctx context.Context) {
+	counts, err := q.db.CountMailsByStatus(ctx)
+	if err != nil {
+		q.logger.Error("could not count queued mails", slog.String("err", err.Error()))
+		return
+	}
+	for _, status := range []string{statusPending, statusFailed} {
+		q.metrics.MailQueueSize.WithLabelValues(status).Set(float64(counts[status]))
+	}
+}
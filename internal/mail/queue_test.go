@@ -0,0 +1,243 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/firefart/go-webserver-template/internal/config"
+	"github.com/firefart/go-webserver-template/internal/database"
+	"github.com/firefart/go-webserver-template/internal/metrics"
+	"github.com/firefart/go-webserver-template/internal/notification"
+	"github.com/firefart/go-webserver-template/internal/testutil"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeServer records the messages sent over its sessions
+type fakeServer struct {
+	mu       sync.Mutex
+	sessions int
+	failures int
+	messages []Message
+	// block holds every send until it is closed, started is signaled first
+	block   chan struct{}
+	started chan struct{}
+}
+
+type fakeSession struct {
+	server *fakeServer
+}
+
+func (s *fakeServer) dial(_ context.Context) (session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions++
+	return &fakeSession{server: s}, nil
+}
+
+func (s *fakeSession) send(msg Message) error {
+	if s.server.block != nil {
+		s.server.started <- struct{}{}
+		<-s.server.block
+	}
+	s.server.mu.Lock()
+	defer s.server.mu.Unlock()
+	if s.server.failures > 0 {
+		s.server.failures--
+		return errors.New("server down")
+	}
+	s.server.messages = append(s.server.messages, msg)
+	return nil
+}
+
+func (s *fakeSession) close() error {
+	return nil
+}
+
+func (s *fakeServer) stats() (int, []Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sessions, append([]Message(nil), s.messages...)
+}
+
+func counterValue(t *testing.T, c prometheus.Counter) float64 {
+	t.Helper()
+	var m dto.Metric
+	require.NoError(t, c.Write(&m))
+	return m.GetCounter().GetValue()
+}
+
+func gaugeValue(t *testing.T, g prometheus.Gauge) float64 {
+	t.Helper()
+	var m dto.Metric
+	require.NoError(t, g.Write(&m))
+	return m.GetGauge().GetValue()
+}
+
+func newTestQueue(t *testing.T, server *fakeServer) (*Queue, *database.Database, *metrics.Metrics) {
+	t.Helper()
+
+	mailer := newTestMail(t)
+	mailer.dial = server.dial
+	db := testutil.NewDB(t)
+	m, err := metrics.NewMetrics(prometheus.NewRegistry())
+	require.NoError(t, err)
+	q := NewQueue(slog.New(slog.DiscardHandler), db, m, mailer, config.MailQueue{
+		Workers:        1,
+		MaxAttempts:    2,
+		InitialBackoff: 1 * time.Minute,
+		MaxBackoff:     5 * time.Minute,
+		PollInterval:   1 * time.Hour,
+		BatchSize:      10,
+	}, nil)
+	return q, db, m
+}
+
+func testMessage(t *testing.T, subject string) Message {
+	t.Helper()
+	msg, err := NewMessage().To("user@example.com").Subject(subject).TextBody("body").Attach(Attachment{Filename: "file.txt", ContentType: "text/plain", Content: []byte("content")}).Build()
+	require.NoError(t, err)
+	return msg
+}
+
+func TestQueueReusesSession(t *testing.T) {
+	t.Parallel()
+
+	server := &fakeServer{}
+	q, _, m := newTestQueue(t, server)
+
+	for _, subject := range []string{"one", "two", "three"} {
+		require.NoError(t, q.Enqueue(t.Context(), testMessage(t, subject)))
+	}
+	require.InDelta(t, 3, counterValue(t, m.MailsQueued), 0)
+
+	q.process(t.Context(), nil)
+	sessions, messages := server.stats()
+	require.Equal(t, 1, sessions)
+	require.Len(t, messages, 3)
+	// the message survives the round trip through the database
+	require.Equal(t, testMessage(t, "one"), messages[0])
+	require.InDelta(t, 3, counterValue(t, m.MailsSent), 0)
+	require.InDelta(t, 0, gaugeValue(t, m.MailQueueSize.WithLabelValues(statusPending)), 0)
+}
+
+func TestQueueDrainWaitsForRun(t *testing.T) {
+	t.Parallel()
+
+	server := &fakeServer{block: make(chan struct{}), started: make(chan struct{}, 1)}
+	q, db, _ := newTestQueue(t, server)
+	for _, subject := range []string{"one", "two"} {
+		require.NoError(t, q.Enqueue(t.Context(), testMessage(t, subject)))
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	go q.Run(ctx)
+	<-server.started
+	cancel()
+
+	drained := make(chan error)
+	go func() { drained <- q.Drain(ctx) }()
+	select {
+	case <-drained:
+		require.Fail(t, "drain returned before the mail in flight was sent")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(server.block)
+	require.ErrorIs(t, <-drained, context.Canceled)
+
+	// the mail in flight is sent and the rest of the batch stays due
+	_, messages := server.stats()
+	require.Len(t, messages, 1)
+	due, err := db.GetDueMails(t.Context(), time.Now(), 10)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+}
+
+type recordingNotifier struct {
+	mu            sync.Mutex
+	notifications []notification.Notification
+}
+
+func (r *recordingNotifier) Notify(_ context.Context, n notification.Notification) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.notifications = append(r.notifications, n)
+	return nil
+}
+
+func TestQueueRetryAndFail(t *testing.T) {
+	t.Parallel()
+
+	server := &fakeServer{failures: 100}
+	q, db, m := newTestQueue(t, server)
+	notifier := &recordingNotifier{}
+	q.notifier = notifier
+	now := time.Now()
+	q.now = func() time.Time { return now }
+
+	require.NoError(t, q.Enqueue(t.Context(), testMessage(t, "subject")))
+	q.process(t.Context(), nil)
+	require.InDelta(t, 1, counterValue(t, m.MailSendErrors), 0)
+	require.InDelta(t, 1, gaugeValue(t, m.MailQueueSize.WithLabelValues(statusPending)), 0)
+
+	// the failed mail is not due before the backoff elapsed
+	due, err := db.GetDueMails(t.Context(), now, 10)
+	require.NoError(t, err)
+	require.Empty(t, due)
+
+	now = now.Add(1 * time.Minute)
+	q.process(t.Context(), nil)
+	require.InDelta(t, 2, counterValue(t, m.MailSendErrors), 0)
+	require.InDelta(t, 1, counterValue(t, m.MailsFailed), 0)
+	require.InDelta(t, 0, gaugeValue(t, m.MailQueueSize.WithLabelValues(statusPending)), 0)
+	require.InDelta(t, 1, gaugeValue(t, m.MailQueueSize.WithLabelValues(statusFailed)), 0)
+	// only the permanent failure is notified
+	require.Len(t, notifier.notifications, 1)
+	require.Equal(t, notification.SeverityError, notifier.notifications[0].Severity)
+	require.Equal(t, "mail", notifier.notifications[0].Source)
+	require.Contains(t, notifier.notifications[0].Message, "Subject: subject\nAttempts: 2\nError: server down")
+
+	// failed mails are never delivered again
+	server.failures = 0
+	now = now.Add(24 * time.Hour)
+	q.process(t.Context(), nil)
+	_, messages := server.stats()
+	require.Empty(t, messages)
+}
+
+func TestQueueBackoff(t *testing.T) {
+	t.Parallel()
+
+	q, _, _ := newTestQueue(t, &fakeServer{})
+	require.Equal(t, 1*time.Minute, q.backoff(1))
+	require.Equal(t, 2*time.Minute, q.backoff(2))
+	require.Equal(t, 4*time.Minute, q.backoff(3))
+	require.Equal(t, 5*time.Minute, q.backoff(4))
+	require.Equal(t, 5*time.Minute, q.backoff(100))
+}
+
+func TestMailSendRetries(t *testing.T) {
+	t.Parallel()
+
+	server := &fakeServer{failures: 1}
+	mailer := newTestMail(t)
+	mailer.dial = server.dial
+	mailer.config.Mail.To = []string{"a@example.com", "b@example.com", "c@example.com"}
+	mailer.config.Mail.Retries = 2
+	mailer.config.Mail.RetryBackoff = 1 * time.Millisecond
+
+	require.NoError(t, mailer.SendTXTEmail(t.Context(), "subject", "body"))
+	sessions, messages := server.stats()
+	// the first session fails on the first message, the retry sends all
+	require.Equal(t, 2, sessions)
+	require.Len(t, messages, 3)
+
+	server.failures = 100
+	err := mailer.SendTXTEmail(t.Context(), "subject", "body")
+	require.ErrorContains(t, err, "after 2 retries")
+}
@@ -0,0 +1,61 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/a-h/templ"
+)
+
+// Email is a mail rendered from a templ component. The css of style elements
+// in the component is inlined and the plain text part is derived from the
+// html unless the email implements TextEmail.
+type Email interface {
+	Subject() string
+	HTML() templ.Component
+}
+
+// TextEmail is an email with a companion component for the plain text part.
+// The component is converted to text like the html part so it can use
+// paragraphs and line breaks for the structure.
+type TextEmail interface {
+	Email
+	Text() templ.Component
+}
+
+// RenderEmail returns the subject, the plain text and the html body of the email
+func RenderEmail(ctx context.Context, email Email) (string, string, string, error) {
+	var htmlBody strings.Builder
+	if err := email.HTML().Render(ctx, &htmlBody); err != nil {
+		return "", "", "", fmt.Errorf("could not render html: %w", err)
+	}
+	inlined, err := inlineCSS(htmlBody.String())
+	if err != nil {
+		return "", "", "", fmt.Errorf("could not inline css: %w", err)
+	}
+
+	textSource := htmlBody.String()
+	if t, ok := email.(TextEmail); ok {
+		var textBody strings.Builder
+		if err := t.Text().Render(ctx, &textBody); err != nil {
+			return "", "", "", fmt.Errorf("could not render text: %w", err)
+		}
+		textSource = textBody.String()
+	}
+	text, err := htmlToText(textSource)
+	if err != nil {
+		return "", "", "", fmt.Errorf("could not convert html to text: %w", err)
+	}
+
+	return email.Subject(), text, inlined, nil
+}
+
+// SendEmail renders the email and sends it to the configured recipients
+func SendEmail(ctx context.Context, mailer Interface, email Email) error {
+	subject, text, htmlBody, err := RenderEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+	return mailer.SendMultipartEmail(ctx, subject, text, htmlBody)
+}
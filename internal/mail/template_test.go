@@ -0,0 +1,58 @@
+package mail
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/a-h/templ"
+	"github.com/stretchr/testify/require"
+)
+
+type testEmail struct{}
+
+func (testEmail) Subject() string {
+	return "Welcome"
+}
+
+func (testEmail) HTML() templ.Component {
+	return templ.ComponentFunc(func(_ context.Context, w io.Writer) error {
+		_, err := io.WriteString(w, `<html><head><style>p { color: red }</style></head><body><p>Hello <b>user</b></p></body></html>`)
+		return err
+	})
+}
+
+type testTextEmail struct {
+	testEmail
+}
+
+func (testTextEmail) Text() templ.Component {
+	return templ.Raw(`Hello user,<br/>this is the text part`)
+}
+
+func TestRenderEmail(t *testing.T) {
+	t.Parallel()
+
+	subject, text, html, err := RenderEmail(t.Context(), testEmail{})
+	require.NoError(t, err)
+	require.Equal(t, "Welcome", subject)
+	require.Equal(t, "Hello user", text)
+	require.Contains(t, html, `<p style="color: red">Hello <b>user</b></p>`)
+
+	// the companion component replaces the derived text
+	_, text, _, err = RenderEmail(t.Context(), testTextEmail{})
+	require.NoError(t, err)
+	require.Equal(t, "Hello user,\nthis is the text part", text)
+}
+
+func TestSendEmail(t *testing.T) {
+	t.Parallel()
+
+	mailer := newTestCapture(t, "user@example.com")
+	require.NoError(t, SendEmail(t.Context(), mailer, testEmail{}))
+	messages := mailer.Messages()
+	require.Len(t, messages, 1)
+	require.Equal(t, "Welcome", messages[0].Subject)
+	require.Equal(t, "Hello user", messages[0].TextBody)
+	require.Contains(t, messages[0].HTMLBody, "color: red")
+}
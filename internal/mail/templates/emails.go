@@ -0,0 +1,22 @@
+package templates
+
+import (
+	"github.com/a-h/templ"
+	"github.com/firefart/go-webserver-template/internal/mail"
+)
+
+// Test verifies the mail configuration
+type Test struct {
+	Hostname string
+}
+
+// compile time check that struct implements the interface
+var _ mail.Email = Test{}
+
+func (Test) Subject() string {
+	return "Test email"
+}
+
+func (t Test) HTML() templ.Component {
+	return testHTML(t)
+}
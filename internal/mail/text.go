@@ -0,0 +1,103 @@
+package mail
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var whitespace = regexp.MustCompile(`\s+`)
+
+// htmlToText converts a html document into plain text for the text part of a
+// mail. Links are written as "text (url)" and list items start with a dash.
+func htmlToText(document string) (string, error) {
+	doc, err := html.Parse(strings.NewReader(document))
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	writeText(&b, doc)
+
+	// collapse the whitespace of each line and allow at most one empty line
+	var lines []string
+	for line := range strings.Lines(b.String()) {
+		line = strings.Join(strings.Fields(line), " ")
+		if line == "" && (len(lines) == 0 || lines[len(lines)-1] == "") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n")), nil
+}
+
+func writeText(b *strings.Builder, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		// whitespace in html is collapsed, line breaks come from the elements
+		b.WriteString(whitespace.ReplaceAllString(n.Data, " "))
+		return
+	case html.ElementNode:
+	case html.DocumentNode:
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			writeText(b, c)
+		}
+		return
+	default:
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.Head, atom.Style, atom.Script:
+		return
+	case atom.Br:
+		b.WriteString("\n")
+		return
+	case atom.Hr:
+		b.WriteString("\n----\n")
+		return
+	case atom.Img:
+		b.WriteString(attr(n, "alt"))
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.P, atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Table, atom.Ul, atom.Ol, atom.Blockquote:
+		b.WriteString("\n\n")
+	case atom.Div, atom.Tr, atom.Section, atom.Header, atom.Footer:
+		b.WriteString("\n")
+	case atom.Li:
+		b.WriteString("\n- ")
+	case atom.Td, atom.Th:
+		b.WriteString(" ")
+	}
+
+	start := b.Len()
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		writeText(b, c)
+	}
+
+	switch n.DataAtom {
+	case atom.A:
+		href := attr(n, "href")
+		text := strings.TrimSpace(b.String()[start:])
+		if href != "" && !strings.HasPrefix(href, "#") && href != text && strings.TrimPrefix(href, "mailto:") != text {
+			b.WriteString(" (" + href + ")")
+		}
+	case atom.P, atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Table, atom.Ul, atom.Ol, atom.Blockquote:
+		b.WriteString("\n\n")
+	case atom.Div, atom.Tr, atom.Section, atom.Header, atom.Footer:
+		b.WriteString("\n")
+	}
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
@@ -0,0 +1,38 @@
+package mail
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTMLToText(t *testing.T) {
+	t.Parallel()
+
+	text, err := htmlToText(`<!DOCTYPE html><html><head><title>ignored</title><style>p { color: red }</style></head>
+<body>
+	<h1>Reset   your
+	password</h1>
+	<p>Hello &amp; welcome,<br/>click <a href="https://example.com/reset?a=1&amp;b=2">here</a> or
+	visit <a href="https://example.com">https://example.com</a>.</p>
+	<ul><li>one</li><li>two</li></ul>
+	<p>Contact <a href="mailto:support@example.com">support@example.com</a> <img src="logo.png" alt="Logo"/></p>
+	<hr/>
+	<table><tr><td>a</td><td>b</td></tr></table>
+	<script>alert(1)</script>
+</body></html>`)
+	require.NoError(t, err)
+	require.Equal(t, `Reset your password
+
+Hello & welcome,
+click here (https://example.com/reset?a=1&b=2) or visit https://example.com.
+
+- one
+- two
+
+Contact support@example.com Logo
+
+----
+
+a b`, text)
+}
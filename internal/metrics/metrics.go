@@ -8,13 +8,31 @@
 )
 
 type Metrics struct {
-	Errors          *prometheus.CounterVec
-	CacheHits       *prometheus.CounterVec
-	CacheMisses     *prometheus.CounterVec
-	RequestCount    *prometheus.CounterVec
-	RequestDuration *prometheus.HistogramVec
-	RequestSize     *prometheus.HistogramVec
-	ResponseSize    *prometheus.HistogramVec
+	Errors                    *prometheus.CounterVec
+	CacheHits                 *prometheus.CounterVec
+	CacheMisses               *prometheus.CounterVec
+	HTTPClientRetries         *prometheus.CounterVec
+	HTTPClientCircuitBreaker  *prometheus.GaugeVec
+	HTTPClientCircuitRejected *prometheus.CounterVec
+	HTTPClientRequests        *prometheus.CounterVec
+	HTTPClientDuration        *prometheus.HistogramVec
+	HTTPClientPhaseDuration   *prometheus.HistogramVec
+	HTTPClientQueueWait       *prometheus.HistogramVec
+	HTTPClientRateLimited     *prometheus.CounterVec
+	NotificationsQueued       *prometheus.CounterVec
+	NotificationsSent         *prometheus.CounterVec
+	NotificationsFailed       *prometheus.CounterVec
+	NotificationsDeadLettered *prometheus.CounterVec
+	NotificationsSuppressed   *prometheus.CounterVec
+	MailsQueued               prometheus.Counter
+	MailsSent                 prometheus.Counter
+	MailSendErrors            prometheus.Counter
+	MailsFailed               prometheus.Counter
+	MailQueueSize             *prometheus.GaugeVec
+	RequestCount              *prometheus.CounterVec
+	RequestDuration           *prometheus.HistogramVec
+	RequestSize               *prometheus.HistogramVec
+	ResponseSize              *prometheus.HistogramVec
 }
 
 func NewMetrics(reg prometheus.Registerer, opts ...OptionsMetricsFunc) (*Metrics, error) {
@@ -40,6 +58,131 @@ func NewMetrics(reg prometheus.Registerer, opts ...OptionsMetricsFunc) (*Metrics
 			},
 			[]string{"cache_name"},
 		),
+		HTTPClientRetries: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "http_client_retries_total",
+				Help: "How many outgoing HTTP requests were retried, partitioned by target host and HTTP method.",
+			},
+			[]string{"host", "method"},
+		),
+		HTTPClientCircuitBreaker: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "http_client_circuit_breaker_state",
+				Help: "State of the circuit breaker per target host (0 = closed, 1 = half-open, 2 = open).",
+			},
+			[]string{"host"},
+		),
+		HTTPClientCircuitRejected: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "http_client_circuit_breaker_rejected_total",
+				Help: "How many outgoing HTTP requests were rejected by an open circuit breaker, partitioned by target host.",
+			},
+			[]string{"host"},
+		),
+		HTTPClientRequests: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "http_client_requests_total",
+				Help: "How many outgoing HTTP requests were sent, partitioned by target host, HTTP method and status code.",
+			},
+			[]string{"host", "method", "code"},
+		),
+		HTTPClientDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "http_client_request_duration_seconds",
+				Help:    "The outgoing HTTP request latencies in seconds, partitioned by target host, HTTP method and status code.",
+				Buckets: prometheus.DefBuckets,
+			},
+			[]string{"host", "method", "code"},
+		),
+		HTTPClientPhaseDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "http_client_phase_duration_seconds",
+				Help:    "The duration of the phases (dns, connect, tls, ttfb) of outgoing HTTP requests in seconds.",
+				Buckets: prometheus.DefBuckets,
+			},
+			[]string{"host", "phase"},
+		),
+		HTTPClientQueueWait: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "http_client_queue_wait_seconds",
+				Help:    "Time outgoing HTTP requests waited for the rate limiter and the concurrency limit, partitioned by target host.",
+				Buckets: prometheus.DefBuckets,
+			},
+			[]string{"host"},
+		),
+		HTTPClientRateLimited: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "http_client_rate_limited_total",
+				Help: "How many outgoing HTTP requests were rejected by the rate or concurrency limit, partitioned by target host.",
+			},
+			[]string{"host"},
+		),
+		NotificationsQueued: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "notifications_queued_total",
+				Help: "How many notifications were added to the outbox, partitioned by service.",
+			},
+			[]string{"service"},
+		),
+		NotificationsSent: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "notifications_sent_total",
+				Help: "How many notifications were delivered, partitioned by service.",
+			},
+			[]string{"service"},
+		),
+		NotificationsFailed: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "notifications_failed_total",
+				Help: "How many notification delivery attempts failed, partitioned by service.",
+			},
+			[]string{"service"},
+		),
+		NotificationsDeadLettered: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "notifications_dead_lettered_total",
+				Help: "How many notifications were given up after the max attempts, partitioned by service.",
+			},
+			[]string{"service"},
+		),
+		NotificationsSuppressed: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "notifications_suppressed_total",
+				Help: "How many duplicate notifications were suppressed, partitioned by severity.",
+			},
+			[]string{"severity"},
+		),
+		MailsQueued: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "mails_queued_total",
+				Help: "How many mails were queued for delivery.",
+			},
+		),
+		MailsSent: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "mails_sent_total",
+				Help: "How many mails were sent.",
+			},
+		),
+		MailSendErrors: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "mail_send_errors_total",
+				Help: "How many attempts to send a mail failed.",
+			},
+		),
+		MailsFailed: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "mails_failed_total",
+				Help: "How many queued mails were given up after the max attempts.",
+			},
+		),
+		MailQueueSize: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "mail_queue_size",
+				Help: "How many mails are in the queue, partitioned by status (pending or failed).",
+			},
+			[]string{"status"},
+		),
 	}
 	// also add the default collectors
 	if err := reg.Register(collectors.NewGoCollector()); err != nil {
@@ -58,6 +201,61 @@ func NewMetrics(reg prometheus.Registerer, opts ...OptionsMetricsFunc) (*Metrics
 		return nil, fmt.Errorf("failed to register cache misses metric: %w", err)
 	}
 
+	if err := reg.Register(m.HTTPClientRetries); err != nil {
+		return nil, fmt.Errorf("failed to register http client retries metric: %w", err)
+	}
+	if err := reg.Register(m.HTTPClientCircuitBreaker); err != nil {
+		return nil, fmt.Errorf("failed to register http client circuit breaker metric: %w", err)
+	}
+	if err := reg.Register(m.HTTPClientCircuitRejected); err != nil {
+		return nil, fmt.Errorf("failed to register http client circuit breaker rejected metric: %w", err)
+	}
+	if err := reg.Register(m.HTTPClientRequests); err != nil {
+		return nil, fmt.Errorf("failed to register http client requests metric: %w", err)
+	}
+	if err := reg.Register(m.HTTPClientDuration); err != nil {
+		return nil, fmt.Errorf("failed to register http client duration metric: %w", err)
+	}
+	if err := reg.Register(m.HTTPClientPhaseDuration); err != nil {
+		return nil, fmt.Errorf("failed to register http client phase duration metric: %w", err)
+	}
+	if err := reg.Register(m.HTTPClientQueueWait); err != nil {
+		return nil, fmt.Errorf("failed to register http client queue wait metric: %w", err)
+	}
+	if err := reg.Register(m.HTTPClientRateLimited); err != nil {
+		return nil, fmt.Errorf("failed to register http client rate limited metric: %w", err)
+	}
+	if err := reg.Register(m.NotificationsQueued); err != nil {
+		return nil, fmt.Errorf("failed to register notifications queued metric: %w", err)
+	}
+	if err := reg.Register(m.NotificationsSent); err != nil {
+		return nil, fmt.Errorf("failed to register notifications sent metric: %w", err)
+	}
+	if err := reg.Register(m.NotificationsFailed); err != nil {
+		return nil, fmt.Errorf("failed to register notifications failed metric: %w", err)
+	}
+	if err := reg.Register(m.NotificationsDeadLettered); err != nil {
+		return nil, fmt.Errorf("failed to register notifications dead lettered metric: %w", err)
+	}
+	if err := reg.Register(m.NotificationsSuppressed); err != nil {
+		return nil, fmt.Errorf("failed to register notifications suppressed metric: %w", err)
+	}
+	if err := reg.Register(m.MailsQueued); err != nil {
+		return nil, fmt.Errorf("failed to register mails queued metric: %w", err)
+	}
+	if err := reg.Register(m.MailsSent); err != nil {
+		return nil, fmt.Errorf("failed to register mails sent metric: %w", err)
+	}
+	if err := reg.Register(m.MailSendErrors); err != nil {
+		return nil, fmt.Errorf("failed to register mail send errors metric: %w", err)
+	}
+	if err := reg.Register(m.MailsFailed); err != nil {
+		return nil, fmt.Errorf("failed to register mails failed metric: %w", err)
+	}
+	if err := reg.Register(m.MailQueueSize); err != nil {
+		return nil, fmt.Errorf("failed to register mail queue size metric: %w", err)
+	}
+
 	for _, o := range opts {
 		if err := o(m, reg); err != nil {
 			return nil, err
@@ -0,0 +1,65 @@
+package notification
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/firefart/go-webserver-template/internal/config"
+	inthttp "github.com/firefart/go-webserver-template/internal/http"
+
+	"github.com/nikoksr/notify"
+)
+
+// Gotify sends notifications to a gotify server
+type Gotify struct {
+	client   *inthttp.Client
+	url      string
+	appToken string
+	priority int
+}
+
+// compile time check that struct implements the interface
+var _ notify.Notifier = (*Gotify)(nil)
+
+func NewGotify(client *inthttp.Client, configuration config.NotificationGotify) (*Gotify, error) {
+	u, err := url.JoinPath(configuration.ServerURL, "message")
+	if err != nil {
+		return nil, fmt.Errorf("invalid gotify server url: %w", err)
+	}
+	return &Gotify{
+		client:   client,
+		url:      u,
+		appToken: configuration.AppToken,
+		priority: configuration.Priority,
+	}, nil
+}
+
+func (g *Gotify) Send(ctx context.Context, subject, message string) error {
+	// see https://gotify.net/api-docs#/message/createMessage
+	payload := struct {
+		Title    string         `json:"title"`
+		Message  string         `json:"message"`
+		Priority int            `json:"priority"`
+		Extras   map[string]any `json:"extras"`
+	}{
+		Title:    subject,
+		Message:  message,
+		Priority: g.priority,
+		// see https://gotify.net/docs/msgextras#clientdisplay
+		Extras: map[string]any{
+			"client::display": map[string]string{"contentType": "text/markdown"},
+		},
+	}
+
+	req, err := newJSONRequest(ctx, http.MethodPost, g.url, payload)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Gotify-Key", g.appToken)
+	if err := do(g.client, req); err != nil {
+		return fmt.Errorf("gotify: %w", err)
+	}
+	return nil
+}
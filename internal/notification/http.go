@@ -0,0 +1,59 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	inthttp "github.com/firefart/go-webserver-template/internal/http"
+)
+
+// errorBodySize is the maximum number of body bytes kept in a StatusError
+const errorBodySize = 1024
+
+// do sends the request and returns a StatusError for non 2xx responses. The
+// response body is discarded.
+func do(client *inthttp.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, err := io.ReadAll(io.LimitReader(resp.Body, errorBodySize+1))
+		if err != nil {
+			return fmt.Errorf("could not read response body: %w", err)
+		}
+		statusErr := &inthttp.StatusError{
+			StatusCode: resp.StatusCode,
+			Header:     resp.Header,
+			Body:       body,
+		}
+		if len(body) > errorBodySize {
+			statusErr.Body = body[:errorBodySize]
+			statusErr.Truncated = true
+		}
+		return statusErr
+	}
+
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// newJSONRequest creates a request with the JSON encoded payload as body
+func newJSONRequest(ctx context.Context, method, url string, payload any) (*http.Request, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode request body: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("could not create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
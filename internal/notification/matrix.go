@@ -0,0 +1,70 @@
+package notification
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/firefart/go-webserver-template/internal/config"
+	inthttp "github.com/firefart/go-webserver-template/internal/http"
+
+	"github.com/nikoksr/notify"
+)
+
+var htmlTags = regexp.MustCompile(`<[^>]*>`)
+
+// Matrix sends notifications as html messages to matrix rooms
+type Matrix struct {
+	client        *inthttp.Client
+	homeserverURL string
+	accessToken   string
+	roomIDs       []string
+}
+
+// compile time check that struct implements the interface
+var _ notify.Notifier = (*Matrix)(nil)
+
+func NewMatrix(client *inthttp.Client, configuration config.NotificationMatrix) *Matrix {
+	return &Matrix{
+		client:        client,
+		homeserverURL: configuration.HomeserverURL,
+		accessToken:   configuration.AccessToken,
+		roomIDs:       configuration.RoomIDs,
+	}
+}
+
+func (m *Matrix) Send(ctx context.Context, subject, message string) error {
+	if subject != "" {
+		message = fmt.Sprintf("<b>%s</b>\n%s", html.EscapeString(subject), message)
+	}
+	// the message is html, body is the plain text fallback
+	// see https://spec.matrix.org/latest/client-server-api/#mroommessage-msgtypes
+	payload := map[string]string{
+		"msgtype":        "m.text",
+		"body":           html.UnescapeString(htmlTags.ReplaceAllString(message, "")),
+		"format":         "org.matrix.custom.html",
+		"formatted_body": strings.ReplaceAll(message, "\n", "<br>"),
+	}
+
+	var errs []error
+	for _, roomID := range m.roomIDs {
+		// the transaction id makes retries of the same request idempotent
+		// see https://spec.matrix.org/latest/client-server-api/#put_matrixclientv3roomsroomidsendeventtypetxnid
+		u := fmt.Sprintf("%s/_matrix/client/v3/rooms/%s/send/m.room.message/%s", strings.TrimSuffix(m.homeserverURL, "/"), url.PathEscape(roomID), rand.Text())
+		req, err := newJSONRequest(ctx, http.MethodPut, u, payload)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+m.accessToken)
+		if err := do(m.client, req); err != nil {
+			errs = append(errs, fmt.Errorf("matrix room %s: %w", roomID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
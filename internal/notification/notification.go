@@ -0,0 +1,69 @@
+package notification
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// SkipNotify marks loggers or single records that are never forwarded to the
+// notifications by the log forwarding. It is used for the loggers of the
+// notification pipeline so a failing notification does not trigger another
+// notification, and by code that sends its own notifications for the errors
+// it logs.
+var SkipNotify = slog.Bool("skip_notify", true)
+
+// Severity classifies a notification
+type Severity string
+
+const (
+	SeverityInfo     Severity = "info"
+	SeverityWarning  Severity = "warning"
+	SeverityError    Severity = "error"
+	SeverityCritical Severity = "critical"
+)
+
+// Notification is a single message for the notification services
+type Notification struct {
+	Severity Severity
+	// Subject is prefixed with the severity, an empty subject results in the
+	// severity only
+	Subject string
+	Message string
+	// Source is the subsystem sending the notification, for example server
+	Source string
+	// Tags are free form labels used for routing
+	Tags []string
+	// Route is the pattern of the route that caused the notification, if any.
+	// It is part of the fingerprint used for deduplication.
+	Route string
+	// Request is the request that caused the notification, if any
+	Request *Request
+	// Time defaults to the time the notification is queued
+	Time time.Time
+}
+
+// Request describes the HTTP request that caused a notification
+type Request struct {
+	Method    string
+	Host      string
+	Path      string
+	ClientIP  string
+	UserAgent string
+}
+
+// Notifier is used by all subsystems to send notifications. Implementations
+// decide which services receive it.
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// FullSubject returns the subject including the severity
+func (n Notification) FullSubject() string {
+	severity := strings.ToUpper(string(n.Severity))
+	if n.Subject == "" {
+		return severity
+	}
+	return severity + ": " + n.Subject
+}
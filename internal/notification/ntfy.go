@@ -0,0 +1,63 @@
+package notification
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/firefart/go-webserver-template/internal/config"
+	inthttp "github.com/firefart/go-webserver-template/internal/http"
+
+	"github.com/nikoksr/notify"
+)
+
+// Ntfy publishes notifications to a ntfy topic
+type Ntfy struct {
+	client    *inthttp.Client
+	serverURL string
+	topic     string
+	token     string
+	priority  int
+}
+
+// compile time check that struct implements the interface
+var _ notify.Notifier = (*Ntfy)(nil)
+
+func NewNtfy(client *inthttp.Client, configuration config.NotificationNtfy) *Ntfy {
+	return &Ntfy{
+		client:    client,
+		serverURL: configuration.ServerURL,
+		topic:     configuration.Topic,
+		token:     configuration.Token,
+		priority:  configuration.Priority,
+	}
+}
+
+func (n *Ntfy) Send(ctx context.Context, subject, message string) error {
+	// see https://docs.ntfy.sh/publish/#publish-as-json
+	payload := struct {
+		Topic    string `json:"topic"`
+		Title    string `json:"title"`
+		Message  string `json:"message"`
+		Priority int    `json:"priority,omitempty"`
+		Markdown bool   `json:"markdown"`
+	}{
+		Topic:    n.topic,
+		Title:    subject,
+		Message:  message,
+		Priority: n.priority,
+		Markdown: true,
+	}
+
+	req, err := newJSONRequest(ctx, http.MethodPost, n.serverURL, payload)
+	if err != nil {
+		return err
+	}
+	if n.token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.token)
+	}
+	if err := do(n.client, req); err != nil {
+		return fmt.Errorf("ntfy: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,236 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/firefart/go-webserver-template/internal/config"
+	"github.com/firefart/go-webserver-template/internal/database"
+	"github.com/firefart/go-webserver-template/internal/metrics"
+
+	"github.com/nikoksr/notify"
+)
+
+// Outbox persists notifications in the database before they are delivered so
+// they survive outages of the notification services and restarts. Every
+// notification is stored once per service so a failing service does not
+// cause duplicates on the others.
+type Outbox struct {
+	logger   *slog.Logger
+	db       database.Interface
+	metrics  *metrics.Metrics
+	config   config.NotificationOutbox
+	services map[string]notify.Notifier
+	router   *router
+	renderer *renderer
+	now      func() time.Time
+
+	wake chan struct{}
+	// closed when Run returns so Drain never delivers concurrently to it
+	done chan struct{}
+}
+
+// compile time check that struct implements the interface
+var _ Notifier = (*Outbox)(nil)
+
+// NewOutbox creates a new outbox delivering to the given services, keyed by
+// their name. The name is stored with each notification so it must be stable
+// across restarts.
+func NewOutbox(logger *slog.Logger, db database.Interface, m *metrics.Metrics, configuration config.Notification, services map[string]notify.Notifier) (*Outbox, error) {
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	renderer, err := newRenderer(configuration.Templates)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Outbox{
+		logger:   logger,
+		db:       db,
+		metrics:  m,
+		config:   configuration.Outbox,
+		services: services,
+		router:   newRouter(configuration.Routes, names),
+		renderer: renderer,
+		now:      time.Now,
+		wake:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}, nil
+}
+
+// Notify renders and queues the notification for the services selected by the
+// routes. It only returns an error if the notification could not be rendered
+// or persisted.
+func (o *Outbox) Notify(ctx context.Context, n Notification) error {
+	now := o.now()
+	if n.Time.IsZero() {
+		n.Time = now
+	}
+	var errs []error
+	for _, name := range o.router.services(n) {
+		subject, message, err := o.renderer.render(name, n)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not render notification for %s: %w", name, err))
+			continue
+		}
+		if _, err := o.db.InsertNotification(ctx, name, subject, message, now); err != nil {
+			errs = append(errs, fmt.Errorf("could not queue notification for %s: %w", name, err))
+			continue
+		}
+		o.metrics.NotificationsQueued.WithLabelValues(name).Inc()
+	}
+
+	// trigger the delivery without waiting for the next poll
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+
+	return errors.Join(errs...)
+}
+
+// Run delivers queued notifications until the context is cancelled. Call
+// Drain afterwards to deliver the notifications still due.
+func (o *Outbox) Run(ctx context.Context) {
+	defer close(o.done)
+
+	ticker := time.NewTicker(o.config.PollInterval)
+	defer ticker.Stop()
+	for {
+		o.process(ctx)
+
+		select {
+		case <-ticker.C:
+		case <-o.wake:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Drain waits for Run to return and delivers all notifications that are due
+// until the context is done. Notifications that still fail stay in the
+// database and are retried on the next start. Run must have been started as
+// Drain never delivers concurrently to it. Run returns right after its context
+// is cancelled so the database can be closed once Drain returned.
+func (o *Outbox) Drain(ctx context.Context) error {
+	<-o.done
+
+	o.process(ctx)
+	return ctx.Err()
+}
+
+// process delivers batches of due notifications until there are no more or
+// the context is done
+func (o *Outbox) process(ctx context.Context) {
+	for ctx.Err() == nil {
+		due, err := o.db.GetDueNotifications(ctx, o.now(), int64(o.config.BatchSize))
+		if err != nil {
+			if ctx.Err() == nil {
+				o.logger.Error("could not get due notifications", slog.String("err", err.Error()))
+			}
+			return
+		}
+		if len(due) == 0 {
+			return
+		}
+		o.deliverBatch(ctx, due)
+	}
+}
+
+func (o *Outbox) deliverBatch(ctx context.Context, batch []database.OutboxNotification) {
+	jobs := make(chan database.OutboxNotification)
+	var wg sync.WaitGroup
+	for range min(o.config.Workers, len(batch)) {
+		wg.Go(func() {
+			for n := range jobs {
+				o.deliver(ctx, n)
+			}
+		})
+	}
+	for _, n := range batch {
+		// the rest of the batch stays due
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- n
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// deliver sends the notification and records the result. Sending is cut off
+// when the context is done but the result is still recorded so the database
+// can be closed once the workers returned.
+func (o *Outbox) deliver(ctx context.Context, n database.OutboxNotification) {
+	logger := o.logger.With(slog.Int64("id", n.ID), slog.String("service", n.Service))
+	dbCtx := context.WithoutCancel(ctx)
+
+	service, ok := o.services[n.Service]
+	if !ok {
+		// the service was removed from the config so this can never succeed
+		logger.Error("notification service not configured, dead lettering notification")
+		o.metrics.NotificationsDeadLettered.WithLabelValues(n.Service).Inc()
+		if err := o.db.DeadLetterNotification(dbCtx, n.ID, n.Attempts, "service not configured"); err != nil {
+			logger.Error("could not dead letter notification", slog.String("err", err.Error()))
+		}
+		return
+	}
+
+	sendCtx, cancel := context.WithTimeout(ctx, o.config.SendTimeout)
+	err := service.Send(sendCtx, n.Subject, n.Message)
+	cancel()
+	if err != nil && ctx.Err() != nil {
+		// the attempt was interrupted by the shutdown and not by the service so
+		// the notification stays due for Drain or the next start
+		logger.Debug("notification delivery interrupted", slog.String("err", err.Error()))
+		return
+	}
+	if err == nil {
+		logger.Debug("notification sent")
+		o.metrics.NotificationsSent.WithLabelValues(n.Service).Inc()
+		if err := o.db.DeleteNotification(dbCtx, n.ID); err != nil {
+			logger.Error("could not delete sent notification", slog.String("err", err.Error()))
+		}
+		return
+	}
+
+	o.metrics.NotificationsFailed.WithLabelValues(n.Service).Inc()
+	attempts := n.Attempts + 1
+	if attempts >= int64(o.config.MaxAttempts) {
+		logger.Error("notification failed permanently, dead lettering notification", slog.Int64("attempts", attempts), slog.String("err", err.Error()))
+		o.metrics.NotificationsDeadLettered.WithLabelValues(n.Service).Inc()
+		if err := o.db.DeadLetterNotification(dbCtx, n.ID, attempts, err.Error()); err != nil {
+			logger.Error("could not dead letter notification", slog.String("err", err.Error()))
+		}
+		return
+	}
+
+	next := o.now().Add(o.backoff(attempts))
+	logger.Warn("notification failed, retrying later", slog.Int64("attempts", attempts), slog.Time("next_attempt", next), slog.String("err", err.Error()))
+	if err := o.db.RetryNotification(dbCtx, n.ID, attempts, next, err.Error()); err != nil {
+		logger.Error("could not reschedule notification", slog.String("err", err.Error()))
+	}
+}
+
+// backoff returns the exponential wait time after the given number of failed
+// attempts
+func (o *Outbox) backoff(attempts int64) time.Duration {
+	wait := o.config.InitialBackoff
+	for i := int64(1); i < attempts; i++ {
+		wait *= 2
+		if wait >= o.config.MaxBackoff {
+			return o.config.MaxBackoff
+		}
+	}
+	return min(wait, o.config.MaxBackoff)
+}
@@ -0,0 +1,242 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/firefart/go-webserver-template/internal/config"
+	"github.com/firefart/go-webserver-template/internal/database"
+	"github.com/firefart/go-webserver-template/internal/metrics"
+	"github.com/firefart/go-webserver-template/internal/testutil"
+	"github.com/nikoksr/notify"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+)
+
+type testService struct {
+	mu       sync.Mutex
+	failures int
+	messages []string
+}
+
+func (s *testService) Send(_ context.Context, _, message string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("service down")
+	}
+	s.messages = append(s.messages, message)
+	return nil
+}
+
+func (s *testService) Notify(ctx context.Context, n Notification) error {
+	return s.Send(ctx, n.FullSubject(), n.Message)
+}
+
+func (s *testService) sent() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.messages...)
+}
+
+func counterValue(t *testing.T, c prometheus.Counter) float64 {
+	t.Helper()
+	var m dto.Metric
+	require.NoError(t, c.Write(&m))
+	return m.GetCounter().GetValue()
+}
+
+func newTestOutbox(t *testing.T, db database.Interface, services map[string]notify.Notifier) (*Outbox, *metrics.Metrics) {
+	t.Helper()
+
+	m, err := metrics.NewMetrics(prometheus.NewRegistry())
+	require.NoError(t, err)
+	o, err := NewOutbox(slog.New(slog.DiscardHandler), db, m, config.Notification{
+		// keep the raw message to simplify the assertions
+		Templates: []config.NotificationTemplate{{Message: "{{.Message}}"}},
+		Outbox: config.NotificationOutbox{
+			Workers:        2,
+			MaxAttempts:    3,
+			InitialBackoff: 1 * time.Minute,
+			MaxBackoff:     5 * time.Minute,
+			PollInterval:   1 * time.Hour,
+			SendTimeout:    1 * time.Second,
+			BatchSize:      10,
+		},
+	}, services)
+	require.NoError(t, err)
+	return o, m
+}
+
+func TestOutboxRetryAndDeadLetter(t *testing.T) {
+	t.Parallel()
+
+	db := testutil.NewDB(t)
+	healthy := &testService{}
+	broken := &testService{failures: 100}
+	o, m := newTestOutbox(t, db, map[string]notify.Notifier{
+		"healthy": healthy,
+		"broken":  broken,
+	})
+	now := time.Now()
+	o.now = func() time.Time { return now }
+
+	require.NoError(t, o.Notify(t.Context(), Notification{Severity: SeverityError, Message: "message"}))
+	require.InDelta(t, 1, counterValue(t, m.NotificationsQueued.WithLabelValues("healthy")), 0)
+	require.InDelta(t, 1, counterValue(t, m.NotificationsQueued.WithLabelValues("broken")), 0)
+
+	o.process(t.Context())
+	require.Equal(t, []string{"message"}, healthy.sent())
+	require.InDelta(t, 1, counterValue(t, m.NotificationsSent.WithLabelValues("healthy")), 0)
+	require.InDelta(t, 1, counterValue(t, m.NotificationsFailed.WithLabelValues("broken")), 0)
+
+	// the failed notification is not due before the backoff elapsed
+	due, err := db.GetDueNotifications(t.Context(), now, 10)
+	require.NoError(t, err)
+	require.Empty(t, due)
+	due, err = db.GetDueNotifications(t.Context(), now.Add(1*time.Minute), 10)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	require.Equal(t, "broken", due[0].Service)
+	require.Equal(t, int64(1), due[0].Attempts)
+	require.Equal(t, "service down", due[0].LastError)
+
+	now = now.Add(1 * time.Minute)
+	o.process(t.Context())
+	now = now.Add(2 * time.Minute)
+	o.process(t.Context())
+	require.InDelta(t, 3, counterValue(t, m.NotificationsFailed.WithLabelValues("broken")), 0)
+	require.InDelta(t, 1, counterValue(t, m.NotificationsDeadLettered.WithLabelValues("broken")), 0)
+
+	// dead letters are never retried
+	due, err = db.GetDueNotifications(t.Context(), now.Add(24*time.Hour), 10)
+	require.NoError(t, err)
+	require.Empty(t, due)
+}
+
+func TestOutboxDrain(t *testing.T) {
+	t.Parallel()
+
+	db := testutil.NewDB(t)
+	service := &testService{}
+	o, _ := newTestOutbox(t, db, map[string]notify.Notifier{"test": service})
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	// a cancelled run loop still allows draining the queued notifications
+	o.Run(ctx)
+	for range 25 {
+		require.NoError(t, o.Notify(t.Context(), Notification{Severity: SeverityError, Message: "message"}))
+	}
+
+	require.NoError(t, o.Drain(t.Context()))
+	require.Len(t, service.sent(), 25)
+	due, err := db.GetDueNotifications(t.Context(), time.Now(), 100)
+	require.NoError(t, err)
+	require.Empty(t, due)
+}
+
+// blockingService blocks the first send until its context is done
+type blockingService struct {
+	testService
+	started chan struct{}
+	once    sync.Once
+}
+
+func (s *blockingService) Send(ctx context.Context, subject, message string) error {
+	blocked := false
+	s.once.Do(func() { blocked = true })
+	if blocked {
+		close(s.started)
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return s.testService.Send(ctx, subject, message)
+}
+
+func TestOutboxShutdownInterruptsDelivery(t *testing.T) {
+	t.Parallel()
+
+	db := testutil.NewDB(t)
+	service := &blockingService{started: make(chan struct{})}
+	o, m := newTestOutbox(t, db, map[string]notify.Notifier{"test": service})
+
+	ctx, cancel := context.WithCancel(t.Context())
+	go o.Run(ctx)
+	require.NoError(t, o.Notify(t.Context(), Notification{Severity: SeverityError, Message: "message"}))
+	<-service.started
+	cancel()
+
+	// Run returns without waiting for the send timeout and the interrupted
+	// notification is delivered by Drain
+	require.NoError(t, o.Drain(t.Context()))
+	require.Equal(t, []string{"message"}, service.sent())
+	require.InDelta(t, 0, counterValue(t, m.NotificationsFailed.WithLabelValues("test")), 0)
+	due, err := db.GetDueNotifications(t.Context(), time.Now().Add(24*time.Hour), 10)
+	require.NoError(t, err)
+	require.Empty(t, due)
+}
+
+func TestOutboxBackoff(t *testing.T) {
+	t.Parallel()
+
+	o, _ := newTestOutbox(t, &database.MockDB{}, nil)
+	require.Equal(t, 1*time.Minute, o.backoff(1))
+	require.Equal(t, 2*time.Minute, o.backoff(2))
+	require.Equal(t, 4*time.Minute, o.backoff(3))
+	require.Equal(t, 5*time.Minute, o.backoff(4))
+	require.Equal(t, 5*time.Minute, o.backoff(100))
+}
+
+func TestOutboxRouting(t *testing.T) {
+	t.Parallel()
+
+	db := testutil.NewDB(t)
+	m, err := metrics.NewMetrics(prometheus.NewRegistry())
+	require.NoError(t, err)
+	o, err := NewOutbox(slog.New(slog.DiscardHandler), db, m, config.Notification{
+		Outbox: config.NotificationOutbox{BatchSize: 10},
+		Routes: []config.NotificationRoute{
+			{Severities: []string{"critical"}, Services: []string{"telegram", "email", "disabled"}},
+			{Severities: []string{"warning"}, Services: []string{"msteams"}},
+			{Severities: []string{"info"}},
+		},
+	}, map[string]notify.Notifier{
+		"telegram": &testService{},
+		"email":    &testService{},
+		"msteams":  &testService{},
+	})
+	require.NoError(t, err)
+
+	queued := func(n Notification) []string {
+		t.Helper()
+		ids := make(map[int64]bool)
+		before, err := db.GetDueNotifications(t.Context(), time.Now().Add(1*time.Hour), 100)
+		require.NoError(t, err)
+		for _, b := range before {
+			ids[b.ID] = true
+		}
+		require.NoError(t, o.Notify(t.Context(), n))
+		after, err := db.GetDueNotifications(t.Context(), time.Now().Add(1*time.Hour), 100)
+		require.NoError(t, err)
+		var services []string
+		for _, a := range after {
+			if !ids[a.ID] {
+				services = append(services, a.Service)
+			}
+		}
+		return services
+	}
+
+	require.ElementsMatch(t, []string{"telegram", "email"}, queued(Notification{Severity: SeverityCritical, Subject: "down", Message: "critical"}))
+	require.ElementsMatch(t, []string{"msteams"}, queued(Notification{Severity: SeverityWarning, Message: "warning"}))
+	require.Empty(t, queued(Notification{Severity: SeverityInfo, Message: "info"}))
+	// no matching route sends to all services
+	require.ElementsMatch(t, []string{"telegram", "email", "msteams"}, queued(Notification{Severity: SeverityError, Message: "error"}))
+}
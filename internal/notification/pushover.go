@@ -0,0 +1,62 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/firefart/go-webserver-template/internal/config"
+	inthttp "github.com/firefart/go-webserver-template/internal/http"
+
+	"github.com/nikoksr/notify"
+)
+
+// Pushover sends notifications to pushover users or groups
+type Pushover struct {
+	client   *inthttp.Client
+	apiURL   string
+	apiToken string
+	userKeys []string
+	priority int
+}
+
+// compile time check that struct implements the interface
+var _ notify.Notifier = (*Pushover)(nil)
+
+func NewPushover(client *inthttp.Client, configuration config.NotificationPushover) *Pushover {
+	return &Pushover{
+		client:   client,
+		apiURL:   configuration.APIURL,
+		apiToken: configuration.APIToken,
+		userKeys: configuration.UserKeys,
+		priority: configuration.Priority,
+	}
+}
+
+func (p *Pushover) Send(ctx context.Context, subject, message string) error {
+	var errs []error
+	for _, userKey := range p.userKeys {
+		// see https://pushover.net/api#messages
+		form := url.Values{}
+		form.Set("token", p.apiToken)
+		form.Set("user", userKey)
+		form.Set("title", subject)
+		form.Set("message", message)
+		form.Set("priority", strconv.Itoa(p.priority))
+		form.Set("html", "1")
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.apiURL, strings.NewReader(form.Encode()))
+		if err != nil {
+			return fmt.Errorf("could not create request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if err := do(p.client, req); err != nil {
+			errs = append(errs, fmt.Errorf("pushover: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
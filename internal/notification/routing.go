@@ -0,0 +1,55 @@
+package notification
+
+import (
+	"slices"
+
+	"github.com/firefart/go-webserver-template/internal/config"
+)
+
+// router selects the services of a notification based on the configured
+// routes. The first matching route wins, notifications without a matching
+// route are sent to all services.
+type router struct {
+	routes []config.NotificationRoute
+	all    []string
+}
+
+func newRouter(routes []config.NotificationRoute, services []string) *router {
+	return &router{
+		routes: routes,
+		all:    services,
+	}
+}
+
+// services returns the names of the services the notification is sent to.
+// Services of a route that are not enabled are skipped.
+func (r *router) services(n Notification) []string {
+	for _, route := range r.routes {
+		if !routeMatches(route, n) {
+			continue
+		}
+		var services []string
+		for _, s := range route.Services {
+			if slices.Contains(r.all, s) && !slices.Contains(services, s) {
+				services = append(services, s)
+			}
+		}
+		return services
+	}
+	return r.all
+}
+
+func routeMatches(route config.NotificationRoute, n Notification) bool {
+	if len(route.Severities) > 0 && !slices.Contains(route.Severities, string(n.Severity)) {
+		return false
+	}
+	if len(route.Sources) > 0 && !slices.Contains(route.Sources, n.Source) {
+		return false
+	}
+	if len(route.Tags) > 0 && !slices.ContainsFunc(n.Tags, func(tag string) bool {
+		return slices.Contains(route.Tags, tag)
+	}) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,82 @@
+package notification
+
+import (
+	"testing"
+
+	"github.com/firefart/go-webserver-template/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouteMatches(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		route config.NotificationRoute
+		n     Notification
+		match bool
+	}{
+		{
+			name:  "empty route matches all",
+			route: config.NotificationRoute{},
+			n:     Notification{Severity: SeverityInfo},
+			match: true,
+		},
+		{
+			name:  "severity",
+			route: config.NotificationRoute{Severities: []string{"error", "critical"}},
+			n:     Notification{Severity: SeverityCritical},
+			match: true,
+		},
+		{
+			name:  "other severity",
+			route: config.NotificationRoute{Severities: []string{"error", "critical"}},
+			n:     Notification{Severity: SeverityWarning},
+			match: false,
+		},
+		{
+			name:  "source",
+			route: config.NotificationRoute{Sources: []string{"server"}},
+			n:     Notification{Severity: SeverityError, Source: "server"},
+			match: true,
+		},
+		{
+			name:  "other source",
+			route: config.NotificationRoute{Sources: []string{"server"}},
+			n:     Notification{Severity: SeverityError, Source: "database"},
+			match: false,
+		},
+		{
+			name:  "one of the tags",
+			route: config.NotificationRoute{Tags: []string{"status:502", "status:503"}},
+			n:     Notification{Severity: SeverityError, Tags: []string{"http", "status:503"}},
+			match: true,
+		},
+		{
+			name:  "no tags",
+			route: config.NotificationRoute{Tags: []string{"status:503"}},
+			n:     Notification{Severity: SeverityError},
+			match: false,
+		},
+		{
+			name:  "all conditions must match",
+			route: config.NotificationRoute{Severities: []string{"error"}, Sources: []string{"server"}},
+			n:     Notification{Severity: SeverityError, Source: "database"},
+			match: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.match, routeMatches(tt.route, tt.n))
+		})
+	}
+}
+
+func TestFullSubject(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "ERROR", Notification{Severity: SeverityError}.FullSubject())
+	require.Equal(t, "CRITICAL: database down", Notification{Severity: SeverityCritical, Subject: "database down"}.FullSubject())
+}
@@ -0,0 +1,232 @@
+package notification
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/firefart/go-webserver-template/internal/config"
+	inthttp "github.com/firefart/go-webserver-template/internal/http"
+	"github.com/firefart/go-webserver-template/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+type recordedRequest struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   []byte
+}
+
+// newRecordingServer records all requests and responds with the given status
+func newRecordingServer(t *testing.T, status int) (*httptest.Server, func() []recordedRequest) {
+	t.Helper()
+	var mu sync.Mutex
+	var requests []recordedRequest
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		mu.Lock()
+		requests = append(requests, recordedRequest{
+			Method: r.Method,
+			Path:   r.URL.EscapedPath(),
+			Header: r.Header.Clone(),
+			Body:   body,
+		})
+		mu.Unlock()
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte("ok"))
+	}))
+	t.Cleanup(ts.Close)
+	return ts, func() []recordedRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]recordedRequest(nil), requests...)
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	t.Parallel()
+
+	ts, requests := newRecordingServer(t, http.StatusOK)
+	webhook, err := NewWebhook(testutil.NewHTTPClient(t), config.NotificationWebhook{
+		URL:             ts.URL + "/hook",
+		Method:          http.MethodPost,
+		ContentType:     "application/json",
+		Headers:         map[string]string{"X-Source": "test"},
+		Secret:          "secret",
+		SignatureHeader: "X-Signature-256",
+	})
+	require.NoError(t, err)
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	webhook.now = func() time.Time { return now }
+
+	require.NoError(t, webhook.Send(t.Context(), "ERROR", `a "quoted" message`))
+	reqs := requests()
+	require.Len(t, reqs, 1)
+	require.Equal(t, http.MethodPost, reqs[0].Method)
+	require.Equal(t, "/hook", reqs[0].Path)
+	require.Equal(t, "test", reqs[0].Header.Get("X-Source"))
+	require.Equal(t, "application/json", reqs[0].Header.Get("Content-Type"))
+	require.JSONEq(t, `{"subject":"ERROR","message":"a \"quoted\" message","timestamp":"2026-01-02T03:04:05Z"}`, string(reqs[0].Body))
+	require.Equal(t, "sha256="+Sign([]byte("secret"), reqs[0].Body), reqs[0].Header.Get("X-Signature-256"))
+}
+
+func TestWebhookTemplate(t *testing.T) {
+	t.Parallel()
+
+	ts, requests := newRecordingServer(t, http.StatusOK)
+	webhook, err := NewWebhook(testutil.NewHTTPClient(t), config.NotificationWebhook{
+		URL:          ts.URL,
+		Method:       http.MethodPut,
+		ContentType:  "text/plain",
+		BodyTemplate: "{{.Subject}}: {{.Message}}",
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, webhook.Send(t.Context(), "ERROR", "message"))
+	reqs := requests()
+	require.Len(t, reqs, 1)
+	require.Equal(t, http.MethodPut, reqs[0].Method)
+	require.Equal(t, "ERROR: message", string(reqs[0].Body))
+	// no secret, no signature
+	require.Empty(t, reqs[0].Header.Get("X-Signature-256"))
+
+	_, err = NewWebhook(testutil.NewHTTPClient(t), config.NotificationWebhook{BodyTemplate: "{{.Subject"})
+	require.Error(t, err)
+}
+
+func TestWebhookError(t *testing.T) {
+	t.Parallel()
+
+	ts, _ := newRecordingServer(t, http.StatusBadRequest)
+	webhook, err := NewWebhook(testutil.NewHTTPClient(t), config.NotificationWebhook{
+		URL:         ts.URL,
+		Method:      http.MethodPost,
+		ContentType: "application/json",
+	})
+	require.NoError(t, err)
+
+	err = webhook.Send(t.Context(), "ERROR", "message")
+	var statusErr *inthttp.StatusError
+	require.ErrorAs(t, err, &statusErr)
+	require.Equal(t, http.StatusBadRequest, statusErr.StatusCode)
+}
+
+func TestSlack(t *testing.T) {
+	t.Parallel()
+
+	ts, requests := newRecordingServer(t, http.StatusOK)
+	slack := NewSlack(testutil.NewHTTPClient(t), config.NotificationSlack{
+		WebhookURLs: []string{ts.URL + "/one", ts.URL + "/two"},
+	})
+
+	require.NoError(t, slack.Send(t.Context(), "ERROR", "message"))
+	reqs := requests()
+	require.Len(t, reqs, 2)
+	require.Equal(t, "/one", reqs[0].Path)
+	require.Equal(t, "/two", reqs[1].Path)
+	require.JSONEq(t, `{"text":"*ERROR*\nmessage"}`, string(reqs[0].Body))
+
+	// the subject is part of the message for inline titles
+	require.NoError(t, slack.Send(t.Context(), "", "message"))
+	reqs = requests()
+	require.Len(t, reqs, 4)
+	require.JSONEq(t, `{"text":"message"}`, string(reqs[3].Body))
+}
+
+func TestNtfy(t *testing.T) {
+	t.Parallel()
+
+	ts, requests := newRecordingServer(t, http.StatusOK)
+	ntfy := NewNtfy(testutil.NewHTTPClient(t), config.NotificationNtfy{
+		ServerURL: ts.URL,
+		Topic:     "alerts",
+		Token:     "token",
+		Priority:  4,
+	})
+
+	require.NoError(t, ntfy.Send(t.Context(), "ERROR", "message"))
+	reqs := requests()
+	require.Len(t, reqs, 1)
+	require.Equal(t, http.MethodPost, reqs[0].Method)
+	require.Equal(t, "Bearer token", reqs[0].Header.Get("Authorization"))
+	require.JSONEq(t, `{"topic":"alerts","title":"ERROR","message":"message","priority":4,"markdown":true}`, string(reqs[0].Body))
+}
+
+func TestGotify(t *testing.T) {
+	t.Parallel()
+
+	ts, requests := newRecordingServer(t, http.StatusOK)
+	gotify, err := NewGotify(testutil.NewHTTPClient(t), config.NotificationGotify{
+		ServerURL: ts.URL + "/gotify/",
+		AppToken:  "token",
+		Priority:  5,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, gotify.Send(t.Context(), "ERROR", "message"))
+	reqs := requests()
+	require.Len(t, reqs, 1)
+	require.Equal(t, "/gotify/message", reqs[0].Path)
+	require.Equal(t, "token", reqs[0].Header.Get("X-Gotify-Key"))
+	require.JSONEq(t, `{"title":"ERROR","message":"message","priority":5,"extras":{"client::display":{"contentType":"text/markdown"}}}`, string(reqs[0].Body))
+}
+
+func TestMatrix(t *testing.T) {
+	t.Parallel()
+
+	ts, requests := newRecordingServer(t, http.StatusOK)
+	matrix := NewMatrix(testutil.NewHTTPClient(t), config.NotificationMatrix{
+		HomeserverURL: ts.URL,
+		AccessToken:   "token",
+		RoomIDs:       []string{"!one:example.com", "!two:example.com"},
+	})
+
+	require.NoError(t, matrix.Send(t.Context(), "ERROR", "a &lt;b&gt;\n<b>bold</b>"))
+	reqs := requests()
+	require.Len(t, reqs, 2)
+	require.Equal(t, http.MethodPut, reqs[0].Method)
+	require.True(t, strings.HasPrefix(reqs[0].Path, "/_matrix/client/v3/rooms/%21one:example.com/send/m.room.message/"), reqs[0].Path)
+	require.True(t, strings.HasPrefix(reqs[1].Path, "/_matrix/client/v3/rooms/%21two:example.com/send/m.room.message/"), reqs[1].Path)
+	// every request needs its own transaction id
+	require.NotEqual(t, reqs[0].Path[strings.LastIndex(reqs[0].Path, "/"):], reqs[1].Path[strings.LastIndex(reqs[1].Path, "/"):])
+	require.Equal(t, "Bearer token", reqs[0].Header.Get("Authorization"))
+
+	var body map[string]string
+	require.NoError(t, json.Unmarshal(reqs[0].Body, &body))
+	require.Equal(t, map[string]string{
+		"msgtype":        "m.text",
+		"body":           "ERROR\na <b>\nbold",
+		"format":         "org.matrix.custom.html",
+		"formatted_body": "<b>ERROR</b><br>a &lt;b&gt;<br><b>bold</b>",
+	}, body)
+}
+
+func TestPushover(t *testing.T) {
+	t.Parallel()
+
+	ts, requests := newRecordingServer(t, http.StatusOK)
+	pushover := NewPushover(testutil.NewHTTPClient(t), config.NotificationPushover{
+		APIURL:   ts.URL + "/1/messages.json",
+		APIToken: "token",
+		UserKeys: []string{"user1", "user2"},
+		Priority: 1,
+	})
+
+	require.NoError(t, pushover.Send(t.Context(), "ERROR", "message"))
+	reqs := requests()
+	require.Len(t, reqs, 2)
+	require.Equal(t, "/1/messages.json", reqs[0].Path)
+	require.Equal(t, "application/x-www-form-urlencoded", reqs[0].Header.Get("Content-Type"))
+	require.Equal(t, "html=1&message=message&priority=1&title=ERROR&token=token&user=user1", string(reqs[0].Body))
+	require.Equal(t, "html=1&message=message&priority=1&title=ERROR&token=token&user=user2", string(reqs[1].Body))
+}
@@ -0,0 +1,51 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/firefart/go-webserver-template/internal/config"
+	inthttp "github.com/firefart/go-webserver-template/internal/http"
+
+	"github.com/nikoksr/notify"
+)
+
+// Slack sends notifications to slack incoming webhooks
+type Slack struct {
+	client      *inthttp.Client
+	webhookURLs []string
+}
+
+// compile time check that struct implements the interface
+var _ notify.Notifier = (*Slack)(nil)
+
+func NewSlack(client *inthttp.Client, configuration config.NotificationSlack) *Slack {
+	return &Slack{
+		client:      client,
+		webhookURLs: configuration.WebhookURLs,
+	}
+}
+
+func (s *Slack) Send(ctx context.Context, subject, message string) error {
+	text := message
+	if subject != "" {
+		text = fmt.Sprintf("*%s*\n%s", subject, message)
+	}
+	payload := map[string]string{
+		"text": text,
+	}
+
+	var errs []error
+	for _, url := range s.webhookURLs {
+		req, err := newJSONRequest(ctx, http.MethodPost, url, payload)
+		if err != nil {
+			return err
+		}
+		if err := do(s.client, req); err != nil {
+			errs = append(errs, fmt.Errorf("slack: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,342 @@
+package notification
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"os"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"github.com/firefart/go-webserver-template/internal/config"
+)
+
+type format string
+
+const (
+	formatPlain    format = "plain"
+	formatMarkdown format = "markdown"
+	// formatMrkdwn is the markdown dialect of slack
+	formatMrkdwn format = "mrkdwn"
+	formatHTML   format = "html"
+)
+
+// serviceSpec describes how a service displays a message
+type serviceSpec struct {
+	format format
+	// maxLength is the maximum number of characters of the message, 0 is unlimited
+	maxLength int
+	// inlineTitle services display the subject as part of the formatted
+	// message so it is rendered into the message and not passed separately
+	inlineTitle bool
+}
+
+var serviceSpecs = map[string]serviceSpec{
+	"telegram": {format: formatHTML, maxLength: 4096, inlineTitle: true},
+	"discord":  {format: formatMarkdown, maxLength: 2000, inlineTitle: true},
+	"email":    {format: formatPlain},
+	"mailgun":  {format: formatPlain},
+	"msteams":  {format: formatMarkdown, maxLength: 20000},
+	"webhook":  {format: formatPlain},
+	"slack":    {format: formatMrkdwn, maxLength: 40000, inlineTitle: true},
+	"ntfy":     {format: formatMarkdown, maxLength: 4096},
+	"gotify":   {format: formatMarkdown},
+	"matrix":   {format: formatHTML, maxLength: 32000, inlineTitle: true},
+	"pushover": {format: formatHTML, maxLength: 1024},
+}
+
+const defaultSubjectTemplate = `{{.Subject}}`
+
+// the default message templates only differ in the markup, the data is
+// escaped by the escape function of the format
+var defaultMessageTemplates = map[format]string{
+	formatPlain: `{{with .Title}}{{.}}
+{{end}}{{.Message}}
+{{with .Request}}
+Request: {{.Method}} {{.Host}}{{.Path}}
+Client IP: {{.ClientIP}}
+{{end}}{{with .Route}}Route: {{.}}
+{{end}}Server: {{.Hostname}} ({{.Version}})
+Time: {{.Time.Format "2006-01-02T15:04:05Z07:00"}}`,
+
+	formatMarkdown: `{{with .Title}}**{{escape .}}**
+{{end}}{{escape .Message}}
+{{with .Request}}
+**Request:** {{escape .Method}} {{escape .Host}}{{escape .Path}}
+**Client IP:** {{escape .ClientIP}}
+{{end}}{{with .Route}}**Route:** {{escape .}}
+{{end}}**Server:** {{escape .Hostname}} ({{escape .Version}})
+**Time:** {{.Time.Format "2006-01-02T15:04:05Z07:00"}}`,
+
+	formatMrkdwn: `{{with .Title}}*{{escape .}}*
+{{end}}{{escape .Message}}
+{{with .Request}}
+*Request:* {{escape .Method}} {{escape .Host}}{{escape .Path}}
+*Client IP:* {{escape .ClientIP}}
+{{end}}{{with .Route}}*Route:* {{escape .}}
+{{end}}*Server:* {{escape .Hostname}} ({{escape .Version}})
+*Time:* {{.Time.Format "2006-01-02T15:04:05Z07:00"}}`,
+
+	formatHTML: `{{with .Title}}<b>{{escape .}}</b>
+{{end}}{{escape .Message}}
+{{with .Request}}
+<b>Request:</b> {{escape .Method}} {{escape .Host}}{{escape .Path}}
+<b>Client IP:</b> {{escape .ClientIP}}
+{{end}}{{with .Route}}<b>Route:</b> {{escape .}}
+{{end}}<b>Server:</b> {{escape .Hostname}} ({{escape .Version}})
+<b>Time:</b> {{.Time.Format "2006-01-02T15:04:05Z07:00"}}`,
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", `*`, `\*`, `_`, `\_`, `~`, `\~`,
+	`[`, `\[`, `]`, `\]`, `<`, `\<`, `>`, `\>`, `#`, `\#`, `|`, `\|`,
+)
+
+// see https://api.slack.com/reference/surfaces/formatting#escaping
+var mrkdwnEscaper = strings.NewReplacer(`&`, `&amp;`, `<`, `&lt;`, `>`, `&gt;`)
+
+func escapeFunc(f format) func(string) string {
+	switch f {
+	case formatMarkdown:
+		return markdownEscaper.Replace
+	case formatMrkdwn:
+		return mrkdwnEscaper.Replace
+	case formatHTML:
+		return html.EscapeString
+	default:
+		return func(s string) string { return s }
+	}
+}
+
+// TemplateData is passed to the subject and message templates
+type TemplateData struct {
+	Severity Severity
+	// Subject includes the severity
+	Subject string
+	// Title is the subject for services displaying it inside the message,
+	// empty otherwise
+	Title    string
+	Message  string
+	Source   string
+	Tags     []string
+	Route    string
+	Request  *Request
+	Time     time.Time
+	Hostname string
+	Version  string
+	Revision string
+}
+
+type messageTemplate struct {
+	services   []string
+	severities []string
+	subject    map[format]*template.Template
+	message    map[format]*template.Template
+	maxLength  int
+}
+
+// renderer renders the notifications for the individual services
+type renderer struct {
+	templates []messageTemplate
+	defaults  messageTemplate
+	hostname  string
+	version   string
+	revision  string
+}
+
+func newRenderer(templates []config.NotificationTemplate) (*renderer, error) {
+	defaults, err := compileTemplate(defaultSubjectTemplate, defaultMessageTemplates)
+	if err != nil {
+		return nil, fmt.Errorf("invalid default template: %w", err)
+	}
+
+	r := renderer{
+		defaults: defaults,
+		hostname: "unknown",
+		version:  "unknown",
+	}
+	for i, t := range templates {
+		// the same template text is used for all formats
+		messages := make(map[format]string)
+		if t.Message != "" {
+			for f := range defaultMessageTemplates {
+				messages[f] = t.Message
+			}
+		}
+		compiled, err := compileTemplate(t.Subject, messages)
+		if err != nil {
+			return nil, fmt.Errorf("invalid notification template %d: %w", i, err)
+		}
+		compiled.services = t.Services
+		compiled.severities = t.Severities
+		compiled.maxLength = t.MaxLength
+		r.templates = append(r.templates, compiled)
+	}
+
+	if hostname, err := os.Hostname(); err == nil {
+		r.hostname = hostname
+	}
+	if buildInfo, ok := debug.ReadBuildInfo(); ok {
+		r.version = buildInfo.Main.Version
+		for _, setting := range buildInfo.Settings {
+			if setting.Key == "vcs.revision" {
+				r.revision = setting.Value
+			}
+		}
+	}
+
+	return &r, nil
+}
+
+func compileTemplate(subject string, messages map[format]string) (messageTemplate, error) {
+	t := messageTemplate{
+		subject: make(map[format]*template.Template),
+		message: make(map[format]*template.Template),
+	}
+	for f := range defaultMessageTemplates {
+		funcs := template.FuncMap{
+			"escape": escapeFunc(f),
+			"upper":  strings.ToUpper,
+			"join":   strings.Join,
+		}
+		if subject != "" {
+			// subjects are always plain text
+			tmpl, err := template.New("subject").Funcs(funcs).Funcs(template.FuncMap{"escape": escapeFunc(formatPlain)}).Parse(subject)
+			if err != nil {
+				return t, fmt.Errorf("invalid subject template: %w", err)
+			}
+			t.subject[f] = tmpl
+		}
+		if message, ok := messages[f]; ok {
+			tmpl, err := template.New("message").Funcs(funcs).Parse(message)
+			if err != nil {
+				return t, fmt.Errorf("invalid message template: %w", err)
+			}
+			t.message[f] = tmpl
+		}
+	}
+	return t, nil
+}
+
+func (t messageTemplate) matches(service string, severity Severity) bool {
+	if len(t.services) > 0 && !slices.Contains(t.services, service) {
+		return false
+	}
+	if len(t.severities) > 0 && !slices.Contains(t.severities, string(severity)) {
+		return false
+	}
+	return true
+}
+
+// render returns the subject and message of the notification for the service
+func (r *renderer) render(service string, n Notification) (string, string, error) {
+	spec, ok := serviceSpecs[service]
+	if !ok {
+		spec = serviceSpec{format: formatPlain}
+	}
+
+	subjectTmpl := r.defaults.subject[spec.format]
+	messageTmpl := r.defaults.message[spec.format]
+	maxLength := spec.maxLength
+	for _, t := range r.templates {
+		if !t.matches(service, n.Severity) {
+			continue
+		}
+		if tmpl, ok := t.subject[spec.format]; ok {
+			subjectTmpl = tmpl
+		}
+		if tmpl, ok := t.message[spec.format]; ok {
+			messageTmpl = tmpl
+		}
+		if t.maxLength > 0 {
+			maxLength = t.maxLength
+		}
+		break
+	}
+
+	data := TemplateData{
+		Severity: n.Severity,
+		Subject:  n.FullSubject(),
+		Message:  n.Message,
+		Source:   n.Source,
+		Tags:     n.Tags,
+		Route:    n.Route,
+		Request:  n.Request,
+		Time:     n.Time,
+		Hostname: r.hostname,
+		Version:  r.version,
+		Revision: r.revision,
+	}
+
+	subject, err := execute(subjectTmpl, data)
+	if err != nil {
+		return "", "", fmt.Errorf("could not render subject: %w", err)
+	}
+	data.Subject = subject
+	if spec.inlineTitle {
+		data.Title = subject
+		subject = ""
+	}
+
+	message, err := execute(messageTmpl, data)
+	if err != nil {
+		return "", "", fmt.Errorf("could not render message: %w", err)
+	}
+	if maxLength <= 0 {
+		return subject, message, nil
+	}
+
+	if utf8.RuneCountInString(message) <= maxLength {
+		return subject, message, nil
+	}
+
+	// shorten the error message instead of cutting the rendered message so
+	// the markup stays intact. Escaping changes the length so search for the
+	// longest message that fits.
+	raw := data.Message
+	lo, hi := 0, utf8.RuneCountInString(raw)-1
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		data.Message = truncate(raw, mid)
+		m, err := execute(messageTmpl, data)
+		if err != nil {
+			return "", "", fmt.Errorf("could not render message: %w", err)
+		}
+		if utf8.RuneCountInString(m) <= maxLength {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	data.Message = truncate(raw, lo)
+	message, err = execute(messageTmpl, data)
+	if err != nil {
+		return "", "", fmt.Errorf("could not render message: %w", err)
+	}
+	// the template itself may exceed the limit
+	return subject, truncate(message, maxLength), nil
+}
+
+func execute(tmpl *template.Template, data TemplateData) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// truncate shortens s to at most n runes, truncated strings end with an ellipsis
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	if n <= 0 {
+		return ""
+	}
+	runes := []rune(s)
+	return string(runes[:n-1]) + "…"
+}
@@ -0,0 +1,137 @@
+package notification
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/firefart/go-webserver-template/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func testNotification() Notification {
+	return Notification{
+		Severity: SeverityError,
+		Message:  "query failed: <nil> *rows*",
+		Source:   "server",
+		Route:    "GET /dummy",
+		Request: &Request{
+			Method:   "GET",
+			Host:     "example.com",
+			Path:     "/dummy",
+			ClientIP: "127.0.0.1",
+		},
+		Time: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+}
+
+func TestRenderDefault(t *testing.T) {
+	t.Parallel()
+
+	r, err := newRenderer(nil)
+	require.NoError(t, err)
+	r.hostname = "web1"
+	r.version = "v1.2.3"
+
+	// html with the subject inside the message
+	subject, message, err := r.render("telegram", testNotification())
+	require.NoError(t, err)
+	require.Empty(t, subject)
+	require.Equal(t, `<b>ERROR</b>
+query failed: &lt;nil&gt; *rows*
+
+<b>Request:</b> GET example.com/dummy
+<b>Client IP:</b> 127.0.0.1
+<b>Route:</b> GET /dummy
+<b>Server:</b> web1 (v1.2.3)
+<b>Time:</b> 2026-01-02T03:04:05Z`, message)
+
+	// markdown with a separate subject
+	subject, message, err = r.render("msteams", testNotification())
+	require.NoError(t, err)
+	require.Equal(t, "ERROR", subject)
+	require.True(t, strings.HasPrefix(message, "query failed: \\<nil\\> \\*rows\\*\n"), message)
+	require.Contains(t, message, "**Client IP:** 127.0.0.1")
+
+	// plain text without a request
+	n := testNotification()
+	n.Request = nil
+	n.Route = ""
+	subject, message, err = r.render("email", n)
+	require.NoError(t, err)
+	require.Equal(t, "ERROR", subject)
+	require.Equal(t, "query failed: <nil> *rows*\nServer: web1 (v1.2.3)\nTime: 2026-01-02T03:04:05Z", message)
+}
+
+func TestRenderTemplates(t *testing.T) {
+	t.Parallel()
+
+	r, err := newRenderer([]config.NotificationTemplate{
+		{
+			Services:   []string{"telegram"},
+			Severities: []string{"critical"},
+			Subject:    "PAGE: {{.Subject}}",
+			Message:    "{{escape .Title}} {{escape .Message}}",
+		},
+		{
+			Services: []string{"telegram"},
+			Message:  "{{.Request.Method}} {{escape .Message}}",
+		},
+	})
+	require.NoError(t, err)
+
+	n := testNotification()
+	n.Severity = SeverityCritical
+	_, message, err := r.render("telegram", n)
+	require.NoError(t, err)
+	require.Equal(t, "PAGE: CRITICAL query failed: &lt;nil&gt; *rows*", message)
+
+	// the first match wins
+	_, message, err = r.render("telegram", testNotification())
+	require.NoError(t, err)
+	require.Equal(t, "GET query failed: &lt;nil&gt; *rows*", message)
+
+	// other services keep the default
+	subject, _, err := r.render("pushover", testNotification())
+	require.NoError(t, err)
+	require.Equal(t, "ERROR", subject)
+
+	_, err = newRenderer([]config.NotificationTemplate{{Message: "{{.Message"}})
+	require.Error(t, err)
+}
+
+func TestRenderMaxLength(t *testing.T) {
+	t.Parallel()
+
+	r, err := newRenderer([]config.NotificationTemplate{
+		{Services: []string{"msteams"}, MaxLength: 200},
+	})
+	require.NoError(t, err)
+
+	n := testNotification()
+	n.Message = strings.Repeat("<x>", 200)
+	// pushover is limited to 1024 characters by default
+	_, message, err := r.render("pushover", n)
+	require.NoError(t, err)
+	require.LessOrEqual(t, utf8.RuneCountInString(message), 1024)
+	// the raw message is cut before escaping so no entity is cut in half
+	require.Contains(t, message, "&lt;x…\n")
+	require.Contains(t, message, "<b>Time:</b>")
+
+	_, message, err = r.render("msteams", n)
+	require.NoError(t, err)
+	require.LessOrEqual(t, utf8.RuneCountInString(message), 200)
+	// the message is shortened, the markup stays intact
+	require.Contains(t, message, "…")
+	require.Contains(t, message, "**Time:**")
+}
+
+func TestTruncate(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "abc", truncate("abc", 3))
+	require.Equal(t, "a…", truncate("abc", 2))
+	require.Equal(t, "ä…", truncate("äöü", 2))
+	require.Empty(t, truncate("abc", 0))
+}
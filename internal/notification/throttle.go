@@ -0,0 +1,169 @@
+package notification
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/firefart/go-webserver-template/internal/config"
+	"github.com/firefart/go-webserver-template/internal/metrics"
+)
+
+var (
+	fingerprintUUID   = regexp.MustCompile(`(?i)\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`)
+	fingerprintHex    = regexp.MustCompile(`(?i)\b(?:0x)?[0-9a-f]*[0-9][0-9a-f]*\b`)
+	fingerprintSpaces = regexp.MustCompile(`\s+`)
+)
+
+// Fingerprint identifies notifications caused by the same error. Variable
+// parts like ids, numbers, addresses and ports are removed from the message
+// so the same error on different requests shares a fingerprint.
+func Fingerprint(n Notification) string {
+	msg := strings.ToLower(n.Message)
+	msg = fingerprintUUID.ReplaceAllString(msg, "<id>")
+	msg = fingerprintHex.ReplaceAllString(msg, "<n>")
+	msg = fingerprintSpaces.ReplaceAllString(strings.TrimSpace(msg), " ")
+
+	h := sha256.Sum256([]byte(string(n.Severity) + "\x00" + n.Source + "\x00" + n.Route + "\x00" + msg))
+	return hex.EncodeToString(h[:])
+}
+
+type throttleEntry struct {
+	notification Notification
+	windowStart  time.Time
+	window       time.Duration
+	count        int
+	suppressed   int
+}
+
+// Throttler suppresses duplicate notifications within a window and sends a
+// summary of the suppressed ones when the window ends. Severities without a
+// rule are passed through unchanged.
+type Throttler struct {
+	logger  *slog.Logger
+	metrics *metrics.Metrics
+	next    Notifier
+	rules   map[Severity]config.NotificationThrottleRule
+	now     func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*throttleEntry
+}
+
+// compile time check that struct implements the interface
+var _ Notifier = (*Throttler)(nil)
+
+func NewThrottler(logger *slog.Logger, m *metrics.Metrics, next Notifier, configuration config.NotificationThrottle) *Throttler {
+	rules := make(map[Severity]config.NotificationThrottleRule)
+	if configuration.Enabled {
+		for severity, rule := range configuration.Rules {
+			rules[Severity(severity)] = rule
+		}
+	}
+
+	return &Throttler{
+		logger:  logger,
+		metrics: m,
+		next:    next,
+		rules:   rules,
+		now:     time.Now,
+		entries: make(map[string]*throttleEntry),
+	}
+}
+
+// Notify sends the notification unless the number of identical notifications
+// in the current window exceeds the burst of the severity
+func (t *Throttler) Notify(ctx context.Context, n Notification) error {
+	rule, ok := t.rules[n.Severity]
+	if !ok {
+		return t.next.Notify(ctx, n)
+	}
+
+	fingerprint := Fingerprint(n)
+	now := t.now()
+
+	t.mu.Lock()
+	entry, ok := t.entries[fingerprint]
+	if !ok {
+		entry = &throttleEntry{
+			notification: n,
+			windowStart:  now,
+			window:       rule.Window,
+		}
+		t.entries[fingerprint] = entry
+	}
+	entry.count++
+	suppress := entry.count > rule.Burst
+	if suppress {
+		entry.suppressed++
+	}
+	t.mu.Unlock()
+
+	if suppress {
+		t.logger.Debug("suppressed duplicate notification", slog.String("fingerprint", fingerprint), slog.String("severity", string(n.Severity)))
+		t.metrics.NotificationsSuppressed.WithLabelValues(string(n.Severity)).Inc()
+		return nil
+	}
+	return t.next.Notify(ctx, n)
+}
+
+// Run sends the summaries of ended windows until the context is cancelled
+func (t *Throttler) Run(ctx context.Context) {
+	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			t.flush(ctx, false)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Flush sends the summaries of all windows with suppressed notifications,
+// including the ones that did not end yet. It is meant to be called on
+// shutdown.
+func (t *Throttler) Flush(ctx context.Context) {
+	t.flush(ctx, true)
+}
+
+func (t *Throttler) flush(ctx context.Context, all bool) {
+	now := t.now()
+
+	var summaries []Notification
+	t.mu.Lock()
+	for fingerprint, entry := range t.entries {
+		if !all && now.Sub(entry.windowStart) < entry.window {
+			continue
+		}
+		if entry.suppressed > 0 {
+			summaries = append(summaries, summarize(entry, now))
+		}
+		delete(t.entries, fingerprint)
+	}
+	t.mu.Unlock()
+
+	for _, summary := range summaries {
+		if err := t.next.Notify(ctx, summary); err != nil {
+			t.logger.Error("could not send notification summary", slog.String("err", err.Error()))
+		}
+	}
+}
+
+func summarize(entry *throttleEntry, now time.Time) Notification {
+	n := entry.notification
+	elapsed := min(now.Sub(entry.windowStart), entry.window).Round(time.Second)
+	message := fmt.Sprintf("%d more occurrences of %q in the last %s", entry.suppressed, n.Message, elapsed)
+	if n.Route != "" {
+		message = fmt.Sprintf("%d more occurrences of %q on %s in the last %s", entry.suppressed, n.Message, n.Route, elapsed)
+	}
+	n.Message = message
+	return n
+}
@@ -0,0 +1,120 @@
+package notification
+
+import (
+	"fmt"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/firefart/go-webserver-template/internal/config"
+	"github.com/firefart/go-webserver-template/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFingerprint(t *testing.T) {
+	t.Parallel()
+
+	base := Notification{Severity: SeverityError, Route: "GET /dummy", Message: "could not query user 1234: dial tcp 10.0.0.1:5432: connection refused"}
+
+	same := base
+	same.Message = "could not query user 987: dial tcp 10.0.0.2:5432: connection refused"
+	require.Equal(t, Fingerprint(base), Fingerprint(same))
+
+	uuid := base
+	uuid.Message = "could not query user 3f2504e0-4f89-11d3-9a0c-0305e82c3301: dial tcp 10.0.0.1:5432: connection refused"
+	uuid2 := uuid
+	uuid2.Message = "could not query user 6ba7b810-9dad-11d1-80b4-00c04fd430c8: dial tcp 10.0.0.1:5432: connection refused"
+	require.Equal(t, Fingerprint(uuid), Fingerprint(uuid2))
+
+	otherRoute := base
+	otherRoute.Route = "POST /dummy"
+	require.NotEqual(t, Fingerprint(base), Fingerprint(otherRoute))
+
+	otherMessage := base
+	otherMessage.Message = "could not query user 1234: context deadline exceeded"
+	require.NotEqual(t, Fingerprint(base), Fingerprint(otherMessage))
+}
+
+func TestThrottler(t *testing.T) {
+	t.Parallel()
+
+	m, err := metrics.NewMetrics(prometheus.NewRegistry())
+	require.NoError(t, err)
+	service := &testService{}
+	throttler := NewThrottler(slog.New(slog.DiscardHandler), m, service, config.NotificationThrottle{
+		Enabled: true,
+		Rules: map[string]config.NotificationThrottleRule{
+			"error": {Window: 10 * time.Minute, Burst: 2},
+		},
+	})
+	now := time.Now()
+	throttler.now = func() time.Time { return now }
+
+	n := Notification{Severity: SeverityError, Subject: "ERROR", Message: "error 1", Route: "GET /"}
+	for i := range 5 {
+		n.Message = fmt.Sprintf("error %d", i+1)
+		require.NoError(t, throttler.Notify(t.Context(), n))
+	}
+	// severities without a rule are never throttled
+	for range 3 {
+		require.NoError(t, throttler.Notify(t.Context(), Notification{Severity: SeverityInfo, Message: "info"}))
+	}
+	require.Equal(t, []string{"error 1", "error 2", "info", "info", "info"}, service.sent())
+	require.InDelta(t, 3, counterValue(t, m.NotificationsSuppressed.WithLabelValues("error")), 0)
+
+	// no summary before the window ends
+	now = now.Add(5 * time.Minute)
+	throttler.flush(t.Context(), false)
+	require.Len(t, service.sent(), 5)
+
+	now = now.Add(5 * time.Minute)
+	throttler.flush(t.Context(), false)
+	sent := service.sent()
+	require.Len(t, sent, 6)
+	require.Equal(t, `3 more occurrences of "error 1" on GET / in the last 10m0s`, sent[5])
+
+	// the next window starts fresh
+	require.NoError(t, throttler.Notify(t.Context(), n))
+	require.Len(t, service.sent(), 7)
+}
+
+func TestThrottlerFlush(t *testing.T) {
+	t.Parallel()
+
+	m, err := metrics.NewMetrics(prometheus.NewRegistry())
+	require.NoError(t, err)
+	service := &testService{}
+	throttler := NewThrottler(slog.New(slog.DiscardHandler), m, service, config.NotificationThrottle{
+		Enabled: true,
+		Rules: map[string]config.NotificationThrottleRule{
+			"error": {Window: 1 * time.Hour, Burst: 1},
+		},
+	})
+
+	for range 3 {
+		require.NoError(t, throttler.Notify(t.Context(), Notification{Severity: SeverityError, Message: "boom"}))
+	}
+	throttler.Flush(t.Context())
+	sent := service.sent()
+	require.Len(t, sent, 2)
+	require.Contains(t, sent[1], `2 more occurrences of "boom"`)
+}
+
+func TestThrottlerDisabled(t *testing.T) {
+	t.Parallel()
+
+	m, err := metrics.NewMetrics(prometheus.NewRegistry())
+	require.NoError(t, err)
+	service := &testService{}
+	throttler := NewThrottler(slog.New(slog.DiscardHandler), m, service, config.NotificationThrottle{
+		Rules: map[string]config.NotificationThrottleRule{
+			"error": {Window: 1 * time.Hour, Burst: 1},
+		},
+	})
+
+	for range 3 {
+		require.NoError(t, throttler.Notify(t.Context(), Notification{Severity: SeverityError, Message: "boom"}))
+	}
+	require.Len(t, service.sent(), 3)
+}
@@ -0,0 +1,111 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"text/template"
+	"time"
+
+	"github.com/firefart/go-webserver-template/internal/config"
+	inthttp "github.com/firefart/go-webserver-template/internal/http"
+
+	"github.com/nikoksr/notify"
+)
+
+// DefaultWebhookBodyTemplate is used if no body template is configured
+const DefaultWebhookBodyTemplate = `{"subject":{{json .Subject}},"message":{{json .Message}},"timestamp":{{json .Timestamp}}}`
+
+// webhookData is passed to the body template
+type webhookData struct {
+	Subject   string
+	Message   string
+	Timestamp time.Time
+}
+
+// Webhook sends notifications to a generic HTTP endpoint. The body is
+// rendered from a template and optionally signed with a HMAC-SHA256 of the
+// body.
+type Webhook struct {
+	client          *inthttp.Client
+	url             string
+	method          string
+	contentType     string
+	headers         map[string]string
+	body            *template.Template
+	secret          []byte
+	signatureHeader string
+	now             func() time.Time
+}
+
+// compile time check that struct implements the interface
+var _ notify.Notifier = (*Webhook)(nil)
+
+func NewWebhook(client *inthttp.Client, configuration config.NotificationWebhook) (*Webhook, error) {
+	bodyTemplate := configuration.BodyTemplate
+	if bodyTemplate == "" {
+		bodyTemplate = DefaultWebhookBodyTemplate
+	}
+	tmpl, err := template.New("webhook").Funcs(template.FuncMap{
+		"json": func(v any) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}).Parse(bodyTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook body template: %w", err)
+	}
+
+	return &Webhook{
+		client:          client,
+		url:             configuration.URL,
+		method:          configuration.Method,
+		contentType:     configuration.ContentType,
+		headers:         configuration.Headers,
+		body:            tmpl,
+		secret:          []byte(configuration.Secret),
+		signatureHeader: configuration.SignatureHeader,
+		now:             time.Now,
+	}, nil
+}
+
+func (w *Webhook) Send(ctx context.Context, subject, message string) error {
+	var body bytes.Buffer
+	if err := w.body.Execute(&body, webhookData{
+		Subject:   subject,
+		Message:   message,
+		Timestamp: w.now().UTC(),
+	}); err != nil {
+		return fmt.Errorf("could not render webhook body: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, w.method, w.url, bytes.NewReader(body.Bytes()))
+	if err != nil {
+		return fmt.Errorf("could not create request: %w", err)
+	}
+	req.Header.Set("Content-Type", w.contentType)
+	for k, v := range w.headers {
+		req.Header.Set(k, v)
+	}
+	if len(w.secret) > 0 {
+		req.Header.Set(w.signatureHeader, "sha256="+Sign(w.secret, body.Bytes()))
+	}
+
+	if err := do(w.client, req); err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	return nil
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of the body. Receivers of a
+// webhook use it to verify the signature header.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,90 @@
+package oidc
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/firefart/go-webserver-template/internal/auth"
+	"github.com/firefart/go-webserver-template/internal/config"
+)
+
+// Claims are the verified claims of an id token
+type Claims map[string]any
+
+// String returns the claim if it is a string
+func (c Claims) String(name string) string {
+	s, _ := c[name].(string)
+	return s
+}
+
+// Strings returns the claim if it is a list of strings or a single string
+func (c Claims) Strings(name string) []string {
+	switch v := c[name].(type) {
+	case string:
+		return []string{v}
+	case []any:
+		var list []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	default:
+		return nil
+	}
+}
+
+// Principal returns the principal of the user with the role of the first
+// matching rule
+func (p *Provider) Principal(claims Claims) (auth.Principal, error) {
+	username := claims.String(p.config.UsernameClaim)
+	if username == "" {
+		username = claims.String("sub")
+	}
+	groups := claims.Strings(p.config.GroupsClaim)
+	for _, rule := range p.config.Rules {
+		if matchRule(rule, groups, claims) {
+			return auth.Principal{
+				Username: username,
+				Role:     rule.Role,
+			}, nil
+		}
+	}
+	return auth.Principal{}, fmt.Errorf("%w: %s matches no rule", ErrNotAuthorized, username)
+}
+
+func matchRule(rule config.OIDCRule, groups []string, claims Claims) bool {
+	if len(rule.Groups) > 0 && !slices.ContainsFunc(rule.Groups, func(group string) bool {
+		return slices.Contains(groups, group)
+	}) {
+		return false
+	}
+	for name, want := range rule.Claims {
+		if !matchClaim(claims[name], want) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchClaim compares the claim to the configured string. Lists match if one
+// of the items matches.
+func matchClaim(value any, want string) bool {
+	switch v := value.(type) {
+	case string:
+		return v == want
+	case bool:
+		return strconv.FormatBool(v) == want
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64) == want
+	case []any:
+		for _, item := range v {
+			if matchClaim(item, want) {
+				return true
+			}
+		}
+	}
+	return false
+}
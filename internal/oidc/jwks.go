@@ -0,0 +1,184 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"sync"
+	"time"
+
+	inthttp "github.com/firefart/go-webserver-template/internal/http"
+)
+
+// minRefreshInterval limits the refreshes of the key set triggered by tokens
+// signed with unknown keys
+const minRefreshInterval = 1 * time.Minute
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jwks struct {
+	Keys []jwk `json:"keys"`
+}
+
+// keySet caches the signing keys of the provider. The keys are refreshed
+// after the ttl and when a token is signed with an unknown key, which happens
+// after a key rotation.
+type keySet struct {
+	logger  *slog.Logger
+	client  *inthttp.Client
+	url     string
+	ttl     time.Duration
+	now     func() time.Time
+	mu      sync.Mutex
+	keys    map[string]crypto.PublicKey
+	fetched time.Time
+}
+
+func newKeySet(logger *slog.Logger, client *inthttp.Client, url string, ttl time.Duration) *keySet {
+	return &keySet{
+		logger: logger,
+		client: client,
+		url:    url,
+		ttl:    ttl,
+		now:    time.Now,
+	}
+}
+
+// key returns the key with the id. If the token has no key id the key set
+// must contain a single key.
+func (s *keySet) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	age := s.now().Sub(s.fetched)
+	if s.keys != nil && age < s.ttl {
+		if key, ok := s.lookup(kid); ok {
+			return key, nil
+		}
+		if age < minRefreshInterval {
+			return nil, fmt.Errorf("unknown key %q", kid)
+		}
+	}
+
+	if err := s.refresh(ctx); err != nil {
+		return nil, err
+	}
+	if key, ok := s.lookup(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown key %q", kid)
+}
+
+func (s *keySet) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, true
+		}
+	}
+	key, ok := s.keys[kid]
+	return key, ok
+}
+
+func (s *keySet) refresh(ctx context.Context) error {
+	set, err := inthttp.GetJSON[jwks](ctx, s.client, s.url)
+	if err != nil {
+		return fmt.Errorf("could not fetch the key set: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			// skip unsupported keys, the provider may publish more key types
+			s.logger.Debug("skipping key of the key set", slog.String("kid", k.Kid), slog.String("err", err.Error()))
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	s.keys = keys
+	s.fetched = s.now()
+	return nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus: %w", err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exponent: %w", err)
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid exponent")
+		}
+		modulus := new(big.Int).SetBytes(n)
+		if modulus.BitLen() < 2048 {
+			return nil, errors.New("rsa keys must have at least 2048 bits")
+		}
+		return &rsa.PublicKey{N: modulus, E: int(exponent.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid x coordinate: %w", err)
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid y coordinate: %w", err)
+		}
+		size := (curve.Params().BitSize + 7) / 8
+		if len(x) != size || len(y) != size {
+			return nil, errors.New("invalid coordinate length")
+		}
+		// also checks that the point is on the curve
+		key, err := ecdsa.ParseUncompressedPublicKey(curve, append(append([]byte{4}, x...), y...))
+		if err != nil {
+			return nil, fmt.Errorf("invalid ec key: %w", err)
+		}
+		return key, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
@@ -0,0 +1,131 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	_ "crypto/sha256" // register the hashes of the signature algorithms
+	_ "crypto/sha512"
+)
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// jwt is a parsed but not yet verified token
+type jwt struct {
+	header    jwtHeader
+	payload   []byte
+	signed    []byte
+	signature []byte
+}
+
+func parseJWT(raw string) (jwt, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return jwt{}, errors.New("token must have three parts")
+	}
+	var t jwt
+	header, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return jwt{}, fmt.Errorf("could not decode header: %w", err)
+	}
+	if err := json.Unmarshal(header, &t.header); err != nil {
+		return jwt{}, fmt.Errorf("could not parse header: %w", err)
+	}
+	t.payload, err = base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return jwt{}, fmt.Errorf("could not decode payload: %w", err)
+	}
+	t.signature, err = base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return jwt{}, fmt.Errorf("could not decode signature: %w", err)
+	}
+	t.signed = []byte(parts[0] + "." + parts[1])
+	return t, nil
+}
+
+// algorithms maps the supported signature algorithms to their hash. The
+// symmetric HS algorithms and none are not supported on purpose.
+var algorithms = map[string]crypto.Hash{
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+	"PS256": crypto.SHA256,
+	"PS384": crypto.SHA384,
+	"PS512": crypto.SHA512,
+	"ES256": crypto.SHA256,
+	"ES384": crypto.SHA384,
+	"ES512": crypto.SHA512,
+	"EdDSA": 0,
+}
+
+// verifySignature checks the signature of the token with the key. The key
+// type must match the algorithm of the token.
+func verifySignature(t jwt, key crypto.PublicKey) error {
+	hash, ok := algorithms[t.header.Alg]
+	if !ok {
+		return fmt.Errorf("unsupported algorithm %q", t.header.Alg)
+	}
+	var digest []byte
+	if hash != 0 {
+		h := hash.New()
+		h.Write(t.signed)
+		digest = h.Sum(nil)
+	}
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		switch t.header.Alg[:2] {
+		case "RS":
+			return rsa.VerifyPKCS1v15(k, hash, digest, t.signature)
+		case "PS":
+			return rsa.VerifyPSS(k, hash, digest, t.signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		}
+	case *ecdsa.PublicKey:
+		if t.header.Alg[:2] != "ES" {
+			break
+		}
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if curveAlgorithm(k.Curve.Params().Name) != t.header.Alg || len(t.signature) != 2*size {
+			return errors.New("invalid ecdsa signature")
+		}
+		r := new(big.Int).SetBytes(t.signature[:size])
+		s := new(big.Int).SetBytes(t.signature[size:])
+		if !ecdsa.Verify(k, digest, r, s) {
+			return errors.New("invalid ecdsa signature")
+		}
+		return nil
+	case ed25519.PublicKey:
+		if t.header.Alg != "EdDSA" {
+			break
+		}
+		if !ed25519.Verify(k, t.signed, t.signature) {
+			return errors.New("invalid eddsa signature")
+		}
+		return nil
+	}
+	return fmt.Errorf("key of type %T can't be used with algorithm %s", key, t.header.Alg)
+}
+
+func curveAlgorithm(curve string) string {
+	switch curve {
+	case "P-256":
+		return "ES256"
+	case "P-384":
+		return "ES384"
+	case "P-521":
+		return "ES512"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,103 @@
+package oidc
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func encodeToken(t *testing.T, alg string, sign func(signed []byte) []byte) string {
+	t.Helper()
+	signed := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"`+alg+`","kid":"1"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"1234"}`))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sign([]byte(signed)))
+}
+
+func TestVerifySignatureES256(t *testing.T) {
+	t.Parallel()
+
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	public, err := private.PublicKey.Bytes()
+	require.NoError(t, err)
+	key, err := jwk{
+		Kty: "EC",
+		Crv: "P-256",
+		X:   base64.RawURLEncoding.EncodeToString(public[1:33]),
+		Y:   base64.RawURLEncoding.EncodeToString(public[33:]),
+	}.publicKey()
+	require.NoError(t, err)
+
+	sign := func(signed []byte) []byte {
+		digest := sha256.Sum256(signed)
+		r, s, err := ecdsa.Sign(rand.Reader, private, digest[:])
+		require.NoError(t, err)
+		signature := make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+		return signature
+	}
+
+	token, err := parseJWT(encodeToken(t, "ES256", sign))
+	require.NoError(t, err)
+	require.NoError(t, verifySignature(token, key))
+
+	// the algorithm must match the curve
+	token, err = parseJWT(encodeToken(t, "ES384", sign))
+	require.NoError(t, err)
+	require.Error(t, verifySignature(token, key))
+
+	// an ec key can't verify rsa signatures
+	token, err = parseJWT(encodeToken(t, "RS256", sign))
+	require.NoError(t, err)
+	require.ErrorContains(t, verifySignature(token, key), "can't be used with algorithm")
+}
+
+func TestVerifySignatureEdDSA(t *testing.T) {
+	t.Parallel()
+
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	key, err := jwk{
+		Kty: "OKP",
+		Crv: "Ed25519",
+		X:   base64.RawURLEncoding.EncodeToString(public),
+	}.publicKey()
+	require.NoError(t, err)
+
+	raw := encodeToken(t, "EdDSA", func(signed []byte) []byte {
+		return ed25519.Sign(private, signed)
+	})
+	token, err := parseJWT(raw)
+	require.NoError(t, err)
+	require.NoError(t, verifySignature(token, key))
+
+	// tamper with the payload
+	parts := strings.Split(raw, ".")
+	parts[1] = base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"admin"}`))
+	token, err = parseJWT(strings.Join(parts, "."))
+	require.NoError(t, err)
+	require.ErrorContains(t, verifySignature(token, key), "invalid eddsa signature")
+}
+
+func TestParseJWT(t *testing.T) {
+	t.Parallel()
+
+	for _, raw := range []string{
+		"",
+		"a.b",
+		"a.b.c.d",
+		"!!.e30.c2ln",
+		base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256"}`)) + ".!!.c2ln",
+	} {
+		_, err := parseJWT(raw)
+		require.Error(t, err, raw)
+	}
+}
@@ -0,0 +1,272 @@
+// Package oidctest provides an in-process OpenID Connect provider for tests
+package oidctest
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// authorization is a code issued by the authorize endpoint
+type authorization struct {
+	clientID    string
+	redirectURI string
+	nonce       string
+	challenge   string
+	claims      map[string]any
+}
+
+// Provider is a fake identity provider. The authorize endpoint logs the user
+// in without interaction and redirects back with a code.
+type Provider struct {
+	Server       *httptest.Server
+	ClientID     string
+	ClientSecret string
+
+	mu            sync.Mutex
+	key           *rsa.PrivateKey
+	kid           string
+	claims        map[string]any
+	codes         map[string]authorization
+	jwksRequests  int
+	tokenRequests int
+}
+
+// New starts the provider, close it with Close
+func New(clientID, clientSecret string) *Provider {
+	p := &Provider{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		codes:        make(map[string]authorization),
+		claims: map[string]any{
+			"sub":                "user-1",
+			"preferred_username": "user",
+		},
+	}
+	p.RotateKey()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("GET /jwks", p.jwks)
+	mux.HandleFunc("GET /authorize", p.authorize)
+	mux.HandleFunc("POST /token", p.token)
+	p.Server = httptest.NewServer(mux)
+	return p
+}
+
+// Issuer is the issuer url of the provider
+func (p *Provider) Issuer() string {
+	return p.Server.URL
+}
+
+func (p *Provider) Close() {
+	p.Server.Close()
+}
+
+// SetClaims sets the claims of the user logging in next, the standard
+// claims are added when the token is issued
+func (p *Provider) SetClaims(claims map[string]any) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.claims = maps.Clone(claims)
+}
+
+// RotateKey replaces the signing key
+func (p *Provider) RotateKey() {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.key = key
+	p.kid = rand.Text()
+}
+
+// JWKSRequests returns the number of requests to the key set
+func (p *Provider) JWKSRequests() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.jwksRequests
+}
+
+// TokenRequests returns the number of successful code redemptions
+func (p *Provider) TokenRequests() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.tokenRequests
+}
+
+// IDToken returns an id token for the client with the nonce signed with the
+// current key. The claims overwrite the standard claims.
+func (p *Provider) IDToken(nonce string, claims map[string]any) string {
+	now := time.Now()
+	all := map[string]any{
+		"iss":   p.Issuer(),
+		"aud":   p.ClientID,
+		"iat":   now.Unix(),
+		"exp":   now.Add(5 * time.Minute).Unix(),
+		"nonce": nonce,
+	}
+	maps.Copy(all, claims)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.sign("RS256", p.kid, all)
+}
+
+// SignToken signs the claims as they are with the algorithm and key id of
+// the header. Only RS256 signatures are valid, other algorithms get a
+// signature that must be rejected.
+func (p *Provider) SignToken(alg, kid string, claims map[string]any) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.sign(alg, kid, claims)
+}
+
+func (p *Provider) sign(alg, kid string, claims map[string]any) string {
+	header, err := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	if err != nil {
+		panic(err)
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		panic(err)
+	}
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	var signature []byte
+	if alg == "RS256" {
+		digest := sha256.Sum256([]byte(signed))
+		signature, err = rsa.SignPKCS1v15(rand.Reader, p.key, crypto.SHA256, digest[:])
+		if err != nil {
+			panic(err)
+		}
+	} else {
+		signature = []byte("invalid")
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func (p *Provider) discovery(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                           p.Issuer(),
+		"authorization_endpoint":           p.Issuer() + "/authorize",
+		"token_endpoint":                   p.Issuer() + "/token",
+		"jwks_uri":                         p.Issuer() + "/jwks",
+		"code_challenge_methods_supported": []string{"S256"},
+	})
+}
+
+func (p *Provider) jwks(w http.ResponseWriter, _ *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.jwksRequests++
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"kid": p.kid,
+			"n":   base64.RawURLEncoding.EncodeToString(p.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(p.key.E)).Bytes()),
+		}},
+	})
+}
+
+func (p *Provider) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	redirectURI, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || q.Get("client_id") != p.ClientID || q.Get("response_type") != "code" {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	callback := redirectURI.Query()
+	callback.Set("state", q.Get("state"))
+	if q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		callback.Set("error", "invalid_request")
+	} else {
+		code := rand.Text()
+		p.mu.Lock()
+		p.codes[code] = authorization{
+			clientID:    q.Get("client_id"),
+			redirectURI: q.Get("redirect_uri"),
+			nonce:       q.Get("nonce"),
+			challenge:   q.Get("code_challenge"),
+			claims:      maps.Clone(p.claims),
+		}
+		p.mu.Unlock()
+		callback.Set("code", code)
+	}
+	redirectURI.RawQuery = callback.Encode()
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+func (p *Provider) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		tokenError(w, "invalid_request")
+		return
+	}
+	clientID := r.PostForm.Get("client_id")
+	if user, pass, ok := r.BasicAuth(); ok {
+		user, _ = url.QueryUnescape(user)
+		pass, _ = url.QueryUnescape(pass)
+		if pass != p.ClientSecret {
+			tokenError(w, "invalid_client")
+			return
+		}
+		clientID = user
+	} else if p.ClientSecret != "" {
+		tokenError(w, "invalid_client")
+		return
+	}
+
+	p.mu.Lock()
+	code, ok := p.codes[r.PostForm.Get("code")]
+	// codes can only be used once
+	delete(p.codes, r.PostForm.Get("code"))
+	p.mu.Unlock()
+	challenge := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	switch {
+	case r.PostForm.Get("grant_type") != "authorization_code", !ok:
+		tokenError(w, "invalid_grant")
+		return
+	case code.clientID != clientID, code.redirectURI != r.PostForm.Get("redirect_uri"):
+		tokenError(w, "invalid_grant")
+		return
+	case base64.RawURLEncoding.EncodeToString(challenge[:]) != code.challenge:
+		tokenError(w, "invalid_grant")
+		return
+	}
+
+	p.mu.Lock()
+	p.tokenRequests++
+	p.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": rand.Text(),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     p.IDToken(code.nonce, code.claims),
+	})
+}
+
+func tokenError(w http.ResponseWriter, code string) {
+	writeJSON(w, http.StatusBadRequest, map[string]string{"error": code})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		panic(fmt.Sprintf("could not encode response: %v", err))
+	}
+}
@@ -0,0 +1,255 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/firefart/go-webserver-template/internal/config"
+	inthttp "github.com/firefart/go-webserver-template/internal/http"
+)
+
+const (
+	// flowTimeout is the time a user has to log in at the provider
+	flowTimeout = 10 * time.Minute
+	// leeway allows for clock skew between the provider and this server
+	leeway = 1 * time.Minute
+)
+
+var (
+	// ErrInvalidToken is returned if the id token fails the verification
+	ErrInvalidToken = errors.New("invalid id token")
+	// ErrNotAuthorized is returned if the user matches no rule
+	ErrNotAuthorized = errors.New("user is not authorized")
+)
+
+type discovery struct {
+	Issuer                        string   `json:"issuer"`
+	AuthorizationEndpoint         string   `json:"authorization_endpoint"`
+	TokenEndpoint                 string   `json:"token_endpoint"`
+	JWKSURI                       string   `json:"jwks_uri"`
+	CodeChallengeMethodsSupported []string `json:"code_challenge_methods_supported"`
+}
+
+type tokenResponse struct {
+	IDToken string `json:"id_token"`
+}
+
+// Provider logs users in with the authorization code flow of an OpenID
+// Connect provider
+type Provider struct {
+	logger   *slog.Logger
+	client   *inthttp.Client
+	config   config.OIDC
+	authURL  *url.URL
+	tokenURL string
+	keys     *keySet
+	now      func() time.Time
+}
+
+// New fetches the configuration of the provider from the discovery endpoint
+// of the issuer
+func New(ctx context.Context, logger *slog.Logger, client *inthttp.Client, configuration config.OIDC) (*Provider, error) {
+	if !slices.Contains(configuration.Scopes, "openid") {
+		return nil, errors.New("oidc scopes must contain openid")
+	}
+	wellKnown := strings.TrimSuffix(configuration.Issuer, "/") + "/.well-known/openid-configuration"
+	d, err := inthttp.GetJSON[discovery](ctx, client, wellKnown)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch the oidc discovery document: %w", err)
+	}
+	if d.Issuer != configuration.Issuer {
+		return nil, fmt.Errorf("issuer %q of the discovery document does not match the configured issuer %q", d.Issuer, configuration.Issuer)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, errors.New("oidc discovery document is missing endpoints")
+	}
+	if len(d.CodeChallengeMethodsSupported) > 0 && !slices.Contains(d.CodeChallengeMethodsSupported, "S256") {
+		return nil, errors.New("oidc provider does not support pkce with S256")
+	}
+	authURL, err := url.Parse(d.AuthorizationEndpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid authorization endpoint: %w", err)
+	}
+
+	return &Provider{
+		logger:   logger,
+		client:   client,
+		config:   configuration,
+		authURL:  authURL,
+		tokenURL: d.TokenEndpoint,
+		keys:     newKeySet(logger, client, d.JWKSURI, configuration.JWKSCacheTTL),
+		now:      time.Now,
+	}, nil
+}
+
+// Flow holds the values of a login between the redirect to the provider and
+// the callback. It is stored in the session of the user.
+type Flow struct {
+	State    string    `json:"state"`
+	Nonce    string    `json:"nonce"`
+	Verifier string    `json:"verifier"`
+	Next     string    `json:"next"`
+	Expires  time.Time `json:"expires"`
+}
+
+// NewFlow starts a new login, next is the local url the user is redirected
+// to after the login
+func (p *Provider) NewFlow(next string) Flow {
+	return Flow{
+		State: rand.Text(),
+		Nonce: rand.Text(),
+		// the pkce verifier needs at least 43 characters
+		Verifier: rand.Text() + rand.Text(),
+		Next:     next,
+		Expires:  p.now().Add(flowTimeout),
+	}
+}
+
+// CheckState checks that the state of the callback belongs to the flow
+func (p *Provider) CheckState(f Flow, state string) error {
+	if state == "" || subtle.ConstantTimeCompare([]byte(f.State), []byte(state)) != 1 {
+		return errors.New("invalid state")
+	}
+	if !p.now().Before(f.Expires) {
+		return errors.New("login expired")
+	}
+	return nil
+}
+
+// AuthCodeURL returns the url of the provider the user is redirected to
+func (p *Provider) AuthCodeURL(f Flow) string {
+	challenge := sha256.Sum256([]byte(f.Verifier))
+	u := *p.authURL
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", p.config.ClientID)
+	q.Set("redirect_uri", p.config.RedirectURL)
+	q.Set("scope", strings.Join(p.config.Scopes, " "))
+	q.Set("state", f.State)
+	q.Set("nonce", f.Nonce)
+	q.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	q.Set("code_challenge_method", "S256")
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// Exchange redeems the code of the callback at the token endpoint and
+// returns the verified claims of the id token
+func (p *Provider) Exchange(ctx context.Context, code string, f Flow) (Claims, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"code_verifier": {f.Verifier},
+	}
+	if p.config.ClientSecret == "" {
+		// public clients only send their id
+		form.Set("client_id", p.config.ClientID)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("could not create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if p.config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+	resp, err := inthttp.DoJSON[tokenResponse](p.client, req)
+	if err != nil {
+		return nil, fmt.Errorf("could not redeem the code: %w", err)
+	}
+	if resp.IDToken == "" {
+		return nil, fmt.Errorf("%w: token response has no id token", ErrInvalidToken)
+	}
+	return p.Verify(ctx, resp.IDToken, f.Nonce)
+}
+
+// Verify checks the signature and the claims of the id token
+func (p *Provider) Verify(ctx context.Context, rawIDToken, nonce string) (Claims, error) {
+	t, err := parseJWT(rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+	if _, ok := algorithms[t.header.Alg]; !ok {
+		return nil, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidToken, t.header.Alg)
+	}
+	key, err := p.keys.key(ctx, t.header.Kid)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+	if err := verifySignature(t, key); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	var standard struct {
+		Issuer    string   `json:"iss"`
+		Audience  audience `json:"aud"`
+		AZP       string   `json:"azp"`
+		Expires   *float64 `json:"exp"`
+		IssuedAt  *float64 `json:"iat"`
+		Nonce     string   `json:"nonce"`
+		Subject   string   `json:"sub"`
+		NotBefore *float64 `json:"nbf"`
+	}
+	if err := json.Unmarshal(t.payload, &standard); err != nil {
+		return nil, fmt.Errorf("%w: could not parse claims: %w", ErrInvalidToken, err)
+	}
+	now := p.now()
+	switch {
+	case standard.Issuer != p.config.Issuer:
+		return nil, fmt.Errorf("%w: wrong issuer %q", ErrInvalidToken, standard.Issuer)
+	case !slices.Contains(standard.Audience, p.config.ClientID):
+		return nil, fmt.Errorf("%w: wrong audience", ErrInvalidToken)
+	case (len(standard.Audience) > 1 || standard.AZP != "") && standard.AZP != p.config.ClientID:
+		return nil, fmt.Errorf("%w: wrong authorized party", ErrInvalidToken)
+	case standard.Subject == "":
+		return nil, fmt.Errorf("%w: missing subject", ErrInvalidToken)
+	case standard.Expires == nil || !now.Before(unixTime(*standard.Expires).Add(leeway)):
+		return nil, fmt.Errorf("%w: token expired", ErrInvalidToken)
+	case standard.IssuedAt == nil || unixTime(*standard.IssuedAt).After(now.Add(leeway)):
+		return nil, fmt.Errorf("%w: token issued in the future", ErrInvalidToken)
+	case standard.NotBefore != nil && unixTime(*standard.NotBefore).After(now.Add(leeway)):
+		return nil, fmt.Errorf("%w: token not yet valid", ErrInvalidToken)
+	case nonce == "" || subtle.ConstantTimeCompare([]byte(standard.Nonce), []byte(nonce)) != 1:
+		return nil, fmt.Errorf("%w: wrong nonce", ErrInvalidToken)
+	}
+
+	var claims Claims
+	if err := json.Unmarshal(t.payload, &claims); err != nil {
+		return nil, fmt.Errorf("%w: could not parse claims: %w", ErrInvalidToken, err)
+	}
+	return claims, nil
+}
+
+func unixTime(seconds float64) time.Time {
+	return time.UnixMilli(int64(seconds * 1000))
+}
+
+// audience is a single string or a list of strings
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(b, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
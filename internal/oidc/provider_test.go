@@ -0,0 +1,292 @@
+package oidc
+
+import (
+	"log/slog"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/firefart/go-webserver-template/internal/auth"
+	"github.com/firefart/go-webserver-template/internal/config"
+	"github.com/firefart/go-webserver-template/internal/oidc/oidctest"
+	"github.com/firefart/go-webserver-template/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+const testRedirectURL = "https://app.example.com/auth/oidc/callback"
+
+func newTestConfig(idp *oidctest.Provider) config.OIDC {
+	return config.OIDC{
+		Enabled:       true,
+		Issuer:        idp.Issuer(),
+		ClientID:      idp.ClientID,
+		ClientSecret:  idp.ClientSecret,
+		RedirectURL:   testRedirectURL,
+		Scopes:        []string{"openid", "profile"},
+		UsernameClaim: "preferred_username",
+		GroupsClaim:   "groups",
+		JWKSCacheTTL:  time.Hour,
+		Rules: []config.OIDCRule{
+			{Role: auth.RoleAdmin, Groups: []string{"admins"}},
+			{Role: auth.RoleUser, Claims: map[string]string{"email_verified": "true"}},
+		},
+	}
+}
+
+func newTestProvider(t *testing.T) (*Provider, *oidctest.Provider) {
+	t.Helper()
+	idp := oidctest.New("client", "secret")
+	t.Cleanup(idp.Close)
+	p, err := New(t.Context(), slog.New(slog.DiscardHandler), testutil.NewHTTPClient(t), newTestConfig(idp))
+	require.NoError(t, err)
+	return p, idp
+}
+
+// authorize sends the user to the provider and returns the callback url
+func authorize(t *testing.T, p *Provider, f Flow) *url.URL {
+	t.Helper()
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, p.AuthCodeURL(f), nil)
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	return callback
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	idp := oidctest.New("client", "secret")
+	t.Cleanup(idp.Close)
+
+	c := newTestConfig(idp)
+	_, err := New(t.Context(), slog.New(slog.DiscardHandler), testutil.NewHTTPClient(t), c)
+	require.NoError(t, err)
+
+	c.Issuer = idp.Issuer() + "/"
+	_, err = New(t.Context(), slog.New(slog.DiscardHandler), testutil.NewHTTPClient(t), c)
+	require.ErrorContains(t, err, "does not match the configured issuer")
+
+	c.Issuer = idp.Issuer() + "/unknown"
+	_, err = New(t.Context(), slog.New(slog.DiscardHandler), testutil.NewHTTPClient(t), c)
+	require.ErrorContains(t, err, "could not fetch the oidc discovery document")
+}
+
+func TestLoginFlow(t *testing.T) {
+	t.Parallel()
+
+	p, idp := newTestProvider(t)
+	idp.SetClaims(map[string]any{
+		"sub":                "1234",
+		"preferred_username": "alice",
+		"groups":             []string{"users", "admins"},
+	})
+
+	f := p.NewFlow("/admin")
+	callback := authorize(t, p, f)
+	require.Equal(t, "app.example.com", callback.Host)
+	require.NoError(t, p.CheckState(f, callback.Query().Get("state")))
+
+	claims, err := p.Exchange(t.Context(), callback.Query().Get("code"), f)
+	require.NoError(t, err)
+	require.Equal(t, "1234", claims.String("sub"))
+
+	principal, err := p.Principal(claims)
+	require.NoError(t, err)
+	require.Equal(t, auth.Principal{Username: "alice", Role: auth.RoleAdmin}, principal)
+
+	// codes can only be redeemed once
+	_, err = p.Exchange(t.Context(), callback.Query().Get("code"), f)
+	require.Error(t, err)
+	require.Equal(t, 1, idp.TokenRequests())
+}
+
+func TestExchangeWrongVerifier(t *testing.T) {
+	t.Parallel()
+
+	p, idp := newTestProvider(t)
+	f := p.NewFlow("/")
+	callback := authorize(t, p, f)
+
+	// an attacker who intercepted the code does not know the verifier
+	other := p.NewFlow("/")
+	other.Nonce = f.Nonce
+	_, err := p.Exchange(t.Context(), callback.Query().Get("code"), other)
+	require.ErrorContains(t, err, "could not redeem the code")
+	require.Equal(t, 0, idp.TokenRequests())
+}
+
+func TestCheckState(t *testing.T) {
+	t.Parallel()
+
+	p, _ := newTestProvider(t)
+	f := p.NewFlow("/")
+	require.NoError(t, p.CheckState(f, f.State))
+	require.Error(t, p.CheckState(f, ""))
+	require.Error(t, p.CheckState(f, "wrong"))
+
+	p.now = func() time.Time { return time.Now().Add(flowTimeout + time.Second) }
+	require.ErrorContains(t, p.CheckState(f, f.State), "expired")
+}
+
+func TestVerify(t *testing.T) {
+	t.Parallel()
+
+	p, idp := newTestProvider(t)
+	now := time.Now()
+	valid := func(overrides map[string]any) map[string]any {
+		claims := map[string]any{
+			"iss":   idp.Issuer(),
+			"aud":   "client",
+			"sub":   "1234",
+			"iat":   now.Unix(),
+			"exp":   now.Add(5 * time.Minute).Unix(),
+			"nonce": "nonce",
+		}
+		for k, v := range overrides {
+			if v == nil {
+				delete(claims, k)
+				continue
+			}
+			claims[k] = v
+		}
+		return claims
+	}
+
+	tests := []struct {
+		name   string
+		alg    string
+		claims map[string]any
+		nonce  string
+		err    string
+	}{
+		{name: "valid", claims: valid(nil)},
+		{name: "audience list", claims: valid(map[string]any{"aud": []string{"client", "other"}, "azp": "client"})},
+		{name: "audience list without azp", claims: valid(map[string]any{"aud": []string{"client", "other"}}), err: "wrong authorized party"},
+		{name: "wrong issuer", claims: valid(map[string]any{"iss": "https://evil.example.com"}), err: "wrong issuer"},
+		{name: "wrong audience", claims: valid(map[string]any{"aud": "other"}), err: "wrong audience"},
+		{name: "wrong authorized party", claims: valid(map[string]any{"azp": "other"}), err: "wrong authorized party"},
+		{name: "missing subject", claims: valid(map[string]any{"sub": nil}), err: "missing subject"},
+		{name: "expired", claims: valid(map[string]any{"exp": now.Add(-2 * time.Minute).Unix()}), err: "token expired"},
+		{name: "expired within leeway", claims: valid(map[string]any{"exp": now.Add(-30 * time.Second).Unix()})},
+		{name: "missing expiry", claims: valid(map[string]any{"exp": nil}), err: "token expired"},
+		{name: "issued in the future", claims: valid(map[string]any{"iat": now.Add(time.Hour).Unix()}), err: "issued in the future"},
+		{name: "not yet valid", claims: valid(map[string]any{"nbf": now.Add(time.Hour).Unix()}), err: "not yet valid"},
+		{name: "wrong nonce", claims: valid(nil), nonce: "other", err: "wrong nonce"},
+		{name: "missing nonce", claims: valid(map[string]any{"nonce": nil}), err: "wrong nonce"},
+		{name: "alg none", alg: "none", claims: valid(nil), err: "unsupported algorithm"},
+		{name: "alg hs256", alg: "HS256", claims: valid(nil), err: "unsupported algorithm"},
+		{name: "invalid signature", alg: "RS512", claims: valid(nil), err: "verification error"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			alg := tc.alg
+			if alg == "" {
+				alg = "RS256"
+			}
+			nonce := tc.nonce
+			if nonce == "" {
+				nonce = "nonce"
+			}
+			token := idp.SignToken(alg, "", tc.claims)
+			claims, err := p.Verify(t.Context(), token, nonce)
+			if tc.err != "" {
+				require.ErrorIs(t, err, ErrInvalidToken)
+				require.ErrorContains(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, "1234", claims.String("sub"))
+		})
+	}
+}
+
+func TestVerifyKeyRotation(t *testing.T) {
+	t.Parallel()
+
+	p, idp := newTestProvider(t)
+	now := time.Now()
+	p.keys.now = func() time.Time { return now }
+
+	_, err := p.Verify(t.Context(), idp.IDToken("nonce", map[string]any{"sub": "1234"}), "nonce")
+	require.NoError(t, err)
+	require.Equal(t, 1, idp.JWKSRequests())
+
+	// cached
+	_, err = p.Verify(t.Context(), idp.IDToken("nonce", map[string]any{"sub": "1234"}), "nonce")
+	require.NoError(t, err)
+	require.Equal(t, 1, idp.JWKSRequests())
+
+	// unknown keys do not refetch the key set right away
+	idp.RotateKey()
+	_, err = p.Verify(t.Context(), idp.IDToken("nonce", map[string]any{"sub": "1234"}), "nonce")
+	require.ErrorContains(t, err, "unknown key")
+	require.Equal(t, 1, idp.JWKSRequests())
+
+	now = now.Add(minRefreshInterval)
+	_, err = p.Verify(t.Context(), idp.IDToken("nonce", map[string]any{"sub": "1234"}), "nonce")
+	require.NoError(t, err)
+	require.Equal(t, 2, idp.JWKSRequests())
+
+	// the key set is refetched after the ttl
+	now = now.Add(time.Hour)
+	_, err = p.Verify(t.Context(), idp.IDToken("nonce", map[string]any{"sub": "1234"}), "nonce")
+	require.NoError(t, err)
+	require.Equal(t, 3, idp.JWKSRequests())
+}
+
+func TestPrincipal(t *testing.T) {
+	t.Parallel()
+
+	p, _ := newTestProvider(t)
+
+	tests := []struct {
+		name   string
+		claims Claims
+		want   auth.Principal
+		err    error
+	}{
+		{
+			name:   "group",
+			claims: Claims{"sub": "1", "preferred_username": "alice", "groups": []any{"admins"}},
+			want:   auth.Principal{Username: "alice", Role: auth.RoleAdmin},
+		},
+		{
+			name:   "single group",
+			claims: Claims{"sub": "1", "preferred_username": "alice", "groups": "admins"},
+			want:   auth.Principal{Username: "alice", Role: auth.RoleAdmin},
+		},
+		{
+			name:   "claim",
+			claims: Claims{"sub": "2", "groups": []any{"users"}, "email_verified": true},
+			want:   auth.Principal{Username: "2", Role: auth.RoleUser},
+		},
+		{
+			name:   "no rule",
+			claims: Claims{"sub": "3", "groups": []any{"users"}, "email_verified": false},
+			err:    ErrNotAuthorized,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			principal, err := p.Principal(tc.claims)
+			if tc.err != nil {
+				require.ErrorIs(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.want, principal)
+		})
+	}
+}
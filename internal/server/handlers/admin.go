@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/firefart/go-webserver-template/internal/auth"
+	"github.com/firefart/go-webserver-template/internal/server/helper"
+	"github.com/firefart/go-webserver-template/internal/server/httperror"
+	"github.com/firefart/go-webserver-template/internal/server/templates"
+)
+
+// AdminHandler serves the admin pages, it must be protected by the
+// authentication middlewares
+type AdminHandler struct {
+	tokens bool
+	debug  bool
+}
+
+// NewAdminHandler creates the handler, tokens enables the link to the API
+// token management
+func NewAdminHandler(tokens, debug bool) *AdminHandler {
+	return &AdminHandler{
+		tokens: tokens,
+		debug:  debug,
+	}
+}
+
+func (h *AdminHandler) Handler(w http.ResponseWriter, r *http.Request) error {
+	p, ok := auth.PrincipalFromContext(r.Context())
+	if !ok {
+		return httperror.New(http.StatusUnauthorized, "not logged in")
+	}
+	component := templates.Admin(p, h.tokens)
+
+	w.WriteHeader(http.StatusOK)
+
+	if helper.IsHTMX(r) {
+		// only render the single component if it's a htmx request
+		return component.Render(r.Context(), w)
+	}
+	return templates.Layout(component, "Administration", h.debug).Render(r.Context(), w)
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/firefart/go-webserver-template/internal/database"
+	"github.com/firefart/go-webserver-template/internal/server/httperror"
+	"github.com/firefart/go-webserver-template/internal/server/middleware"
+)
+
+// DummyCacheTag is attached to all cached responses containing dummy entries
+const DummyCacheTag = "dummy"
+
+// CacheInvalidator purges cached responses by tag
+type CacheInvalidator interface {
+	Invalidate(ctx context.Context, tags ...string) error
+}
+
+type DummyHandler struct {
+	db    database.Interface
+	cache CacheInvalidator
+}
+
+// NewDummyHandler creates the handler. cache can be nil if response caching
+// is disabled.
+func NewDummyHandler(db database.Interface, cache CacheInvalidator) *DummyHandler {
+	return &DummyHandler{
+		db:    db,
+		cache: cache,
+	}
+}
+
+func (h *DummyHandler) ListHandler(w http.ResponseWriter, r *http.Request) error {
+	middleware.AddCacheTags(r, DummyCacheTag)
+
+	ids, err := h.db.GetAllDummy(r.Context())
+	if err != nil {
+		return err
+	}
+	if ids == nil {
+		ids = []int64{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	return json.NewEncoder(w).Encode(ids)
+}
+
+func (h *DummyHandler) CreateHandler(w http.ResponseWriter, r *http.Request) error {
+	name := r.FormValue("name")
+	if name == "" {
+		return httperror.BadRequest("missing name")
+	}
+
+	id, err := h.db.InsertDummy(r.Context(), name)
+	if err != nil {
+		return err
+	}
+
+	// purge all pages containing dummies
+	if h.cache != nil {
+		if err := h.cache.Invalidate(r.Context(), DummyCacheTag); err != nil {
+			return err
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(id)
+}
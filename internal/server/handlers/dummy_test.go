@@ -0,0 +1,51 @@
+package handlers_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/firefart/go-webserver-template/internal/database"
+	"github.com/firefart/go-webserver-template/internal/server/handlers"
+	"github.com/stretchr/testify/require"
+)
+
+type invalidatorMock struct {
+	tags []string
+}
+
+func (i *invalidatorMock) Invalidate(_ context.Context, tags ...string) error {
+	i.tags = append(i.tags, tags...)
+	return nil
+}
+
+func TestDummyList(t *testing.T) {
+	t.Parallel()
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	require.NoError(t, handlers.NewDummyHandler(database.NewMockDB(), nil).ListHandler(rec, req))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, "[]", rec.Body.String())
+}
+
+func TestDummyCreate(t *testing.T) {
+	t.Parallel()
+
+	invalidator := &invalidatorMock{}
+	h := handlers.NewDummyHandler(database.NewMockDB(), invalidator)
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(url.Values{"name": {"test"}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	require.NoError(t, h.CreateHandler(rec, req))
+	require.Equal(t, http.StatusCreated, rec.Code)
+	require.Equal(t, []string{handlers.DummyCacheTag}, invalidator.tags)
+
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/", nil)
+	require.Error(t, h.CreateHandler(rec, req))
+}
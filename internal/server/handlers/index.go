@@ -20,6 +20,8 @@ func NewIndexHandler(debug bool) *IndexHandler {
 func (h *IndexHandler) Handler(w http.ResponseWriter, r *http.Request) error {
 	component := templates.Homepage()
 
+	// htmx requests get a fragment of the page
+	w.Header().Add("Vary", "Hx-Request")
 	w.WriteHeader(http.StatusOK)
 
 	if helper.IsHTMX(r) {
@@ -14,5 +14,6 @@ func TestIndex(t *testing.T) {
 	rec := httptest.NewRecorder()
 	require.NoError(t, handlers.NewIndexHandler(true).Handler(rec, req))
 	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "Hx-Request", rec.Header().Get("Vary"))
 	require.Greater(t, len(rec.Body.String()), 10)
 }
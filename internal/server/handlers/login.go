@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/firefart/go-webserver-template/internal/auth"
+	"github.com/firefart/go-webserver-template/internal/server/helper"
+	"github.com/firefart/go-webserver-template/internal/server/httperror"
+	"github.com/firefart/go-webserver-template/internal/server/middleware"
+	"github.com/firefart/go-webserver-template/internal/server/templates"
+	"github.com/firefart/go-webserver-template/internal/session"
+
+	"github.com/a-h/templ"
+)
+
+// Authenticator checks the credentials of a login
+type Authenticator interface {
+	Login(ctx context.Context, username, password, ip string) (auth.Principal, error)
+}
+
+// LoginHandler handles the login and logout pages. It needs the session and
+// authentication middlewares. The authenticator is nil if only the single
+// sign-on login is enabled.
+type LoginHandler struct {
+	auth   Authenticator
+	sso    bool
+	logger *slog.Logger
+	debug  bool
+}
+
+func NewLoginHandler(authenticator Authenticator, sso bool, logger *slog.Logger, debug bool) *LoginHandler {
+	return &LoginHandler{
+		auth:   authenticator,
+		sso:    sso,
+		logger: logger,
+		debug:  debug,
+	}
+}
+
+func (h *LoginHandler) LoginFormHandler(w http.ResponseWriter, r *http.Request) error {
+	next := safeRedirect(r.URL.Query().Get("next"))
+	if _, ok := auth.PrincipalFromContext(r.Context()); ok {
+		http.Redirect(w, r, next, http.StatusSeeOther)
+		return nil
+	}
+	return h.render(w, r, http.StatusOK, templates.LoginForm(next, "", "", h.auth != nil, h.sso), "Login")
+}
+
+func (h *LoginHandler) LoginHandler(w http.ResponseWriter, r *http.Request) error {
+	if h.auth == nil {
+		return httperror.NotFound("password login is disabled")
+	}
+	username := r.PostFormValue("username")
+	password := r.PostFormValue("password")
+	next := safeRedirect(r.PostFormValue("next"))
+
+	ip, ok := r.Context().Value(middleware.ContextKeyIP).(string)
+	if !ok {
+		ip = r.RemoteAddr
+	}
+
+	p, err := h.auth.Login(r.Context(), username, password, ip)
+	if err != nil {
+		var message string
+		switch {
+		case errors.Is(err, auth.ErrInvalidCredentials), errors.Is(err, auth.ErrLocked):
+			message = err.Error()
+		default:
+			return err
+		}
+		h.logger.Warn("failed login", slog.String("username", username), slog.String("ip", ip), slog.String("err", err.Error()))
+		status := http.StatusUnauthorized
+		if helper.IsHTMX(r) {
+			// htmx does not swap error responses by default
+			status = http.StatusOK
+		}
+		return h.render(w, r, status, templates.LoginForm(next, username, message, true, h.sso), "Login")
+	}
+
+	// issue a new session id to prevent session fixation
+	session.Renew(r.Context())
+	if err := session.Set(r.Context(), auth.SessionKeyUserID, p.UserID); err != nil {
+		return err
+	}
+	h.logger.Info("successful login", slog.String("username", p.Username), slog.String("ip", ip))
+
+	if helper.IsHTMX(r) {
+		w.Header().Set("HX-Redirect", next)
+		w.WriteHeader(http.StatusOK)
+		return nil
+	}
+	http.Redirect(w, r, next, http.StatusSeeOther)
+	return nil
+}
+
+func (h *LoginHandler) LogoutFormHandler(w http.ResponseWriter, r *http.Request) error {
+	p, ok := auth.PrincipalFromContext(r.Context())
+	if !ok {
+		return h.render(w, r, http.StatusOK, templates.LoggedOut(), "Logout")
+	}
+	return h.render(w, r, http.StatusOK, templates.LogoutForm(p), "Logout")
+}
+
+func (h *LoginHandler) LogoutHandler(w http.ResponseWriter, r *http.Request) error {
+	session.Destroy(r.Context())
+	return h.render(w, r, http.StatusOK, templates.LoggedOut(), "Logout")
+}
+
+func (h *LoginHandler) render(w http.ResponseWriter, r *http.Request, status int, component templ.Component, title string) error {
+	w.WriteHeader(status)
+
+	if helper.IsHTMX(r) {
+		// only render the single component if it's a htmx request
+		return component.Render(r.Context(), w)
+	}
+	return templates.Layout(component, title, h.debug).Render(r.Context(), w)
+}
+
+// safeRedirect only allows local paths as redirect target after the login so
+// the next parameter can't be abused as an open redirect
+func safeRedirect(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	u, err := url.Parse(next)
+	if err != nil || u.Scheme != "" || u.Host != "" {
+		return "/"
+	}
+	return next
+}
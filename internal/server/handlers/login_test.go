@@ -0,0 +1,127 @@
+package handlers_test
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/firefart/go-webserver-template/internal/auth"
+	"github.com/firefart/go-webserver-template/internal/config"
+	"github.com/firefart/go-webserver-template/internal/server/handlers"
+	"github.com/firefart/go-webserver-template/internal/session"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeAuthenticator struct{}
+
+func (fakeAuthenticator) Login(_ context.Context, username, password, _ string) (auth.Principal, error) {
+	switch {
+	case username == "locked":
+		return auth.Principal{}, auth.ErrLocked
+	case username == "admin" && password == "correct horse":
+		return auth.Principal{UserID: 1, Username: "admin", Role: auth.RoleAdmin}, nil
+	default:
+		return auth.Principal{}, auth.ErrInvalidCredentials
+	}
+}
+
+func TestLogin(t *testing.T) {
+	t.Parallel()
+
+	sessions, err := session.NewManager(slog.New(slog.DiscardHandler), session.NewMemoryStore(t.Context(), slog.New(slog.DiscardHandler)), config.Session{
+		CookieName:      "session",
+		SigningKeys:     []string{"0123456789abcdef0123456789abcdef"},
+		IdleTimeout:     30 * time.Minute,
+		AbsoluteTimeout: 2 * time.Hour,
+	})
+	require.NoError(t, err)
+	h := handlers.NewLoginHandler(fakeAuthenticator{}, false, slog.New(slog.DiscardHandler), true)
+
+	serve := func(handler func(http.ResponseWriter, *http.Request) error, req *http.Request) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		sessions.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			require.NoError(t, handler(w, r))
+		})).ServeHTTP(rec, req)
+		return rec
+	}
+	post := func(username, password, next string, htmx bool) *httptest.ResponseRecorder {
+		form := url.Values{"username": {username}, "password": {password}, "next": {next}}
+		req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if htmx {
+			req.Header.Set("Hx-Request", "true")
+		}
+		return serve(h.LoginHandler, req)
+	}
+
+	rec := serve(h.LoginFormHandler, httptest.NewRequest(http.MethodGet, "/login?next=/admin", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), `name="next" value="/admin"`)
+	require.Contains(t, rec.Body.String(), "<html")
+	require.NotContains(t, rec.Body.String(), "/auth/oidc/login")
+
+	rec = post("admin", "wrong", "/admin", false)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	require.Contains(t, rec.Body.String(), auth.ErrInvalidCredentials.Error())
+	require.Empty(t, rec.Result().Cookies())
+
+	// htmx only gets the form with the error
+	rec = post("locked", "correct horse", "/admin", true)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), auth.ErrLocked.Error())
+	require.NotContains(t, rec.Body.String(), "<html")
+
+	rec = post("admin", "correct horse", "/admin", false)
+	require.Equal(t, http.StatusSeeOther, rec.Code)
+	require.Equal(t, "/admin", rec.Header().Get("Location"))
+	require.Len(t, rec.Result().Cookies(), 1)
+
+	rec = post("admin", "correct horse", "/admin", true)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "/admin", rec.Header().Get("HX-Redirect"))
+
+	// only local redirects are allowed
+	for _, next := range []string{"https://example.com", "//example.com", "/\\example.com", "admin"} {
+		rec = post("admin", "correct horse", next, false)
+		require.Equal(t, "/", rec.Header().Get("Location"), next)
+	}
+
+	// a logged in user is redirected
+	req := httptest.NewRequest(http.MethodGet, "/login?next=/admin", nil)
+	req = req.WithContext(auth.WithPrincipal(req.Context(), auth.Principal{UserID: 1}))
+	rec = serve(h.LoginFormHandler, req)
+	require.Equal(t, http.StatusSeeOther, rec.Code)
+	require.Equal(t, "/admin", rec.Header().Get("Location"))
+}
+
+func TestLoginSSOOnly(t *testing.T) {
+	t.Parallel()
+
+	h := handlers.NewLoginHandler(nil, true, slog.New(slog.DiscardHandler), true)
+	rec := httptest.NewRecorder()
+	require.NoError(t, h.LoginFormHandler(rec, httptest.NewRequest(http.MethodGet, "/login?next=/admin", nil)))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), `href="/auth/oidc/login?next=%2Fadmin"`)
+	require.NotContains(t, rec.Body.String(), `name="password"`)
+}
+
+func TestLogout(t *testing.T) {
+	t.Parallel()
+
+	h := handlers.NewLoginHandler(fakeAuthenticator{}, false, slog.New(slog.DiscardHandler), true)
+
+	req := httptest.NewRequest(http.MethodGet, "/logout", nil)
+	req = req.WithContext(auth.WithPrincipal(req.Context(), auth.Principal{UserID: 1, Username: "admin"}))
+	rec := httptest.NewRecorder()
+	require.NoError(t, h.LogoutFormHandler(rec, req))
+	require.Contains(t, rec.Body.String(), "logged in as admin")
+
+	rec = httptest.NewRecorder()
+	require.NoError(t, h.LogoutHandler(rec, httptest.NewRequest(http.MethodPost, "/logout", nil)))
+	require.Contains(t, rec.Body.String(), "Logged out")
+}
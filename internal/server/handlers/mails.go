@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/firefart/go-webserver-template/internal/mail"
+	"github.com/firefart/go-webserver-template/internal/server/helper"
+	"github.com/firefart/go-webserver-template/internal/server/httperror"
+	"github.com/firefart/go-webserver-template/internal/server/templates"
+
+	"github.com/a-h/templ"
+)
+
+// MailCaptureHandler shows the mails recorded by the capture in debug mode
+type MailCaptureHandler struct {
+	capture *mail.Capture
+	debug   bool
+}
+
+func NewMailCaptureHandler(capture *mail.Capture, debug bool) *MailCaptureHandler {
+	return &MailCaptureHandler{
+		capture: capture,
+		debug:   debug,
+	}
+}
+
+func (h *MailCaptureHandler) ListHandler(w http.ResponseWriter, r *http.Request) error {
+	return h.render(w, r, templates.MailList(h.capture.Mails()), "Captured mails")
+}
+
+func (h *MailCaptureHandler) ShowHandler(w http.ResponseWriter, r *http.Request) error {
+	m, err := h.mail(r)
+	if err != nil {
+		return err
+	}
+	return h.render(w, r, templates.MailDetail(m), m.Message.Subject)
+}
+
+func (h *MailCaptureHandler) HTMLHandler(w http.ResponseWriter, r *http.Request) error {
+	m, err := h.mail(r)
+	if err != nil {
+		return err
+	}
+	// the html is shown in an iframe, keep scripts in it from running
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	return h.write(w, "text/html; charset=utf-8", m.Message.HTMLBody)
+}
+
+func (h *MailCaptureHandler) TextHandler(w http.ResponseWriter, r *http.Request) error {
+	m, err := h.mail(r)
+	if err != nil {
+		return err
+	}
+	return h.write(w, "text/plain; charset=utf-8", m.Message.TextBody)
+}
+
+func (h *MailCaptureHandler) RawHandler(w http.ResponseWriter, r *http.Request) error {
+	m, err := h.mail(r)
+	if err != nil {
+		return err
+	}
+	return h.write(w, "text/plain; charset=utf-8", string(m.Raw))
+}
+
+func (h *MailCaptureHandler) mail(r *http.Request) (mail.CapturedMail, error) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return mail.CapturedMail{}, httperror.BadRequest("invalid id")
+	}
+	m, ok := h.capture.Mail(id)
+	if !ok {
+		return mail.CapturedMail{}, httperror.NotFound("mail not found")
+	}
+	return m, nil
+}
+
+func (h *MailCaptureHandler) render(w http.ResponseWriter, r *http.Request, component templ.Component, title string) error {
+	w.WriteHeader(http.StatusOK)
+
+	if helper.IsHTMX(r) {
+		// only render the single component if it's a htmx request
+		return component.Render(r.Context(), w)
+	}
+	return templates.Layout(component, title, h.debug).Render(r.Context(), w)
+}
+
+func (h *MailCaptureHandler) write(w http.ResponseWriter, contentType, content string) error {
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	_, err := w.Write([]byte(content))
+	return err
+}
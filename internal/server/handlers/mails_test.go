@@ -0,0 +1,58 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/firefart/go-webserver-template/internal/config"
+	"github.com/firefart/go-webserver-template/internal/mail"
+	"github.com/firefart/go-webserver-template/internal/server/handlers"
+	"github.com/firefart/go-webserver-template/internal/server/httperror"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMailCapture(t *testing.T) {
+	capture, err := mail.NewCapture(config.Configuration{})
+	require.NoError(t, err)
+	msg, err := mail.NewMessage().To("user@example.com").Subject("Welcome").TextBody("plain text").HTMLBody("<p>html body</p>").Build()
+	require.NoError(t, err)
+	require.NoError(t, capture.Send(t.Context(), msg))
+	id := strconv.Itoa(capture.Mails()[0].ID)
+
+	h := handlers.NewMailCaptureHandler(capture, true)
+
+	tests := []struct {
+		name        string
+		handler     func(http.ResponseWriter, *http.Request) error
+		contentType string
+		contains    string
+	}{
+		{name: "list", handler: h.ListHandler, contains: "Welcome"},
+		{name: "show", handler: h.ShowHandler, contains: "plain text"},
+		{name: "html", handler: h.HTMLHandler, contentType: "text/html; charset=utf-8", contains: "<p>html body</p>"},
+		{name: "text", handler: h.TextHandler, contentType: "text/plain; charset=utf-8", contains: "plain text"},
+		{name: "raw", handler: h.RawHandler, contentType: "text/plain; charset=utf-8", contains: "Subject: Welcome"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/debug/mails", nil)
+			req.SetPathValue("id", id)
+			rec := httptest.NewRecorder()
+			require.NoError(t, tt.handler(rec, req))
+			require.Equal(t, http.StatusOK, rec.Code)
+			if tt.contentType != "" {
+				require.Equal(t, tt.contentType, rec.Header().Get("Content-Type"))
+			}
+			require.Contains(t, rec.Body.String(), tt.contains)
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/debug/mails/2", nil)
+	req.SetPathValue("id", "2")
+	err = h.ShowHandler(httptest.NewRecorder(), req)
+	var httpErr *httperror.HTTPError
+	require.ErrorAs(t, err, &httpErr)
+	require.Equal(t, http.StatusNotFound, httpErr.StatusCode)
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/firefart/go-webserver-template/internal/auth"
+	"github.com/firefart/go-webserver-template/internal/oidc"
+	"github.com/firefart/go-webserver-template/internal/server/httperror"
+	"github.com/firefart/go-webserver-template/internal/session"
+)
+
+// sessionKeyOIDCFlow holds the running login at the provider
+const sessionKeyOIDCFlow = "oidc_flow"
+
+// OIDCProvider runs the authorization code flow of an OpenID Connect provider
+type OIDCProvider interface {
+	NewFlow(next string) oidc.Flow
+	CheckState(f oidc.Flow, state string) error
+	AuthCodeURL(f oidc.Flow) string
+	Exchange(ctx context.Context, code string, f oidc.Flow) (oidc.Claims, error)
+	Principal(claims oidc.Claims) (auth.Principal, error)
+}
+
+// OIDCHandler logs users in with single sign-on. It needs the session
+// middleware.
+type OIDCHandler struct {
+	provider OIDCProvider
+	logger   *slog.Logger
+}
+
+func NewOIDCHandler(provider OIDCProvider, logger *slog.Logger) *OIDCHandler {
+	return &OIDCHandler{
+		provider: provider,
+		logger:   logger,
+	}
+}
+
+// LoginHandler redirects the user to the provider
+func (h *OIDCHandler) LoginHandler(w http.ResponseWriter, r *http.Request) error {
+	f := h.provider.NewFlow(safeRedirect(r.URL.Query().Get("next")))
+	if err := session.Set(r.Context(), sessionKeyOIDCFlow, f); err != nil {
+		return err
+	}
+	http.Redirect(w, r, h.provider.AuthCodeURL(f), http.StatusFound)
+	return nil
+}
+
+// CallbackHandler finishes the login after the provider redirected back
+func (h *OIDCHandler) CallbackHandler(w http.ResponseWriter, r *http.Request) error {
+	f, ok := session.Get[oidc.Flow](r.Context(), sessionKeyOIDCFlow)
+	if !ok {
+		return httperror.BadRequest("no login in progress")
+	}
+	// a flow can only be used once
+	session.Delete(r.Context(), sessionKeyOIDCFlow)
+
+	q := r.URL.Query()
+	if err := h.provider.CheckState(f, q.Get("state")); err != nil {
+		return httperror.BadRequest(err.Error())
+	}
+	if e := q.Get("error"); e != "" {
+		h.logger.Warn("oidc login failed at the provider", slog.String("error", e), slog.String("description", q.Get("error_description")))
+		return httperror.New(http.StatusUnauthorized, "login failed")
+	}
+	if q.Get("code") == "" {
+		return httperror.BadRequest("missing code")
+	}
+
+	claims, err := h.provider.Exchange(r.Context(), q.Get("code"), f)
+	if err != nil {
+		if errors.Is(err, oidc.ErrInvalidToken) {
+			h.logger.Warn("invalid oidc id token", slog.String("err", err.Error()))
+			return httperror.New(http.StatusUnauthorized, "login failed")
+		}
+		return err
+	}
+	p, err := h.provider.Principal(claims)
+	if err != nil {
+		if errors.Is(err, oidc.ErrNotAuthorized) {
+			h.logger.Warn("oidc user is not authorized", slog.String("sub", claims.String("sub")), slog.String("err", err.Error()))
+			return httperror.New(http.StatusForbidden, "not authorized")
+		}
+		return err
+	}
+
+	// issue a new session id to prevent session fixation
+	session.Renew(r.Context())
+	if err := session.Set(r.Context(), auth.SessionKeyPrincipal, p); err != nil {
+		return err
+	}
+	h.logger.Info("successful oidc login", slog.String("username", p.Username), slog.String("role", p.Role))
+	http.Redirect(w, r, f.Next, http.StatusSeeOther)
+	return nil
+}
@@ -0,0 +1,125 @@
+package handlers_test
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/firefart/go-webserver-template/internal/auth"
+	"github.com/firefart/go-webserver-template/internal/config"
+	"github.com/firefart/go-webserver-template/internal/oidc"
+	"github.com/firefart/go-webserver-template/internal/oidc/oidctest"
+	"github.com/firefart/go-webserver-template/internal/server/handlers"
+	"github.com/firefart/go-webserver-template/internal/server/httperror"
+	"github.com/firefart/go-webserver-template/internal/session"
+	"github.com/firefart/go-webserver-template/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOIDCLogin(t *testing.T) {
+	t.Parallel()
+
+	idp := oidctest.New("webserver", "secret")
+	t.Cleanup(idp.Close)
+	provider, err := oidc.New(t.Context(), slog.New(slog.DiscardHandler), testutil.NewHTTPClient(t), config.OIDC{
+		Enabled:       true,
+		Issuer:        idp.Issuer(),
+		ClientID:      idp.ClientID,
+		ClientSecret:  idp.ClientSecret,
+		RedirectURL:   "http://app.example.com/auth/oidc/callback",
+		Scopes:        []string{"openid"},
+		UsernameClaim: "preferred_username",
+		GroupsClaim:   "groups",
+		JWKSCacheTTL:  time.Hour,
+		Rules: []config.OIDCRule{
+			{Role: auth.RoleAdmin, Groups: []string{"admins"}},
+		},
+	})
+	require.NoError(t, err)
+
+	sessions, err := session.NewManager(slog.New(slog.DiscardHandler), session.NewMemoryStore(t.Context(), slog.New(slog.DiscardHandler)), config.Session{
+		CookieName:      "session",
+		SigningKeys:     []string{"0123456789abcdef0123456789abcdef"},
+		IdleTimeout:     30 * time.Minute,
+		AbsoluteTimeout: 2 * time.Hour,
+	})
+	require.NoError(t, err)
+	h := handlers.NewOIDCHandler(provider, slog.New(slog.DiscardHandler))
+
+	serve := func(handler func(http.ResponseWriter, *http.Request) error, target string, cookie *http.Cookie) (*httptest.ResponseRecorder, error) {
+		var handlerErr error
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		sessions.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handlerErr = handler(w, r)
+		})).ServeHTTP(rec, req)
+		return rec, handlerErr
+	}
+	// start a login and return the session cookie and the callback url
+	// the provider redirected to
+	login := func() (*http.Cookie, string) {
+		rec, err := serve(h.LoginHandler, "/auth/oidc/login?next=/admin", nil)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusFound, rec.Code)
+
+		noRedirect := &http.Client{
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+		req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, rec.Header().Get("Location"), nil)
+		require.NoError(t, err)
+		resp, err := noRedirect.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusFound, resp.StatusCode)
+		callback, err := url.Parse(resp.Header.Get("Location"))
+		require.NoError(t, err)
+		return rec.Result().Cookies()[0], callback.RequestURI()
+	}
+	status := func(err error) int {
+		var httpErr *httperror.HTTPError
+		require.ErrorAs(t, err, &httpErr)
+		return httpErr.StatusCode
+	}
+
+	idp.SetClaims(map[string]any{"sub": "1", "preferred_username": "alice", "groups": []string{"admins"}})
+	cookie, callback := login()
+	rec, err := serve(h.CallbackHandler, callback, cookie)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusSeeOther, rec.Code)
+	require.Equal(t, "/admin", rec.Header().Get("Location"))
+
+	// the session id is renewed and holds the principal
+	renewed := rec.Result().Cookies()[0]
+	require.NotEqual(t, cookie.Value, renewed.Value)
+	var p auth.Principal
+	_, err = serve(func(_ http.ResponseWriter, r *http.Request) error {
+		p, _ = session.Get[auth.Principal](r.Context(), auth.SessionKeyPrincipal)
+		return nil
+	}, "/", renewed)
+	require.NoError(t, err)
+	require.Equal(t, auth.Principal{Username: "alice", Role: auth.RoleAdmin}, p)
+
+	// the callback can't be replayed
+	_, err = serve(h.CallbackHandler, callback, cookie)
+	require.Equal(t, http.StatusBadRequest, status(err))
+
+	// the state must match the session
+	cookie, _ = login()
+	_, otherCallback := login()
+	_, err = serve(h.CallbackHandler, otherCallback, cookie)
+	require.Equal(t, http.StatusBadRequest, status(err))
+
+	// users matching no rule are rejected
+	idp.SetClaims(map[string]any{"sub": "2", "preferred_username": "bob", "groups": []string{"users"}})
+	cookie, callback = login()
+	_, err = serve(h.CallbackHandler, callback, cookie)
+	require.Equal(t, http.StatusForbidden, status(err))
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/firefart/go-webserver-template/internal/auth"
+	"github.com/firefart/go-webserver-template/internal/database"
+	"github.com/firefart/go-webserver-template/internal/server/helper"
+	"github.com/firefart/go-webserver-template/internal/server/httperror"
+	"github.com/firefart/go-webserver-template/internal/server/templates"
+
+	"github.com/a-h/templ"
+)
+
+// TokenManager creates, lists and revokes the API tokens
+type TokenManager interface {
+	Create(ctx context.Context, name string, scopes []string, ttl time.Duration) (string, database.APIToken, error)
+	List(ctx context.Context) ([]database.APIToken, error)
+	Revoke(ctx context.Context, id int64) error
+}
+
+// TokenHandler serves the API token management, it must be protected by the
+// authentication middlewares
+type TokenHandler struct {
+	tokens     TokenManager
+	defaultTTL time.Duration
+	debug      bool
+}
+
+func NewTokenHandler(tokens TokenManager, defaultTTL time.Duration, debug bool) *TokenHandler {
+	return &TokenHandler{
+		tokens:     tokens,
+		defaultTTL: defaultTTL,
+		debug:      debug,
+	}
+}
+
+func (h *TokenHandler) ListHandler(w http.ResponseWriter, r *http.Request) error {
+	tokens, err := h.tokens.List(r.Context())
+	if err != nil {
+		return err
+	}
+	return h.render(w, r, templates.TokenList(tokens, auth.Scopes), "API tokens")
+}
+
+func (h *TokenHandler) CreateHandler(w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return httperror.BadRequest("invalid form")
+	}
+	ttl := h.defaultTTL
+	if value := r.PostForm.Get("ttl"); value != "" {
+		var err error
+		ttl, err = time.ParseDuration(value)
+		if err != nil || ttl < 0 {
+			return httperror.BadRequest("invalid ttl")
+		}
+	}
+	name := r.PostForm.Get("name")
+	if name == "" {
+		return httperror.BadRequest("name is required")
+	}
+
+	token, info, err := h.tokens.Create(r.Context(), name, r.PostForm["scope"], ttl)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidScope) {
+			return httperror.BadRequest("invalid scope")
+		}
+		return err
+	}
+	// the token is only shown once and must not end up in any cache
+	w.Header().Set("Cache-Control", "no-store")
+	return h.render(w, r, templates.TokenCreated(token, info), "API token created")
+}
+
+func (h *TokenHandler) RevokeHandler(w http.ResponseWriter, r *http.Request) error {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		return httperror.BadRequest("invalid id")
+	}
+	if err := h.tokens.Revoke(r.Context(), id); err != nil {
+		if errors.Is(err, auth.ErrTokenNotFound) {
+			return httperror.NotFound("token not found")
+		}
+		return err
+	}
+	if helper.IsHTMX(r) {
+		// the empty response replaces the table row
+		w.WriteHeader(http.StatusOK)
+		return nil
+	}
+	http.Redirect(w, r, "/admin/tokens", http.StatusSeeOther)
+	return nil
+}
+
+func (h *TokenHandler) render(w http.ResponseWriter, r *http.Request, component templ.Component, title string) error {
+	w.WriteHeader(http.StatusOK)
+
+	if helper.IsHTMX(r) {
+		// only render the single component if it's a htmx request
+		return component.Render(r.Context(), w)
+	}
+	return templates.Layout(component, title, h.debug).Render(r.Context(), w)
+}
@@ -0,0 +1,102 @@
+package handlers_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/firefart/go-webserver-template/internal/auth"
+	"github.com/firefart/go-webserver-template/internal/database"
+	"github.com/firefart/go-webserver-template/internal/server/handlers"
+	"github.com/firefart/go-webserver-template/internal/server/httperror"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeTokens struct {
+	tokens []database.APIToken
+	ttl    time.Duration
+}
+
+func (f *fakeTokens) Create(_ context.Context, name string, scopes []string, ttl time.Duration) (string, database.APIToken, error) {
+	for _, scope := range scopes {
+		if scope != auth.ScopeDummyRead {
+			return "", database.APIToken{}, auth.ErrInvalidScope
+		}
+	}
+	f.ttl = ttl
+	info := database.APIToken{ID: int64(len(f.tokens) + 1), Name: name, Prefix: "gwt_ABCDEFGH", Scopes: scopes, Created: time.Now()}
+	f.tokens = append(f.tokens, info)
+	return "gwt_ABCDEFGHSECRET", info, nil
+}
+
+func (f *fakeTokens) List(_ context.Context) ([]database.APIToken, error) {
+	return f.tokens, nil
+}
+
+func (f *fakeTokens) Revoke(_ context.Context, id int64) error {
+	for i, t := range f.tokens {
+		if t.ID == id {
+			f.tokens = append(f.tokens[:i], f.tokens[i+1:]...)
+			return nil
+		}
+	}
+	return auth.ErrTokenNotFound
+}
+
+func TestTokens(t *testing.T) {
+	tokens := &fakeTokens{}
+	h := handlers.NewTokenHandler(tokens, 24*time.Hour, true)
+
+	create := func(form url.Values) (*httptest.ResponseRecorder, error) {
+		req := httptest.NewRequest(http.MethodPost, "/admin/tokens", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Hx-Request", "true")
+		rec := httptest.NewRecorder()
+		return rec, h.CreateHandler(rec, req)
+	}
+
+	rec, err := create(url.Values{"name": {"ci"}, "scope": {auth.ScopeDummyRead}})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+	require.Contains(t, rec.Body.String(), "gwt_ABCDEFGHSECRET")
+	require.Equal(t, 24*time.Hour, tokens.ttl)
+
+	_, err = create(url.Values{"name": {"ci"}, "ttl": {"0"}})
+	require.NoError(t, err)
+	require.Zero(t, tokens.ttl)
+
+	var httpErr *httperror.HTTPError
+	for _, form := range []url.Values{
+		{"name": {""}},
+		{"name": {"ci"}, "ttl": {"forever"}},
+		{"name": {"ci"}, "ttl": {"-1h"}},
+		{"name": {"ci"}, "scope": {"root"}},
+	} {
+		_, err = create(form)
+		require.ErrorAs(t, err, &httpErr)
+		require.Equal(t, http.StatusBadRequest, httpErr.StatusCode)
+	}
+
+	// the list only shows the prefix
+	rec = httptest.NewRecorder()
+	require.NoError(t, h.ListHandler(rec, httptest.NewRequest(http.MethodGet, "/admin/tokens", nil)))
+	require.Contains(t, rec.Body.String(), "gwt_ABCDEFGH")
+	require.NotContains(t, rec.Body.String(), "SECRET")
+	require.Contains(t, rec.Body.String(), "/admin/tokens/1/revoke")
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/tokens/1/revoke", nil)
+	req.SetPathValue("id", "1")
+	rec = httptest.NewRecorder()
+	require.NoError(t, h.RevokeHandler(rec, req))
+	require.Equal(t, http.StatusSeeOther, rec.Code)
+	require.Len(t, tokens.tokens, 1)
+
+	err = h.RevokeHandler(httptest.NewRecorder(), req)
+	require.ErrorAs(t, err, &httpErr)
+	require.Equal(t, http.StatusNotFound, httpErr.StatusCode)
+}
@@ -0,0 +1,200 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/firefart/go-webserver-template/internal/auth"
+	"github.com/firefart/go-webserver-template/internal/session"
+)
+
+type AuthenticateConfig struct {
+	// Principal loads the principal of the user id stored in the session.
+	// If nil only principals stored in the session are used.
+	Principal func(ctx context.Context, id int64) (auth.Principal, bool, error)
+
+	Logger *slog.Logger
+}
+
+// Authenticate adds the principal of the logged in user to the request
+// context. It needs the session middleware and lets anonymous requests pass,
+// use RequireAuth or RequireRole to protect routes.
+func Authenticate(config AuthenticateConfig) func(next http.Handler) http.Handler {
+	if config.Logger == nil {
+		config.Logger = slog.New(slog.DiscardHandler)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if p, ok := session.Get[auth.Principal](r.Context(), auth.SessionKeyPrincipal); ok {
+				next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), p)))
+				return
+			}
+			id, ok := session.Get[int64](r.Context(), auth.SessionKeyUserID)
+			if !ok || config.Principal == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			p, found, err := config.Principal(r.Context(), id)
+			if err != nil {
+				config.Logger.Error("could not load user", slog.Int64("id", id), slog.String("err", err.Error()))
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			if !found {
+				// the user was deleted
+				session.Delete(r.Context(), auth.SessionKeyUserID)
+				next.ServeHTTP(w, r)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), p)))
+		})
+	}
+}
+
+type RequireAuthConfig struct {
+	// LoginURL is the page anonymous users are redirected to. The requested
+	// url is added as the next parameter. If empty a 401 is returned.
+	LoginURL string
+}
+
+// RequireAuth only lets requests of logged in users pass. Browsers are
+// redirected to the login page, htmx requests get a HX-Redirect header.
+func RequireAuth(config RequireAuthConfig) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := auth.PrincipalFromContext(r.Context()); ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if config.LoginURL == "" {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			loginURL := config.LoginURL + "?next=" + url.QueryEscape(r.URL.RequestURI())
+			switch {
+			case r.Header.Get("Hx-Request") == "true":
+				w.Header().Set("HX-Redirect", loginURL)
+				w.WriteHeader(http.StatusUnauthorized)
+			case r.Method == http.MethodGet || r.Method == http.MethodHead:
+				http.Redirect(w, r, loginURL, http.StatusSeeOther)
+			default:
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			}
+		})
+	}
+}
+
+type RequireRoleConfig struct {
+	// Roles lists the roles allowed to access the routes
+	Roles []string
+}
+
+// RequireRole only lets requests of users with one of the roles pass. Use it
+// after RequireAuth, anonymous requests get a 401.
+func RequireRole(config RequireRoleConfig) func(next http.Handler) http.Handler {
+	if len(config.Roles) == 0 {
+		panic("require role middleware requires at least one role")
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, ok := auth.PrincipalFromContext(r.Context())
+			if !ok {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			if !p.HasRole(config.Roles...) {
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+type BearerTokenConfig struct {
+	// Authenticate resolves the token to its principal
+	Authenticate func(ctx context.Context, token string) (auth.Principal, error)
+
+	Logger *slog.Logger
+}
+
+// BearerToken adds the principal of the API token in the Authorization header
+// to the request context. Requests without the header pass as anonymous,
+// invalid tokens are rejected.
+func BearerToken(config BearerTokenConfig) func(next http.Handler) http.Handler {
+	if config.Authenticate == nil {
+		panic("bearer token middleware requires an authenticate function")
+	}
+	if config.Logger == nil {
+		config.Logger = slog.New(slog.DiscardHandler)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header := r.Header.Get("Authorization")
+			if header == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			scheme, token, ok := strings.Cut(header, " ")
+			if !ok || !strings.EqualFold(scheme, "Bearer") {
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_request"`)
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			p, err := config.Authenticate(r.Context(), strings.TrimSpace(token))
+			if err != nil {
+				if !errors.Is(err, auth.ErrInvalidToken) {
+					config.Logger.Error("could not check api token", slog.String("err", err.Error()))
+					http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+					return
+				}
+				ip, ok := r.Context().Value(ContextKeyIP).(string)
+				if !ok {
+					ip = r.RemoteAddr
+				}
+				config.Logger.Warn("invalid api token", slog.String("ip", ip))
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), p)))
+		})
+	}
+}
+
+type RequireScopeConfig struct {
+	// Scopes lists the scopes the API token needs for the routes
+	Scopes []string
+}
+
+// RequireScope only lets requests with an API token holding all the scopes
+// pass. Anonymous requests get a 401.
+func RequireScope(config RequireScopeConfig) func(next http.Handler) http.Handler {
+	if len(config.Scopes) == 0 {
+		panic("require scope middleware requires at least one scope")
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, ok := auth.PrincipalFromContext(r.Context())
+			if !ok {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			if !p.HasScopes(config.Scopes...) {
+				w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+strings.Join(config.Scopes, " ")+`"`)
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
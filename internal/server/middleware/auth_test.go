@@ -0,0 +1,240 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/firefart/go-webserver-template/internal/auth"
+	"github.com/firefart/go-webserver-template/internal/config"
+	"github.com/firefart/go-webserver-template/internal/session"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSessions(t *testing.T) *session.Manager {
+	t.Helper()
+	m, err := session.NewManager(slog.New(slog.DiscardHandler), session.NewMemoryStore(t.Context(), slog.New(slog.DiscardHandler)), config.Session{
+		CookieName:      "session",
+		SigningKeys:     []string{"0123456789abcdef0123456789abcdef"},
+		IdleTimeout:     30 * time.Minute,
+		AbsoluteTimeout: 2 * time.Hour,
+	})
+	require.NoError(t, err)
+	return m
+}
+
+func TestAuthenticate(t *testing.T) {
+	t.Parallel()
+
+	sessions := newTestSessions(t)
+	users := map[int64]auth.Principal{
+		1: {UserID: 1, Username: "admin", Role: auth.RoleAdmin},
+	}
+	authenticate := Authenticate(AuthenticateConfig{
+		Principal: func(_ context.Context, id int64) (auth.Principal, bool, error) {
+			if id == 3 {
+				return auth.Principal{}, false, errors.New("database error")
+			}
+			p, ok := users[id]
+			return p, ok, nil
+		},
+	})
+
+	// log in with the user id and return the session cookie
+	login := func(id int64) *http.Cookie {
+		rec := httptest.NewRecorder()
+		sessions.Handler(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+			require.NoError(t, session.Set(r.Context(), auth.SessionKeyUserID, id))
+		})).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		return rec.Result().Cookies()[0]
+	}
+	request := func(cookie *http.Cookie) (*httptest.ResponseRecorder, auth.Principal, bool) {
+		var p auth.Principal
+		var ok bool
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		sessions.Handler(authenticate(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+			p, ok = auth.PrincipalFromContext(r.Context())
+		}))).ServeHTTP(rec, req)
+		return rec, p, ok
+	}
+
+	_, _, ok := request(nil)
+	require.False(t, ok)
+
+	_, p, ok := request(login(1))
+	require.True(t, ok)
+	require.Equal(t, users[1], p)
+
+	// deleted users are anonymous
+	_, _, ok = request(login(2))
+	require.False(t, ok)
+
+	rec, _, _ := request(login(3))
+	require.Equal(t, http.StatusInternalServerError, rec.Code)
+
+	// principals of external logins are stored in the session
+	external := auth.Principal{Username: "sso", Role: auth.RoleUser}
+	rec = httptest.NewRecorder()
+	sessions.Handler(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		require.NoError(t, session.Set(r.Context(), auth.SessionKeyPrincipal, external))
+	})).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	_, p, ok = request(rec.Result().Cookies()[0])
+	require.True(t, ok)
+	require.Equal(t, external, p)
+}
+
+func TestRequireAuth(t *testing.T) {
+	t.Parallel()
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mw := RequireAuth(RequireAuthConfig{LoginURL: "/login"})
+
+	tests := []struct {
+		name     string
+		method   string
+		htmx     bool
+		loggedIn bool
+		config   RequireAuthConfig
+		code     int
+		location string
+		redirect string
+	}{
+		{name: "logged in", method: http.MethodGet, loggedIn: true, code: http.StatusOK},
+		{name: "redirect", method: http.MethodGet, code: http.StatusSeeOther, location: "/login?next=%2Fadmin%3Fpage%3D2"},
+		{name: "htmx", method: http.MethodGet, htmx: true, code: http.StatusUnauthorized, redirect: "/login?next=%2Fadmin%3Fpage%3D2"},
+		{name: "post", method: http.MethodPost, code: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			req := httptest.NewRequest(tt.method, "/admin?page=2", nil)
+			if tt.htmx {
+				req.Header.Set("Hx-Request", "true")
+			}
+			if tt.loggedIn {
+				req = req.WithContext(auth.WithPrincipal(req.Context(), auth.Principal{UserID: 1}))
+			}
+			rec := httptest.NewRecorder()
+			mw(next).ServeHTTP(rec, req)
+			require.Equal(t, tt.code, rec.Code)
+			require.Equal(t, tt.location, rec.Header().Get("Location"))
+			require.Equal(t, tt.redirect, rec.Header().Get("HX-Redirect"))
+		})
+	}
+
+	// without login url
+	rec := httptest.NewRecorder()
+	RequireAuth(RequireAuthConfig{})(next).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestRequireRole(t *testing.T) {
+	t.Parallel()
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mw := RequireRole(RequireRoleConfig{Roles: []string{auth.RoleAdmin}})
+
+	request := func(p *auth.Principal) int {
+		req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+		if p != nil {
+			req = req.WithContext(auth.WithPrincipal(req.Context(), *p))
+		}
+		rec := httptest.NewRecorder()
+		mw(next).ServeHTTP(rec, req)
+		return rec.Code
+	}
+	require.Equal(t, http.StatusOK, request(&auth.Principal{UserID: 1, Role: auth.RoleAdmin}))
+	require.Equal(t, http.StatusForbidden, request(&auth.Principal{UserID: 2, Role: auth.RoleUser}))
+	require.Equal(t, http.StatusUnauthorized, request(nil))
+
+	require.Panics(t, func() {
+		RequireRole(RequireRoleConfig{})
+	})
+}
+
+func TestBearerToken(t *testing.T) {
+	t.Parallel()
+
+	mw := BearerToken(BearerTokenConfig{
+		Authenticate: func(_ context.Context, token string) (auth.Principal, error) {
+			switch token {
+			case "gwt_valid":
+				return auth.Principal{TokenID: 1, Username: "ci", Scopes: []string{auth.ScopeDummyRead}}, nil
+			case "gwt_error":
+				return auth.Principal{}, errors.New("database error")
+			default:
+				return auth.Principal{}, auth.ErrInvalidToken
+			}
+		},
+	})
+
+	tests := []struct {
+		name          string
+		header        string
+		code          string
+		status        int
+		authenticated bool
+	}{
+		{name: "anonymous", status: http.StatusOK},
+		{name: "valid", header: "Bearer gwt_valid", status: http.StatusOK, authenticated: true},
+		{name: "case insensitive scheme", header: "bearer gwt_valid", status: http.StatusOK, authenticated: true},
+		{name: "invalid", header: "Bearer gwt_invalid", status: http.StatusUnauthorized, code: `Bearer error="invalid_token"`},
+		{name: "basic auth", header: "Basic dXNlcjpwYXNz", status: http.StatusUnauthorized, code: `Bearer error="invalid_request"`},
+		{name: "error", header: "Bearer gwt_error", status: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var authenticated bool
+			req := httptest.NewRequest(http.MethodGet, "/api/dummy", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			mw(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				_, authenticated = auth.PrincipalFromContext(r.Context())
+			})).ServeHTTP(rec, req)
+			require.Equal(t, tt.status, rec.Code)
+			require.Equal(t, tt.authenticated, authenticated)
+			require.Equal(t, tt.code, rec.Header().Get("WWW-Authenticate"))
+		})
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	t.Parallel()
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mw := RequireScope(RequireScopeConfig{Scopes: []string{auth.ScopeDummyWrite}})
+
+	request := func(p *auth.Principal) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/dummy", nil)
+		if p != nil {
+			req = req.WithContext(auth.WithPrincipal(req.Context(), *p))
+		}
+		rec := httptest.NewRecorder()
+		mw(next).ServeHTTP(rec, req)
+		return rec
+	}
+	require.Equal(t, http.StatusOK, request(&auth.Principal{TokenID: 1, Scopes: []string{auth.ScopeDummyRead, auth.ScopeDummyWrite}}).Code)
+	rec := request(&auth.Principal{TokenID: 1, Scopes: []string{auth.ScopeDummyRead}})
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	require.Equal(t, `Bearer error="insufficient_scope", scope="dummy:write"`, rec.Header().Get("WWW-Authenticate"))
+	// users logged in with a session have no scopes
+	require.Equal(t, http.StatusForbidden, request(&auth.Principal{UserID: 1, Role: auth.RoleAdmin}).Code)
+	require.Equal(t, http.StatusUnauthorized, request(nil).Code)
+}
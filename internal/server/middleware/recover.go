@@ -4,20 +4,66 @@
 	"log/slog"
 	"net/http"
 	"runtime"
+	"strings"
 )
 
+// RecoverConfig holds configuration for the recover middleware
+type RecoverConfig struct {
+	Logger *slog.Logger
+	// OnPanic is called with the recovered value and the stack after the panic
+	// is logged, it is optional
+	OnPanic func(r *http.Request, err any, stack []byte)
+}
+
 func collectStack() []byte {
 	buf := make([]byte, 64<<10) // limit to 64kb
 	buf = buf[:runtime.Stack(buf, false)]
 	return buf
 }
 
-func Recover(logger *slog.Logger) func(next http.Handler) http.Handler {
+// SummarizeStack returns the first frames of a stack collected after a panic
+// as "function (file:line)" lines. The frames of the panic handling itself
+// are skipped.
+func SummarizeStack(stack []byte, frames int) string {
+	lines := strings.Split(strings.TrimSpace(string(stack)), "\n")
+	// the first line is the goroutine header
+	if len(lines) > 0 && strings.HasPrefix(lines[0], "goroutine ") {
+		lines = lines[1:]
+	}
+	// each frame consists of the function and the indented file location
+	for i := 0; i+1 < len(lines); i += 2 {
+		if strings.HasPrefix(lines[i], "panic(") {
+			lines = lines[i+2:]
+			break
+		}
+	}
+
+	var summary []string
+	for i := 0; i+1 < len(lines) && len(summary) < frames; i += 2 {
+		location := strings.TrimSpace(lines[i+1])
+		// strip the pc offset
+		if idx := strings.LastIndex(location, " +0x"); idx > 0 {
+			location = location[:idx]
+		}
+		summary = append(summary, lines[i]+" ("+location+")")
+	}
+	return strings.Join(summary, "\n")
+}
+
+func Recover(config RecoverConfig) func(next http.Handler) http.Handler {
+	if config.Logger == nil {
+		panic("recover middleware requires a logger")
+	}
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			defer func() {
 				if err := recover(); err != nil {
-					logger.Error("panic recovered", slog.String("method", r.Method), slog.String("url", r.URL.String()), slog.Any("error", err), slog.String("stack", string(collectStack())))
+					stack := collectStack()
+					config.Logger.Error("panic recovered", slog.String("method", r.Method), slog.String("url", r.URL.String()), slog.Any("error", err), slog.String("stack", string(stack)))
+					if config.OnPanic != nil {
+						config.OnPanic(r, err, stack)
+					}
 					http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 				}
 			}()
@@ -15,7 +15,7 @@ func TestRecover(t *testing.T) {
 	t.Run("normal operation without panic", func(t *testing.T) {
 		var logOutput bytes.Buffer
 		logger := slog.New(slog.NewJSONHandler(&logOutput, nil))
-		middleware := Recover(logger)
+		middleware := Recover(RecoverConfig{Logger: logger})
 		nextHandler := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
 			w.WriteHeader(http.StatusOK)
 			w.Write([]byte("success"))
@@ -32,7 +32,7 @@ func TestRecover(t *testing.T) {
 	t.Run("recovers from string panic", func(t *testing.T) {
 		var logOutput bytes.Buffer
 		logger := slog.New(slog.NewJSONHandler(&logOutput, nil))
-		middleware := Recover(logger)
+		middleware := Recover(RecoverConfig{Logger: logger})
 		nextHandler := http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
 			panic("test panic")
 		})
@@ -57,7 +57,7 @@ func TestRecover(t *testing.T) {
 	t.Run("recovers from error panic", func(t *testing.T) {
 		var logOutput bytes.Buffer
 		logger := slog.New(slog.NewJSONHandler(&logOutput, nil))
-		middleware := Recover(logger)
+		middleware := Recover(RecoverConfig{Logger: logger})
 		nextHandler := http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
 			panic(http.ErrUseLastResponse)
 		})
@@ -73,7 +73,7 @@ func TestRecover(t *testing.T) {
 	t.Run("middleware chain continues after recovery", func(t *testing.T) {
 		var logOutput bytes.Buffer
 		logger := slog.New(slog.NewJSONHandler(&logOutput, nil))
-		middleware := Recover(logger)
+		middleware := Recover(RecoverConfig{Logger: logger})
 		// Simulate a middleware chain where one handler panics
 		panicHandler := http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
 			panic("middleware panic")
@@ -88,4 +88,57 @@ func TestRecover(t *testing.T) {
 		require.Equal(t, http.StatusInternalServerError, w.Code)
 		require.NotEmpty(t, logOutput.String())
 	})
+
+	t.Run("calls the panic hook", func(t *testing.T) {
+		logger := slog.New(slog.DiscardHandler)
+		var recovered any
+		var stack []byte
+		middleware := Recover(RecoverConfig{
+			Logger: logger,
+			OnPanic: func(_ *http.Request, err any, s []byte) {
+				recovered = err
+				stack = s
+			},
+		})
+		handler := middleware(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+			panic("hook panic")
+		}))
+		req := httptest.NewRequest(http.MethodGet, "/hook", nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		require.Equal(t, http.StatusInternalServerError, w.Code)
+		require.Equal(t, "hook panic", recovered)
+		require.Contains(t, string(stack), "goroutine")
+	})
+}
+
+func TestSummarizeStack(t *testing.T) {
+	stack := []byte(`goroutine 7 [running]:
+github.com/example/middleware.collectStack()
+	/src/middleware/recover.go:20 +0x45
+github.com/example/middleware.Recover.func1.1.1()
+	/src/middleware/recover.go:33 +0x8d
+panic({0x7d4a60?, 0xa1b2c0?})
+	/usr/lib/go/src/runtime/panic.go:787 +0x132
+github.com/example/server.(*server).handleIndex(0xc000180000, {0xa2f1e8, 0xc0001a2000}, 0xc0001b4000)
+	/src/server/handle_index.go:12 +0x25
+net/http.HandlerFunc.ServeHTTP(0xc000012345?, {0xa2f1e8?, 0xc0001a2000?}, 0x0?)
+	/usr/lib/go/src/net/http/server.go:2294 +0x29
+created by net/http.(*Server).Serve in goroutine 1
+	/usr/lib/go/src/net/http/server.go:3454 +0x485
+`)
+
+	require.Equal(t, `github.com/example/server.(*server).handleIndex(0xc000180000, {0xa2f1e8, 0xc0001a2000}, 0xc0001b4000) (/src/server/handle_index.go:12)
+net/http.HandlerFunc.ServeHTTP(0xc000012345?, {0xa2f1e8?, 0xc0001a2000?}, 0x0?) (/usr/lib/go/src/net/http/server.go:2294)`, SummarizeStack(stack, 2))
+
+	// a real stack keeps the caller of the panic
+	var summary string
+	func() {
+		defer func() {
+			_ = recover()
+			summary = SummarizeStack(collectStack(), 1)
+		}()
+		panic("test")
+	}()
+	require.Contains(t, summary, "TestSummarizeStack")
 }
@@ -0,0 +1,475 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/firefart/go-webserver-template/internal/cacher"
+	"github.com/firefart/go-webserver-template/internal/metrics"
+)
+
+const contextKeyCacheTags ContextKey = "cache_tags"
+
+// CachedResponse is a response stored by the ResponseCache middleware
+type CachedResponse struct {
+	StatusCode   int
+	Header       http.Header
+	Body         []byte
+	ETag         string
+	LastModified time.Time
+	// Tags maps the tags of the response to their generation at the time the
+	// response was rendered
+	Tags map[string]string
+}
+
+// ResponseCacheConfig holds configuration for the response cache middleware
+type ResponseCacheConfig struct {
+	Store   cacher.Store
+	Logger  *slog.Logger
+	Metrics *metrics.Metrics
+	// TTL is used if the handler does not set a max-age
+	TTL time.Duration
+	// MaxTTL caps the max-age set by handlers
+	MaxTTL time.Duration
+	// VaryHeaders are request headers that are part of the cache key.
+	// Responses varying on other headers are not cached.
+	VaryHeaders []string
+	// MaxBodySize is the maximum size of a response body that is cached
+	MaxBodySize int64
+}
+
+// ResponseCache caches rendered GET and HEAD responses. Handlers can attach
+// tags to a response using AddCacheTags and purge all responses of a tag
+// using Invalidate.
+//
+// Every tag has a generation in the store and a cached response is only
+// served while the generations of all its tags are unchanged. Invalidating a
+// tag is a single write of a new generation so it is safe with stores shared
+// by several instances.
+type ResponseCache struct {
+	config    ResponseCacheConfig
+	responses *cacher.Cache[CachedResponse]
+	tags      *cacher.Cache[string]
+}
+
+type cacheTags struct {
+	mu   sync.Mutex
+	tags []string
+}
+
+// AddCacheTags attaches tags to the response of the current request so it
+// can later be invalidated. It is a no-op if the response cache is not used
+// for the request.
+func AddCacheTags(r *http.Request, tags ...string) {
+	t, ok := r.Context().Value(contextKeyCacheTags).(*cacheTags)
+	if !ok {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, tag := range tags {
+		if !slices.Contains(t.tags, tag) {
+			t.tags = append(t.tags, tag)
+		}
+	}
+}
+
+func NewResponseCache(config ResponseCacheConfig) (*ResponseCache, error) {
+	if config.Store == nil {
+		return nil, errors.New("response cache requires a store")
+	}
+	if config.Metrics == nil {
+		return nil, errors.New("response cache requires metrics")
+	}
+	if config.Logger == nil {
+		config.Logger = slog.New(slog.DiscardHandler)
+	}
+	if config.TTL <= 0 {
+		config.TTL = 5 * time.Minute
+	}
+	if config.MaxTTL <= 0 {
+		config.MaxTTL = 24 * time.Hour
+	}
+	if config.MaxBodySize <= 0 {
+		config.MaxBodySize = 1 << 20 // 1MB
+	}
+
+	responses, err := cacher.New[CachedResponse](config.Logger, config.Metrics, config.Store, "responses", config.TTL)
+	if err != nil {
+		return nil, err
+	}
+	// the tag generations must live as long as the longest cached response
+	tags, err := cacher.New[string](config.Logger, config.Metrics, config.Store, "response_tags", config.MaxTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ResponseCache{
+		config:    config,
+		responses: responses,
+		tags:      tags,
+	}, nil
+}
+
+// Invalidate outdates all cached responses with one of the given tags
+func (rc *ResponseCache) Invalidate(ctx context.Context, tags ...string) error {
+	var errs error
+	for _, tag := range tags {
+		// a new generation outdates all responses rendered with the old one
+		errs = errors.Join(errs, rc.tags.Set(ctx, tag, rand.Text()))
+		rc.config.Logger.Debug("invalidated cached responses", slog.String("tag", tag))
+	}
+	return errs
+}
+
+// tagGenerations returns the current generation of the tags and creates the
+// missing ones
+func (rc *ResponseCache) tagGenerations(ctx context.Context, tags []string) (map[string]string, error) {
+	generations := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		generation, found, err := rc.tags.Get(ctx, tag)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			generation = rand.Text()
+			if err := rc.tags.Set(ctx, tag, generation); err != nil {
+				return nil, err
+			}
+		}
+		generations[tag] = generation
+	}
+	return generations, nil
+}
+
+// valid checks that the tags of the cached response were not invalidated
+// since it was rendered
+func (rc *ResponseCache) valid(ctx context.Context, resp CachedResponse) (bool, error) {
+	for tag, generation := range resp.Tags {
+		current, found, err := rc.tags.Get(ctx, tag)
+		if err != nil {
+			return false, err
+		}
+		// expired generations can't be compared anymore
+		if !found || current != generation {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// cacheKey builds the key from the method, host, path, normalized query and
+// the configured vary headers
+func (rc *ResponseCache) cacheKey(r *http.Request) string {
+	var b strings.Builder
+	b.WriteString(r.Method)
+	b.WriteString("\n")
+	b.WriteString(strings.ToLower(r.Host))
+	b.WriteString("\n")
+	b.WriteString(r.URL.Path)
+	b.WriteString("\n")
+	// Encode sorts by key so the order of parameters does not matter
+	b.WriteString(r.URL.Query().Encode())
+	for _, h := range rc.config.VaryHeaders {
+		b.WriteString("\n")
+		b.WriteString(http.CanonicalHeaderKey(h))
+		b.WriteString(":")
+		b.WriteString(strings.Join(r.Header.Values(h), ","))
+	}
+	sum := sha256.Sum256([]byte(b.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+// Handler is the middleware function
+func (rc *ResponseCache) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := rc.cacheKey(r)
+		requestDirectives := parseCacheControl(r.Header.Get("Cache-Control"))
+		if _, noCache := requestDirectives["no-cache"]; !noCache {
+			cached, found, err := rc.responses.Get(r.Context(), key)
+			if err != nil {
+				rc.config.Logger.Error("could not get cached response", slog.String("err", err.Error()))
+			}
+			if found {
+				valid, err := rc.valid(r.Context(), cached)
+				if err != nil {
+					rc.config.Logger.Error("could not check the cache tags", slog.String("err", err.Error()))
+				}
+				if valid {
+					rc.write(w, r, cached, "HIT")
+					return
+				}
+			}
+		}
+
+		tags := &cacheTags{}
+		ctx := context.WithValue(r.Context(), contextKeyCacheTags, tags)
+		rec := &responseRecorder{
+			w:          w,
+			header:     make(http.Header),
+			statusCode: http.StatusOK,
+			maxSize:    rc.config.MaxBodySize,
+		}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+		if rec.passthrough {
+			// the response was already sent
+			return
+		}
+
+		resp := CachedResponse{
+			StatusCode:   rec.statusCode,
+			Header:       rec.header,
+			Body:         rec.body.Bytes(),
+			ETag:         rec.header.Get("ETag"),
+			LastModified: time.Now().UTC().Truncate(time.Second),
+		}
+		if lm := rec.header.Get("Last-Modified"); lm != "" {
+			if t, err := http.ParseTime(lm); err == nil {
+				resp.LastModified = t
+			}
+		}
+
+		ttl, cacheable := rc.ttl(rec)
+		if !cacheable {
+			// pass through uncached responses as is
+			rc.writeUncached(w, rec)
+			return
+		}
+
+		if resp.ETag == "" {
+			sum := sha256.Sum256(resp.Body)
+			resp.ETag = fmt.Sprintf(`"%s"`, hex.EncodeToString(sum[:16]))
+		}
+		resp.Header.Set("ETag", resp.ETag)
+		resp.Header.Set("Last-Modified", resp.LastModified.Format(http.TimeFormat))
+
+		generations, err := rc.tagGenerations(r.Context(), tags.tags)
+		if err != nil {
+			// the response could not be invalidated so it is not cached
+			rc.config.Logger.Error("could not get the cache tags", slog.String("err", err.Error()))
+		} else {
+			resp.Tags = generations
+			if err := rc.responses.SetWithTTL(r.Context(), key, resp, ttl); err != nil {
+				rc.config.Logger.Error("could not cache response", slog.String("err", err.Error()))
+			}
+		}
+
+		rc.write(w, r, resp, "MISS")
+	})
+}
+
+// ttl determines if the recorded response can be cached and for how long
+func (rc *ResponseCache) ttl(rec *responseRecorder) (time.Duration, bool) {
+	if rec.statusCode != http.StatusOK || rec.passthrough {
+		return 0, false
+	}
+	// never cache responses setting cookies
+	if rec.header.Get("Set-Cookie") != "" {
+		return 0, false
+	}
+	if !rc.varyCovered(rec.header) {
+		return 0, false
+	}
+
+	directives := parseCacheControl(rec.header.Get("Cache-Control"))
+	for _, d := range []string{"no-store", "no-cache", "private"} {
+		if _, ok := directives[d]; ok {
+			return 0, false
+		}
+	}
+
+	ttl := rc.config.TTL
+	for _, d := range []string{"s-maxage", "max-age"} {
+		v, ok := directives[d]
+		if !ok {
+			continue
+		}
+		seconds, err := strconv.Atoi(v)
+		if err != nil || seconds <= 0 {
+			return 0, false
+		}
+		ttl = time.Duration(seconds) * time.Second
+		break
+	}
+	return min(ttl, rc.config.MaxTTL), true
+}
+
+// varyCovered checks that all headers the response varies on are part of the
+// cache key
+func (rc *ResponseCache) varyCovered(header http.Header) bool {
+	for _, v := range header.Values("Vary") {
+		for h := range strings.SplitSeq(v, ",") {
+			h = strings.TrimSpace(h)
+			if h == "" {
+				continue
+			}
+			if h == "*" {
+				return false
+			}
+			if !slices.ContainsFunc(rc.config.VaryHeaders, func(vary string) bool {
+				return strings.EqualFold(vary, h)
+			}) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (rc *ResponseCache) write(w http.ResponseWriter, r *http.Request, resp CachedResponse, cacheStatus string) {
+	for k, v := range resp.Header {
+		w.Header()[k] = slices.Clone(v)
+	}
+	w.Header().Set("X-Cache", cacheStatus)
+
+	if notModified(r, resp) {
+		// headers not allowed on a 304 response
+		for _, h := range []string{"Content-Type", "Content-Length"} {
+			w.Header().Del(h)
+		}
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Length", strconv.Itoa(len(resp.Body)))
+	w.WriteHeader(resp.StatusCode)
+	if r.Method != http.MethodHead {
+		if _, err := w.Write(resp.Body); err != nil {
+			rc.config.Logger.Error("could not write cached response", slog.String("err", err.Error()))
+		}
+	}
+}
+
+func (rc *ResponseCache) writeUncached(w http.ResponseWriter, rec *responseRecorder) {
+	for k, v := range rec.header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(rec.statusCode)
+	if _, err := w.Write(rec.body.Bytes()); err != nil {
+		rc.config.Logger.Error("could not write response", slog.String("err", err.Error()))
+	}
+}
+
+// notModified evaluates the conditional request headers. If-None-Match takes
+// precedence over If-Modified-Since as defined in RFC 9110.
+func notModified(r *http.Request, resp CachedResponse) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for candidate := range strings.SplitSeq(inm, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(resp.ETag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" {
+		t, err := http.ParseTime(ims)
+		if err != nil {
+			return false
+		}
+		return !resp.LastModified.Truncate(time.Second).After(t)
+	}
+	return false
+}
+
+func parseCacheControl(header string) map[string]string {
+	directives := make(map[string]string)
+	for part := range strings.SplitSeq(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(part, "=")
+		directives[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(value), `"`)
+	}
+	return directives
+}
+
+// responseRecorder buffers the response so it can be cached. Responses larger
+// than maxSize and flushed responses are passed through to the real writer as
+// they can't be cached anyway.
+type responseRecorder struct {
+	w           http.ResponseWriter
+	header      http.Header
+	body        bytes.Buffer
+	statusCode  int
+	wroteHeader bool
+	maxSize     int64
+	passthrough bool
+}
+
+func (rec *responseRecorder) Header() http.Header {
+	return rec.header
+}
+
+func (rec *responseRecorder) WriteHeader(statusCode int) {
+	if rec.wroteHeader {
+		return
+	}
+	rec.statusCode = statusCode
+	rec.wroteHeader = true
+}
+
+func (rec *responseRecorder) Write(data []byte) (int, error) {
+	if !rec.wroteHeader {
+		rec.WriteHeader(http.StatusOK)
+	}
+	if !rec.passthrough && int64(rec.body.Len()+len(data)) > rec.maxSize {
+		if err := rec.startPassthrough(); err != nil {
+			return 0, err
+		}
+	}
+	if rec.passthrough {
+		return rec.w.Write(data)
+	}
+	return rec.body.Write(data)
+}
+
+// Flush streams the response, flushed responses are not cached
+func (rec *responseRecorder) Flush() {
+	if !rec.wroteHeader {
+		rec.WriteHeader(http.StatusOK)
+	}
+	if !rec.passthrough {
+		if err := rec.startPassthrough(); err != nil {
+			return
+		}
+	}
+	_ = http.NewResponseController(rec.w).Flush()
+}
+
+// Unwrap returns the real writer for http.ResponseController
+func (rec *responseRecorder) Unwrap() http.ResponseWriter {
+	return rec.w
+}
+
+// startPassthrough sends the header and the buffered body to the real writer,
+// all further writes go to the real writer directly
+func (rec *responseRecorder) startPassthrough() error {
+	rec.passthrough = true
+	for k, v := range rec.header {
+		rec.w.Header()[k] = v
+	}
+	rec.w.WriteHeader(rec.statusCode)
+	_, err := rec.w.Write(rec.body.Bytes())
+	rec.body = bytes.Buffer{}
+	return err
+}
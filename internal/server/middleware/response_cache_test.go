@@ -0,0 +1,399 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/firefart/go-webserver-template/internal/cacher"
+	"github.com/firefart/go-webserver-template/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestResponseCache(t *testing.T, varyHeaders ...string) *ResponseCache {
+	t.Helper()
+	m, err := metrics.NewMetrics(prometheus.NewRegistry())
+	require.NoError(t, err)
+	rc, err := NewResponseCache(ResponseCacheConfig{
+		Store:       cacher.NewMemoryStore(t.Context(), slog.New(slog.DiscardHandler)),
+		Metrics:     m,
+		TTL:         1 * time.Minute,
+		VaryHeaders: varyHeaders,
+		MaxBodySize: 100,
+	})
+	require.NoError(t, err)
+	return rc
+}
+
+// racingStore returns the first two reads of the key only after both were
+// done like two replicas updating the same entry at the same time
+type racingStore struct {
+	cacher.Store
+	key     string
+	reads   atomic.Int64
+	barrier sync.WaitGroup
+}
+
+func (s *racingStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, found, err := s.Store.Get(ctx, key)
+	if key == s.key && s.reads.Add(1) <= 2 {
+		s.barrier.Done()
+		s.barrier.Wait()
+	}
+	return value, found, err
+}
+
+type deadlineRecorder struct {
+	*httptest.ResponseRecorder
+	deadlineSet bool
+}
+
+func (r *deadlineRecorder) SetWriteDeadline(time.Time) error {
+	r.deadlineSet = true
+	return nil
+}
+
+func TestResponseCache(t *testing.T) {
+	t.Run("caches get requests", func(t *testing.T) {
+		rc := newTestResponseCache(t)
+		calls := 0
+		handler := rc.Handler(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			calls++
+			w.Header().Set("Content-Type", "text/plain")
+			fmt.Fprintf(w, "call %d", calls)
+		}))
+
+		for i := range 3 {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/test?b=2&a=1", nil))
+			require.Equal(t, http.StatusOK, rec.Code)
+			require.Equal(t, "call 1", rec.Body.String())
+			require.Equal(t, "text/plain", rec.Header().Get("Content-Type"))
+			require.NotEmpty(t, rec.Header().Get("ETag"))
+			require.NotEmpty(t, rec.Header().Get("Last-Modified"))
+			if i == 0 {
+				require.Equal(t, "MISS", rec.Header().Get("X-Cache"))
+			} else {
+				require.Equal(t, "HIT", rec.Header().Get("X-Cache"))
+			}
+		}
+
+		// query order is normalized
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/test?a=1&b=2", nil))
+		require.Equal(t, "HIT", rec.Header().Get("X-Cache"))
+
+		// different query
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/test?a=2", nil))
+		require.Equal(t, "call 2", rec.Body.String())
+
+		// different host
+		req := httptest.NewRequest(http.MethodGet, "/test?a=1&b=2", nil)
+		req.Host = "other.example.com"
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		require.Equal(t, "call 3", rec.Body.String())
+	})
+
+	t.Run("does not cache other methods", func(t *testing.T) {
+		rc := newTestResponseCache(t)
+		calls := 0
+		handler := rc.Handler(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			calls++
+			w.WriteHeader(http.StatusOK)
+		}))
+		for range 2 {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+			require.Empty(t, rec.Header().Get("X-Cache"))
+		}
+		require.Equal(t, 2, calls)
+	})
+
+	t.Run("head requests have no body", func(t *testing.T) {
+		rc := newTestResponseCache(t)
+		handler := rc.Handler(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			fmt.Fprint(w, "content")
+		}))
+		for range 2 {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodHead, "/", nil))
+			require.Equal(t, http.StatusOK, rec.Code)
+			require.Empty(t, rec.Body.String())
+			require.Equal(t, "7", rec.Header().Get("Content-Length"))
+		}
+	})
+
+	t.Run("respects vary headers", func(t *testing.T) {
+		rc := newTestResponseCache(t, "Accept-Language")
+		handler := rc.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, r.Header.Get("Accept-Language"))
+		}))
+		for _, lang := range []string{"de", "en", "de"} {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept-Language", lang)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			require.Equal(t, lang, rec.Body.String())
+		}
+	})
+
+	t.Run("does not cache responses varying on other headers", func(t *testing.T) {
+		tests := []struct {
+			vary   string
+			cached bool
+		}{
+			{vary: "hx-request", cached: true},
+			{vary: "Hx-Request, Accept-Language", cached: false},
+			{vary: "*", cached: false},
+		}
+		for _, tt := range tests {
+			rc := newTestResponseCache(t, "Hx-Request")
+			calls := 0
+			handler := rc.Handler(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				calls++
+				w.Header().Set("Vary", tt.vary)
+				fmt.Fprint(w, "content")
+			}))
+			for range 2 {
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+				require.Equal(t, tt.vary, rec.Header().Get("Vary"))
+			}
+			if tt.cached {
+				require.Equal(t, 1, calls, tt.vary)
+			} else {
+				require.Equal(t, 2, calls, tt.vary)
+			}
+		}
+	})
+
+	t.Run("respects cache control", func(t *testing.T) {
+		tests := []struct {
+			name         string
+			cacheControl string
+			statusCode   int
+			cached       bool
+		}{
+			{name: "no-store", cacheControl: "no-store", statusCode: http.StatusOK, cached: false},
+			{name: "private", cacheControl: "private, max-age=60", statusCode: http.StatusOK, cached: false},
+			{name: "no-cache", cacheControl: "no-cache", statusCode: http.StatusOK, cached: false},
+			{name: "max-age", cacheControl: "public, max-age=60", statusCode: http.StatusOK, cached: true},
+			{name: "error status", cacheControl: "", statusCode: http.StatusInternalServerError, cached: false},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				rc := newTestResponseCache(t)
+				calls := 0
+				handler := rc.Handler(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+					calls++
+					if tt.cacheControl != "" {
+						w.Header().Set("Cache-Control", tt.cacheControl)
+					}
+					w.WriteHeader(tt.statusCode)
+				}))
+				for range 2 {
+					rec := httptest.NewRecorder()
+					handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+					require.Equal(t, tt.statusCode, rec.Code)
+				}
+				if tt.cached {
+					require.Equal(t, 1, calls)
+				} else {
+					require.Equal(t, 2, calls)
+				}
+			})
+		}
+	})
+
+	t.Run("max-age sets ttl", func(t *testing.T) {
+		rc := newTestResponseCache(t)
+		rec := &responseRecorder{header: make(http.Header), statusCode: http.StatusOK}
+		rec.header.Set("Cache-Control", "max-age=10")
+		ttl, ok := rc.ttl(rec)
+		require.True(t, ok)
+		require.Equal(t, 10*time.Second, ttl)
+
+		rec.header.Set("Cache-Control", "max-age=999999999")
+		ttl, ok = rc.ttl(rec)
+		require.True(t, ok)
+		require.Equal(t, rc.config.MaxTTL, ttl)
+	})
+
+	t.Run("does not cache large responses", func(t *testing.T) {
+		rc := newTestResponseCache(t)
+		calls := 0
+		handler := rc.Handler(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			calls++
+			fmt.Fprint(w, string(make([]byte, 101)))
+		}))
+		for range 2 {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+			require.Len(t, rec.Body.Bytes(), 101)
+		}
+		require.Equal(t, 2, calls)
+	})
+
+	t.Run("streams responses over the size limit", func(t *testing.T) {
+		rc := newTestResponseCache(t)
+		chunk := bytes.Repeat([]byte("0123456789"), 6)
+		rec := httptest.NewRecorder()
+		handler := rc.Handler(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "text/plain")
+			for i := range 100 {
+				_, err := w.Write(chunk)
+				require.NoError(t, err)
+				// only the first chunk fits in the buffer, the rest is written
+				// to the client right away
+				if i > 0 {
+					require.Len(t, rec.Body.Bytes(), (i+1)*len(chunk))
+				}
+			}
+		}))
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Equal(t, "text/plain", rec.Header().Get("Content-Type"))
+		require.Equal(t, bytes.Repeat(chunk, 100), rec.Body.Bytes())
+		require.Empty(t, rec.Header().Get("X-Cache"))
+	})
+
+	t.Run("streams flushed responses", func(t *testing.T) {
+		rc := newTestResponseCache(t)
+		calls := 0
+		handler := rc.Handler(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			calls++
+			fmt.Fprint(w, "event")
+			require.NoError(t, http.NewResponseController(w).Flush())
+		}))
+		for range 2 {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+			require.True(t, rec.Flushed)
+			require.Equal(t, "event", rec.Body.String())
+		}
+		require.Equal(t, 2, calls)
+	})
+
+	t.Run("unwraps the writer", func(t *testing.T) {
+		rc := newTestResponseCache(t)
+		handler := rc.Handler(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			require.NoError(t, http.NewResponseController(w).SetWriteDeadline(time.Now().Add(1*time.Minute)))
+			fmt.Fprint(w, "content")
+		}))
+		rec := &deadlineRecorder{ResponseRecorder: httptest.NewRecorder()}
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		require.True(t, rec.deadlineSet)
+		require.Equal(t, "content", rec.Body.String())
+	})
+
+	t.Run("conditional requests", func(t *testing.T) {
+		rc := newTestResponseCache(t)
+		handler := rc.Handler(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			fmt.Fprint(w, "content")
+		}))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		etag := rec.Header().Get("ETag")
+		lastModified := rec.Header().Get("Last-Modified")
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("If-None-Match", fmt.Sprintf(`"other", W/%s`, etag))
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusNotModified, rec.Code)
+		require.Empty(t, rec.Body.String())
+
+		req = httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("If-None-Match", `"other"`)
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		req = httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("If-Modified-Since", lastModified)
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusNotModified, rec.Code)
+
+		req = httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("If-Modified-Since", time.Now().Add(-1*time.Hour).UTC().Format(http.TimeFormat))
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("invalidates by tag", func(t *testing.T) {
+		rc := newTestResponseCache(t)
+		calls := 0
+		handler := rc.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			AddCacheTags(r, r.URL.Query().Get("tag"))
+			fmt.Fprint(w, "content")
+		}))
+		get := func(path string) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		}
+
+		get("/a?tag=one")
+		get("/b?tag=two")
+		get("/a?tag=one")
+		get("/b?tag=two")
+		require.Equal(t, 2, calls)
+
+		require.NoError(t, rc.Invalidate(t.Context(), "one", "unknown"))
+		get("/a?tag=one")
+		get("/b?tag=two")
+		require.Equal(t, 3, calls)
+	})
+
+	t.Run("invalidates by tag on a shared store", func(t *testing.T) {
+		store := &racingStore{
+			Store: cacher.NewMemoryStore(t.Context(), slog.New(slog.DiscardHandler)),
+			key:   "response_tags:shared",
+		}
+		store.barrier.Add(2)
+		var replicas []*ResponseCache
+		for range 2 {
+			m, err := metrics.NewMetrics(prometheus.NewRegistry())
+			require.NoError(t, err)
+			rc, err := NewResponseCache(ResponseCacheConfig{Store: store, Metrics: m})
+			require.NoError(t, err)
+			replicas = append(replicas, rc)
+		}
+		get := func(rc *ResponseCache, path string) string {
+			rec := httptest.NewRecorder()
+			rc.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				AddCacheTags(r, "shared")
+				fmt.Fprint(w, "content")
+			})).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+			return rec.Header().Get("X-Cache")
+		}
+
+		// both replicas cache a page with the same tag at the same time
+		var wg sync.WaitGroup
+		for i, rc := range replicas {
+			wg.Go(func() { get(rc, fmt.Sprintf("/%d", i)) })
+		}
+		wg.Wait()
+
+		require.NoError(t, replicas[0].Invalidate(t.Context(), "shared"))
+		require.Equal(t, "MISS", get(replicas[0], "/0"))
+		require.Equal(t, "MISS", get(replicas[0], "/1"))
+	})
+
+	t.Run("requires store and metrics", func(t *testing.T) {
+		_, err := NewResponseCache(ResponseCacheConfig{})
+		require.Error(t, err)
+	})
+}
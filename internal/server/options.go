@@ -3,14 +3,16 @@
 import (
 	"log/slog"
 
+	"github.com/firefart/go-webserver-template/internal/auth"
 	"github.com/firefart/go-webserver-template/internal/cacher"
 	"github.com/firefart/go-webserver-template/internal/config"
 	"github.com/firefart/go-webserver-template/internal/database"
 	"github.com/firefart/go-webserver-template/internal/http"
 	"github.com/firefart/go-webserver-template/internal/mail"
 	"github.com/firefart/go-webserver-template/internal/metrics"
-
-	"github.com/nikoksr/notify"
+	"github.com/firefart/go-webserver-template/internal/notification"
+	"github.com/firefart/go-webserver-template/internal/oidc"
+	"github.com/firefart/go-webserver-template/internal/session"
 )
 
 type OptionsServerFunc func(c *server) error
@@ -23,7 +25,7 @@ func WithConfig(config config.Configuration) OptionsServerFunc {
 	return func(c *server) error { c.config = config; return nil }
 }
 
-func WithNotify(n *notify.Notify) OptionsServerFunc {
+func WithNotify(n notification.Notifier) OptionsServerFunc {
 	return func(c *server) error { c.notify = n; return nil }
 }
 
@@ -47,6 +49,10 @@ func WithCache(cache *cacher.Cache[string]) OptionsServerFunc {
 	return func(c *server) error { c.cache = cache; return nil }
 }
 
+func WithCacheStore(store cacher.Store) OptionsServerFunc {
+	return func(c *server) error { c.cacheStore = store; return nil }
+}
+
 func WithHTTPClient(client *http.Client) OptionsServerFunc {
 	return func(c *server) error { c.httpClient = client; return nil }
 }
@@ -54,3 +60,19 @@ func WithHTTPClient(client *http.Client) OptionsServerFunc {
 func WithMailer(mailer mail.Interface) OptionsServerFunc {
 	return func(c *server) error { c.mailer = mailer; return nil }
 }
+
+func WithSessions(sessions *session.Manager) OptionsServerFunc {
+	return func(c *server) error { c.sessions = sessions; return nil }
+}
+
+func WithAuthenticator(authenticator *auth.Authenticator) OptionsServerFunc {
+	return func(c *server) error { c.authenticator = authenticator; return nil }
+}
+
+func WithTokens(tokens *auth.Tokens) OptionsServerFunc {
+	return func(c *server) error { c.tokens = tokens; return nil }
+}
+
+func WithOIDC(provider *oidc.Provider) OptionsServerFunc {
+	return func(c *server) error { c.oidc = provider; return nil }
+}
@@ -10,31 +10,40 @@
 	"log/slog"
 	"net/http"
 	"os"
+	"strconv"
 
+	"github.com/firefart/go-webserver-template/internal/auth"
 	"github.com/firefart/go-webserver-template/internal/cacher"
 	"github.com/firefart/go-webserver-template/internal/config"
 	"github.com/firefart/go-webserver-template/internal/database"
 	inthttp "github.com/firefart/go-webserver-template/internal/http"
 	"github.com/firefart/go-webserver-template/internal/mail"
 	"github.com/firefart/go-webserver-template/internal/metrics"
+	"github.com/firefart/go-webserver-template/internal/notification"
+	"github.com/firefart/go-webserver-template/internal/oidc"
 	"github.com/firefart/go-webserver-template/internal/server/handlers"
 	"github.com/firefart/go-webserver-template/internal/server/httperror"
 	"github.com/firefart/go-webserver-template/internal/server/middleware"
 	"github.com/firefart/go-webserver-template/internal/server/router"
-	"github.com/nikoksr/notify"
+	"github.com/firefart/go-webserver-template/internal/session"
 )
 
 type server struct {
-	logger     *slog.Logger
-	config     config.Configuration
-	db         database.Interface
-	notify     *notify.Notify
-	metrics    *metrics.Metrics
-	cache      *cacher.Cache[string]
-	mailer     mail.Interface
-	httpClient *inthttp.Client
-	accessLog  bool
-	debug      bool
+	logger        *slog.Logger
+	config        config.Configuration
+	db            database.Interface
+	notify        notification.Notifier
+	metrics       *metrics.Metrics
+	cache         *cacher.Cache[string]
+	cacheStore    cacher.Store
+	mailer        mail.Interface
+	sessions      *session.Manager
+	authenticator *auth.Authenticator
+	tokens        *auth.Tokens
+	oidc          *oidc.Provider
+	httpClient    *inthttp.Client
+	accessLog     bool
+	debug         bool
 }
 
 //go:embed assets
@@ -67,6 +76,25 @@ func staticFile(w http.ResponseWriter, r *http.Request, filesystem fs.FS, path s
 	return nil
 }
 
+// notificationRequest collects the request details for a notification from
+// the request and the values set by the middlewares
+func notificationRequest(r *http.Request) *notification.Request {
+	req := notification.Request{
+		Method:    r.Method,
+		Host:      r.Host,
+		Path:      r.URL.Path,
+		ClientIP:  r.RemoteAddr,
+		UserAgent: r.UserAgent(),
+	}
+	if host, ok := r.Context().Value(middleware.ContextKeyHost).(string); ok {
+		req.Host = host
+	}
+	if ip, ok := r.Context().Value(middleware.ContextKeyIP).(string); ok {
+		req.ClientIP = ip
+	}
+	return &req
+}
+
 func NewServer(opts ...OptionsServerFunc) (http.Handler, error) {
 	s := server{
 		logger: slog.New(slog.DiscardHandler),
@@ -79,27 +107,40 @@ func NewServer(opts ...OptionsServerFunc) (http.Handler, error) {
 		}
 	}
 
+	// users can log in with a password, single sign-on or both
+	login := s.authenticator != nil || s.oidc != nil
+	if login && s.sessions == nil {
+		return nil, errors.New("authentication requires sessions")
+	}
+
 	r := router.New()
 
+	// the errors are not forwarded by the log notifications, server errors
+	// get their own notification with the request details below
+	errorLogger := s.logger.With(notification.SkipNotify)
 	r.SetErrorHandler(func(w http.ResponseWriter, r *http.Request, err error) {
 		s.metrics.Errors.WithLabelValues(r.Host).Inc()
-		s.logger.Error("error on request", slog.String("err", err.Error()))
+		errorLogger.Error("error on request", slog.String("err", err.Error()))
 		var httpErr *httperror.HTTPError
 		code := http.StatusInternalServerError
 		if errors.As(err, &httpErr) {
 			code = httpErr.StatusCode
 		}
 
-		// send an asynchronous notification (but ignore 404 and stuff)
+		// queue a notification (but ignore 404 and stuff), it is delivered
+		// asynchronously by the outbox and duplicates are throttled
 		if err != nil && code > 499 {
-			s.logger.Error("error on request", slog.String("err", err.Error()))
-
-			go func(e error) {
-				s.logger.Debug("sending error notification", slog.String("err", e.Error()))
-				if err2 := s.notify.Send(context.Background(), "ERROR", e.Error()); err2 != nil {
-					s.logger.Error("error on notification send", slog.String("err", err2.Error()))
-				}
-			}(err)
+			errorLogger.Debug("queueing error notification", slog.String("err", err.Error()))
+			if err2 := s.notify.Notify(context.WithoutCancel(r.Context()), notification.Notification{
+				Severity: notification.SeverityError,
+				Message:  err.Error(),
+				Source:   "server",
+				Tags:     []string{"status:" + strconv.Itoa(code)},
+				Route:    r.Pattern,
+				Request:  notificationRequest(r),
+			}); err2 != nil {
+				errorLogger.Error("error on notification send", slog.String("err", err2.Error()))
+			}
 		}
 
 		// send error page
@@ -121,7 +162,25 @@ func NewServer(opts ...OptionsServerFunc) (http.Handler, error) {
 		http.Error(w, string(content), code)
 	})
 
-	r.Use(middleware.Recover(s.logger))
+	recoverConfig := middleware.RecoverConfig{
+		Logger: s.logger,
+	}
+	if s.config.Notifications.Lifecycle.Panic && s.notify != nil {
+		// the panic is not forwarded by the log notifications a second time
+		recoverConfig.Logger = errorLogger
+		recoverConfig.OnPanic = func(r *http.Request, err any, stack []byte) {
+			if err2 := s.notify.Notify(context.WithoutCancel(r.Context()), notification.Notification{
+				Severity: notification.SeverityCritical,
+				Subject:  "panic recovered",
+				Message:  fmt.Sprintf("%v\n\n%s", err, middleware.SummarizeStack(stack, 10)),
+				Source:   "panic",
+				Request:  notificationRequest(r),
+			}); err2 != nil {
+				errorLogger.Error("error on notification send", slog.String("err", err2.Error()))
+			}
+		}
+	}
+	r.Use(middleware.Recover(recoverConfig))
 	r.Use(middleware.RealIP(middleware.RealIPConfig{
 		IPHeader: s.config.Server.IPHeader,
 	}))
@@ -134,6 +193,22 @@ func NewServer(opts ...OptionsServerFunc) (http.Handler, error) {
 			Metrics: s.metrics,
 		}))
 	}
+	if s.sessions != nil {
+		// the session cookie authenticates the requests so state changing
+		// requests from other origins are rejected, regardless of the
+		// same_site mode of the cookie
+		r.Use(http.NewCrossOriginProtection().Handler)
+		r.Use(s.sessions.Handler)
+	}
+	if login {
+		authenticateConfig := middleware.AuthenticateConfig{
+			Logger: s.logger,
+		}
+		if s.authenticator != nil {
+			authenticateConfig.Principal = s.authenticator.Principal
+		}
+		r.Use(middleware.Authenticate(authenticateConfig))
+	}
 
 	static, err := fs.Sub(fsAssets, "assets/web")
 	if err != nil {
@@ -155,7 +230,34 @@ func NewServer(opts ...OptionsServerFunc) (http.Handler, error) {
 	}
 	r.Handle("/css/", http.StripPrefix("/css/", http.FileServerFS(css)))
 
-	r.HandleFunc("GET /{$}", handlers.NewIndexHandler(s.debug).Handler)
+	// the invalidator must stay a nil interface if caching is disabled
+	var cacheInvalidator handlers.CacheInvalidator
+	var responseCache *middleware.ResponseCache
+	if s.config.Cache.Responses.Enabled && s.cacheStore != nil {
+		responseCache, err = middleware.NewResponseCache(middleware.ResponseCacheConfig{
+			Store:       s.cacheStore,
+			Logger:      s.logger,
+			Metrics:     s.metrics,
+			TTL:         s.config.Cache.Responses.TTL,
+			MaxTTL:      s.config.Cache.Responses.MaxTTL,
+			VaryHeaders: s.config.Cache.Responses.VaryHeaders,
+			MaxBodySize: s.config.Cache.Responses.MaxBodySize,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("could not create response cache: %w", err)
+		}
+		cacheInvalidator = responseCache
+	}
+
+	dummyHandler := handlers.NewDummyHandler(s.db, cacheInvalidator)
+
+	r.Group(func(r *router.Router) {
+		if responseCache != nil {
+			r.Use(responseCache.Handler)
+		}
+
+		r.HandleFunc("GET /{$}", handlers.NewIndexHandler(s.debug).Handler)
+	})
 
 	r.Group(func(r *router.Router) {
 		r.Use(middleware.SecretKeyHeader(middleware.SecretKeyHeaderConfig{
@@ -168,8 +270,87 @@ func NewServer(opts ...OptionsServerFunc) (http.Handler, error) {
 		// health check for monitoring
 		r.HandleFunc(fmt.Sprintf("GET %s", "/health"), handlers.NewHealthHandler().Handler)
 		r.HandleFunc(fmt.Sprintf("GET %s", "/version"), handlers.NewVersionHandler().Handler)
+
+		r.HandleFunc(fmt.Sprintf("POST %s", "/dummy"), dummyHandler.CreateHandler)
+		r.Group(func(r *router.Router) {
+			if responseCache != nil {
+				r.Use(responseCache.Handler)
+			}
+			r.HandleFunc(fmt.Sprintf("GET %s", "/dummy"), dummyHandler.ListHandler)
+		})
 	})
 
+	if login {
+		// the authenticator must stay a nil interface if only single sign-on
+		// is enabled
+		var authenticator handlers.Authenticator
+		if s.authenticator != nil {
+			authenticator = s.authenticator
+		}
+		loginHandler := handlers.NewLoginHandler(authenticator, s.oidc != nil, s.logger, s.debug)
+		r.HandleFunc("GET /login", loginHandler.LoginFormHandler)
+		if s.authenticator != nil {
+			r.HandleFunc("POST /login", loginHandler.LoginHandler)
+		}
+		if s.oidc != nil {
+			oidcHandler := handlers.NewOIDCHandler(s.oidc, s.logger)
+			r.HandleFunc("GET /auth/oidc/login", oidcHandler.LoginHandler)
+			r.HandleFunc("GET /auth/oidc/callback", oidcHandler.CallbackHandler)
+		}
+		r.HandleFunc("GET /logout", loginHandler.LogoutFormHandler)
+		r.HandleFunc("POST /logout", loginHandler.LogoutHandler)
+
+		r.Group(func(r *router.Router) {
+			r.Use(middleware.RequireAuth(middleware.RequireAuthConfig{
+				LoginURL: "/login",
+			}))
+			r.Use(middleware.RequireRole(middleware.RequireRoleConfig{
+				Roles: []string{auth.RoleAdmin},
+			}))
+			r.HandleFunc("GET /admin", handlers.NewAdminHandler(s.tokens != nil, s.debug).Handler)
+
+			if s.tokens != nil {
+				tokenHandler := handlers.NewTokenHandler(s.tokens, s.config.Auth.Tokens.DefaultTTL, s.debug)
+				r.HandleFunc("GET /admin/tokens", tokenHandler.ListHandler)
+				r.HandleFunc("POST /admin/tokens", tokenHandler.CreateHandler)
+				r.HandleFunc("POST /admin/tokens/{id}/revoke", tokenHandler.RevokeHandler)
+			}
+		})
+	}
+
+	// the api for machine clients using api tokens
+	if s.tokens != nil {
+		r.Group(func(r *router.Router) {
+			r.Use(middleware.BearerToken(middleware.BearerTokenConfig{
+				Authenticate: s.tokens.Authenticate,
+				Logger:       s.logger,
+			}))
+
+			r.Group(func(r *router.Router) {
+				r.Use(middleware.RequireScope(middleware.RequireScopeConfig{
+					Scopes: []string{auth.ScopeDummyRead},
+				}))
+				r.HandleFunc("GET /api/dummy", dummyHandler.ListHandler)
+			})
+			r.Group(func(r *router.Router) {
+				r.Use(middleware.RequireScope(middleware.RequireScopeConfig{
+					Scopes: []string{auth.ScopeDummyWrite},
+				}))
+				r.HandleFunc("POST /api/dummy", dummyHandler.CreateHandler)
+			})
+		})
+	}
+
+	// preview the captured mails in debug mode
+	if capture, ok := s.mailer.(*mail.Capture); ok && s.debug {
+		mailCaptureHandler := handlers.NewMailCaptureHandler(capture, s.debug)
+		r.HandleFunc("GET /debug/mails", mailCaptureHandler.ListHandler)
+		r.HandleFunc("GET /debug/mails/{id}", mailCaptureHandler.ShowHandler)
+		r.HandleFunc("GET /debug/mails/{id}/html", mailCaptureHandler.HTMLHandler)
+		r.HandleFunc("GET /debug/mails/{id}/text", mailCaptureHandler.TextHandler)
+		r.HandleFunc("GET /debug/mails/{id}/raw", mailCaptureHandler.RawHandler)
+	}
+
 	// custom 404 for the rest
 	r.HandleFunc("/", notFound)
 
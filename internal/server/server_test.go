@@ -0,0 +1,65 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/firefart/go-webserver-template/internal/config"
+	"github.com/firefart/go-webserver-template/internal/metrics"
+	"github.com/firefart/go-webserver-template/internal/session"
+	"github.com/firefart/go-webserver-template/internal/testutil"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCrossOriginProtection(t *testing.T) {
+	t.Parallel()
+
+	m, err := metrics.NewMetrics(prometheus.NewRegistry())
+	require.NoError(t, err)
+	configuration := config.Configuration{
+		Server: config.Server{
+			SecretKeyHeaderName:  "X-Secret",
+			SecretKeyHeaderValue: "secret",
+		},
+		Session: config.Session{
+			CookieName:      "session",
+			SigningKeys:     []string{"0123456789abcdef0123456789abcdef"},
+			IdleTimeout:     30 * time.Minute,
+			AbsoluteTimeout: 2 * time.Hour,
+		},
+	}
+	sessions, err := session.NewManager(slog.New(slog.DiscardHandler), session.NewMemoryStore(t.Context(), slog.New(slog.DiscardHandler)), configuration.Session)
+	require.NoError(t, err)
+	s, err := NewServer(
+		WithConfig(configuration),
+		WithDB(testutil.NewDB(t)),
+		WithMetrics(m),
+		WithSessions(sessions),
+	)
+	require.NoError(t, err)
+
+	do := func(method, fetchSite string) int {
+		form := url.Values{"name": {"dummy"}}
+		req := httptest.NewRequest(method, "/dummy", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("X-Secret", "secret")
+		req.Header.Set("Sec-Fetch-Site", fetchSite)
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	require.Equal(t, http.StatusForbidden, do(http.MethodPost, "cross-site"))
+	// same site subdomains are another origin
+	require.Equal(t, http.StatusForbidden, do(http.MethodPost, "same-site"))
+	require.Equal(t, http.StatusCreated, do(http.MethodPost, "same-origin"))
+
+	// safe methods are allowed from other origins
+	require.Equal(t, http.StatusOK, do(http.MethodGet, "cross-site"))
+}
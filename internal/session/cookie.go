@@ -0,0 +1,108 @@
+package session
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+var errInvalidCookie = errors.New("invalid session cookie")
+
+// codec signs and optionally encrypts the cookie values. The first key is
+// used for new values, all keys are accepted when decoding.
+type codec struct {
+	name        string
+	signingKeys [][]byte
+	aeads       []cipher.AEAD
+}
+
+func newCodec(name string, signingKeys, encryptionKeys []string) (*codec, error) {
+	if len(signingKeys) == 0 {
+		return nil, errors.New("need at least one session signing key")
+	}
+	c := codec{
+		name: name,
+	}
+	for _, key := range signingKeys {
+		c.signingKeys = append(c.signingKeys, []byte(key))
+	}
+	for _, key := range encryptionKeys {
+		// derive a key of the size needed for AES-256
+		derived := sha256.Sum256([]byte(key))
+		block, err := aes.NewCipher(derived[:])
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		c.aeads = append(c.aeads, aead)
+	}
+	return &c, nil
+}
+
+// encode returns the cookie value for the value in the form payload.signature
+func (c *codec) encode(value string) string {
+	payload := []byte(value)
+	if len(c.aeads) > 0 {
+		aead := c.aeads[0]
+		nonce := make([]byte, aead.NonceSize())
+		_, _ = rand.Read(nonce)
+		// the cookie name is authenticated so values can't be moved between cookies
+		payload = aead.Seal(nonce, nonce, payload, []byte(c.name))
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(c.sign(c.signingKeys[0], encoded))
+}
+
+func (c *codec) decode(cookie string) (string, error) {
+	encoded, signature, ok := strings.Cut(cookie, ".")
+	if !ok {
+		return "", errInvalidCookie
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return "", errInvalidCookie
+	}
+	valid := false
+	for _, key := range c.signingKeys {
+		if hmac.Equal(mac, c.sign(key, encoded)) {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return "", errInvalidCookie
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", errInvalidCookie
+	}
+	if len(c.aeads) == 0 {
+		return string(payload), nil
+	}
+	for _, aead := range c.aeads {
+		if len(payload) < aead.NonceSize() {
+			continue
+		}
+		nonce, ciphertext := payload[:aead.NonceSize()], payload[aead.NonceSize():]
+		plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(c.name))
+		if err == nil {
+			return string(plaintext), nil
+		}
+	}
+	return "", errInvalidCookie
+}
+
+func (c *codec) sign(key []byte, value string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(c.name + "|" + value))
+	return h.Sum(nil)
+}
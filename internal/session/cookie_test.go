@@ -0,0 +1,92 @@
+package session
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testKey1 = "0123456789abcdef0123456789abcdef"
+	testKey2 = "fedcba9876543210fedcba9876543210"
+)
+
+func TestCodec(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		encryptionKeys []string
+	}{
+		{name: "signed"},
+		{name: "encrypted", encryptionKeys: []string{testKey1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			c, err := newCodec("session", []string{testKey1}, tt.encryptionKeys)
+			require.NoError(t, err)
+
+			value := c.encode("id")
+			decoded, err := c.decode(value)
+			require.NoError(t, err)
+			require.Equal(t, "id", decoded)
+
+			// a modified payload or signature is rejected
+			payload, signature, _ := strings.Cut(value, ".")
+			_, err = c.decode("x" + payload + "." + signature)
+			require.ErrorIs(t, err, errInvalidCookie)
+			_, err = c.decode(payload + ".x" + signature)
+			require.ErrorIs(t, err, errInvalidCookie)
+			_, err = c.decode(payload)
+			require.ErrorIs(t, err, errInvalidCookie)
+
+			// the cookie name is part of the signature
+			other, err := newCodec("other", []string{testKey1}, tt.encryptionKeys)
+			require.NoError(t, err)
+			_, err = other.decode(value)
+			require.ErrorIs(t, err, errInvalidCookie)
+		})
+	}
+}
+
+func TestCodecEncryption(t *testing.T) {
+	t.Parallel()
+
+	c, err := newCodec("session", []string{testKey1}, []string{testKey1})
+	require.NoError(t, err)
+	value := c.encode("secret-id")
+	payload, _, _ := strings.Cut(value, ".")
+	require.NotContains(t, payload, "secret")
+	// the random nonce makes every value unique
+	require.NotEqual(t, value, c.encode("secret-id"))
+}
+
+func TestCodecKeyRotation(t *testing.T) {
+	t.Parallel()
+
+	old, err := newCodec("session", []string{testKey1}, []string{testKey1})
+	require.NoError(t, err)
+	value := old.encode("id")
+
+	// the old keys are still accepted after adding new ones
+	rotated, err := newCodec("session", []string{testKey2, testKey1}, []string{testKey2, testKey1})
+	require.NoError(t, err)
+	decoded, err := rotated.decode(value)
+	require.NoError(t, err)
+	require.Equal(t, "id", decoded)
+
+	// new values are created with the first key
+	_, err = old.decode(rotated.encode("id"))
+	require.ErrorIs(t, err, errInvalidCookie)
+
+	// and rejected once the old key is removed
+	removed, err := newCodec("session", []string{testKey2}, []string{testKey2})
+	require.NoError(t, err)
+	_, err = removed.decode(value)
+	require.ErrorIs(t, err, errInvalidCookie)
+
+	_, err = newCodec("session", nil, nil)
+	require.Error(t, err)
+}
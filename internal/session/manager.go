@@ -0,0 +1,216 @@
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/firefart/go-webserver-template/internal/config"
+)
+
+// touchInterval is the maximum time between two updates of the last seen
+// time of unchanged sessions. It limits the writes to the store but also the
+// precision of the idle timeout.
+const touchInterval = 1 * time.Minute
+
+// Manager loads the session of each request from the store and saves it
+// before the response is written
+type Manager struct {
+	logger *slog.Logger
+	store  Store
+	codec  *codec
+	config config.Session
+	now    func() time.Time
+}
+
+func NewManager(logger *slog.Logger, store Store, configuration config.Session) (*Manager, error) {
+	codec, err := newCodec(configuration.CookieName, configuration.SigningKeys, configuration.EncryptionKeys)
+	if err != nil {
+		return nil, err
+	}
+	return &Manager{
+		logger: logger,
+		store:  store,
+		codec:  codec,
+		config: configuration,
+		now:    time.Now,
+	}, nil
+}
+
+// Handler is the middleware adding the session to the request context. New
+// sessions are only stored and sent to the client once a value is set.
+func (m *Manager) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s := m.load(r)
+		sw := &sessionWriter{
+			ResponseWriter: w,
+			commit: func() {
+				m.commit(r.Context(), w, s)
+			},
+		}
+		next.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), contextKey{}, s)))
+		// nothing was written, the headers can still be set
+		sw.once.Do(sw.commit)
+	})
+}
+
+// load returns the session of the request or a new one if the cookie is
+// missing, invalid or the session expired
+func (m *Manager) load(r *http.Request) *state {
+	now := m.now()
+	s := &state{
+		created:  now,
+		lastSeen: now,
+		values:   make(map[string]json.RawMessage),
+	}
+
+	cookie, err := r.Cookie(m.config.CookieName)
+	if err != nil {
+		return s
+	}
+	id, err := m.codec.decode(cookie.Value)
+	if err != nil {
+		m.logger.Debug("invalid session cookie", slog.String("err", err.Error()))
+		return s
+	}
+	data, found, err := m.store.Get(r.Context(), id)
+	if err != nil {
+		m.logger.Error("could not load session", slog.String("err", err.Error()))
+		return s
+	}
+	if !found {
+		return s
+	}
+	var rec record
+	if err := json.Unmarshal(data, &rec); err != nil {
+		m.logger.Error("could not decode session", slog.String("err", err.Error()))
+		return s
+	}
+	if now.Sub(rec.LastSeen) >= m.config.IdleTimeout || now.Sub(rec.Created) >= m.config.AbsoluteTimeout {
+		if err := m.store.Delete(r.Context(), id); err != nil {
+			m.logger.Error("could not delete expired session", slog.String("err", err.Error()))
+		}
+		return s
+	}
+
+	s.id = id
+	s.created = rec.Created
+	s.lastSeen = rec.LastSeen
+	if rec.Values != nil {
+		s.values = rec.Values
+	}
+	return s
+}
+
+// commit saves the session and sets the cookie if needed. It is called before
+// the first write of the response.
+func (m *Manager) commit(ctx context.Context, w http.ResponseWriter, s *state) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ctx = context.WithoutCancel(ctx)
+	now := m.now()
+
+	if s.destroy {
+		if s.id != "" {
+			if err := m.store.Delete(ctx, s.id); err != nil {
+				m.logger.Error("could not delete session", slog.String("err", err.Error()))
+			}
+			m.setCookie(w, "", -1)
+		}
+		return
+	}
+
+	oldID := s.id
+	if s.renew && oldID != "" {
+		if err := m.store.Delete(ctx, oldID); err != nil {
+			m.logger.Error("could not delete renewed session", slog.String("err", err.Error()))
+		}
+		s.id = ""
+	}
+
+	if s.id == "" {
+		// don't store empty sessions
+		if len(s.values) == 0 {
+			if oldID != "" {
+				m.setCookie(w, "", -1)
+			}
+			return
+		}
+		if oldID == "" {
+			s.created = now
+		}
+		s.id = rand.Text()
+	} else if !s.changed && now.Sub(s.lastSeen) < min(touchInterval, m.config.IdleTimeout/2) {
+		return
+	}
+
+	s.lastSeen = now
+	data, err := json.Marshal(record{
+		Created:  s.created,
+		LastSeen: s.lastSeen,
+		Values:   s.values,
+	})
+	if err != nil {
+		m.logger.Error("could not encode session", slog.String("err", err.Error()))
+		return
+	}
+	absolute := s.created.Add(m.config.AbsoluteTimeout)
+	expires := s.lastSeen.Add(m.config.IdleTimeout)
+	if absolute.Before(expires) {
+		expires = absolute
+	}
+	if err := m.store.Set(ctx, s.id, data, expires); err != nil {
+		m.logger.Error("could not save session", slog.String("err", err.Error()))
+		return
+	}
+
+	if s.id != oldID {
+		m.setCookie(w, m.codec.encode(s.id), int(absolute.Sub(now).Seconds()))
+	}
+}
+
+func (m *Manager) setCookie(w http.ResponseWriter, value string, maxAge int) {
+	sameSite := http.SameSiteLaxMode
+	switch m.config.SameSite {
+	case "strict":
+		sameSite = http.SameSiteStrictMode
+	case "none":
+		sameSite = http.SameSiteNoneMode
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     m.config.CookieName,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   maxAge,
+		Secure:   m.config.Secure,
+		HttpOnly: true,
+		SameSite: sameSite,
+	})
+}
+
+// sessionWriter commits the session before the headers are written
+type sessionWriter struct {
+	http.ResponseWriter
+	once   sync.Once
+	commit func()
+}
+
+func (w *sessionWriter) WriteHeader(code int) {
+	w.once.Do(w.commit)
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *sessionWriter) Write(b []byte) (int, error) {
+	w.once.Do(w.commit)
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap allows http.ResponseController to reach the underlying writer
+func (w *sessionWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
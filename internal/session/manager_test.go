@@ -0,0 +1,228 @@
+package session
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/firefart/go-webserver-template/internal/config"
+	"github.com/firefart/go-webserver-template/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+type testUser struct {
+	ID    int64  `json:"id"`
+	Email string `json:"email"`
+}
+
+func newTestManager(t *testing.T, store Store) *Manager {
+	t.Helper()
+	m, err := NewManager(slog.New(slog.DiscardHandler), store, config.Session{
+		CookieName:      "session",
+		SigningKeys:     []string{testKey1},
+		EncryptionKeys:  []string{testKey2},
+		IdleTimeout:     30 * time.Minute,
+		AbsoluteTimeout: 2 * time.Hour,
+		Secure:          true,
+		SameSite:        "strict",
+	})
+	require.NoError(t, err)
+	return m
+}
+
+// client keeps the session cookie between requests
+type client struct {
+	t      *testing.T
+	cookie *http.Cookie
+}
+
+// do sends a request and returns the session cookie set by the response
+func (c *client) do(handler http.Handler) *http.Cookie {
+	c.t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if c.cookie != nil {
+		req.AddCookie(c.cookie)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(c.t, http.StatusOK, rec.Code)
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.MaxAge < 0 {
+			c.cookie = nil
+			return cookie
+		}
+		c.cookie = cookie
+		return cookie
+	}
+	return nil
+}
+
+func TestManager(t *testing.T) {
+	t.Parallel()
+
+	store := NewMemoryStore(t.Context(), slog.New(slog.DiscardHandler))
+	m := newTestManager(t, store)
+	c := &client{t: t}
+
+	// an unused session is not stored
+	cookie := c.do(m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, ok := Get[string](r.Context(), "key")
+		require.False(t, ok)
+		w.WriteHeader(http.StatusOK)
+	})))
+	require.Nil(t, cookie)
+
+	cookie = c.do(m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, Set(r.Context(), "user", testUser{ID: 1, Email: "user@example.com"}))
+		require.NoError(t, Set(r.Context(), "count", 1))
+		_, _ = w.Write([]byte("ok"))
+	})))
+	require.NotNil(t, cookie)
+	require.Equal(t, "session", cookie.Name)
+	require.True(t, cookie.HttpOnly)
+	require.True(t, cookie.Secure)
+	require.Equal(t, http.SameSiteStrictMode, cookie.SameSite)
+	require.Equal(t, int((2 * time.Hour).Seconds()), cookie.MaxAge)
+
+	// the values are read back with their type
+	cookie = c.do(m.Handler(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		user, ok := Get[testUser](r.Context(), "user")
+		require.True(t, ok)
+		require.Equal(t, testUser{ID: 1, Email: "user@example.com"}, user)
+		count, ok := Get[int](r.Context(), "count")
+		require.True(t, ok)
+		require.Equal(t, 1, count)
+		_, ok = Get[int](r.Context(), "user")
+		require.False(t, ok)
+		Delete(r.Context(), "count")
+	})))
+	// the id did not change so no new cookie is sent
+	require.Nil(t, cookie)
+
+	c.do(m.Handler(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		_, ok := Get[int](r.Context(), "count")
+		require.False(t, ok)
+		Destroy(r.Context())
+	})))
+	require.Nil(t, c.cookie)
+}
+
+func TestManagerRenew(t *testing.T) {
+	t.Parallel()
+
+	store := NewMemoryStore(t.Context(), slog.New(slog.DiscardHandler))
+	m := newTestManager(t, store)
+	c := &client{t: t}
+
+	c.do(m.Handler(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		require.NoError(t, Set(r.Context(), "role", "user"))
+	})))
+	oldCookie := c.cookie
+	oldID, err := m.codec.decode(oldCookie.Value)
+	require.NoError(t, err)
+
+	cookie := c.do(m.Handler(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		Renew(r.Context())
+		require.NoError(t, Set(r.Context(), "role", "admin"))
+	})))
+	require.NotNil(t, cookie)
+	newID, err := m.codec.decode(cookie.Value)
+	require.NoError(t, err)
+	require.NotEqual(t, oldID, newID)
+
+	// the old id is gone, the values moved to the new one
+	_, found, err := store.Get(t.Context(), oldID)
+	require.NoError(t, err)
+	require.False(t, found)
+	c.do(m.Handler(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		role, ok := Get[string](r.Context(), "role")
+		require.True(t, ok)
+		require.Equal(t, "admin", role)
+	})))
+
+	c.cookie = oldCookie
+	c.do(m.Handler(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		_, ok := Get[string](r.Context(), "role")
+		require.False(t, ok)
+	})))
+}
+
+func TestManagerTimeouts(t *testing.T) {
+	t.Parallel()
+
+	store := NewMemoryStore(t.Context(), slog.New(slog.DiscardHandler))
+	m := newTestManager(t, store)
+	now := time.Now()
+	m.now = func() time.Time { return now }
+	c := &client{t: t}
+
+	set := m.Handler(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		require.NoError(t, Set(r.Context(), "key", "value"))
+	}))
+	found := func() bool {
+		var ok bool
+		c.do(m.Handler(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+			_, ok = Get[string](r.Context(), "key")
+		})))
+		return ok
+	}
+
+	c.do(set)
+	// every request within the idle timeout extends the session
+	for range 5 {
+		now = now.Add(20 * time.Minute)
+		require.True(t, found())
+	}
+	require.True(t, found())
+
+	// but not beyond the absolute timeout
+	now = now.Add(20 * time.Minute)
+	require.False(t, found())
+
+	c.cookie = nil
+	c.do(set)
+	now = now.Add(31 * time.Minute)
+	require.False(t, found())
+}
+
+func TestManagerInvalidCookie(t *testing.T) {
+	t.Parallel()
+
+	store := NewMemoryStore(t.Context(), slog.New(slog.DiscardHandler))
+	m := newTestManager(t, store)
+	c := &client{t: t, cookie: &http.Cookie{Name: "session", Value: "invalid.value"}}
+	c.do(m.Handler(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		_, ok := Get[string](r.Context(), "key")
+		require.False(t, ok)
+	})))
+}
+
+func TestNoSession(t *testing.T) {
+	t.Parallel()
+
+	require.ErrorIs(t, Set(t.Context(), "key", "value"), ErrNoSession)
+	_, ok := Get[string](t.Context(), "key")
+	require.False(t, ok)
+	Delete(t.Context(), "key")
+	Renew(t.Context())
+	Destroy(t.Context())
+}
+
+func TestSQLiteStore(t *testing.T) {
+	t.Parallel()
+
+	db := testutil.NewDB(t)
+
+	m := newTestManager(t, NewSQLiteStore(t.Context(), slog.New(slog.DiscardHandler), db))
+	c := &client{t: t}
+	c.do(m.Handler(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		require.NoError(t, Set(r.Context(), "key", "value"))
+	})))
+	c.do(m.Handler(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		value, ok := Get[string](r.Context(), "key")
+		require.True(t, ok)
+		require.Equal(t, "value", value)
+	})))
+}
@@ -0,0 +1,87 @@
+package session
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+type memoryEntry struct {
+	data    []byte
+	expires time.Time
+}
+
+// MemoryStore keeps the sessions in process memory. Sessions are lost on
+// restart so it is mainly useful for tests.
+type MemoryStore struct {
+	logger   *slog.Logger
+	mu       sync.RWMutex
+	sessions map[string]memoryEntry
+}
+
+// compile time check that struct implements the interface
+var _ Store = (*MemoryStore)(nil)
+
+func NewMemoryStore(ctx context.Context, logger *slog.Logger) *MemoryStore {
+	s := MemoryStore{
+		logger:   logger,
+		sessions: make(map[string]memoryEntry),
+	}
+
+	// start invalidator go function
+	go s.invalidator(ctx)
+
+	return &s
+}
+
+func (s *MemoryStore) invalidator(ctx context.Context) {
+	ticker := time.NewTicker(1 * time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.deleteExpired()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *MemoryStore) deleteExpired() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for id, e := range s.sessions {
+		if !e.expires.After(now) {
+			delete(s.sessions, id)
+		}
+	}
+}
+
+func (s *MemoryStore) Get(_ context.Context, id string) ([]byte, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	e, ok := s.sessions[id]
+	if !ok || !e.expires.After(time.Now()) {
+		return nil, false, nil
+	}
+	return e.data, true, nil
+}
+
+func (s *MemoryStore) Set(_ context.Context, id string, data []byte, expires time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[id] = memoryEntry{
+		data:    data,
+		expires: expires,
+	}
+	return nil
+}
+
+func (s *MemoryStore) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, id)
+	return nil
+}
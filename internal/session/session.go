@@ -0,0 +1,119 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrNoSession is returned if the request context has no session because the
+// session middleware is not used
+var ErrNoSession = errors.New("no session in context")
+
+type contextKey struct{}
+
+// record is the stored representation of a session
+type record struct {
+	Created  time.Time                  `json:"created"`
+	LastSeen time.Time                  `json:"last_seen"`
+	Values   map[string]json.RawMessage `json:"values"`
+}
+
+// state is the session of a request. The values are kept encoded so they can
+// be read back into the type they were stored with.
+type state struct {
+	mu sync.Mutex
+	// id is empty until the session is stored for the first time
+	id       string
+	created  time.Time
+	lastSeen time.Time
+	values   map[string]json.RawMessage
+	changed  bool
+	renew    bool
+	destroy  bool
+}
+
+func fromContext(ctx context.Context) *state {
+	s, _ := ctx.Value(contextKey{}).(*state)
+	return s
+}
+
+// Get returns the value stored under the key in the session of the request
+// context. It returns false if the key does not exist or the value can't be
+// decoded into T.
+func Get[T any](ctx context.Context, key string) (T, bool) {
+	var value T
+	s := fromContext(ctx)
+	if s == nil {
+		return value, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	raw, ok := s.values[key]
+	if !ok {
+		return value, false
+	}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return value, false
+	}
+	return value, true
+}
+
+// Set stores the value under the key in the session of the request context.
+// The value must be JSON encodable.
+func Set(ctx context.Context, key string, value any) error {
+	s := fromContext(ctx)
+	if s == nil {
+		return ErrNoSession
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("could not encode session value %s: %w", key, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[key] = raw
+	s.changed = true
+	return nil
+}
+
+// Delete removes the key from the session of the request context
+func Delete(ctx context.Context, key string) {
+	s := fromContext(ctx)
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.values[key]; ok {
+		delete(s.values, key)
+		s.changed = true
+	}
+}
+
+// Renew issues a new session id and keeps the values. Call it whenever the
+// privileges change, like on login, to prevent session fixation.
+func Renew(ctx context.Context) {
+	s := fromContext(ctx)
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.renew = true
+}
+
+// Destroy deletes the session and its cookie, like on logout
+func Destroy(ctx context.Context) {
+	s := fromContext(ctx)
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.destroy = true
+	s.values = make(map[string]json.RawMessage)
+}
@@ -0,0 +1,63 @@
+package session
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/firefart/go-webserver-template/internal/database"
+)
+
+// SQLiteStore persists the sessions in the application database so they
+// survive restarts
+type SQLiteStore struct {
+	logger *slog.Logger
+	db     database.Interface
+}
+
+// compile time check that struct implements the interface
+var _ Store = (*SQLiteStore)(nil)
+
+func NewSQLiteStore(ctx context.Context, logger *slog.Logger, db database.Interface) *SQLiteStore {
+	s := SQLiteStore{
+		logger: logger,
+		db:     db,
+	}
+
+	// start invalidator go function
+	go s.invalidator(ctx)
+
+	return &s
+}
+
+func (s *SQLiteStore) invalidator(ctx context.Context) {
+	ticker := time.NewTicker(1 * time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			deleted, err := s.db.DeleteExpiredSessions(ctx)
+			if err != nil {
+				s.logger.Error("could not delete expired sessions", slog.String("err", err.Error()))
+				continue
+			}
+			if deleted > 0 {
+				s.logger.Debug("deleted expired sessions", slog.Int64("count", deleted))
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *SQLiteStore) Get(ctx context.Context, id string) ([]byte, bool, error) {
+	return s.db.GetSession(ctx, id)
+}
+
+func (s *SQLiteStore) Set(ctx context.Context, id string, data []byte, expires time.Time) error {
+	return s.db.SetSession(ctx, id, data, expires)
+}
+
+func (s *SQLiteStore) Delete(ctx context.Context, id string) error {
+	return s.db.DeleteSession(ctx, id)
+}
@@ -0,0 +1,32 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/firefart/go-webserver-template/internal/config"
+	"github.com/firefart/go-webserver-template/internal/database"
+)
+
+// Store persists the session data by session id. Implementations must be safe
+// for concurrent use and must not return expired sessions.
+type Store interface {
+	Get(ctx context.Context, id string) ([]byte, bool, error)
+	Set(ctx context.Context, id string, data []byte, expires time.Time) error
+	Delete(ctx context.Context, id string) error
+}
+
+// NewStore creates the store configured in the session section of the config.
+// The context controls the lifetime of background cleanup routines.
+func NewStore(ctx context.Context, configuration config.Configuration, logger *slog.Logger, db database.Interface) (Store, error) {
+	switch configuration.Session.Backend {
+	case "memory":
+		return NewMemoryStore(ctx, logger), nil
+	case "", "sqlite":
+		return NewSQLiteStore(ctx, logger, db), nil
+	default:
+		return nil, fmt.Errorf("invalid session backend %q", configuration.Session.Backend)
+	}
+}
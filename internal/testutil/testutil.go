@@ -0,0 +1,55 @@
+// Package testutil holds the test fixtures shared by several packages
+package testutil
+
+import (
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/firefart/go-webserver-template/internal/config"
+	"github.com/firefart/go-webserver-template/internal/database"
+	inthttp "github.com/firefart/go-webserver-template/internal/http"
+	"github.com/firefart/go-webserver-template/internal/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+// NewDB returns a migrated sqlite database in the temp dir of the test. It is
+// closed when the test ends.
+func NewDB(t testing.TB) *database.Database {
+	t.Helper()
+
+	file, err := os.CreateTemp(t.TempDir(), "*.sqlite")
+	require.NoError(t, err)
+	db, err := database.New(t.Context(), config.Configuration{
+		Database: config.Database{
+			Filename: file.Name(),
+		},
+	}, slog.New(slog.DiscardHandler), false)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, db.Close(1*time.Second))
+	})
+	return db
+}
+
+// NewHTTPClient returns a http client without retries
+func NewHTTPClient(t testing.TB) *inthttp.Client {
+	t.Helper()
+
+	m, err := metrics.NewMetrics(prometheus.NewRegistry())
+	require.NoError(t, err)
+	client, err := inthttp.NewHTTPClient(config.Configuration{
+		Timeout: 5 * time.Second,
+		HTTPClient: config.HTTPClient{
+			MaxResponseSize: 1 << 20,
+			Retry: config.HTTPClientRetry{
+				MaxAttempts: 1,
+			},
+		},
+	}, slog.New(slog.DiscardHandler), m, false)
+	require.NoError(t, err)
+	return client
+}
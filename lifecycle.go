@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/firefart/go-webserver-template/internal/config"
+	"github.com/firefart/go-webserver-template/internal/notification"
+)
+
+// errServerFailed is the shutdown reason if one of the servers stops serving
+var errServerFailed = errors.New("server failed")
+
+func versionString() string {
+	buildInfo, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	version := buildInfo.Main.Version
+	for _, setting := range buildInfo.Settings {
+		if setting.Key == "vcs.revision" {
+			return fmt.Sprintf("%s (%s)", version, setting.Value)
+		}
+	}
+	return version
+}
+
+func startupNotification(configuration config.Configuration, migrations int) notification.Notification {
+	lines := []string{
+		"Version: " + versionString(),
+		"Listen: " + configuration.Server.Listen,
+	}
+	if configuration.Server.ListenMetrics != "" {
+		lines = append(lines, "Metrics: "+configuration.Server.ListenMetrics)
+	}
+	if configuration.Server.ListenPprof != "" {
+		lines = append(lines, "Pprof: "+configuration.Server.ListenPprof)
+	}
+	lines = append(lines, fmt.Sprintf("Applied migrations: %d", migrations))
+
+	return notification.Notification{
+		Severity: notification.SeverityInfo,
+		Subject:  "instance started",
+		Message:  strings.Join(lines, "\n"),
+		Source:   "lifecycle",
+		Tags:     []string{"lifecycle:startup"},
+	}
+}
+
+func shutdownNotification(reason error, uptime time.Duration) notification.Notification {
+	severity := notification.SeverityInfo
+	if errors.Is(reason, errServerFailed) {
+		severity = notification.SeverityError
+	}
+	r := "unknown"
+	if reason != nil {
+		r = reason.Error()
+	}
+	return notification.Notification{
+		Severity: severity,
+		Subject:  "instance shutting down",
+		Message:  fmt.Sprintf("Reason: %s\nUptime: %s", r, uptime.Round(time.Second)),
+		Source:   "lifecycle",
+		Tags:     []string{"lifecycle:shutdown"},
+	}
+}
+
+func crashNotification(err error) notification.Notification {
+	return notification.Notification{
+		Severity: notification.SeverityCritical,
+		Subject:  "instance crashed",
+		Message:  err.Error(),
+		Source:   "lifecycle",
+		Tags:     []string{"lifecycle:crash"},
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/firefart/go-webserver-template/internal/config"
+	"github.com/firefart/go-webserver-template/internal/notification"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartupNotification(t *testing.T) {
+	t.Parallel()
+
+	var configuration config.Configuration
+	configuration.Server.Listen = "127.0.0.1:8000"
+	configuration.Server.ListenMetrics = "127.0.0.1:8001"
+	n := startupNotification(configuration, 3)
+	require.Equal(t, notification.SeverityInfo, n.Severity)
+	require.Equal(t, "lifecycle", n.Source)
+	require.Contains(t, n.Message, "Listen: 127.0.0.1:8000\nMetrics: 127.0.0.1:8001\n")
+	require.NotContains(t, n.Message, "Pprof")
+	require.Contains(t, n.Message, "Applied migrations: 3")
+}
+
+func TestShutdownNotification(t *testing.T) {
+	t.Parallel()
+
+	n := shutdownNotification(errors.New("interrupt signal received"), 90*time.Minute+1500*time.Millisecond)
+	require.Equal(t, notification.SeverityInfo, n.Severity)
+	require.Equal(t, "Reason: interrupt signal received\nUptime: 1h30m2s", n.Message)
+
+	// a failing server is an error
+	n = shutdownNotification(fmt.Errorf("%w: address in use", errServerFailed), time.Second)
+	require.Equal(t, notification.SeverityError, n.Severity)
+	require.Contains(t, n.Message, "Reason: server failed: address in use")
+
+	n = shutdownNotification(nil, time.Second)
+	require.Contains(t, n.Message, "Reason: unknown")
+}
+
+func TestCrashNotification(t *testing.T) {
+	t.Parallel()
+
+	n := crashNotification(errors.New("failed to create server"))
+	require.Equal(t, notification.SeverityCritical, n.Severity)
+	require.Equal(t, "failed to create server", n.Message)
+}
@@ -1,10 +1,16 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"io"
 	"log/slog"
 	"os"
 	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/firefart/go-webserver-template/internal/notification"
 
 	"github.com/charmbracelet/log"
 	"github.com/mattn/go-isatty"
@@ -64,5 +70,261 @@ func newLogger(debugMode, jsonOutput bool, logFile io.Writer) *slog.Logger {
 			ReportCaller: debugMode,
 		})
 	}
-	return slog.New(handler)
+	return slog.New(newNotifyHandler(handler))
+}
+
+// logQueueSize is the number of records waiting to be forwarded. Records are
+// dropped while the queue is full so logging never blocks on the notifier.
+const logQueueSize = 100
+
+type notifyingKey struct{}
+
+// forwardedRecord is a record waiting in the queue of a notifyTarget
+type forwardedRecord struct {
+	ctx          context.Context
+	notification notification.Notification
+	// next is the wrapped handler the errors of the forwarding are logged to
+	next slog.Handler
+}
+
+// notifyTarget is shared between a handler and all handlers derived from it.
+// The records are forwarded by a worker so the notifier, which writes to the
+// database, is not called on the logging path.
+type notifyTarget struct {
+	notifier notification.Notifier
+	level    slog.Level
+	queue    chan forwardedRecord
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+func newNotifyTarget(notifier notification.Notifier, level slog.Level) *notifyTarget {
+	return &notifyTarget{
+		notifier: notifier,
+		level:    level,
+		queue:    make(chan forwardedRecord, logQueueSize),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+func (t *notifyTarget) run() {
+	defer close(t.done)
+	for {
+		select {
+		case r := <-t.queue:
+			t.forward(r)
+		case <-t.stop:
+			// forward the records queued before stopping
+			for {
+				select {
+				case r := <-t.queue:
+					t.forward(r)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (t *notifyTarget) forward(r forwardedRecord) {
+	if err := t.notifier.Notify(r.ctx, r.notification); err != nil {
+		// report the error to the wrapped handler only, using the logger would
+		// forward the error again
+		logDirect(r.ctx, r.next, "could not forward log record to notifications", slog.String("err", err.Error()))
+	}
+}
+
+// logDirect logs an error to the wrapped handler, bypassing the forwarding
+func logDirect(ctx context.Context, next slog.Handler, msg string, attrs ...slog.Attr) {
+	if !next.Enabled(ctx, slog.LevelError) {
+		return
+	}
+	rec := slog.NewRecord(time.Now(), slog.LevelError, msg, 0)
+	rec.AddAttrs(attrs...)
+	_ = next.Handle(ctx, rec)
+}
+
+// notifyHandler passes all records to the wrapped handler and forwards the
+// records at or above the configured level to the notifications. Nothing is
+// forwarded until a notifier is set with setLogNotifier.
+type notifyHandler struct {
+	next   slog.Handler
+	target *atomic.Pointer[notifyTarget]
+	// attrs are the formatted attributes added with WithAttrs
+	attrs  []string
+	prefix string
+	skip   bool
+}
+
+var _ slog.Handler = (*notifyHandler)(nil)
+
+func newNotifyHandler(next slog.Handler) *notifyHandler {
+	return &notifyHandler{
+		next:   next,
+		target: new(atomic.Pointer[notifyTarget]),
+	}
+}
+
+// setLogNotifier forwards the records of the logger at or above level to
+// notifier. A nil notifier stops forwarding. The records queued for the
+// previous notifier are forwarded before it returns.
+func setLogNotifier(logger *slog.Logger, notifier notification.Notifier, level slog.Level) {
+	h, ok := logger.Handler().(*notifyHandler)
+	if !ok {
+		return
+	}
+	var t *notifyTarget
+	if notifier != nil {
+		t = newNotifyTarget(notifier, level)
+		go t.run()
+	}
+	if old := h.target.Swap(t); old != nil {
+		close(old.stop)
+		<-old.done
+	}
+}
+
+func (h *notifyHandler) forwards(ctx context.Context, level slog.Level) (*notifyTarget, bool) {
+	if h.skip {
+		return nil, false
+	}
+	// records logged while forwarding a record are not forwarded again
+	if ctx != nil && ctx.Value(notifyingKey{}) != nil {
+		return nil, false
+	}
+	t := h.target.Load()
+	if t == nil || level < t.level {
+		return nil, false
+	}
+	return t, true
+}
+
+func (h *notifyHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	if h.next.Enabled(ctx, level) {
+		return true
+	}
+	_, ok := h.forwards(ctx, level)
+	return ok
+}
+
+func (h *notifyHandler) Handle(ctx context.Context, r slog.Record) error {
+	r, skip := stripSkipNotify(r)
+
+	var err error
+	// the wrapped handler relies on Enabled to filter the records
+	if h.next.Enabled(ctx, r.Level) {
+		err = h.next.Handle(ctx, r)
+	}
+
+	if skip {
+		return err
+	}
+	t, ok := h.forwards(ctx, r.Level)
+	if !ok {
+		return err
+	}
+
+	lines := make([]string, 0, len(h.attrs)+r.NumAttrs()+1)
+	lines = append(lines, r.Message)
+	lines = append(lines, h.attrs...)
+	r.Attrs(func(a slog.Attr) bool {
+		lines = appendAttr(lines, h.prefix, a)
+		return true
+	})
+
+	n := notification.Notification{
+		Severity: severityFromLevel(r.Level),
+		Subject:  r.Message,
+		Message:  strings.Join(lines, "\n"),
+		Source:   "log",
+		Time:     r.Time,
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	nctx := context.WithValue(context.WithoutCancel(ctx), notifyingKey{}, true)
+	select {
+	case t.queue <- forwardedRecord{ctx: nctx, notification: n, next: h.next}:
+	default:
+		logDirect(ctx, h.next, "log notification queue is full, dropping record", slog.String("msg", r.Message))
+	}
+	return err
+}
+
+// stripSkipNotify removes the SkipNotify marker passed with the record itself
+// and reports if it was present
+func stripSkipNotify(r slog.Record) (slog.Record, bool) {
+	skip := false
+	r.Attrs(func(a slog.Attr) bool {
+		skip = a.Key == notification.SkipNotify.Key
+		return !skip
+	})
+	if !skip {
+		return r, false
+	}
+	stripped := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key != notification.SkipNotify.Key {
+			stripped.AddAttrs(a)
+		}
+		return true
+	})
+	return stripped, true
+}
+
+func (h *notifyHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.attrs = append([]string(nil), h.attrs...)
+	passed := make([]slog.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		if a.Key == notification.SkipNotify.Key {
+			clone.skip = true
+			continue
+		}
+		passed = append(passed, a)
+		clone.attrs = appendAttr(clone.attrs, h.prefix, a)
+	}
+	clone.next = h.next.WithAttrs(passed)
+	return &clone
+}
+
+func (h *notifyHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	clone.prefix = h.prefix + name + "."
+	clone.next = h.next.WithGroup(name)
+	return &clone
+}
+
+// appendAttr appends the attribute as key=value, groups are flattened
+func appendAttr(lines []string, prefix string, a slog.Attr) []string {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return lines
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix = prefix + a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			lines = appendAttr(lines, prefix, ga)
+		}
+		return lines
+	}
+	return append(lines, fmt.Sprintf("%s%s=%s", prefix, a.Key, a.Value))
+}
+
+func severityFromLevel(level slog.Level) notification.Severity {
+	switch {
+	case level >= slog.LevelError:
+		return notification.SeverityError
+	case level >= slog.LevelWarn:
+		return notification.SeverityWarning
+	default:
+		return notification.SeverityInfo
+	}
 }
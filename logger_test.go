@@ -2,11 +2,25 @@
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"io"
 	"log/slog"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/firefart/go-webserver-template/internal/config"
+	"github.com/firefart/go-webserver-template/internal/database"
+	"github.com/firefart/go-webserver-template/internal/metrics"
+	"github.com/firefart/go-webserver-template/internal/notification"
+	"github.com/firefart/go-webserver-template/internal/server"
+	"github.com/firefart/go-webserver-template/internal/testutil"
+	"github.com/nikoksr/notify"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/require"
 )
 
@@ -160,3 +174,192 @@ func TestNewLoggerHandlerSelection(t *testing.T) {
 		})
 	}
 }
+
+type recordingNotifier struct {
+	mu            sync.Mutex
+	notifications []notification.Notification
+	err           error
+	// logger is used while notifying to test the recursion guard
+	logger *slog.Logger
+	// block holds back Notify until it is closed
+	block chan struct{}
+}
+
+func (r *recordingNotifier) Notify(ctx context.Context, n notification.Notification) error {
+	if r.logger != nil {
+		r.logger.ErrorContext(ctx, "logged while notifying")
+	}
+	if r.block != nil {
+		<-r.block
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.notifications = append(r.notifications, n)
+	return r.err
+}
+
+func (r *recordingNotifier) recorded() []notification.Notification {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]notification.Notification(nil), r.notifications...)
+}
+
+func TestNotifyHandler(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	logger := slog.New(newNotifyHandler(slog.NewTextHandler(&buf, nil)))
+	notifier := &recordingNotifier{}
+
+	// nothing is forwarded without a notifier
+	logger.Error("before setup")
+	require.Empty(t, notifier.recorded())
+
+	setLogNotifier(logger, notifier, slog.LevelWarn)
+	logger.Info("below the level")
+	logger.With(slog.String("component", "db")).WithGroup("query").Warn("slow query", slog.Int("ms", 1200), slog.Group("args", slog.String("id", "1")))
+	logger.With(notification.SkipNotify).Error("skipped")
+	logger.Error("skipped inline", slog.String("err", "boom"), notification.SkipNotify)
+	// wait for the queued records
+	setLogNotifier(logger, nil, 0)
+
+	recorded := notifier.recorded()
+	require.Len(t, recorded, 1)
+	require.Equal(t, notification.SeverityWarning, recorded[0].Severity)
+	require.Equal(t, "slow query", recorded[0].Subject)
+	require.Equal(t, "slow query\ncomponent=db\nquery.ms=1200\nquery.args.id=1", recorded[0].Message)
+	require.Equal(t, "log", recorded[0].Source)
+
+	// all records are still logged, the marker is not
+	require.Contains(t, buf.String(), "below the level")
+	require.Contains(t, buf.String(), "skipped")
+	require.Contains(t, buf.String(), `msg="skipped inline" err=boom`)
+	require.NotContains(t, buf.String(), notification.SkipNotify.Key)
+
+	logger.Error("after removal")
+	require.Len(t, notifier.recorded(), 1)
+}
+
+func TestNotifyHandlerRecursion(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	logger := slog.New(newNotifyHandler(slog.NewTextHandler(&buf, nil)))
+	notifier := &recordingNotifier{
+		err:    errors.New("send failed"),
+		logger: logger,
+	}
+	setLogNotifier(logger, notifier, slog.LevelError)
+
+	logger.Error("first")
+	setLogNotifier(logger, nil, 0)
+	// the record logged by the notifier and the send error are not forwarded
+	require.Len(t, notifier.recorded(), 1)
+	require.Contains(t, buf.String(), "logged while notifying")
+	require.Contains(t, buf.String(), "could not forward log record to notifications")
+	require.Contains(t, buf.String(), "send failed")
+}
+
+func TestNotifyHandlerLevel(t *testing.T) {
+	t.Parallel()
+
+	// the wrapped handler only logs errors, warnings are forwarded anyway
+	var buf bytes.Buffer
+	logger := slog.New(newNotifyHandler(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelError})))
+	notifier := &recordingNotifier{}
+	setLogNotifier(logger, notifier, slog.LevelWarn)
+
+	logger.Warn("warning")
+	setLogNotifier(logger, nil, 0)
+	require.Len(t, notifier.recorded(), 1)
+	require.Empty(t, buf.String())
+}
+
+func TestNotifyHandlerQueueFull(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	logger := slog.New(newNotifyHandler(slog.NewTextHandler(&buf, nil)))
+	notifier := &recordingNotifier{block: make(chan struct{})}
+	setLogNotifier(logger, notifier, slog.LevelError)
+
+	// logging does not block on a slow notifier
+	for i := range logQueueSize + 10 {
+		logger.Error("error", slog.Int("i", i))
+	}
+	close(notifier.block)
+	setLogNotifier(logger, nil, 0)
+
+	recorded := notifier.recorded()
+	require.GreaterOrEqual(t, len(recorded), logQueueSize)
+	require.Less(t, len(recorded), logQueueSize+10)
+	require.Contains(t, buf.String(), "log notification queue is full")
+}
+
+// failingDummyDB fails to list the dummies to cause server errors
+type failingDummyDB struct {
+	database.Interface
+}
+
+func (failingDummyDB) GetAllDummy(context.Context) ([]int64, error) {
+	return nil, errors.New("database is down")
+}
+
+func TestServerErrorNotifications(t *testing.T) {
+	t.Parallel()
+
+	db := testutil.NewDB(t)
+	m, err := metrics.NewMetrics(prometheus.NewRegistry())
+	require.NoError(t, err)
+	outbox, err := notification.NewOutbox(slog.New(slog.DiscardHandler), db, m, config.Notification{}, map[string]notify.Notifier{
+		"test": &testNotifier{},
+	})
+	require.NoError(t, err)
+	throttler := notification.NewThrottler(slog.New(slog.DiscardHandler), m, outbox, config.NotificationThrottle{})
+
+	// all errors are forwarded by the logger like with logging.notify set to error
+	logger := slog.New(newNotifyHandler(slog.DiscardHandler))
+	setLogNotifier(logger, throttler, slog.LevelError)
+	s, err := server.NewServer(
+		server.WithLogger(logger),
+		server.WithDB(failingDummyDB{Interface: db}),
+		server.WithNotify(throttler),
+		server.WithMetrics(m),
+		server.WithConfig(config.Configuration{
+			Server: config.Server{
+				SecretKeyHeaderName:  "X-Secret",
+				SecretKeyHeaderValue: "secret",
+			},
+		}),
+	)
+	require.NoError(t, err)
+
+	queued := func() int {
+		// wait for the forwarded log records
+		setLogNotifier(logger, nil, 0)
+		setLogNotifier(logger, throttler, slog.LevelError)
+		due, err := db.GetDueNotifications(t.Context(), time.Now().Add(time.Hour), 100)
+		require.NoError(t, err)
+		return len(due)
+	}
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/does-not-exist", nil))
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	require.Equal(t, 0, queued())
+
+	// client errors are logged but do not notify
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/dummy", nil)
+	req.Header.Set("X-Secret", "secret")
+	s.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Equal(t, 0, queued())
+
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/dummy", nil)
+	req.Header.Set("X-Secret", "secret")
+	s.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusInternalServerError, rec.Code)
+	require.Equal(t, 1, queued())
+}
@@ -14,17 +14,23 @@
 	"os/signal"
 	"path/filepath"
 	"runtime/debug"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/firefart/go-webserver-template/internal/auth"
 	"github.com/firefart/go-webserver-template/internal/cacher"
 	"github.com/firefart/go-webserver-template/internal/config"
 	"github.com/firefart/go-webserver-template/internal/database"
 	"github.com/firefart/go-webserver-template/internal/http"
 	"github.com/firefart/go-webserver-template/internal/mail"
 	"github.com/firefart/go-webserver-template/internal/metrics"
+	"github.com/firefart/go-webserver-template/internal/notification"
+	"github.com/firefart/go-webserver-template/internal/oidc"
 	"github.com/firefart/go-webserver-template/internal/server"
+	"github.com/firefart/go-webserver-template/internal/session"
 	"github.com/hashicorp/go-multierror"
+	"github.com/mattn/go-isatty"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"gopkg.in/natefinch/lumberjack.v2"
@@ -40,12 +46,28 @@ func main() {
 	var jsonOutput bool
 	var version bool
 	var configCheckMode bool
+	var testNotifyMode bool
+	var testMailMode bool
+	var createUser string
+	var resetPassword string
+	var userRole string
+	var tokenCmd tokenCommand
 	var configFilename string
 	cli := cliOptions{}
 	flag.BoolVar(&cli.debugMode, "debug", false, "Enable DEBUG mode")
 	flag.StringVar(&configFilename, "config", "", "config file to use")
 	flag.BoolVar(&jsonOutput, "json", false, "output in json instead")
 	flag.BoolVar(&configCheckMode, "configcheck", false, "just check the config")
+	flag.BoolVar(&testNotifyMode, "test-notify", false, "send a test message to all enabled notification services")
+	flag.BoolVar(&testMailMode, "test-mail", false, "send a test mail to the configured recipients")
+	flag.StringVar(&createUser, "create-user", "", "create a user with the password read from stdin and exit")
+	flag.StringVar(&resetPassword, "reset-password", "", "reset the password of a user to the one read from stdin and exit")
+	flag.StringVar(&userRole, "role", auth.RoleUser, "role of the user created with -create-user (user or admin)")
+	flag.StringVar(&tokenCmd.create, "create-token", "", "create an api token with the name, print it and exit")
+	flag.StringVar(&tokenCmd.scopes, "token-scopes", "", fmt.Sprintf("comma separated scopes of the token created with -create-token (%s)", strings.Join(auth.Scopes, ", ")))
+	flag.StringVar(&tokenCmd.ttl, "token-ttl", "", "ttl of the token created with -create-token, 0 never expires (default from the config)")
+	flag.BoolVar(&tokenCmd.list, "list-tokens", false, "list the api tokens and exit")
+	flag.Int64Var(&tokenCmd.revoke, "revoke-token", 0, "revoke the api token with the id and exit")
 	flag.BoolVar(&version, "version", false, "show version")
 	flag.Parse()
 
@@ -73,6 +95,14 @@ func main() {
 		log.Fatalln("Error in config:", err.Error())
 	}
 
+	// the validation only checks that the dkim key file exists, load it to
+	// also catch invalid keys with -configcheck
+	if configuration.Mail.DKIM.Enabled {
+		if _, err := mail.NewDKIMSigner(configuration.Mail.DKIM); err != nil {
+			log.Fatalln("Error in config:", err.Error())
+		}
+	}
+
 	// if we are in config check mode, we just validate the config and exit
 	// if the config has errors, the statements above will already exit with an error
 	if configCheckMode {
@@ -119,6 +149,43 @@ func main() {
 	}
 
 	ctx := context.Background()
+
+	// send test messages to verify the config and exit
+	if testNotifyMode || testMailMode {
+		if err := runConfigTests(ctx, os.Stdout, logger, configuration, cli.debugMode, testNotifyMode, testMailMode); err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	// create or reset a user and exit
+	if createUser != "" || resetPassword != "" {
+		var in io.Reader
+		// generate a password instead of echoing it on the terminal
+		if !isatty.IsTerminal(os.Stdin.Fd()) && !isatty.IsCygwinTerminal(os.Stdin.Fd()) {
+			in = os.Stdin
+		}
+		username, reset := createUser, false
+		if resetPassword != "" {
+			username, reset = resetPassword, true
+		}
+		if err := manageUser(ctx, os.Stdout, in, logger, configuration, username, userRole, reset); err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	// manage the api tokens and exit
+	if tokenCmd.enabled() {
+		if err := manageTokens(ctx, os.Stdout, logger, configuration, tokenCmd); err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
 	err = run(ctx, logger, configuration, cli)
 	if err != nil {
 		logger.Error(err.Error())
@@ -126,9 +193,14 @@ func main() {
 	}
 }
 
-func run(ctx context.Context, logger *slog.Logger, configuration config.Configuration, cliOptions cliOptions) error {
+func run(ctx context.Context, logger *slog.Logger, configuration config.Configuration, cliOptions cliOptions) (retErr error) {
+	start := time.Now()
+
 	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM, syscall.SIGINT)
 	defer cancel()
+	// the cause is reported as the shutdown reason
+	ctx, cancelCause := context.WithCancelCause(ctx)
+	defer cancelCause(nil)
 
 	reg := prometheus.NewRegistry()
 	var metricOpts []metrics.OptionsMetricsFunc
@@ -141,7 +213,20 @@ func run(ctx context.Context, logger *slog.Logger, configuration config.Configur
 		return fmt.Errorf("failed to create metrics: %w", err)
 	}
 
-	notify, err := setupNotifications(configuration, logger)
+	httpClient, err := http.NewHTTPClient(configuration, logger, m, cliOptions.debugMode)
+	if err != nil {
+		return err
+	}
+	go func() {
+		if err := httpClient.WatchCertDir(ctx); err != nil {
+			logger.Error("error watching cert dir", slog.String("err", err.Error()))
+		}
+	}()
+
+	// the notification pipeline must not forward its own errors to the
+	// notifications
+	notifyLogger := logger.With(notification.SkipNotify)
+	notificationServices, err := setupNotifications(configuration, notifyLogger, httpClient.WithLogger(notifyLogger))
 	if err != nil {
 		return err
 	}
@@ -156,21 +241,66 @@ func run(ctx context.Context, logger *slog.Logger, configuration config.Configur
 		}
 	}()
 
-	cache := cacher.New[string](ctx, logger, m, "cache", configuration.Cache.Timeout)
+	outbox, err := notification.NewOutbox(notifyLogger, db, m, configuration.Notifications, notificationServices)
+	if err != nil {
+		return fmt.Errorf("failed to create notification outbox: %w", err)
+	}
+	go outbox.Run(ctx)
+	throttler := notification.NewThrottler(notifyLogger, m, outbox, configuration.Notifications.Throttle)
+	go throttler.Run(ctx)
+	if configuration.Logging.Notify.Enabled {
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(configuration.Logging.Notify.Level)); err != nil {
+			return fmt.Errorf("invalid log notify level: %w", err)
+		}
+		setLogNotifier(logger, throttler, level)
+		// the outbox is drained and the database is closed afterwards
+		defer setLogNotifier(logger, nil, 0)
+	}
+	defer func() {
+		if retErr == nil || !configuration.Notifications.Lifecycle.Crash {
+			return
+		}
+		// the throttler is not flushed on errors so queue the notification directly
+		if err := outbox.Notify(context.WithoutCancel(ctx), crashNotification(retErr)); err != nil {
+			logger.Error("error on crash notification", slog.String("err", err.Error()))
+			return
+		}
+		// stop the outbox worker and deliver the queued notifications
+		cancelCause(retErr)
+		drainCtx, drainCancel := context.WithTimeout(context.WithoutCancel(ctx), configuration.Server.GracefulTimeout)
+		defer drainCancel()
+		if err := outbox.Drain(drainCtx); err != nil {
+			logger.Error("error on notification outbox drain", slog.String("err", err.Error()))
+		}
+	}()
 
-	httpClient, err := http.NewHTTPClient(configuration, logger, cliOptions.debugMode)
+	cacheStore, err := cacher.NewStore(ctx, configuration, logger, db, throttler)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to create cache store: %w", err)
+	}
+	if closer, ok := cacheStore.(io.Closer); ok {
+		// release the connections of remote stores
+		defer func() {
+			if err := closer.Close(); err != nil {
+				logger.Error("error on cache store close", slog.String("err", err.Error()))
+			}
+		}()
+	}
+	cache, err := cacher.New[string](logger, m, cacheStore, "cache", configuration.Cache.Timeout)
+	if err != nil {
+		return fmt.Errorf("failed to create cache: %w", err)
 	}
 
 	options := []server.OptionsServerFunc{
 		server.WithLogger(logger),
 		server.WithConfig(configuration),
 		server.WithDB(db),
-		server.WithNotify(notify),
+		server.WithNotify(throttler),
 		server.WithDebug(cliOptions.debugMode),
 		server.WithMetrics(m),
 		server.WithCache(cache),
+		server.WithCacheStore(cacheStore),
 		server.WithHTTPClient(httpClient),
 	}
 
@@ -178,12 +308,59 @@ func run(ctx context.Context, logger *slog.Logger, configuration config.Configur
 		options = append(options, server.WithAccessLog())
 	}
 
+	if configuration.Session.Enabled {
+		sessionStore, err := session.NewStore(ctx, configuration, logger, db)
+		if err != nil {
+			return fmt.Errorf("failed to create session store: %w", err)
+		}
+		sessions, err := session.NewManager(logger, sessionStore, configuration.Session)
+		if err != nil {
+			return fmt.Errorf("failed to create session manager: %w", err)
+		}
+		options = append(options, server.WithSessions(sessions))
+	}
+
+	if configuration.Auth.Enabled {
+		if !configuration.Session.Enabled {
+			return errors.New("auth requires sessions to be enabled")
+		}
+		authenticator, err := auth.NewAuthenticator(ctx, logger, db, configuration.Auth)
+		if err != nil {
+			return fmt.Errorf("failed to create authenticator: %w", err)
+		}
+		options = append(options, server.WithAuthenticator(authenticator))
+	}
+	if configuration.Auth.Tokens.Enabled {
+		options = append(options, server.WithTokens(auth.NewTokens(logger, db)))
+	}
+	if configuration.Auth.OIDC.Enabled {
+		if !configuration.Session.Enabled {
+			return errors.New("oidc requires sessions to be enabled")
+		}
+		provider, err := oidc.New(ctx, logger, httpClient, configuration.Auth.OIDC)
+		if err != nil {
+			return fmt.Errorf("failed to create oidc provider: %w", err)
+		}
+		options = append(options, server.WithOIDC(provider))
+	}
+
+	var mailQueue *mail.Queue
 	if configuration.Mail.Enabled {
 		mailer, err := mail.New(configuration, logger)
 		if err != nil {
 			return err
 		}
-		options = append(options, server.WithMailer(mailer))
+		mailQueue = mail.NewQueue(logger, db, m, mailer, configuration.Mail.Queue, throttler)
+		go mailQueue.Run(ctx)
+		options = append(options, server.WithMailer(mailQueue))
+	} else if cliOptions.debugMode {
+		// capture the mails in memory so they can be previewed without a mail server
+		capture, err := mail.NewCapture(configuration)
+		if err != nil {
+			return err
+		}
+		logger.Info("mail is disabled, capturing mails on /debug/mails")
+		options = append(options, server.WithMailer(capture))
 	}
 
 	s, err := server.NewServer(options...)
@@ -209,7 +386,7 @@ func run(ctx context.Context, logger *slog.Logger, configuration config.Configur
 		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, nethttp.ErrServerClosed) {
 			logger.Error("error on listenandserve", slog.String("err", err.Error()))
 			// emit signal to kill server
-			cancel()
+			cancelCause(fmt.Errorf("%w: %w", errServerFailed, err))
 		}
 	}()
 
@@ -231,7 +408,7 @@ func run(ctx context.Context, logger *slog.Logger, configuration config.Configur
 			if err := srvMetrics.ListenAndServe(); err != nil && !errors.Is(err, nethttp.ErrServerClosed) {
 				logger.Error("error on metrics listenandserve", slog.String("err", err.Error()))
 				// emit signal to kill server
-				cancel()
+				cancelCause(fmt.Errorf("%w: metrics: %w", errServerFailed, err))
 			}
 		}()
 	}
@@ -260,14 +437,25 @@ func run(ctx context.Context, logger *slog.Logger, configuration config.Configur
 			if err := srvPprof.ListenAndServe(); err != nil && !errors.Is(err, nethttp.ErrServerClosed) {
 				logger.Error("error on pprof listenandserve", slog.String("err", err.Error()))
 				// emit signal to kill server
-				cancel()
+				cancelCause(fmt.Errorf("%w: pprof: %w", errServerFailed, err))
 			}
 		}()
 	}
 
+	if configuration.Notifications.Lifecycle.Startup {
+		if err := throttler.Notify(ctx, startupNotification(configuration, db.AppliedMigrations())); err != nil {
+			logger.Error("error on startup notification", slog.String("err", err.Error()))
+		}
+	}
+
 	// wait for a signal
 	<-ctx.Done()
-	logger.Info("received shutdown signal")
+	logger.Info("received shutdown signal", slog.Any("reason", context.Cause(ctx)))
+	if configuration.Notifications.Lifecycle.Shutdown {
+		if err := throttler.Notify(context.WithoutCancel(ctx), shutdownNotification(context.Cause(ctx), time.Since(start))); err != nil {
+			logger.Error("error on shutdown notification", slog.String("err", err.Error()))
+		}
+	}
 	// create a new context for shutdown
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), configuration.Server.GracefulTimeout)
 	defer cancel()
@@ -285,5 +473,21 @@ func run(ctx context.Context, logger *slog.Logger, configuration config.Configur
 			logger.Error("error on pprof srv shutdown", slog.String("err", err.Error()))
 		}
 	}
+	// report the suppressed notifications before draining the outbox
+	throttler.Flush(shutdownCtx)
+	// deliver the notifications queued until now, the rest is sent on the next start
+	if err := outbox.Drain(shutdownCtx); err != nil {
+		logger.Error("error on notification outbox drain", slog.String("err", err.Error()))
+	}
+	if mailQueue != nil {
+		// deliver the mails queued until now, the rest is sent on the next start
+		if err := mailQueue.Drain(shutdownCtx); err != nil {
+			logger.Error("error on mail queue drain", slog.String("err", err.Error()))
+		}
+	}
+	// a failed server is a crash and not a graceful shutdown
+	if cause := context.Cause(ctx); errors.Is(cause, errServerFailed) {
+		return cause
+	}
 	return nil
 }
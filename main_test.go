@@ -0,0 +1,72 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/firefart/go-webserver-template/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunServerFailed(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var bodies []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		mu.Lock()
+		bodies = append(bodies, string(body))
+		mu.Unlock()
+	}))
+	t.Cleanup(ts.Close)
+
+	// occupy the listen address so the server fails to start
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, l.Close()) })
+
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.json")
+	require.NoError(t, os.WriteFile(configFile, []byte(`{
+  "server": {
+    "listen": "`+l.Addr().String()+`",
+    "graceful_timeout": "5s",
+    "secret_key_header_value": "secret"
+  },
+  "database": {
+    "filename": "`+filepath.Join(dir, "db.sqlite3")+`"
+  },
+  "notifications": {
+    "lifecycle": {
+      "crash": true
+    },
+    "webhook": {
+      "enabled": true,
+      "url": "`+ts.URL+`"
+    }
+  }
+}`), 0o600))
+	configuration, err := config.GetConfig(configFile)
+	require.NoError(t, err)
+
+	err = run(t.Context(), slog.New(slog.DiscardHandler), configuration, cliOptions{})
+	require.ErrorIs(t, err, errServerFailed)
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, bodies, 1)
+	require.Contains(t, bodies[0], "instance crashed")
+	require.Contains(t, bodies[0], "address already in use")
+}
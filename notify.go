@@ -7,6 +7,8 @@
 	"strconv"
 
 	"github.com/firefart/go-webserver-template/internal/config"
+	"github.com/firefart/go-webserver-template/internal/http"
+	"github.com/firefart/go-webserver-template/internal/notification"
 
 	"github.com/nikoksr/notify"
 	"github.com/nikoksr/notify/service/discord"
@@ -16,9 +18,10 @@
 	"github.com/nikoksr/notify/service/telegram"
 )
 
-func setupNotifications(configuration config.Configuration, logger *slog.Logger) (*notify.Notify, error) {
-	not := notify.New()
-	var services []notify.Notifier
+// setupNotifications returns the enabled notification services keyed by a
+// stable name which is used to persist notifications in the outbox
+func setupNotifications(configuration config.Configuration, logger *slog.Logger, httpClient *http.Client) (map[string]notify.Notifier, error) {
+	services := make(map[string]notify.Notifier)
 
 	if configuration.Notifications.Telegram.Enabled {
 		if configuration.Notifications.Telegram.APIToken != "" {
@@ -28,7 +31,7 @@ func setupNotifications(configuration config.Configuration, logger *slog.Logger)
 				return nil, fmt.Errorf("telegram setup: %w", err)
 			}
 			telegramService.AddReceivers(configuration.Notifications.Telegram.ChatIDs...)
-			services = append(services, telegramService)
+			services["telegram"] = telegramService
 		}
 	}
 
@@ -50,7 +53,7 @@ func setupNotifications(configuration config.Configuration, logger *slog.Logger)
 			}
 
 			discordService.AddReceivers(configuration.Notifications.Discord.ChannelIDs...)
-			services = append(services, discordService)
+			services["discord"] = discordService
 		}
 	}
 
@@ -69,7 +72,7 @@ func setupNotifications(configuration config.Configuration, logger *slog.Logger)
 				)
 			}
 			mailService.AddReceivers(configuration.Notifications.Email.Recipients...)
-			services = append(services, mailService)
+			services["email"] = mailService
 		}
 	}
 
@@ -83,7 +86,7 @@ func setupNotifications(configuration config.Configuration, logger *slog.Logger)
 				mailgun.WithEurope(),
 			)
 			mailgunService.AddReceivers(configuration.Notifications.Mailgun.Recipients...)
-			services = append(services, mailgunService)
+			services["mailgun"] = mailgunService
 		}
 	}
 
@@ -93,10 +96,47 @@ func setupNotifications(configuration config.Configuration, logger *slog.Logger)
 			msteamsService := msteams.New()
 			msteamsService.WithWrapText(true)
 			msteamsService.AddReceivers(configuration.Notifications.MSTeams.Webhooks...)
-			services = append(services, msteamsService)
+			services["msteams"] = msteamsService
 		}
 	}
 
-	not.UseServices(services...)
-	return not, nil
+	if configuration.Notifications.Webhook.Enabled {
+		logger.Info("Notifications: using webhook")
+		webhookService, err := notification.NewWebhook(httpClient, configuration.Notifications.Webhook)
+		if err != nil {
+			return nil, fmt.Errorf("webhook setup: %w", err)
+		}
+		services["webhook"] = webhookService
+	}
+
+	if configuration.Notifications.Slack.Enabled {
+		logger.Info("Notifications: using slack")
+		services["slack"] = notification.NewSlack(httpClient, configuration.Notifications.Slack)
+	}
+
+	if configuration.Notifications.Ntfy.Enabled {
+		logger.Info("Notifications: using ntfy")
+		services["ntfy"] = notification.NewNtfy(httpClient, configuration.Notifications.Ntfy)
+	}
+
+	if configuration.Notifications.Gotify.Enabled {
+		logger.Info("Notifications: using gotify")
+		gotifyService, err := notification.NewGotify(httpClient, configuration.Notifications.Gotify)
+		if err != nil {
+			return nil, fmt.Errorf("gotify setup: %w", err)
+		}
+		services["gotify"] = gotifyService
+	}
+
+	if configuration.Notifications.Matrix.Enabled {
+		logger.Info("Notifications: using matrix")
+		services["matrix"] = notification.NewMatrix(httpClient, configuration.Notifications.Matrix)
+	}
+
+	if configuration.Notifications.Pushover.Enabled {
+		logger.Info("Notifications: using pushover")
+		services["pushover"] = notification.NewPushover(httpClient, configuration.Notifications.Pushover)
+	}
+
+	return services, nil
 }
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/firefart/go-webserver-template/internal/config"
+	"github.com/firefart/go-webserver-template/internal/http"
+	"github.com/firefart/go-webserver-template/internal/mail"
+	"github.com/firefart/go-webserver-template/internal/mail/templates"
+	"github.com/firefart/go-webserver-template/internal/metrics"
+
+	"github.com/nikoksr/notify"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	testSubject = "Test notification"
+	testMessage = "This is a test message from %s to verify the configuration."
+)
+
+var errTestFailed = errors.New("at least one test message could not be sent")
+
+// testResult is the outcome of sending a test message to a single service
+type testResult struct {
+	service  string
+	duration time.Duration
+	err      error
+}
+
+// runConfigTests sends test messages to the configured notification services
+// and the mail server and prints the results to w
+func runConfigTests(ctx context.Context, w io.Writer, logger *slog.Logger, configuration config.Configuration, debugMode, testNotify, testMail bool) error {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	message := fmt.Sprintf(testMessage, hostname)
+
+	var results []testResult
+	if testNotify {
+		m, err := metrics.NewMetrics(prometheus.NewRegistry())
+		if err != nil {
+			return fmt.Errorf("failed to create metrics: %w", err)
+		}
+		httpClient, err := http.NewHTTPClient(configuration, logger, m, debugMode)
+		if err != nil {
+			return err
+		}
+		services, err := setupNotifications(configuration, logger, httpClient)
+		if err != nil {
+			return err
+		}
+		if len(services) == 0 {
+			return errors.New("no notification services are enabled")
+		}
+		results = append(results, sendTestNotifications(ctx, services, configuration.Notifications.Outbox.SendTimeout, message)...)
+	}
+
+	if testMail {
+		if !configuration.Mail.Enabled {
+			return errors.New("mail is not enabled")
+		}
+		mailer, err := mail.New(configuration, logger)
+		if err != nil {
+			return err
+		}
+		results = append(results, sendTestMail(ctx, mailer, hostname))
+	}
+
+	if err := printTestResults(w, results); err != nil {
+		return err
+	}
+	for _, r := range results {
+		if r.err != nil {
+			return errTestFailed
+		}
+	}
+	return nil
+}
+
+// sendTestNotifications sends the message to every service on its own so a
+// failing service does not hide the result of the others
+func sendTestNotifications(ctx context.Context, services map[string]notify.Notifier, timeout time.Duration, message string) []testResult {
+	results := make([]testResult, 0, len(services))
+	for _, name := range slices.Sorted(maps.Keys(services)) {
+		sendCtx, cancel := context.WithTimeout(ctx, timeout)
+		start := time.Now()
+		err := services[name].Send(sendCtx, testSubject, message)
+		cancel()
+		results = append(results, testResult{
+			service:  name,
+			duration: time.Since(start),
+			err:      err,
+		})
+	}
+	return results
+}
+
+func sendTestMail(ctx context.Context, mailer mail.Interface, hostname string) testResult {
+	start := time.Now()
+	err := mail.SendEmail(ctx, mailer, templates.Test{Hostname: hostname})
+	return testResult{
+		service:  "mail",
+		duration: time.Since(start),
+		err:      err,
+	}
+}
+
+func printTestResults(w io.Writer, results []testResult) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SERVICE\tRESULT\tDURATION\tERROR")
+	for _, r := range results {
+		result := "ok"
+		errMsg := "-"
+		if r.err != nil {
+			result = "failed"
+			errMsg = r.err.Error()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", r.service, result, r.duration.Round(time.Millisecond), errMsg)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/firefart/go-webserver-template/internal/config"
+	"github.com/firefart/go-webserver-template/internal/mail"
+
+	"github.com/nikoksr/notify"
+	"github.com/stretchr/testify/require"
+)
+
+type testNotifier struct {
+	err      error
+	subjects []string
+}
+
+func (n *testNotifier) Send(ctx context.Context, subject, _ string) error {
+	if _, ok := ctx.Deadline(); !ok {
+		return errors.New("no deadline")
+	}
+	n.subjects = append(n.subjects, subject)
+	return n.err
+}
+
+func TestSendTestNotifications(t *testing.T) {
+	t.Parallel()
+
+	ok := &testNotifier{}
+	failing := &testNotifier{err: errors.New("invalid token")}
+	results := sendTestNotifications(t.Context(), map[string]notify.Notifier{
+		"telegram": failing,
+		"discord":  ok,
+	}, time.Second, "message")
+
+	// every service is tried and the results are sorted by name
+	require.Len(t, results, 2)
+	require.Equal(t, "discord", results[0].service)
+	require.NoError(t, results[0].err)
+	require.Equal(t, "telegram", results[1].service)
+	require.EqualError(t, results[1].err, "invalid token")
+	require.Equal(t, []string{testSubject}, ok.subjects)
+	require.Equal(t, []string{testSubject}, failing.subjects)
+}
+
+func TestPrintTestResults(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	require.NoError(t, printTestResults(&buf, []testResult{
+		{service: "discord", duration: 120 * time.Millisecond},
+		{service: "mail", duration: 2 * time.Second, err: errors.New("auth failed")},
+	}))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3)
+	require.Equal(t, []string{"SERVICE", "RESULT", "DURATION", "ERROR"}, strings.Fields(lines[0]))
+	require.Equal(t, []string{"discord", "ok", "120ms", "-"}, strings.Fields(lines[1]))
+	require.Equal(t, []string{"mail", "failed", "2s", "auth", "failed"}, strings.Fields(lines[2]))
+}
+
+func TestSendTestMail(t *testing.T) {
+	t.Parallel()
+
+	var configuration config.Configuration
+	configuration.Mail.To = []string{"admin@example.com"}
+	mailer, err := mail.NewCapture(configuration)
+	require.NoError(t, err)
+	result := sendTestMail(t.Context(), mailer, "web1")
+	require.Equal(t, "mail", result.service)
+	require.NoError(t, result.err)
+	messages := mailer.Messages()
+	require.Len(t, messages, 1)
+	require.Equal(t, "Test email", messages[0].Subject)
+	require.Contains(t, messages[0].TextBody, "This is a test email from web1 to verify the mail configuration.")
+	require.Contains(t, messages[0].HTMLBody, "<b>web1</b>")
+}
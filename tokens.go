@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/firefart/go-webserver-template/internal/auth"
+	"github.com/firefart/go-webserver-template/internal/config"
+	"github.com/firefart/go-webserver-template/internal/database"
+)
+
+// tokenCommand holds the cli options to manage the api tokens
+type tokenCommand struct {
+	create string
+	scopes string
+	// ttl is empty for the configured default
+	ttl    string
+	list   bool
+	revoke int64
+}
+
+func (c tokenCommand) enabled() bool {
+	return c.create != "" || c.list || c.revoke != 0
+}
+
+// manageTokens creates, lists or revokes the api tokens and prints the
+// result to w. A created token is printed once and can't be shown again.
+func manageTokens(ctx context.Context, w io.Writer, logger *slog.Logger, configuration config.Configuration, cmd tokenCommand) (retErr error) {
+	ttl := configuration.Auth.Tokens.DefaultTTL
+	if cmd.ttl != "" {
+		var err error
+		ttl, err = time.ParseDuration(cmd.ttl)
+		if err != nil {
+			return fmt.Errorf("invalid token ttl: %w", err)
+		}
+	}
+
+	db, err := database.New(ctx, configuration, logger, false)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := db.Close(configuration.Server.GracefulTimeout); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+	tokens := auth.NewTokens(logger, db)
+
+	switch {
+	case cmd.create != "":
+		var scopes []string
+		for scope := range strings.SplitSeq(cmd.scopes, ",") {
+			if scope = strings.TrimSpace(scope); scope != "" {
+				scopes = append(scopes, scope)
+			}
+		}
+		token, info, err := tokens.Create(ctx, cmd.create, scopes, ttl)
+		if err != nil {
+			return fmt.Errorf("could not create token: %w", err)
+		}
+		fmt.Fprintf(w, "created token %d for %s, it will not be shown again:\n%s\n", info.ID, info.Name, token)
+		return nil
+	case cmd.revoke != 0:
+		if err := tokens.Revoke(ctx, cmd.revoke); err != nil {
+			return fmt.Errorf("could not revoke token %d: %w", cmd.revoke, err)
+		}
+		fmt.Fprintf(w, "revoked token %d\n", cmd.revoke)
+		return nil
+	case cmd.list:
+		list, err := tokens.List(ctx)
+		if err != nil {
+			return err
+		}
+		return printTokens(w, list)
+	default:
+		return errors.New("no token command given")
+	}
+}
+
+func printTokens(w io.Writer, tokens []database.APIToken) error {
+	formatTime := func(t time.Time) string {
+		if t.IsZero() {
+			return "never"
+		}
+		return t.Format(time.RFC3339)
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tPREFIX\tSCOPES\tCREATED\tEXPIRES\tLAST USED")
+	for _, t := range tokens {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			t.ID, t.Name, t.Prefix, strings.Join(t.Scopes, ","),
+			t.Created.Format(time.RFC3339), formatTime(t.Expires), formatTime(t.LastUsed),
+		)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/firefart/go-webserver-template/internal/auth"
+	"github.com/firefart/go-webserver-template/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManageTokens(t *testing.T) {
+	t.Parallel()
+
+	file, err := os.CreateTemp(t.TempDir(), "*.sqlite")
+	require.NoError(t, err)
+	configuration := config.Configuration{
+		Server: config.Server{
+			GracefulTimeout: 1 * time.Second,
+		},
+		Database: config.Database{
+			Filename: file.Name(),
+		},
+	}
+	logger := slog.New(slog.DiscardHandler)
+
+	var out bytes.Buffer
+	require.NoError(t, manageTokens(t.Context(), &out, logger, configuration, tokenCommand{create: "ci", scopes: auth.ScopeDummyRead + ", " + auth.ScopeDummyWrite, ttl: "1h"}))
+	require.Regexp(t, regexp.MustCompile(`created token 1 for ci, it will not be shown again:\n`+auth.TokenPrefix+`\w+\n`), out.String())
+
+	require.Error(t, manageTokens(t.Context(), &out, logger, configuration, tokenCommand{create: "ci", scopes: "root"}))
+	require.Error(t, manageTokens(t.Context(), &out, logger, configuration, tokenCommand{create: "ci", ttl: "forever"}))
+
+	out.Reset()
+	require.NoError(t, manageTokens(t.Context(), &out, logger, configuration, tokenCommand{list: true}))
+	require.Contains(t, out.String(), "dummy:read,dummy:write")
+
+	out.Reset()
+	require.NoError(t, manageTokens(t.Context(), &out, logger, configuration, tokenCommand{revoke: 1}))
+	require.Equal(t, "revoked token 1\n", out.String())
+	require.ErrorIs(t, manageTokens(t.Context(), &out, logger, configuration, tokenCommand{revoke: 1}), auth.ErrTokenNotFound)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/firefart/go-webserver-template/internal/auth"
+	"github.com/firefart/go-webserver-template/internal/config"
+	"github.com/firefart/go-webserver-template/internal/database"
+)
+
+// manageUser creates the user or resets the password of an existing one. The
+// password is read from the first line of in. If in is nil or empty a random
+// password is generated and printed to w.
+func manageUser(ctx context.Context, w io.Writer, in io.Reader, logger *slog.Logger, configuration config.Configuration, username, role string, reset bool) (retErr error) {
+	password, err := readPassword(in)
+	if err != nil {
+		return err
+	}
+	generated := password == ""
+	if generated {
+		password = rand.Text()
+	}
+
+	db, err := database.New(ctx, configuration, logger, false)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := db.Close(configuration.Server.GracefulTimeout); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+
+	// stop the cleanup routine of the authenticator on return
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	authenticator, err := auth.NewAuthenticator(ctx, logger, db, configuration.Auth)
+	if err != nil {
+		return err
+	}
+
+	if reset {
+		if err := authenticator.SetPassword(ctx, username, password); err != nil {
+			return fmt.Errorf("could not reset password of user %s: %w", username, err)
+		}
+		fmt.Fprintf(w, "password of user %s was reset\n", username)
+	} else {
+		if _, err := authenticator.CreateUser(ctx, username, password, role); err != nil {
+			return fmt.Errorf("could not create user %s: %w", username, err)
+		}
+		fmt.Fprintf(w, "created user %s with role %s\n", username, role)
+	}
+	if generated {
+		fmt.Fprintf(w, "generated password: %s\n", password)
+	}
+	return nil
+}
+
+func readPassword(in io.Reader) (string, error) {
+	if in == nil {
+		return "", nil
+	}
+	scanner := bufio.NewScanner(in)
+	if !scanner.Scan() {
+		return "", scanner.Err()
+	}
+	return strings.TrimRight(scanner.Text(), "\r"), nil
+}
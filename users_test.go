@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/firefart/go-webserver-template/internal/auth"
+	"github.com/firefart/go-webserver-template/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManageUser(t *testing.T) {
+	t.Parallel()
+
+	file, err := os.CreateTemp(t.TempDir(), "*.sqlite")
+	require.NoError(t, err)
+	configuration := config.Configuration{
+		Server: config.Server{
+			GracefulTimeout: 1 * time.Second,
+		},
+		Database: config.Database{
+			Filename: file.Name(),
+		},
+		Auth: config.Auth{
+			Password: config.AuthPassword{
+				Memory:      8 * 1024,
+				Iterations:  1,
+				Parallelism: 1,
+				MinLength:   8,
+			},
+		},
+	}
+	logger := slog.New(slog.DiscardHandler)
+
+	var out bytes.Buffer
+	require.NoError(t, manageUser(t.Context(), &out, strings.NewReader("correct horse\n"), logger, configuration, "admin", auth.RoleAdmin, false))
+	require.Equal(t, "created user admin with role admin\n", out.String())
+
+	// the user exists already
+	require.Error(t, manageUser(t.Context(), &out, strings.NewReader("correct horse\n"), logger, configuration, "admin", auth.RoleAdmin, false))
+
+	out.Reset()
+	require.NoError(t, manageUser(t.Context(), &out, nil, logger, configuration, "admin", "", true))
+	require.Contains(t, out.String(), "password of user admin was reset\ngenerated password: ")
+
+	require.Error(t, manageUser(t.Context(), &out, strings.NewReader("correct horse\n"), logger, configuration, "unknown", "", true))
+}